@@ -0,0 +1,37 @@
+package record
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrOffsetOutOfRange is returned when reading an offset that is not in the log.
+// It carries the OutOfRange gRPC status so clients can tell it apart from real failures.
+type ErrOffsetOutOfRange struct {
+	Offset uint64
+}
+
+func (e ErrOffsetOutOfRange) GRPCStatus() *status.Status {
+	return status.New(codes.OutOfRange, e.Error())
+}
+
+func (e ErrOffsetOutOfRange) Error() string {
+	return fmt.Sprintf("offset is out of range when reading segments: %d", e.Offset)
+}
+
+// ErrOffsetConflict is returned when a record was required to land at one offset but the log is at another.
+// It carries the Aborted gRPC status, the caller lost a race and may retry against the new offset.
+type ErrOffsetConflict struct {
+	Expected uint64
+	Actual   uint64
+}
+
+func (e ErrOffsetConflict) GRPCStatus() *status.Status {
+	return status.New(codes.Aborted, e.Error())
+}
+
+func (e ErrOffsetConflict) Error() string {
+	return fmt.Sprintf("offset conflict: expected %d, got %d", e.Expected, e.Actual)
+}
@@ -23,6 +23,7 @@ const (
 	Log_Consume_FullMethodName       = "/record.Log/Consume"
 	Log_ProduceStream_FullMethodName = "/record.Log/ProduceStream"
 	Log_ConsumeStream_FullMethodName = "/record.Log/ConsumeStream"
+	Log_BulkConsume_FullMethodName   = "/record.Log/BulkConsume"
 )
 
 // LogClient is the client API for Log service.
@@ -39,11 +40,15 @@ type LogClient interface {
 	// Clients send a stream of ProduceRequest messages and receive a stream of ProduceResponse messages,
 	// allowing for efficient, bidirectional communication.
 	ProduceStream(ctx context.Context, opts ...grpc.CallOption) (Log_ProduceStreamClient, error)
-	// ConsumeStream streams log entries to a client, starting from a specified offset.
+	// ConsumeStream streams log entries to a client, starting from the offset in its first ConsumeRequest.
 	// Clients call this method to subscribe to log entries being appended to the log.
+	// Sending another ConsumeRequest at any point seeks the stream to its offset.
 	// The stream continues sending log entries to the client until the stream is closed
 	// by the client or an error occurs.
-	ConsumeStream(ctx context.Context, in *ConsumeRequest, opts ...grpc.CallOption) (Log_ConsumeStreamClient, error)
+	ConsumeStream(ctx context.Context, opts ...grpc.CallOption) (Log_ConsumeStreamClient, error)
+	// BulkConsume sends every record from from_offset to to_offset inclusive, then ends the stream.
+	// Unlike ConsumeStream it never waits for new records, so it suits fetching an exact range.
+	BulkConsume(ctx context.Context, in *ConsumeRangeRequest, opts ...grpc.CallOption) (Log_BulkConsumeClient, error)
 }
 
 type logClient struct {
@@ -103,12 +108,43 @@ func (x *logProduceStreamClient) Recv() (*ProduceResponse, error) {
 	return m, nil
 }
 
-func (c *logClient) ConsumeStream(ctx context.Context, in *ConsumeRequest, opts ...grpc.CallOption) (Log_ConsumeStreamClient, error) {
+func (c *logClient) ConsumeStream(ctx context.Context, opts ...grpc.CallOption) (Log_ConsumeStreamClient, error) {
 	stream, err := c.cc.NewStream(ctx, &Log_ServiceDesc.Streams[1], Log_ConsumeStream_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
 	x := &logConsumeStreamClient{stream}
+	return x, nil
+}
+
+type Log_ConsumeStreamClient interface {
+	Send(*ConsumeRequest) error
+	Recv() (*ConsumeResponse, error)
+	grpc.ClientStream
+}
+
+type logConsumeStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *logConsumeStreamClient) Send(m *ConsumeRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *logConsumeStreamClient) Recv() (*ConsumeResponse, error) {
+	m := new(ConsumeResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *logClient) BulkConsume(ctx context.Context, in *ConsumeRangeRequest, opts ...grpc.CallOption) (Log_BulkConsumeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Log_ServiceDesc.Streams[2], Log_BulkConsume_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &logBulkConsumeClient{stream}
 	if err := x.ClientStream.SendMsg(in); err != nil {
 		return nil, err
 	}
@@ -118,16 +154,16 @@ func (c *logClient) ConsumeStream(ctx context.Context, in *ConsumeRequest, opts
 	return x, nil
 }
 
-type Log_ConsumeStreamClient interface {
+type Log_BulkConsumeClient interface {
 	Recv() (*ConsumeResponse, error)
 	grpc.ClientStream
 }
 
-type logConsumeStreamClient struct {
+type logBulkConsumeClient struct {
 	grpc.ClientStream
 }
 
-func (x *logConsumeStreamClient) Recv() (*ConsumeResponse, error) {
+func (x *logBulkConsumeClient) Recv() (*ConsumeResponse, error) {
 	m := new(ConsumeResponse)
 	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
@@ -149,11 +185,15 @@ type LogServer interface {
 	// Clients send a stream of ProduceRequest messages and receive a stream of ProduceResponse messages,
 	// allowing for efficient, bidirectional communication.
 	ProduceStream(Log_ProduceStreamServer) error
-	// ConsumeStream streams log entries to a client, starting from a specified offset.
+	// ConsumeStream streams log entries to a client, starting from the offset in its first ConsumeRequest.
 	// Clients call this method to subscribe to log entries being appended to the log.
+	// Sending another ConsumeRequest at any point seeks the stream to its offset.
 	// The stream continues sending log entries to the client until the stream is closed
 	// by the client or an error occurs.
-	ConsumeStream(*ConsumeRequest, Log_ConsumeStreamServer) error
+	ConsumeStream(Log_ConsumeStreamServer) error
+	// BulkConsume sends every record from from_offset to to_offset inclusive, then ends the stream.
+	// Unlike ConsumeStream it never waits for new records, so it suits fetching an exact range.
+	BulkConsume(*ConsumeRangeRequest, Log_BulkConsumeServer) error
 	mustEmbedUnimplementedLogServer()
 }
 
@@ -170,9 +210,12 @@ func (UnimplementedLogServer) Consume(context.Context, *ConsumeRequest) (*Consum
 func (UnimplementedLogServer) ProduceStream(Log_ProduceStreamServer) error {
 	return status.Errorf(codes.Unimplemented, "method ProduceStream not implemented")
 }
-func (UnimplementedLogServer) ConsumeStream(*ConsumeRequest, Log_ConsumeStreamServer) error {
+func (UnimplementedLogServer) ConsumeStream(Log_ConsumeStreamServer) error {
 	return status.Errorf(codes.Unimplemented, "method ConsumeStream not implemented")
 }
+func (UnimplementedLogServer) BulkConsume(*ConsumeRangeRequest, Log_BulkConsumeServer) error {
+	return status.Errorf(codes.Unimplemented, "method BulkConsume not implemented")
+}
 func (UnimplementedLogServer) mustEmbedUnimplementedLogServer() {}
 
 // UnsafeLogServer may be embedded to opt out of forward compatibility for this service.
@@ -249,23 +292,49 @@ func (x *logProduceStreamServer) Recv() (*ProduceRequest, error) {
 }
 
 func _Log_ConsumeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LogServer).ConsumeStream(&logConsumeStreamServer{stream})
+}
+
+type Log_ConsumeStreamServer interface {
+	Send(*ConsumeResponse) error
+	Recv() (*ConsumeRequest, error)
+	grpc.ServerStream
+}
+
+type logConsumeStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *logConsumeStreamServer) Send(m *ConsumeResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *logConsumeStreamServer) Recv() (*ConsumeRequest, error) {
 	m := new(ConsumeRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Log_BulkConsume_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ConsumeRangeRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(LogServer).ConsumeStream(m, &logConsumeStreamServer{stream})
+	return srv.(LogServer).BulkConsume(m, &logBulkConsumeServer{stream})
 }
 
-type Log_ConsumeStreamServer interface {
+type Log_BulkConsumeServer interface {
 	Send(*ConsumeResponse) error
 	grpc.ServerStream
 }
 
-type logConsumeStreamServer struct {
+type logBulkConsumeServer struct {
 	grpc.ServerStream
 }
 
-func (x *logConsumeStreamServer) Send(m *ConsumeResponse) error {
+func (x *logBulkConsumeServer) Send(m *ConsumeResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
@@ -296,6 +365,12 @@ var Log_ServiceDesc = grpc.ServiceDesc{
 			StreamName:    "ConsumeStream",
 			Handler:       _Log_ConsumeStream_Handler,
 			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "BulkConsume",
+			Handler:       _Log_BulkConsume_Handler,
+			ServerStreams: true,
 		},
 	},
 	Metadata: "record.proto",
@@ -0,0 +1,36 @@
+package record
+
+// RecordJSON mirrors Record with JSON struct tags for REST and JavaScript clients.
+// Value and header values are encoded as base64, the same way encoding/json handles any []byte.
+type RecordJSON struct {
+	Offset     uint64            `json:"offset"`
+	Seq        uint64            `json:"seq,omitempty"`
+	Key        []byte            `json:"key,omitempty"`
+	Value      []byte            `json:"value"`
+	Headers    map[string][]byte `json:"headers,omitempty"`
+	ProducerID string            `json:"producer_id,omitempty"`
+}
+
+// ToJSON converts the record into its JSON representation.
+func (x *Record) ToJSON() *RecordJSON {
+	return &RecordJSON{
+		Offset:     x.GetOffset(),
+		Seq:        x.GetSeq(),
+		Key:        x.GetKey(),
+		Value:      x.GetValue(),
+		Headers:    x.GetHeaders(),
+		ProducerID: x.GetProducerId(),
+	}
+}
+
+// ToRecord converts the JSON representation back into a record.
+func (j *RecordJSON) ToRecord() *Record {
+	return &Record{
+		Offset:     j.Offset,
+		Seq:        j.Seq,
+		Key:        j.Key,
+		Value:      j.Value,
+		Headers:    j.Headers,
+		ProducerId: j.ProducerID,
+	}
+}
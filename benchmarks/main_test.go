@@ -0,0 +1,228 @@
+// Package benchmarks exercises the whole stack, a gRPC client talking to the log server
+// backed by a real Log on disk, to get numbers that resemble production use.
+//
+// Run them from the repository root with:
+//
+//	go test ./benchmarks -run '^$' -bench . -benchmem
+//
+// Alongside ns/op every benchmark reports records/s and MB/s, where MB/s counts record values only.
+// The client talks to the server over an in-memory bufconn listener, so the numbers include
+// gRPC serialization but not the network. Each benchmark logs a one line summary with -v.
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	stdlog "log"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/logger"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	bufSize     = 1024 * 1024
+	recordBytes = 256 // Size of the value in every benchmark record
+)
+
+// Value shared by every produced record
+var value = make([]byte, recordBytes)
+
+// setupBenchmark starts a log server on a fresh log in a temporary directory and returns a client for it.
+func setupBenchmark(b *testing.B) (client api.LogClient, teardown func()) {
+	b.Helper()
+
+	// The server logs every request, which would drown out what we are measuring
+	stdlog.SetOutput(io.Discard)
+
+	tempDir, err := os.MkdirTemp("", "benchmark")
+	if err != nil {
+		b.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	// Large segments so the numbers are not dominated by segment rotation
+	clog, err := logger.NewLog(tempDir,
+		logger.WithMaxStoreBytes(64*1024*1024),
+		logger.WithMaxIndexBytes(4*1024*1024),
+	)
+	if err != nil {
+		b.Fatalf("Failed to create log: %v", err)
+	}
+
+	srv, err := server.NewGRPCServer(server.WithCommitLog(clog))
+	if err != nil {
+		b.Fatalf("Failed to create server: %v", err)
+	}
+
+	lis := bufconn.Listen(bufSize)
+	gsrv := grpc.NewServer()
+	srv.Register(gsrv)
+	go gsrv.Serve(lis)
+
+	cc, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		b.Fatalf("Failed to dial server: %v", err)
+	}
+
+	teardown = func() {
+		cc.Close()
+		gsrv.Stop()
+		lis.Close()
+		clog.Close()
+		os.RemoveAll(tempDir)
+		stdlog.SetOutput(os.Stderr)
+	}
+
+	return api.NewLogClient(cc), teardown
+}
+
+// reportThroughput reports records/s and MB/s for records of recordBytes each processed since start.
+func reportThroughput(b *testing.B, start time.Time, records int) {
+	b.Helper()
+
+	seconds := time.Since(start).Seconds()
+	if seconds == 0 {
+		return
+	}
+
+	recordsPerSecond := float64(records) / seconds
+	mbPerSecond := float64(records*recordBytes) / seconds / 1e6
+	b.ReportMetric(recordsPerSecond, "records/s")
+	b.ReportMetric(mbPerSecond, "MB/s")
+	b.Logf("%d records of %d bytes: %.0f records/s, %.2f MB/s", records, recordBytes, recordsPerSecond, mbPerSecond)
+}
+
+// BenchmarkProduceSequential appends records one unary call at a time.
+func BenchmarkProduceSequential(b *testing.B) {
+	client, teardown := setupBenchmark(b)
+	defer teardown()
+
+	ctx := context.Background()
+	req := &api.ProduceRequest{Record: &api.Record{Value: value}}
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.Produce(ctx, req); err != nil {
+			b.Fatalf("Produce failed: %v", err)
+		}
+	}
+	b.StopTimer()
+
+	reportThroughput(b, start, b.N)
+}
+
+// BenchmarkProduceParallel appends records from several goroutines sharing one connection.
+func BenchmarkProduceParallel(b *testing.B) {
+	client, teardown := setupBenchmark(b)
+	defer teardown()
+
+	ctx := context.Background()
+	var produced int64
+
+	// Four goroutines per CPU, most of their time is spent waiting on the log's lock
+	b.SetParallelism(4)
+	b.ResetTimer()
+	start := time.Now()
+	b.RunParallel(func(pb *testing.PB) {
+		req := &api.ProduceRequest{Record: &api.Record{Value: value}}
+		for pb.Next() {
+			if _, err := client.Produce(ctx, req); err != nil {
+				b.Errorf("Produce failed: %v", err)
+				return
+			}
+			atomic.AddInt64(&produced, 1)
+		}
+	})
+	b.StopTimer()
+
+	reportThroughput(b, start, int(atomic.LoadInt64(&produced)))
+}
+
+// BenchmarkConsumeSequential reads back records one unary call at a time.
+func BenchmarkConsumeSequential(b *testing.B) {
+	client, teardown := setupBenchmark(b)
+	defer teardown()
+
+	// Fill the log before measuring, the reads are what we are after
+	ctx := context.Background()
+	req := &api.ProduceRequest{Record: &api.Record{Value: value}}
+	for i := 0; i < b.N; i++ {
+		if _, err := client.Produce(ctx, req); err != nil {
+			b.Fatalf("Produce failed: %v", err)
+		}
+	}
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		res, err := client.Consume(ctx, &api.ConsumeRequest{Offset: uint64(i)})
+		if err != nil {
+			b.Fatalf("Consume failed: %v", err)
+		}
+		if res.Record.Offset != uint64(i) {
+			b.Fatalf("Consumed offset %d, expected %d", res.Record.Offset, i)
+		}
+	}
+	b.StopTimer()
+
+	reportThroughput(b, start, b.N)
+}
+
+// BenchmarkProduceStreamThroughput pipelines records over a single produce stream,
+// receiving acknowledgements on a separate goroutine.
+func BenchmarkProduceStreamThroughput(b *testing.B) {
+	client, teardown := setupBenchmark(b)
+	defer teardown()
+
+	stream, err := client.ProduceStream(context.Background())
+	if err != nil {
+		b.Fatalf("ProduceStream failed: %v", err)
+	}
+
+	// Collect every acknowledgement so the benchmark only ends once all records are stored
+	acked := make(chan error, 1)
+	go func() {
+		for i := 0; i < b.N; i++ {
+			if _, err := stream.Recv(); err != nil {
+				acked <- fmt.Errorf("receiving acknowledgement %d: %w", i, err)
+				return
+			}
+		}
+		acked <- nil
+	}()
+
+	req := &api.ProduceRequest{Record: &api.Record{Value: value}}
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if err := stream.Send(req); err != nil {
+			b.Fatalf("Send failed: %v", err)
+		}
+	}
+	if err := <-acked; err != nil {
+		b.Fatal(err)
+	}
+	b.StopTimer()
+
+	if err := stream.CloseSend(); err != nil {
+		b.Fatalf("CloseSend failed: %v", err)
+	}
+
+	reportThroughput(b, start, b.N)
+}
@@ -0,0 +1,135 @@
+//go:build go1.21
+
+// Command cutelogd serves a log stored on local disk over gRPC.
+// It stops on SIGTERM or SIGINT, draining in-flight RPCs and syncing the log before it exits.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/logger"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/server"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stderr); err != nil {
+		slog.Error("cutelogd failed", "err", err)
+		os.Exit(1)
+	}
+}
+
+// run serves the log configured by args until ctx is done, logging to logOut
+func run(ctx context.Context, args []string, logOut io.Writer) error {
+	flags := flag.NewFlagSet("cutelogd", flag.ContinueOnError)
+	dir := flags.String("dir", "./data", "directory the log is stored in")
+	addr := flags.String("addr", ":8400", "address the gRPC server listens on")
+	maxStoreMB := flags.Uint64("max-store-mb", 0, "maximum size of a segment's store in MiB, 0 keeps the log's default")
+	maxIndexMB := flags.Uint64("max-index-mb", 0, "maximum size of a segment's index in MiB, 0 keeps the log's default")
+	metricsAddr := flags.String("metrics-addr", "", "address to serve Prometheus metrics on at /metrics, empty disables it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(logOut, nil)))
+	slog.Info("starting cutelogd", "dir", *dir, "addr", *addr, "max_store_mb", *maxStoreMB, "max_index_mb", *maxIndexMB)
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+
+	// Sizes left at zero fall back to the log's defaults, or to what an existing log was created with
+	var opts []logger.LogOption
+	if *maxStoreMB > 0 {
+		opts = append(opts, logger.WithMaxStoreBytes(*maxStoreMB<<20))
+	}
+	if *maxIndexMB > 0 {
+		opts = append(opts, logger.WithMaxIndexBytes(*maxIndexMB<<20))
+	}
+	clog, err := logger.NewLog(*dir, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to open log in %s: %w", *dir, err)
+	}
+
+	srv, err := server.NewGRPCServer(server.WithCommitLog(clog))
+	if err != nil {
+		clog.Close()
+		return err
+	}
+	gsrv := srv.NewServer()
+
+	healthSrv := health.NewServer()
+	healthpb.RegisterHealthServer(gsrv, healthSrv)
+	healthSrv.SetServingStatus(api.Log_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		clog.Close()
+		return err
+	}
+	slog.Info("listening", "addr", lis.Addr().String())
+
+	// Metrics are served on their own listener, so scraping never competes with the gRPC port
+	var metricsSrv *http.Server
+	if *metricsAddr != "" {
+		metricsLis, err := net.Listen("tcp", *metricsAddr)
+		if err != nil {
+			lis.Close()
+			clog.Close()
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metricsHandler(clog))
+		metricsSrv = &http.Server{Handler: mux}
+		slog.Info("serving metrics", "addr", metricsLis.Addr().String())
+		go func() {
+			if err := metricsSrv.Serve(metricsLis); err != http.ErrServerClosed {
+				slog.Error("metrics server stopped", "err", err)
+			}
+		}()
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- gsrv.Serve(lis)
+	}()
+
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	case err = <-serveErr:
+		slog.Error("server stopped", "err", err)
+	}
+
+	// Stop taking new work, let in-flight RPCs finish, then make everything durable
+	healthSrv.Shutdown()
+	gsrv.GracefulStop()
+	if metricsSrv != nil {
+		metricsSrv.Close()
+	}
+	if syncErr := clog.Sync(); syncErr != nil && err == nil {
+		err = syncErr
+	}
+	if closeErr := clog.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		slog.Info("stopped")
+	}
+	return err
+}
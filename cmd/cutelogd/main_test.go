@@ -0,0 +1,114 @@
+//go:build go1.21
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/logger"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestCutelogd(t *testing.T) {
+	// In the subprocess: run the daemon itself with the flags after --
+	if os.Getenv("CUTELOGD_SUBPROCESS") != "" {
+		for i, arg := range os.Args {
+			if arg == "--" {
+				os.Args = append([]string{os.Args[0]}, os.Args[i+1:]...)
+				break
+			}
+		}
+		main()
+		os.Exit(0)
+	}
+
+	tempDir, err := os.MkdirTemp("", "cutelogd_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCutelogd$", "--", "--dir", tempDir, "--addr", "127.0.0.1:0", "--max-store-mb", "1", "--metrics-addr", "127.0.0.1:0")
+	cmd.Env = append(os.Environ(), "CUTELOGD_SUBPROCESS=1")
+	stderr, err := cmd.StderrPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+
+	// The daemon logs the addresses it ended up listening on
+	addrs := make(chan string, 1)
+	metricsAddrs := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.Contains(line, "msg=listening"):
+				addrs <- strings.TrimPrefix(line[strings.Index(line, "addr="):], "addr=")
+			case strings.Contains(line, `msg="serving metrics"`):
+				metricsAddrs <- strings.TrimPrefix(line[strings.Index(line, "addr="):], "addr=")
+			}
+		}
+	}()
+	var addr, metricsAddr string
+	for addr == "" || metricsAddr == "" {
+		select {
+		case addr = <-addrs:
+		case metricsAddr = <-metricsAddrs:
+		case <-time.After(10 * time.Second):
+			t.Fatal("cutelogd did not start listening")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cc, err := grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+
+	health, err := healthpb.NewHealthClient(cc).Check(ctx, &healthpb.HealthCheckRequest{Service: api.Log_ServiceDesc.ServiceName})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, health.Status)
+
+	client := api.NewLogClient(cc)
+	produced, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("hello daemon")}})
+	require.NoError(t, err)
+	consumed, err := client.Consume(ctx, &api.ConsumeRequest{Offset: produced.Offset})
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello daemon"), consumed.Record.Value)
+
+	// The metrics endpoint reports the record
+	res, err := http.Get("http://" + metricsAddr + "/metrics")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	metrics, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(metrics), "# TYPE cute_log_record_count gauge\ncute_log_record_count 1\n")
+	for _, name := range []string{"cute_log_segment_count", "cute_log_store_bytes", "cute_log_index_bytes"} {
+		require.Contains(t, string(metrics), "\n"+name+" ")
+	}
+
+	// SIGTERM shuts the daemon down cleanly
+	require.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+	require.NoError(t, cmd.Wait())
+
+	// The record survived the restart
+	clog, err := logger.NewLog(tempDir)
+	require.NoError(t, err)
+	defer clog.Close()
+	record, err := clog.Read(produced.Offset)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello daemon"), record.Value)
+}
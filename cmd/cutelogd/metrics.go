@@ -0,0 +1,32 @@
+//go:build go1.21
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/BryceDouglasJames/Cute-Logger/internal/logger"
+)
+
+// metricsHandler serves the log's stats in the Prometheus text format
+func metricsHandler(clog *logger.Log) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats, err := clog.Stats()
+		if err != nil {
+			slog.Error("failed to collect metrics", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		gauge := func(name, help string, value uint64) {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+		}
+		gauge("cute_log_segment_count", "Number of segments in the log.", uint64(stats.Segments))
+		gauge("cute_log_record_count", "Number of records in the log.", stats.Records)
+		gauge("cute_log_store_bytes", "Bytes in the stores of all segments.", stats.StoreBytes)
+		gauge("cute_log_index_bytes", "Bytes in the indexes of all segments.", stats.IndexBytes)
+	})
+}
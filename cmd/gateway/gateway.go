@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gateway exposes a LogClient over plain HTTP for clients that cannot speak gRPC
+type gateway struct {
+	client api.LogClient
+}
+
+// produceResponse is the body POST /produce answers with
+type produceResponse struct {
+	Offset uint64 `json:"offset"`
+}
+
+// newGateway returns the gateway's routes:
+//
+//	POST /produce          appends the JSON record in the body and returns its offset
+//	GET  /consume/{offset} returns the record at offset as JSON
+//	GET  /stream/{offset}  streams the records from offset on as server-sent events
+func newGateway(client api.LogClient) http.Handler {
+	g := &gateway{client: client}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/produce", g.produce)
+	mux.HandleFunc("/consume/", g.consume)
+	mux.HandleFunc("/stream/", g.stream)
+	return mux
+}
+
+func (g *gateway) produce(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Values are base64 encoded, the same way api.RecordJSON hands them out
+	var record api.RecordJSON
+	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+		http.Error(w, fmt.Sprintf("invalid record: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	res, err := g.client.Produce(r.Context(), &api.ProduceRequest{Record: record.ToRecord()})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, produceResponse{Offset: res.Offset})
+}
+
+func (g *gateway) consume(w http.ResponseWriter, r *http.Request) {
+	offset, ok := parseOffset(w, r, "/consume/")
+	if !ok {
+		return
+	}
+
+	res, err := g.client.Consume(r.Context(), &api.ConsumeRequest{Offset: offset})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, res.Record.ToJSON())
+}
+
+func (g *gateway) stream(w http.ResponseWriter, r *http.Request) {
+	offset, ok := parseOffset(w, r, "/stream/")
+	if !ok {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The stream ends when the HTTP client goes away
+	stream, err := g.client.ConsumeStream(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := stream.Send(&api.ConsumeRequest{Offset: offset}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			// Headers are already sent, so a failure can only be reported as an event of its own
+			if err != io.EOF && r.Context().Err() == nil {
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", status.Convert(err).Message())
+				flusher.Flush()
+			}
+			return
+		}
+
+		data, err := json.Marshal(res.Record.ToJSON())
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, "id: %d\ndata: %s\n\n", res.Record.Offset, data)
+		flusher.Flush()
+	}
+}
+
+// parseOffset reads the offset that follows prefix in the request path, answering the request itself when it cannot
+func parseOffset(w http.ResponseWriter, r *http.Request, prefix string) (uint64, bool) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return 0, false
+	}
+
+	offset, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, prefix), 10, 64)
+	if err != nil {
+		http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return offset, true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers with the HTTP status closest to the gRPC status of err
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+
+	code := http.StatusBadGateway
+	switch st.Code() {
+	case codes.InvalidArgument:
+		code = http.StatusBadRequest
+	case codes.OutOfRange, codes.NotFound:
+		code = http.StatusNotFound
+	case codes.Unavailable, codes.ResourceExhausted:
+		code = http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		code = http.StatusGatewayTimeout
+	}
+
+	http.Error(w, st.Message(), code)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	log "github.com/BryceDouglasJames/Cute-Logger/internal/logger"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/server"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// setupGateway starts a log server over bufconn and an HTTP gateway in front of it
+func setupGateway(t *testing.T) (*httptest.Server, func()) {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "gateway_test")
+	require.NoError(t, err)
+
+	clog, err := log.NewLog(dir)
+	require.NoError(t, err)
+
+	srv, err := server.NewGRPCServer(server.WithCommitLog(clog))
+	require.NoError(t, err)
+	gsrv := srv.NewServer()
+
+	lis := bufconn.Listen(1024 * 1024)
+	go gsrv.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	gw := httptest.NewServer(newGateway(api.NewLogClient(conn)))
+
+	return gw, func() {
+		gw.Close()
+		conn.Close()
+		gsrv.Stop()
+		clog.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestGatewayProduceConsume(t *testing.T) {
+	gw, teardown := setupGateway(t)
+	defer teardown()
+
+	// "aGVsbG8=" is "hello" in base64
+	res, err := http.Post(gw.URL+"/produce", "application/json", strings.NewReader(`{"value":"aGVsbG8="}`))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var produced produceResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&produced))
+	require.Equal(t, uint64(0), produced.Offset)
+
+	res, err = http.Get(fmt.Sprintf("%s/consume/%d", gw.URL, produced.Offset))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var record api.RecordJSON
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&record))
+	require.Equal(t, []byte("hello"), record.Value)
+
+	// Offsets past the end of the log are not found
+	res, err = http.Get(gw.URL + "/consume/1")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	// Malformed requests are rejected
+	res, err = http.Get(gw.URL + "/consume/abc")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res, err = http.Post(gw.URL+"/produce", "application/json", strings.NewReader(`not json`))
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res, err = http.Get(gw.URL + "/produce")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+}
+
+func TestGatewayStream(t *testing.T) {
+	gw, teardown := setupGateway(t)
+	defer teardown()
+
+	for _, value := range []string{"b25l", "dHdv", "dGhyZWU="} {
+		res, err := http.Post(gw.URL+"/produce", "application/json", strings.NewReader(`{"value":"`+value+`"}`))
+		require.NoError(t, err)
+		res.Body.Close()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gw.URL+"/stream/1", nil)
+	require.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	// Each record arrives as an event carrying its JSON form
+	scanner := bufio.NewScanner(res.Body)
+	for _, want := range []string{"two", "three"} {
+		var data string
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				break
+			}
+			if strings.HasPrefix(line, "data: ") {
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		require.NoError(t, scanner.Err())
+
+		var record api.RecordJSON
+		require.NoError(t, json.Unmarshal([]byte(data), &record))
+		require.Equal(t, []byte(want), record.Value)
+	}
+}
@@ -0,0 +1,35 @@
+// Command gateway serves a log over HTTP by forwarding requests to its gRPC server.
+// The address of the gRPC server is read from CUTE_LOG_GRPC_ADDR.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address the HTTP gateway listens on")
+	flag.Parse()
+
+	grpcAddr := os.Getenv("CUTE_LOG_GRPC_ADDR")
+	if grpcAddr == "" {
+		log.Fatal("CUTE_LOG_GRPC_ADDR must be set to the address of the log's gRPC server")
+	}
+
+	conn, err := grpc.Dial(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("failed to dial %s: %v", grpcAddr, err)
+	}
+	defer conn.Close()
+
+	log.Printf("gateway listening on %s, forwarding to %s", *addr, grpcAddr)
+	if err := http.ListenAndServe(*addr, newGateway(api.NewLogClient(conn))); err != nil {
+		log.Fatal(err)
+	}
+}
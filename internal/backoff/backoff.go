@@ -0,0 +1,68 @@
+package backoff
+
+import (
+	"sync"
+	"time"
+)
+
+// BackOff decides how long to wait between attempts at something that keeps failing,
+// such as polling a log that has no new records.
+type BackOff interface {
+	// NextBackOff returns how long to wait before the next attempt
+	NextBackOff() time.Duration
+
+	// Reset starts over after an attempt succeeded
+	Reset()
+}
+
+// constantBackOff always waits the same amount of time
+type constantBackOff struct {
+	d time.Duration
+}
+
+// ConstantBackOff returns a BackOff that always waits d.
+func ConstantBackOff(d time.Duration) BackOff {
+	return &constantBackOff{d: d}
+}
+
+func (b *constantBackOff) NextBackOff() time.Duration { return b.d }
+
+func (b *constantBackOff) Reset() {}
+
+// exponentialBackOff doubles its wait on every attempt, between min and max
+type exponentialBackOff struct {
+	mutex    sync.Mutex
+	min, max time.Duration
+	next     time.Duration
+}
+
+// ExponentialBackOff returns a BackOff that waits min first and doubles the wait after every attempt, up to max.
+func ExponentialBackOff(min, max time.Duration) BackOff {
+	if max < min {
+		max = min
+	}
+	return &exponentialBackOff{min: min, max: max, next: min}
+}
+
+func (b *exponentialBackOff) NextBackOff() time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	d := b.next
+	if b.next < b.max {
+		// Doubling could overflow long before max is reached, so clamp to max
+		if b.next > b.max/2 {
+			b.next = b.max
+		} else {
+			b.next *= 2
+		}
+	}
+	return d
+}
+
+func (b *exponentialBackOff) Reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.next = b.min
+}
@@ -0,0 +1,30 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstantBackOff(t *testing.T) {
+	b := ConstantBackOff(10 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		require.Equal(t, 10*time.Millisecond, b.NextBackOff())
+	}
+	b.Reset()
+	require.Equal(t, 10*time.Millisecond, b.NextBackOff())
+}
+
+func TestExponentialBackOff(t *testing.T) {
+	b := ExponentialBackOff(time.Millisecond, 5*time.Millisecond)
+
+	// Doubles until it hits the maximum, then stays there
+	for _, want := range []time.Duration{1, 2, 4, 5, 5} {
+		require.Equal(t, want*time.Millisecond, b.NextBackOff())
+	}
+
+	// Starts over from the minimum after a reset
+	b.Reset()
+	require.Equal(t, time.Millisecond, b.NextBackOff())
+}
@@ -0,0 +1,498 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Ensure retryClient implements the LogClient interface
+var _ api.LogClient = (*retryClient)(nil)
+
+// retryClient wraps the generated LogClient and retries calls that fail because the server
+// is temporarily unavailable or overloaded, backing off exponentially between attempts.
+type retryClient struct {
+	client api.LogClient
+	conn   *grpc.ClientConn
+
+	maxRetries  int
+	backoff     time.Duration
+	dialOptions []grpc.DialOption
+}
+
+// ClientOption defines a function signature for configuring the retryClient
+type ClientOption func(*retryClient) error
+
+// Sets how many times a failed call is retried before its error is returned.
+func WithMaxRetries(n int) ClientOption {
+	return func(c *retryClient) error {
+		if n < 0 {
+			return errors.New("max retries cannot be negative")
+		}
+		c.maxRetries = n
+		return nil
+	}
+}
+
+// Sets the delay before the first retry. Every following retry waits twice as long as the previous one.
+func WithBackoff(d time.Duration) ClientOption {
+	return func(c *retryClient) error {
+		if d <= 0 {
+			return errors.New("backoff must be positive")
+		}
+		c.backoff = d
+		return nil
+	}
+}
+
+// Replaces the options used to dial the server. By default the connection uses insecure credentials.
+func WithDialOptions(opts ...grpc.DialOption) ClientOption {
+	return func(c *retryClient) error {
+		c.dialOptions = opts
+		return nil
+	}
+}
+
+// NewLogClientWithRetry dials addr and returns a LogClient that transparently retries
+// Unavailable and ResourceExhausted failures, including re-establishing broken streams.
+// The returned client also implements io.Closer to release the underlying connection.
+func NewLogClientWithRetry(addr string, opts ...ClientOption) (api.LogClient, error) {
+	// Initialize the client with default configuration
+	c := &retryClient{
+		maxRetries: 3,
+		backoff:    100 * time.Millisecond,
+		dialOptions: []grpc.DialOption{
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		},
+	}
+
+	// Apply each ClientOption passed to the function
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := grpc.Dial(addr, c.dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	c.client = api.NewLogClient(conn)
+
+	return c, nil
+}
+
+// Close releases the underlying connection
+func (c *retryClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *retryClient) Produce(ctx context.Context, in *api.ProduceRequest, opts ...grpc.CallOption) (res *api.ProduceResponse, err error) {
+	err = c.retry(ctx, func() error {
+		res, err = c.client.Produce(ctx, in, opts...)
+		return err
+	})
+	return res, err
+}
+
+func (c *retryClient) Consume(ctx context.Context, in *api.ConsumeRequest, opts ...grpc.CallOption) (res *api.ConsumeResponse, err error) {
+	err = c.retry(ctx, func() error {
+		res, err = c.client.Consume(ctx, in, opts...)
+		return err
+	})
+	return res, err
+}
+
+func (c *retryClient) ProduceStream(ctx context.Context, opts ...grpc.CallOption) (api.Log_ProduceStreamClient, error) {
+	var stream api.Log_ProduceStreamClient
+	err := c.retry(ctx, func() (err error) {
+		stream, err = c.client.ProduceStream(ctx, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &produceStream{
+		stream: stream,
+		client: c,
+		ctx:    ctx,
+		opts:   opts,
+	}, nil
+}
+
+func (c *retryClient) ConsumeStream(ctx context.Context, opts ...grpc.CallOption) (api.Log_ConsumeStreamClient, error) {
+	var stream api.Log_ConsumeStreamClient
+	err := c.retry(ctx, func() (err error) {
+		stream, err = c.client.ConsumeStream(ctx, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &consumeStream{
+		Log_ConsumeStreamClient: stream,
+		client:                  c,
+		ctx:                     ctx,
+		opts:                    opts,
+	}, nil
+}
+
+func (c *retryClient) BulkConsume(ctx context.Context, in *api.ConsumeRangeRequest, opts ...grpc.CallOption) (api.Log_BulkConsumeClient, error) {
+	var stream api.Log_BulkConsumeClient
+	err := c.retry(ctx, func() (err error) {
+		stream, err = c.client.BulkConsume(ctx, in, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &bulkConsumeStream{
+		Log_BulkConsumeClient: stream,
+		req:                   proto.Clone(in).(*api.ConsumeRangeRequest),
+		client:                c,
+		ctx:                   ctx,
+		opts:                  opts,
+	}, nil
+}
+
+// retry calls fn until it succeeds, fails with an error that is not worth retrying,
+// or runs out of attempts.
+func (c *retryClient) retry(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !isRetryable(err) || attempt >= c.maxRetries {
+			return err
+		}
+
+		if err := c.wait(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+// wait sleeps for the backoff of the given attempt, or until ctx is done.
+func (c *retryClient) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(c.backoff << attempt)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isRetryable reports whether err is a transient failure on the server side.
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+// consumeStream re-opens the consume stream after a transient failure,
+// resuming right after the last record that was received, or at the last seek if
+// nothing was received since.
+type consumeStream struct {
+	api.Log_ConsumeStreamClient
+
+	mutex  sync.Mutex
+	req    *api.ConsumeRequest // Nil until the first request was sent
+	closed bool                // Whether CloseSend was called
+
+	client *retryClient
+	ctx    context.Context
+	opts   []grpc.CallOption
+}
+
+func (s *consumeStream) Send(req *api.ConsumeRequest) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Keep our own copy of the request since its offset moves as records are received
+	s.req = proto.Clone(req).(*api.ConsumeRequest)
+
+	// A broken stream is picked up and replaced by Recv, which replays the seek
+	if err := s.Log_ConsumeStreamClient.Send(req); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func (s *consumeStream) CloseSend() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	return s.Log_ConsumeStreamClient.CloseSend()
+}
+
+func (s *consumeStream) Recv() (*api.ConsumeResponse, error) {
+	for attempt := 0; ; attempt++ {
+		s.mutex.Lock()
+		stream := s.Log_ConsumeStreamClient
+		s.mutex.Unlock()
+
+		res, err := stream.Recv()
+		if err == nil {
+			// Remember where to pick up from if the stream breaks
+			s.mutex.Lock()
+			if s.req != nil {
+				s.req.Offset = res.Record.Offset + 1
+			}
+			s.mutex.Unlock()
+			return res, nil
+		}
+
+		if !isRetryable(err) || attempt >= s.client.maxRetries {
+			return nil, err
+		}
+		if err := s.client.wait(s.ctx, attempt); err != nil {
+			return nil, err
+		}
+
+		// Open a fresh stream from the next expected offset
+		if err := s.reconnect(); err != nil {
+			if !isRetryable(err) {
+				return nil, err
+			}
+			continue
+		}
+	}
+}
+
+// reconnect opens a new stream and replays where the broken one was at.
+func (s *consumeStream) reconnect() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stream, err := s.client.client.ConsumeStream(s.ctx, s.opts...)
+	if err != nil {
+		return err
+	}
+
+	if s.req != nil {
+		if err := stream.Send(s.req); err != nil {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+	}
+	if s.closed {
+		if err := stream.CloseSend(); err != nil {
+			return err
+		}
+	}
+
+	s.Log_ConsumeStreamClient = stream
+	return nil
+}
+
+// bulkConsumeStream re-opens the bulk consume stream after a transient failure,
+// asking only for the part of the range that was not received yet.
+type bulkConsumeStream struct {
+	api.Log_BulkConsumeClient
+
+	req *api.ConsumeRangeRequest // Moves up as records are received
+
+	client *retryClient
+	ctx    context.Context
+	opts   []grpc.CallOption
+}
+
+func (s *bulkConsumeStream) Recv() (*api.ConsumeResponse, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := s.Log_BulkConsumeClient.Recv()
+		if err == nil {
+			s.req.FromOffset = res.Record.Offset + 1
+			return res, nil
+		}
+
+		if !isRetryable(err) || attempt >= s.client.maxRetries {
+			return nil, err
+		}
+		if err := s.client.wait(s.ctx, attempt); err != nil {
+			return nil, err
+		}
+
+		// The whole range was received before the stream broke
+		if s.req.FromOffset > s.req.ToOffset {
+			return nil, io.EOF
+		}
+
+		stream, err := s.client.client.BulkConsume(s.ctx, s.req, s.opts...)
+		if err != nil {
+			if !isRetryable(err) {
+				return nil, err
+			}
+			continue
+		}
+		s.Log_BulkConsumeClient = stream
+	}
+}
+
+// produceStream re-opens the produce stream after a transient failure and resends
+// every request that has not been acknowledged yet, giving at-least-once delivery.
+type produceStream struct {
+	mutex      sync.Mutex
+	stream     api.Log_ProduceStreamClient
+	generation int
+	pending    []*api.ProduceRequest
+
+	client *retryClient
+	ctx    context.Context
+	opts   []grpc.CallOption
+}
+
+func (s *produceStream) Send(req *api.ProduceRequest) error {
+	s.mutex.Lock()
+	s.pending = append(s.pending, req)
+	stream, gen := s.stream, s.generation
+	s.mutex.Unlock()
+
+	err := stream.Send(req)
+	if err == nil {
+		return nil
+	}
+
+	// Send reports a broken stream as io.EOF, the actual status only shows up on Recv
+	if err == io.EOF {
+		err = status.Error(codes.Unavailable, "produce stream closed")
+	}
+
+	// Reconnecting resends everything pending, including this request
+	return s.reconnect(gen, err)
+}
+
+func (s *produceStream) Recv() (*api.ProduceResponse, error) {
+	for {
+		s.mutex.Lock()
+		stream, gen := s.stream, s.generation
+		s.mutex.Unlock()
+
+		res, err := stream.Recv()
+		if err == nil {
+			// Responses arrive in request order, so the oldest pending request is acknowledged
+			s.mutex.Lock()
+			if gen == s.generation && len(s.pending) > 0 {
+				s.pending = s.pending[1:]
+			}
+			s.mutex.Unlock()
+			return res, nil
+		}
+
+		if err == io.EOF {
+			return nil, err
+		}
+		if err := s.reconnect(gen, err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *produceStream) CloseSend() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stream.CloseSend()
+}
+
+func (s *produceStream) Header() (metadata.MD, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stream.Header()
+}
+
+func (s *produceStream) Trailer() metadata.MD {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stream.Trailer()
+}
+
+func (s *produceStream) Context() context.Context {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stream.Context()
+}
+
+func (s *produceStream) SendMsg(m interface{}) error {
+	req, ok := m.(*api.ProduceRequest)
+	if !ok {
+		return errors.New("produce stream only sends produce requests")
+	}
+	return s.Send(req)
+}
+
+func (s *produceStream) RecvMsg(m interface{}) error {
+	res, ok := m.(*api.ProduceResponse)
+	if !ok {
+		return errors.New("produce stream only receives produce responses")
+	}
+	got, err := s.Recv()
+	if err != nil {
+		return err
+	}
+	proto.Merge(res, got)
+	return nil
+}
+
+// reconnect replaces a broken stream and resends every pending request on the new one.
+// gen is the generation of the stream that failed; if another caller already replaced it,
+// there is nothing left to do.
+func (s *produceStream) reconnect(gen int, cause error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if gen != s.generation {
+		return nil
+	}
+
+	for attempt := 0; ; attempt++ {
+		if !isRetryable(cause) || attempt >= s.client.maxRetries {
+			return cause
+		}
+		if err := s.client.wait(s.ctx, attempt); err != nil {
+			return err
+		}
+
+		stream, err := s.client.client.ProduceStream(s.ctx, s.opts...)
+		if err != nil {
+			cause = err
+			continue
+		}
+
+		// Replay everything the server has not acknowledged yet
+		if err := s.resend(stream); err != nil {
+			cause = status.Error(codes.Unavailable, err.Error())
+			continue
+		}
+
+		s.stream = stream
+		s.generation++
+		return nil
+	}
+}
+
+// resend replays the pending requests on stream.
+func (s *produceStream) resend(stream api.Log_ProduceStreamClient) error {
+	for _, req := range s.pending {
+		if err := stream.Send(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
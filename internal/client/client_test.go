@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	log "github.com/BryceDouglasJames/Cute-Logger/internal/logger"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/server"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const bufSize = 1024 * 1024
+
+// failingStream makes the server side of a stream fail with Unavailable after a number of messages
+type failingStream struct {
+	grpc.ServerStream
+
+	// Streams send and receive from different goroutines
+	mutex     sync.Mutex
+	remaining int
+}
+
+func (f *failingStream) SendMsg(m interface{}) error {
+	if !f.take() {
+		return status.Error(codes.Unavailable, "injected send failure")
+	}
+	return f.ServerStream.SendMsg(m)
+}
+
+// RecvMsg counts a message once it has arrived, since a server reading ahead is already waiting for the next one
+// while it answers the last
+func (f *failingStream) RecvMsg(m interface{}) error {
+	if err := f.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if !f.take() {
+		return status.Error(codes.Unavailable, "injected recv failure")
+	}
+	return nil
+}
+
+// take uses up one message, reporting false once the stream should fail
+func (f *failingStream) take() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.remaining == 0 {
+		return false
+	}
+	f.remaining--
+	return true
+}
+
+// setupTest starts a log server that fails the first unaryFailures unary calls and
+// breaks the first stream of each kind after streamMessages messages.
+func setupTest(t *testing.T, unaryFailures int32, streamMessages int, opts ...ClientOption) (client api.LogClient, teardown func()) {
+	t.Helper()
+
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "client_test")
+	require.NoError(t, err)
+
+	clog, err := log.NewLog(tempDir)
+	require.NoError(t, err)
+
+	var unaryCalls int32
+	var brokenStreams sync.Map
+	gsrv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if atomic.AddInt32(&unaryCalls, 1) <= unaryFailures {
+				return nil, status.Error(codes.Unavailable, "injected unary failure")
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if _, broken := brokenStreams.LoadOrStore(info.FullMethod, true); broken || streamMessages < 0 {
+				return handler(srv, ss)
+			}
+			if err := handler(srv, &failingStream{ServerStream: ss, remaining: streamMessages}); err != nil {
+				return status.Error(codes.Unavailable, "injected stream failure")
+			}
+			return nil
+		}),
+	)
+
+	srv, err := server.NewGRPCServer(server.WithCommitLog(clog))
+	require.NoError(t, err)
+	srv.Register(gsrv)
+
+	lis := bufconn.Listen(bufSize)
+	go gsrv.Serve(lis)
+
+	opts = append([]ClientOption{
+		WithBackoff(time.Millisecond),
+		WithDialOptions(
+			grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+				return lis.Dial()
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		),
+	}, opts...)
+	client, err = NewLogClientWithRetry("bufnet", opts...)
+	require.NoError(t, err)
+
+	teardown = func() {
+		client.(io.Closer).Close()
+		gsrv.Stop()
+		lis.Close()
+		clog.Close()
+		os.RemoveAll(tempDir)
+	}
+
+	return client, teardown
+}
+
+func TestRetryClientUnary(t *testing.T) {
+	// The first two calls fail, which is within the default retry budget
+	client, teardown := setupTest(t, 2, -1)
+	defer teardown()
+	ctx := context.Background()
+
+	record := &api.Record{Value: []byte("retried record")}
+	produceResp, err := client.Produce(ctx, &api.ProduceRequest{Record: record})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), produceResp.Offset)
+
+	consumeResp, err := client.Consume(ctx, &api.ConsumeRequest{Offset: produceResp.Offset})
+	require.NoError(t, err)
+	require.Equal(t, record.Value, consumeResp.Record.Value)
+}
+
+func TestRetryClientGivesUp(t *testing.T) {
+	// More failures than retries should surface the original error
+	client, teardown := setupTest(t, 5, -1, WithMaxRetries(2))
+	defer teardown()
+
+	_, err := client.Produce(context.Background(), &api.ProduceRequest{Record: &api.Record{Value: []byte("lost")}})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestRetryClientStreams(t *testing.T) {
+	// Each stream breaks after its second message the first time it is opened
+	client, teardown := setupTest(t, 0, 2)
+	defer teardown()
+	ctx := context.Background()
+
+	produceStream, err := client.ProduceStream(ctx)
+	require.NoError(t, err)
+
+	// Every record should be acknowledged even though the stream breaks midway
+	total := 4
+	for i := 0; i < total; i++ {
+		require.NoError(t, produceStream.Send(&api.ProduceRequest{Record: &api.Record{Value: []byte(fmt.Sprintf("message %d", i))}}))
+		res, err := produceStream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, uint64(i), res.Offset)
+	}
+	require.NoError(t, produceStream.CloseSend())
+
+	// Consuming should resume right where the broken stream left off
+	consumeStream, err := client.ConsumeStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, consumeStream.Send(&api.ConsumeRequest{Offset: 0}))
+
+	for i := 0; i < total; i++ {
+		res, err := consumeStream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, uint64(i), res.Record.Offset)
+		require.Equal(t, []byte(fmt.Sprintf("message %d", i)), res.Record.Value)
+	}
+}
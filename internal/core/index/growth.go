@@ -0,0 +1,33 @@
+package index
+
+// GrowthStrategy decides how large the index file becomes once it runs out of room for another entry.
+type GrowthStrategy interface {
+	// NextSize returns the new size in bytes for an index that is currently current bytes.
+	NextSize(current uint64) uint64
+}
+
+type linearGrowth struct {
+	step uint64
+}
+
+// LinearGrowth grows the index by a fixed number of bytes every time it fills up.
+func LinearGrowth(step uint64) GrowthStrategy {
+	return linearGrowth{step: step}
+}
+
+func (g linearGrowth) NextSize(current uint64) uint64 {
+	return current + g.step
+}
+
+type exponentialGrowth struct {
+	factor float64
+}
+
+// ExponentialGrowth scales the index by factor every time it fills up. A factor of 2 doubles it.
+func ExponentialGrowth(factor float64) GrowthStrategy {
+	return exponentialGrowth{factor: factor}
+}
+
+func (g exponentialGrowth) NextSize(current uint64) uint64 {
+	return uint64(float64(current) * g.factor)
+}
@@ -1,20 +1,42 @@
 package index
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 
 	"github.com/tysonmote/gommap"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// On-disk layout of an index entry: the relative offset, the store position, then the timestamp
+const (
+	offsetFieldLen    = 4
+	positionFieldLen  = 8
+	timestampFieldLen = 8
+	indexEntryLen     = offsetFieldLen + positionFieldLen + timestampFieldLen
+)
+
+// HeaderLength is the size of the magic header at the start of every index file.
+// Entries start right after it.
+const HeaderLength = 8
+
+// Identifies a file as an index, in this version of the on-disk format
+var magic = [HeaderLength]byte{0xC0, 0x7E, 0x49, 0x44, 0x58, 0x00, 0x02, 0x00}
+
 var (
-	offset      uint64 = 4
-	wordLength  uint64 = 8
-	entryLength        = offset + wordLength
+	// ErrInvalidIndexMagic is returned when opening a file that does not start with the index magic header.
+	// Either the file is not an index, or it was written by an incompatible version of the format.
+	ErrInvalidIndexMagic = errors.New("file is not an index or uses an unsupported format")
+
+	// ErrNonMonotonicOffset is returned when a write does not advance the index offset.
+	ErrNonMonotonicOffset = errors.New("index offset must be strictly increasing")
 
-	enc = binary.BigEndian
+	// ErrNonMonotonicPosition is returned when a write does not advance the store position.
+	ErrNonMonotonicPosition = errors.New("index position must be strictly increasing")
 )
 
 type Options struct {
@@ -23,16 +45,38 @@ type Options struct {
 	UseMemoryMapping bool
 	AutoCreate       bool
 	MaxIndexBytes    uint64
+	Encoding         binary.ByteOrder
+	Growth           GrowthStrategy
+	Tracer           trace.Tracer
 }
 
 // Represents a function that applies configuration options to an Options instance
 type IndexOptions func(*Options)
 
+// Entry is a single record in the index: the offset relative to the segment's base
+// offset, the position of the record in the store, and when the record was appended.
+// Read and Write take an Entry rather than separate values so new fields can be added
+// without changing their signatures.
+type Entry struct {
+	Off       uint32
+	Pos       uint64
+	CreatedAt uint64 // Unix nanoseconds
+}
+
 type Index struct {
 	File             *os.File
-	Size             uint64
+	Size             uint64 // Bytes of entries, not counting the header
 	MemoryMap        gommap.MMap
 	UseMemoryMapping bool
+
+	enc    binary.ByteOrder
+	growth GrowthStrategy
+
+	// Room for entries the index was opened with, restored on the next write after ShrinkToFit
+	maxBytes uint64
+
+	// Traces Write and Read when set, see WithTracer
+	tracer trace.Tracer
 }
 
 // Default settings for Index
@@ -43,6 +87,7 @@ func DefaultOptions() *Options {
 		UseMemoryMapping: false,
 		AutoCreate:       true,
 		MaxIndexBytes:    1024,
+		Encoding:         binary.BigEndian,
 	}
 }
 
@@ -81,6 +126,22 @@ func WithMaxIndexBytes(maxIndexBytes uint64) IndexOptions {
 	}
 }
 
+// Sets the byte order used for entries.
+// An index must always be read with the same byte order it was written with.
+func WithEncoding(order binary.ByteOrder) IndexOptions {
+	return func(opts *Options) {
+		opts.Encoding = order
+	}
+}
+
+// Lets a memory-mapped index grow past MaxIndexBytes instead of reporting EOF when it is full.
+// MaxIndexBytes then only sets the initial size of the index file.
+func WithGrowthStrategy(s GrowthStrategy) IndexOptions {
+	return func(opts *Options) {
+		opts.Growth = s
+	}
+}
+
 func NewIndex(optFns ...IndexOptions) (*Index, error) {
 	// Initialize with default options.
 	opts := DefaultOptions()
@@ -91,7 +152,7 @@ func NewIndex(optFns ...IndexOptions) (*Index, error) {
 	}
 
 	var err error
-	newIndex := &Index{}
+	newIndex := &Index{enc: opts.Encoding, growth: opts.Growth, maxBytes: opts.MaxIndexBytes, tracer: opts.Tracer}
 
 	// Check if a custom file is provided in options
 	if opts.File == nil {
@@ -104,8 +165,14 @@ func NewIndex(optFns ...IndexOptions) (*Index, error) {
 				return nil, err
 			}
 		} else {
-			// Otherwise, return err because option was not enabled
-			return nil, errors.New("you do not have auto-create enabled for indexes please use index.WithAutoCreate option to enable")
+			// Otherwise the index file has to exist already
+			newIndex.File, err = os.OpenFile(opts.FilePath, os.O_RDWR, 0)
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("index file %q does not exist and AutoCreate is disabled: %w", opts.FilePath, err)
+			}
+			if err != nil {
+				return nil, err
+			}
 		}
 	} else if opts.File != nil {
 		// If the file is already open, check if it's usable
@@ -114,7 +181,7 @@ func NewIndex(optFns ...IndexOptions) (*Index, error) {
 		}
 
 		// Optionally, reset the file's offset or ensure it's ready for use
-		if _, err := opts.File.Seek(0, os.SEEK_END); err != nil {
+		if _, err := opts.File.Seek(0, io.SeekEnd); err != nil {
 			return nil, err
 		}
 
@@ -130,10 +197,25 @@ func NewIndex(optFns ...IndexOptions) (*Index, error) {
 	if err != nil {
 		return nil, err
 	}
-	newIndex.Size = uint64(fi.Size())
 
-	// Truncate new index into index file
-	if err = os.Truncate(newIndex.File.Name(), int64(opts.MaxIndexBytes)); err != nil {
+	// A new index gets the magic header, an existing one has to carry it already
+	if fi.Size() == 0 {
+		if _, err := newIndex.File.WriteAt(magic[:], 0); err != nil {
+			return nil, err
+		}
+	} else {
+		var header [HeaderLength]byte
+		if _, err := newIndex.File.ReadAt(header[:], 0); err != nil && err != io.EOF {
+			return nil, err
+		}
+		if header != magic {
+			return nil, ErrInvalidIndexMagic
+		}
+		newIndex.Size = uint64(fi.Size()) - HeaderLength
+	}
+
+	// Truncate new index into index file, MaxIndexBytes is the room for entries after the header
+	if err = os.Truncate(newIndex.File.Name(), int64(HeaderLength+opts.MaxIndexBytes)); err != nil {
 		return nil, err
 	}
 
@@ -144,7 +226,8 @@ func NewIndex(optFns ...IndexOptions) (*Index, error) {
 	if opts.UseMemoryMapping {
 		// Ensure the file descriptor supports the intended memory map protections.
 		mmapProt := gommap.PROT_READ | gommap.PROT_WRITE
-		mmapFlags := gommap.MAP_SHARED | gommap.MAP_ANONYMOUS
+		// The mapping has to be backed by the file, otherwise entries never reach disk
+		mmapFlags := gommap.MAP_SHARED
 
 		newMap, err := gommap.Map(newIndex.File.Fd(), mmapProt, mmapFlags)
 		if err != nil {
@@ -157,56 +240,320 @@ func NewIndex(optFns ...IndexOptions) (*Index, error) {
 	return newIndex, nil
 }
 
-func (i *Index) Write(off uint32, pos uint64) error {
+func (i *Index) Write(e Entry) error {
+	return i.WriteContext(context.Background(), e)
+}
+
+// WriteContext is Write, tracing the write as a child of the span in ctx
+func (i *Index) WriteContext(ctx context.Context, e Entry) error {
+	if i.tracer == nil {
+		return i.write(e)
+	}
+	return i.traced(ctx, "write", func() uint64 { return uint64(e.Off) }, func() error {
+		return i.write(e)
+	})
+}
+
+// write is Write without tracing
+func (i *Index) write(e Entry) error {
 	// Check if there's enough space left in the memory-mapped file to write a new entry
-	if uint64(len(i.MemoryMap)) < i.Size+entryLength {
-		return io.EOF
+	if uint64(len(i.MemoryMap)) < HeaderLength+i.Size+indexEntryLen {
+		switch {
+		case !i.UseMemoryMapping:
+			return io.EOF
+		// A shrunk index gets back the room it was opened with
+		case i.Size+indexEntryLen <= i.maxBytes:
+			if err := i.remap(HeaderLength + i.maxBytes); err != nil {
+				return err
+			}
+		// Without a growth strategy a full index stays full
+		case i.growth == nil:
+			return io.EOF
+		default:
+			if err := i.grow(); err != nil {
+				return err
+			}
+		}
 	}
 
-	// Write the offset value to the memory-mapped file at the current size position
-	enc.PutUint32(i.MemoryMap[i.Size:i.Size+offset], off)
+	// Offsets and store positions only ever grow, so anything else means the caller is corrupting the index
+	if i.Size > 0 {
+		last, err := i.read(-1)
+		if err != nil {
+			return err
+		}
+		if e.Off <= last.Off {
+			return ErrNonMonotonicOffset
+		}
+		if e.Pos <= last.Pos {
+			return ErrNonMonotonicPosition
+		}
+	}
 
-	// Write the position value immediately after offset in the memory-mapped file
-	enc.PutUint64(i.MemoryMap[i.Size+offset:i.Size+entryLength], pos)
+	// Write the entry to the memory-mapped file at the current size position, past the header
+	i.putEntry(HeaderLength+i.Size, e)
 
 	// Increase size counter for index
-	i.Size += uint64(entryLength)
+	i.Size += indexEntryLen
 
 	return nil
 }
 
-func (i *Index) Read(in int64) (out uint32, pos uint64, err error) {
+// WriteAt overwrites the existing entry at position n, counting from 0, and syncs it to disk.
+// The entry has to stay between its neighbours, just like Write requires for a new entry.
+// It returns io.EOF when there is no entry at n yet.
+func (i *Index) WriteAt(n uint64, e Entry) error {
+	pos := HeaderLength + n*indexEntryLen
+	if n*indexEntryLen+indexEntryLen > i.Size || pos+indexEntryLen > uint64(len(i.MemoryMap)) {
+		return io.EOF
+	}
+
+	// Offsets and store positions still have to grow from one entry to the next
+	if n > 0 {
+		prev, err := i.read(int64(n - 1))
+		if err != nil {
+			return err
+		}
+		if e.Off <= prev.Off {
+			return ErrNonMonotonicOffset
+		}
+		if e.Pos <= prev.Pos {
+			return ErrNonMonotonicPosition
+		}
+	}
+	if next, err := i.read(int64(n + 1)); err == nil {
+		if e.Off >= next.Off {
+			return ErrNonMonotonicOffset
+		}
+		if e.Pos >= next.Pos {
+			return ErrNonMonotonicPosition
+		}
+	}
+
+	i.putEntry(pos, e)
+
+	// A corrected entry has to survive a crash right away
+	return i.Flush(gommap.MS_SYNC)
+}
+
+// putEntry encodes e into the memory map at byte position pos
+func (i *Index) putEntry(pos uint64, e Entry) {
+	// The offset comes first, the position immediately after it, and the timestamp last
+	i.enc.PutUint32(i.MemoryMap[pos:pos+offsetFieldLen], e.Off)
+	i.enc.PutUint64(i.MemoryMap[pos+offsetFieldLen:pos+offsetFieldLen+positionFieldLen], e.Pos)
+	i.enc.PutUint64(i.MemoryMap[pos+offsetFieldLen+positionFieldLen:pos+indexEntryLen], e.CreatedAt)
+}
+
+func (i *Index) Read(in int64) (Entry, error) {
+	return i.ReadContext(context.Background(), in)
+}
+
+// ReadContext is Read, tracing the read as a child of the span in ctx
+func (i *Index) ReadContext(ctx context.Context, in int64) (Entry, error) {
+	if i.tracer == nil {
+		return i.read(in)
+	}
+	var entry Entry
+	err := i.traced(ctx, "read", func() uint64 { return uint64(entry.Off) }, func() (err error) {
+		entry, err = i.read(in)
+		return err
+	})
+	return entry, err
+}
+
+// read is Read without tracing
+func (i *Index) read(in int64) (Entry, error) {
+	var entryIdx uint32
+
 	// If the index size is 0, return EOF to indicate no entries can be read
 	if i.Size == 0 {
-		return 0, 0, io.EOF
+		return Entry{}, io.EOF
 	}
 
-	// If in is -1, calculate the index of the last entry. Otherwise, use in as the index
+	// If in is -1, calculate the number of the last entry. Otherwise, use in as the entry number
 	if in == -1 {
-		out = uint32((i.Size / entryLength) - 1)
+		entryIdx = uint32((i.Size / indexEntryLen) - 1)
 	} else {
-		out = uint32(in)
+		entryIdx = uint32(in)
 	}
 
-	// Calculate the byte position of the entry within the memory-mapped file
-	pos = uint64(out) * entryLength
+	// Byte position of the entry from the start of the entries, before the header is added, in 64 bits so it cannot overflow
+	bytePos := uint64(entryIdx) * indexEntryLen
 
 	// If the calculated position is beyond the size of the index, return EOF
-	if i.Size < pos+entryLength {
-		return 0, 0, io.EOF
+	if i.Size < bytePos+indexEntryLen {
+		return Entry{}, io.EOF
+	}
+
+	// Read the entry value and position from the memory-mapped file, past the header
+	bytePos += HeaderLength
+	return Entry{
+		Off:       i.enc.Uint32(i.MemoryMap[bytePos : bytePos+offsetFieldLen]),
+		Pos:       i.enc.Uint64(i.MemoryMap[bytePos+offsetFieldLen : bytePos+offsetFieldLen+positionFieldLen]),
+		CreatedAt: i.enc.Uint64(i.MemoryMap[bytePos+offsetFieldLen+positionFieldLen : bytePos+indexEntryLen]),
+	}, nil
+}
+
+// grow resizes the index file according to the growth strategy and remaps it
+func (i *Index) grow() error {
+	// Always make room for at least one more entry, whatever the strategy says
+	next := i.growth.NextSize(uint64(len(i.MemoryMap)))
+	if next < HeaderLength+i.Size+indexEntryLen {
+		next = HeaderLength + i.Size + indexEntryLen
+	}
+
+	return i.remap(next)
+}
+
+// EntryCount returns the number of complete entries in the index. A file cut off part way through
+// an entry has a size that is not a multiple of the entry length; the partial entry is not counted.
+func (i *Index) EntryCount() uint64 {
+	return i.Size / indexEntryLen
+}
+
+// IsFull reports whether another entry would no longer fit in the room the index was opened with.
+// The room does not have to be a multiple of the entry length, whatever is left over never holds an entry.
+func (i *Index) IsFull() bool {
+	return (i.EntryCount()+1)*indexEntryLen > i.maxBytes
+}
+
+// Remaining returns how many more entries fit in the index before it is full
+func (i *Index) Remaining() uint64 {
+	used := i.EntryCount() * indexEntryLen
+	if used >= i.maxBytes {
+		return 0
+	}
+	return (i.maxBytes - used) / indexEntryLen
+}
+
+// ShrinkToFit truncates the index file to the entries it holds and maps it again at that size.
+// The room given up is taken back on the next write.
+func (i *Index) ShrinkToFit() error {
+	if !i.UseMemoryMapping {
+		return i.File.Truncate(int64(HeaderLength + i.Size))
 	}
+	return i.remap(HeaderLength + i.Size)
+}
 
-	// Read the entry value and position from the memory-mapped file
-	out = enc.Uint32(i.MemoryMap[pos : pos+offset])
-	pos = enc.Uint64(i.MemoryMap[pos+offset : pos+entryLength])
+// remap resizes the index file to size bytes and maps it again
+func (i *Index) remap(size uint64) error {
+	// Make sure everything written so far is on disk before dropping the mapping
+	if err := i.MemoryMap.Sync(gommap.MS_SYNC); err != nil {
+		return err
+	}
+	if err := i.MemoryMap.UnsafeUnmap(); err != nil {
+		return err
+	}
 
-	return out, pos, nil
+	// Resize the file and map it again at its new size
+	if err := i.File.Truncate(int64(size)); err != nil {
+		return err
+	}
+	newMap, err := gommap.Map(i.File.Fd(), gommap.PROT_READ|gommap.PROT_WRITE, gommap.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	i.MemoryMap = newMap
+
+	return nil
+}
+
+// Find returns the entry for the relative offset off. Unlike Read, which looks entries up
+// by their position, Find binary searches the offsets, so it also works after records were
+// compacted out of the index and left gaps behind.
+func (i *Index) Find(off uint32) (Entry, error) {
+	// Offsets are strictly increasing, so the entries are sorted by offset
+	lo, hi := int64(0), int64(i.Size/indexEntryLen)-1
+	for lo <= hi {
+		mid := lo + (hi-lo)/2
+		entry, err := i.read(mid)
+		if err != nil {
+			return Entry{}, err
+		}
+
+		switch {
+		case entry.Off == off:
+			return entry, nil
+		case entry.Off < off:
+			lo = mid + 1
+		default:
+			hi = mid - 1
+		}
+	}
+
+	return Entry{}, io.EOF
+}
+
+// ReadByTimestamp returns the first entry created at or after ts, in Unix nanoseconds.
+// Entries are expected in timestamp order, which holds for entries written by a segment.
+// It returns io.EOF when every entry is older than ts.
+func (i *Index) ReadByTimestamp(ts uint64) (Entry, error) {
+	// Find the first entry that is not older than ts
+	lo, hi := int64(0), int64(i.Size/indexEntryLen)
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		entry, err := i.read(mid)
+		if err != nil {
+			return Entry{}, err
+		}
+		if entry.CreatedAt < ts {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	if lo == int64(i.Size/indexEntryLen) {
+		return Entry{}, io.EOF
+	}
+	return i.read(lo)
+}
+
+// Name returns the path of the index file, matching Store.Name
+func (i *Index) Name() string {
+	return i.File.Name()
+}
+
+// Sync commits the memory map and the index file to stable storage
+func (i *Index) Sync() error {
+	if err := i.Flush(gommap.MS_SYNC); err != nil {
+		return err
+	}
+
+	return i.File.Sync()
+}
+
+// Flush writes the memory map back to the index file without syncing the file itself.
+// MS_SYNC waits for the writeback to finish, MS_ASYNC only schedules it.
+func (i *Index) Flush(flags gommap.SyncFlags) error {
+	if i.MemoryMap == nil {
+		return nil
+	}
+
+	return i.MemoryMap.Sync(flags)
+}
+
+// Truncate keeps the first n entries of the index and discards the rest
+func (i *Index) Truncate(n uint64) error {
+	newSize := n * indexEntryLen
+	if newSize > i.Size {
+		return io.EOF
+	}
+
+	// Zero out the discarded entries so stale data never resurfaces
+	for b := HeaderLength + newSize; b < HeaderLength+i.Size; b++ {
+		i.MemoryMap[b] = 0
+	}
+	i.Size = newSize
+
+	return nil
 }
 
 func (i *Index) Close() error {
 	// Check if mmap exists and is valid before attempting to sync
 	if i.MemoryMap != nil {
-		if err := i.MemoryMap.Sync(gommap.MS_SYNC); err != nil {
+		// On Linux a synchronous msync already puts the entries on disk, so the file needs no separate sync
+		if err := i.Flush(gommap.MS_SYNC); err != nil {
 			return err
 		}
 	} else if len(i.MemoryMap) == 0 {
@@ -215,11 +562,8 @@ func (i *Index) Close() error {
 		return errors.New("something is very wrong index mmap should not be nil")
 	}
 
-	// Ensure file is synced and truncated properly
-	if err := i.File.Sync(); err != nil {
-		return err
-	}
-	if err := i.File.Truncate(int64(i.Size)); err != nil {
+	// Ensure file is truncated properly
+	if err := i.File.Truncate(int64(HeaderLength + i.Size)); err != nil {
 		return err
 	}
 
@@ -1,9 +1,19 @@
 package index
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"io"
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/tysonmote/gommap"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestNewIndexDefaultOptions(t *testing.T) {
@@ -47,6 +57,10 @@ func TestNewIndexWithMemoryMapping(t *testing.T) {
 	if !idx.UseMemoryMapping {
 		t.Error("Expected memory mapping to be enabled")
 	}
+
+	if idx.Name() != tmpFile.Name() {
+		t.Errorf("Expected index name %s, got %s", tmpFile.Name(), idx.Name())
+	}
 }
 
 func TestIndexReadWrite(t *testing.T) {
@@ -102,7 +116,7 @@ func TestIndexReadWrite(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Skip writing for the "Attempt to Read Beyond Written Data" test case
 			if tt.name != "Attempt to Read Beyond Written Data" {
-				err := i.Write(tt.wantOff, tt.wantPos)
+				err := i.Write(Entry{Off: tt.wantOff, Pos: tt.wantPos})
 				if err != nil {
 					t.Fatalf("Write() failed: %v", err)
 				}
@@ -110,7 +124,7 @@ func TestIndexReadWrite(t *testing.T) {
 
 			// Attempt to read back the entry/entries
 			// 'off - 1' converts offset to index for Read
-			out, pos, err := i.Read(int64(tt.wantOff - 1))
+			entry, err := i.Read(int64(tt.wantOff - 1))
 			if err != tt.wantErr {
 				t.Errorf("%s: Read() error = %v, wantErr %v", tt.name, err, tt.wantErr)
 				return
@@ -119,11 +133,11 @@ func TestIndexReadWrite(t *testing.T) {
 				// If an error is expected, further checks are skipped
 				return
 			}
-			if out != tt.wantOff {
-				t.Errorf("%s: Read() gotOff = %v, want %v", tt.name, out, tt.wantOff)
+			if entry.Off != tt.wantOff {
+				t.Errorf("%s: Read() gotOff = %v, want %v", tt.name, entry.Off, tt.wantOff)
 			}
-			if pos != tt.wantPos {
-				t.Errorf("%s: Read() gotPos = %v, want %v", tt.name, pos, tt.wantPos)
+			if entry.Pos != tt.wantPos {
+				t.Errorf("%s: Read() gotPos = %v, want %v", tt.name, entry.Pos, tt.wantPos)
 			}
 		})
 	}
@@ -150,3 +164,628 @@ func TestIndexClose(t *testing.T) {
 		t.Errorf("Failed to close Index: %v", err)
 	}
 }
+
+func TestNewIndexWithoutAutoCreate(t *testing.T) {
+	dir, err := os.MkdirTemp("", "index_autocreate_test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A missing file is reported along with its path
+	missing := dir + "/missing.index"
+	_, err = NewIndex(WithFilePath(missing), WithAutoCreate(false))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected a not exist error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), missing) {
+		t.Errorf("Expected the error to name %s, got %v", missing, err)
+	}
+
+	// An existing file is opened as usual
+	existing := dir + "/existing.index"
+	if err := os.WriteFile(existing, nil, 0644); err != nil {
+		t.Fatalf("Failed to create index file: %v", err)
+	}
+	i, err := NewIndex(WithFilePath(existing), WithAutoCreate(false))
+	if err != nil {
+		t.Fatalf("Failed to open existing index: %v", err)
+	}
+	if err := i.Close(); err != nil {
+		t.Errorf("Failed to close Index: %v", err)
+	}
+}
+
+func TestIndexWriteRejectsNonMonotonicEntries(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp("", "0.index")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+
+	// Clean up
+	defer os.Remove(tmpFile.Name())
+
+	i, err := NewIndex(WithFile(tmpFile), WithMemoryMapping(true))
+	if err != nil {
+		t.Fatalf("Failed to create index with memory mapping enabled: %v", err)
+	}
+
+	// Seed the index with a valid entry
+	if err := i.Write(Entry{Off: 1, Pos: 12}); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		off     uint32
+		pos     uint64
+		wantErr error
+	}{
+		{name: "Duplicate Offset", off: 1, pos: 24, wantErr: ErrNonMonotonicOffset},
+		{name: "Decreasing Offset", off: 0, pos: 24, wantErr: ErrNonMonotonicOffset},
+		{name: "Duplicate Position", off: 2, pos: 12, wantErr: ErrNonMonotonicPosition},
+		{name: "Decreasing Position", off: 2, pos: 0, wantErr: ErrNonMonotonicPosition},
+		{name: "Increasing Entry", off: 2, pos: 24, wantErr: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := i.Write(Entry{Off: tt.off, Pos: tt.pos}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: Write() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+		})
+	}
+
+	// Only the two valid entries should have been written
+	if i.Size != 2*indexEntryLen {
+		t.Errorf("Expected index size %d, got %d", 2*indexEntryLen, i.Size)
+	}
+}
+
+func TestIndexEncoding(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp("", "0.index")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+
+	// Clean up
+	defer os.Remove(tmpFile.Name())
+
+	i, err := NewIndex(WithFile(tmpFile), WithMemoryMapping(true), WithEncoding(binary.LittleEndian))
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	want := Entry{Off: 1, Pos: 12}
+	if err := i.Write(want); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	// Reading back with the same byte order round-trips
+	got, err := i.Read(0)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("Read() got %+v, want %+v", got, want)
+	}
+
+	// The raw bytes should be laid out little-endian
+	if off := binary.LittleEndian.Uint32(i.MemoryMap[HeaderLength : HeaderLength+offsetFieldLen]); off != want.Off {
+		t.Errorf("Expected little-endian offset %d, got %d", want.Off, off)
+	}
+	if off := binary.BigEndian.Uint32(i.MemoryMap[HeaderLength : HeaderLength+offsetFieldLen]); off == want.Off {
+		t.Errorf("Offset should not decode as big-endian")
+	}
+}
+
+func TestIndexGrowthStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy GrowthStrategy
+		wantSize int
+	}{
+		{name: "Linear Growth", strategy: LinearGrowth(indexEntryLen), wantSize: int(HeaderLength + 4*indexEntryLen)},
+		{name: "Exponential Growth", strategy: ExponentialGrowth(2), wantSize: int(2 * (HeaderLength + 3*indexEntryLen))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create a temporary file for testing
+			tmpFile, err := os.CreateTemp("", "0.index")
+			if err != nil {
+				t.Fatalf("Failed to create temporary file: %v", err)
+			}
+
+			// Clean up
+			defer os.Remove(tmpFile.Name())
+
+			// Start with room for exactly three entries
+			i, err := NewIndex(
+				WithFile(tmpFile),
+				WithMemoryMapping(true),
+				WithMaxIndexBytes(3*indexEntryLen),
+				WithGrowthStrategy(tt.strategy),
+			)
+			if err != nil {
+				t.Fatalf("Failed to create index: %v", err)
+			}
+
+			// The fourth entry should trigger growth rather than EOF
+			for n := uint32(0); n < 4; n++ {
+				if err := i.Write(Entry{Off: n, Pos: uint64(n+1) * 12}); err != nil {
+					t.Fatalf("Write() of entry %d failed: %v", n, err)
+				}
+			}
+
+			if len(i.MemoryMap) != tt.wantSize {
+				t.Errorf("Expected index to grow to %d bytes, got %d", tt.wantSize, len(i.MemoryMap))
+			}
+
+			// Entries written before the growth survive the remap
+			for n := uint32(0); n < 4; n++ {
+				entry, err := i.Read(int64(n))
+				if err != nil {
+					t.Fatalf("Read() of entry %d failed: %v", n, err)
+				}
+				if entry.Off != n || entry.Pos != uint64(n+1)*12 {
+					t.Errorf("Read() of entry %d got %+v", n, entry)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexMagicHeader(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp("", "0.index")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+
+	// Clean up
+	defer os.Remove(tmpFile.Name())
+
+	i, err := NewIndex(WithFilePath(tmpFile.Name()), WithMemoryMapping(true))
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if err := i.Write(Entry{Off: 0, Pos: 1}); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if err := i.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	// A new index starts with the magic header
+	data, err := os.ReadFile(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to read index file: %v", err)
+	}
+	if !bytes.Equal(data[:HeaderLength], magic[:]) {
+		t.Errorf("Expected magic header %x, got %x", magic, data[:HeaderLength])
+	}
+
+	// Reopening the index keeps its entries
+	i, err = NewIndex(WithFilePath(tmpFile.Name()), WithMemoryMapping(true))
+	if err != nil {
+		t.Fatalf("Failed to reopen index: %v", err)
+	}
+	if entry, err := i.Read(0); err != nil || entry != (Entry{Off: 0, Pos: 1}) {
+		t.Errorf("Read() after reopen got %+v, %v", entry, err)
+	}
+	if err := i.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	// Anything else, like a store file renamed to .index, is rejected
+	notAnIndex, err := os.CreateTemp("", "1.index")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+	defer os.Remove(notAnIndex.Name())
+	if _, err := notAnIndex.Write([]byte{0, 0, 0, 0, 0, 0, 0, 4, 't', 'e', 's', 't'}); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if _, err := NewIndex(WithFile(notAnIndex), WithMemoryMapping(true)); !errors.Is(err, ErrInvalidIndexMagic) {
+		t.Errorf("Expected %v, got %v", ErrInvalidIndexMagic, err)
+	}
+}
+
+func FuzzIndexRoundTrip(f *testing.F) {
+	// Every indexEntryLen bytes of input are decoded into one entry to write
+	f.Add([]byte{})
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	f.Add([]byte{0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 12, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 24, 0, 0, 0, 0, 0, 0, 0, 2})
+	f.Add([]byte{0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 24, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 12, 0, 0, 0, 0, 0, 0, 0, 1})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Create a temporary file for testing
+		tmpFile, err := os.CreateTemp("", "fuzz.*.index")
+		if err != nil {
+			t.Fatalf("Failed to create temporary file: %v", err)
+		}
+
+		// Clean up
+		defer os.Remove(tmpFile.Name())
+
+		i, err := NewIndex(WithFile(tmpFile), WithMemoryMapping(true))
+		if err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+		defer i.Close()
+
+		var written []Entry
+		for len(data) >= int(indexEntryLen) {
+			e := Entry{
+				Off:       binary.BigEndian.Uint32(data[:offsetFieldLen]),
+				Pos:       binary.BigEndian.Uint64(data[offsetFieldLen : offsetFieldLen+positionFieldLen]),
+				CreatedAt: binary.BigEndian.Uint64(data[offsetFieldLen+positionFieldLen : indexEntryLen]),
+			}
+			data = data[indexEntryLen:]
+
+			err := i.Write(e)
+			switch {
+			case err == nil:
+				written = append(written, e)
+			case errors.Is(err, io.EOF):
+				// The index is full, nothing more can be written
+			case errors.Is(err, ErrNonMonotonicOffset), errors.Is(err, ErrNonMonotonicPosition):
+				// Rejections are only valid when the entry really goes backwards
+				last := written[len(written)-1]
+				if e.Off > last.Off && e.Pos > last.Pos {
+					t.Fatalf("Write() rejected increasing entry %+v after %+v: %v", e, last, err)
+				}
+			default:
+				t.Fatalf("Write() failed: %v", err)
+			}
+		}
+
+		// Every accepted entry must read back unchanged
+		for n, want := range written {
+			got, err := i.Read(int64(n))
+			if err != nil {
+				t.Fatalf("Read() of entry %d failed: %v", n, err)
+			}
+			if got != want {
+				t.Errorf("Read() of entry %d got %+v, want %+v", n, got, want)
+			}
+		}
+	})
+}
+
+func TestIndexShrinkToFit(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp("", "0.index")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+
+	// Clean up
+	defer os.Remove(tmpFile.Name())
+
+	i, err := NewIndex(WithFile(tmpFile), WithMemoryMapping(true), WithMaxIndexBytes(indexEntryLen*4))
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	defer i.Close()
+
+	for n := uint32(0); n < 2; n++ {
+		if err := i.Write(Entry{Off: n, Pos: uint64(n) + 1}); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+
+	// The file and the mapping only cover the header and the entries written
+	if err := i.ShrinkToFit(); err != nil {
+		t.Fatalf("ShrinkToFit() failed: %v", err)
+	}
+	fi, err := i.File.Stat()
+	if err != nil {
+		t.Fatalf("Failed to stat index file: %v", err)
+	}
+	if want := int64(HeaderLength + 2*indexEntryLen); fi.Size() != want || int64(len(i.MemoryMap)) != want {
+		t.Errorf("Expected file and mapping of %d bytes, got %d and %d", want, fi.Size(), len(i.MemoryMap))
+	}
+
+	// Writing again takes back the room the index was opened with, and no more
+	for n := uint32(2); n < 4; n++ {
+		if err := i.Write(Entry{Off: n, Pos: uint64(n) + 1}); err != nil {
+			t.Fatalf("Write() after shrinking failed: %v", err)
+		}
+	}
+	if err := i.Write(Entry{Off: 4, Pos: 5}); err != io.EOF {
+		t.Errorf("Expected io.EOF once the index is full, got %v", err)
+	}
+
+	for n := int64(0); n < 4; n++ {
+		entry, err := i.Read(n)
+		if err != nil {
+			t.Fatalf("Read(%d) failed: %v", n, err)
+		}
+		if entry.Off != uint32(n) || entry.Pos != uint64(n)+1 {
+			t.Errorf("Read(%d) = %+v", n, entry)
+		}
+	}
+}
+
+func TestIndexFlush(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp("", "0.index")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+
+	// Clean up
+	defer os.Remove(tmpFile.Name())
+
+	i, err := NewIndex(WithFile(tmpFile), WithMemoryMapping(true))
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	defer i.Close()
+
+	if err := i.Write(Entry{Off: 0, Pos: 1}); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	// Both durability levels are accepted
+	for _, flags := range []gommap.SyncFlags{gommap.MS_ASYNC, gommap.MS_SYNC} {
+		if err := i.Flush(flags); err != nil {
+			t.Fatalf("Flush(%d) failed: %v", flags, err)
+		}
+	}
+
+	// After a synchronous flush the entry can be read straight from the file
+	data := make([]byte, indexEntryLen)
+	if _, err := tmpFile.ReadAt(data, HeaderLength); err != nil {
+		t.Fatalf("Failed to read index file: %v", err)
+	}
+	if off, pos := binary.BigEndian.Uint32(data[:offsetFieldLen]), binary.BigEndian.Uint64(data[offsetFieldLen:]); off != 0 || pos != 1 {
+		t.Errorf("Expected entry {0 1} on disk, got {%d %d}", off, pos)
+	}
+}
+
+func TestIndexReadByTimestamp(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp("", "0.index")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+
+	// Clean up
+	defer os.Remove(tmpFile.Name())
+
+	i, err := NewIndex(WithFile(tmpFile), WithMemoryMapping(true))
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	defer i.Close()
+
+	// No entries means nothing is found
+	if _, err := i.ReadByTimestamp(0); err != io.EOF {
+		t.Errorf("Expected io.EOF on an empty index, got %v", err)
+	}
+
+	// Two entries share a timestamp
+	for n, ts := range []uint64{100, 200, 200, 300} {
+		if err := i.Write(Entry{Off: uint32(n), Pos: uint64(n) + 1, CreatedAt: ts}); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		ts      uint64
+		wantOff uint32
+		wantErr error
+	}{
+		{ts: 0, wantOff: 0},
+		{ts: 100, wantOff: 0},
+		{ts: 150, wantOff: 1},
+		{ts: 200, wantOff: 1},
+		{ts: 300, wantOff: 3},
+		{ts: 301, wantErr: io.EOF},
+	}
+	for _, tt := range tests {
+		entry, err := i.ReadByTimestamp(tt.ts)
+		if err != tt.wantErr {
+			t.Errorf("ReadByTimestamp(%d) error = %v, want %v", tt.ts, err, tt.wantErr)
+			continue
+		}
+		if err == nil && entry.Off != tt.wantOff {
+			t.Errorf("ReadByTimestamp(%d) = offset %d, want %d", tt.ts, entry.Off, tt.wantOff)
+		}
+	}
+}
+
+func TestIndexWriteAt(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp("", "0.index")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+
+	// Clean up
+	defer os.Remove(tmpFile.Name())
+
+	i, err := NewIndex(WithFile(tmpFile), WithMemoryMapping(true))
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	defer i.Close()
+
+	for n := uint32(0); n < 5; n++ {
+		if err := i.Write(Entry{Off: n, Pos: uint64(n) * 100}); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+
+	// Patch the third entry's position
+	if err := i.WriteAt(2, Entry{Off: 2, Pos: 250, CreatedAt: 7}); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	for n := int64(0); n < 5; n++ {
+		want := Entry{Off: uint32(n), Pos: uint64(n) * 100}
+		if n == 2 {
+			want = Entry{Off: 2, Pos: 250, CreatedAt: 7}
+		}
+		got, err := i.Read(n)
+		if err != nil {
+			t.Fatalf("Read(%d) failed: %v", n, err)
+		}
+		if got != want {
+			t.Errorf("Read(%d) = %+v, want %+v", n, got, want)
+		}
+	}
+
+	// The patch cannot break the ordering or go past the last entry
+	if err := i.WriteAt(2, Entry{Off: 2, Pos: 300}); !errors.Is(err, ErrNonMonotonicPosition) {
+		t.Errorf("Expected %v, got %v", ErrNonMonotonicPosition, err)
+	}
+	if err := i.WriteAt(2, Entry{Off: 1, Pos: 250}); !errors.Is(err, ErrNonMonotonicOffset) {
+		t.Errorf("Expected %v, got %v", ErrNonMonotonicOffset, err)
+	}
+	if err := i.WriteAt(5, Entry{Off: 5, Pos: 500}); err != io.EOF {
+		t.Errorf("Expected io.EOF past the last entry, got %v", err)
+	}
+}
+
+func TestIndexTracing(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "tracing.index")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	i, err := NewIndex(WithFile(tmpFile), WithMemoryMapping(true), WithTracer(tp.Tracer(TracerName)))
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	defer i.Close()
+
+	if err := i.Write(Entry{Off: 3, Pos: 8}); err != nil {
+		t.Fatalf("Failed to write entry: %v", err)
+	}
+	if _, err := i.Read(0); err != nil {
+		t.Fatalf("Failed to read entry: %v", err)
+	}
+
+	// One span per call, carrying the offset and the size after it
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	for n, name := range []string{"index.write", "index.read"} {
+		if spans[n].Name != name {
+			t.Errorf("Expected span %q, got %q", name, spans[n].Name)
+		}
+		attrs := attribute.NewSet(spans[n].Attributes...)
+		if v, _ := attrs.Value("index.offset"); v.AsInt64() != 3 {
+			t.Errorf("Expected offset 3 on %s, got %d", name, v.AsInt64())
+		}
+		if v, _ := attrs.Value("index.size"); v.AsInt64() != int64(i.Size) {
+			t.Errorf("Expected size %d on %s, got %d", i.Size, name, v.AsInt64())
+		}
+	}
+
+	// A failed read is marked as an error
+	exporter.Reset()
+	if _, err := i.Read(5); err == nil {
+		t.Fatal("Expected reading a missing entry to fail")
+	}
+	spans = exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Status.Code != otelcodes.Error {
+		t.Errorf("Expected one errored span, got %+v", spans)
+	}
+}
+
+func TestIndexEntryCount(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "0.index")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	// Room for two entries and part of a third, which never holds one
+	i, err := NewIndex(WithFile(tmpFile), WithMemoryMapping(true), WithMaxIndexBytes(indexEntryLen*2+indexEntryLen/2))
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	defer i.Close()
+
+	for n := uint32(0); n < 2; n++ {
+		if i.IsFull() {
+			t.Fatalf("Expected room for entry %d", n)
+		}
+		if err := i.Write(Entry{Off: n, Pos: uint64(n) + 1}); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+		if got := i.EntryCount(); got != uint64(n)+1 {
+			t.Errorf("Expected %d entries, got %d", n+1, got)
+		}
+	}
+
+	// The leftover room does not count, the index is full and says so
+	if !i.IsFull() {
+		t.Error("Expected the index to be full with two entries")
+	}
+	if err := i.Write(Entry{Off: 2, Pos: 3}); err != io.EOF {
+		t.Errorf("Expected io.EOF writing past the room, got %v", err)
+	}
+
+	// A partially written entry is not counted
+	i.Size += indexEntryLen / 2
+	if got := i.EntryCount(); got != 2 {
+		t.Errorf("Expected 2 complete entries, got %d", got)
+	}
+}
+
+func TestIndexValidate(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "0.index")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	i, err := NewIndex(WithFile(tmpFile), WithMemoryMapping(true))
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	defer i.Close()
+
+	if err := i.Validate(0); err != nil {
+		t.Errorf("Expected an empty index to be valid, got %v", err)
+	}
+	for n := uint32(0); n < 5; n++ {
+		if err := i.Write(Entry{Off: n, Pos: uint64(n) * 100}); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+	if err := i.Validate(500); err != nil {
+		t.Errorf("Expected a valid index, got %v", err)
+	}
+
+	// Go around WriteAt to corrupt the third entry, which breaks it and the one after it
+	i.putEntry(HeaderLength+2*indexEntryLen, Entry{Off: 0, Pos: 900})
+
+	err = i.Validate(500)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected a *ValidationError, got %v", err)
+	}
+	want := []error{ErrNonMonotonicOffset, ErrPositionOutOfBounds, ErrNonMonotonicPosition}
+	if len(invalid.Violations) != len(want) {
+		t.Fatalf("Expected %d violations, got %v", len(want), invalid.Violations)
+	}
+	for n, violation := range invalid.Violations {
+		if !errors.Is(violation, want[n]) {
+			t.Errorf("Violation %d: expected %v, got %v", n, want[n], violation)
+		}
+	}
+}
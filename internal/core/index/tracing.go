@@ -0,0 +1,40 @@
+package index
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name to get the index's tracer from a provider under, e.g. otel.Tracer(index.TracerName)
+const TracerName = "cute-logger/index"
+
+// Traces every Write and Read with a span from tracer, recording the operation, the offset and the index size.
+// Without it, the default, the index does no tracing work at all.
+func WithTracer(tracer trace.Tracer) IndexOptions {
+	return func(opts *Options) {
+		opts.Tracer = tracer
+	}
+}
+
+// traced runs fn inside a span named after op, a child of the span in ctx if there is one.
+// Callers skip it when the index has no tracer.
+// off is the offset the operation is about, as known once fn has run.
+func (i *Index) traced(ctx context.Context, op string, off func() uint64, fn func() error) error {
+	_, span := i.tracer.Start(ctx, "index."+op)
+	defer span.End()
+
+	err := fn()
+	span.SetAttributes(
+		attribute.String("index.operation", op),
+		attribute.Int64("index.offset", int64(off())),
+		attribute.Int64("index.size", int64(i.Size)),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	return err
+}
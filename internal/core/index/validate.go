@@ -0,0 +1,59 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPositionOutOfBounds is returned by Validate for an entry pointing past the end of the store
+var ErrPositionOutOfBounds = errors.New("index position is past the end of the store")
+
+// ValidationError lists every problem Validate found in an index, not just the first.
+// Each violation wraps ErrNonMonotonicOffset, ErrNonMonotonicPosition or ErrPositionOutOfBounds.
+type ValidationError struct {
+	Violations []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for n, violation := range e.Violations {
+		msgs[n] = violation.Error()
+	}
+	return fmt.Sprintf("index has %d violations: %s", len(e.Violations), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the violations, so errors.Is finds them on toolchains that support multiple wrapped errors
+func (e *ValidationError) Unwrap() []error {
+	return e.Violations
+}
+
+// Validate checks every entry against the one before it and against the size of the store it points into:
+// offsets and positions have to be strictly increasing and no position may be at or past storeSize.
+// It returns a *ValidationError holding all the violations found, or nil for a sound index.
+func (i *Index) Validate(storeSize uint64) error {
+	var violations []error
+	var prev Entry
+	for n := uint64(0); n < i.EntryCount(); n++ {
+		entry, err := i.read(int64(n))
+		if err != nil {
+			return err
+		}
+
+		if n > 0 && entry.Off <= prev.Off {
+			violations = append(violations, fmt.Errorf("entry %d: offset %d follows %d: %w", n, entry.Off, prev.Off, ErrNonMonotonicOffset))
+		}
+		if n > 0 && entry.Pos <= prev.Pos {
+			violations = append(violations, fmt.Errorf("entry %d: position %d follows %d: %w", n, entry.Pos, prev.Pos, ErrNonMonotonicPosition))
+		}
+		if entry.Pos >= storeSize {
+			violations = append(violations, fmt.Errorf("entry %d: position %d in a store of %d bytes: %w", n, entry.Pos, storeSize, ErrPositionOutOfBounds))
+		}
+		prev = entry
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package segment
+
+import (
+	"container/list"
+	"sync"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"google.golang.org/protobuf/proto"
+)
+
+// recordCache is a least recently used cache of decoded records, keyed by offset.
+// A nil cache is valid and caches nothing, so segments without WithReadCache pay nothing for it.
+type recordCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+
+	// Most recently used entries at the front
+	order   *list.List
+	entries map[uint64]*list.Element
+}
+
+type cacheEntry struct {
+	offset uint64
+	record *api.Record
+}
+
+func newRecordCache(maxEntries int) *recordCache {
+	if maxEntries <= 0 {
+		return nil
+	}
+
+	return &recordCache{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[uint64]*list.Element, maxEntries),
+	}
+}
+
+// get returns a copy of the record cached for offset, so callers are free to modify it
+func (c *recordCache) get(offset uint64) (*api.Record, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.entries[offset]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return proto.Clone(elem.Value.(*cacheEntry).record).(*api.Record), true
+}
+
+// put caches a copy of record, evicting the least recently used entry when the cache is full
+func (c *recordCache) put(offset uint64, record *api.Record) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	record = proto.Clone(record).(*api.Record)
+	if elem, ok := c.entries[offset]; ok {
+		elem.Value.(*cacheEntry).record = record
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[offset] = c.order.PushFront(&cacheEntry{offset: offset, record: record})
+	if c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).offset)
+	}
+}
+
+// remove drops the entry for offset, if any
+func (c *recordCache) remove(offset uint64) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.entries[offset]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, offset)
+	}
+}
+
+// clear drops every entry
+func (c *recordCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.order.Init()
+	c.entries = make(map[uint64]*list.Element, c.maxEntries)
+}
@@ -0,0 +1,189 @@
+package segment
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/BryceDouglasJames/Cute-Logger/internal/core/index"
+)
+
+// Kinds of journal entries
+const (
+	journalIntent byte = 1 // A record is about to be written to the store and index
+	journalCommit byte = 2 // The record with the same offset made it into both
+)
+
+// Journal entries are length prefixed like store entries: an 8 byte big-endian length, then the payload.
+// An intent carries its kind, the record's offset, the store size before the write, the append time and the record.
+// A commit carries only its kind and the offset.
+const (
+	journalLenWidth    = 8
+	journalIntentWidth = 1 + 8 + 8 + 8
+	journalCommitWidth = 1 + 8
+)
+
+// Once a journal holding only committed entries grows past this size it is cleared
+const journalCompactBytes = 1 << 20
+
+// journalIntentEntry is a write the journal recorded before it was made
+type journalIntentEntry struct {
+	offset    uint64
+	storeSize uint64
+	createdAt uint64
+	record    []byte
+}
+
+// journal records each append before it is made, so one that was cut off half way can be finished on open
+type journal struct {
+	file *os.File
+	size uint64
+}
+
+func openJournal(path string, perm os.FileMode) (*journal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &journal{file: file, size: uint64(fi.Size())}, nil
+}
+
+// begin durably records that the record p is about to be appended at offset
+func (j *journal) begin(offset, storeSize, createdAt uint64, p []byte) error {
+	payload := make([]byte, journalIntentWidth+len(p))
+	payload[0] = journalIntent
+	binary.BigEndian.PutUint64(payload[1:9], offset)
+	binary.BigEndian.PutUint64(payload[9:17], storeSize)
+	binary.BigEndian.PutUint64(payload[17:25], createdAt)
+	copy(payload[journalIntentWidth:], p)
+
+	if err := j.write(payload); err != nil {
+		return err
+	}
+
+	// The intent has to be on disk before the store is touched
+	return j.file.Sync()
+}
+
+// commit marks the append at offset as complete.
+// It is not synced: if the marker is lost, replaying the intent writes the same record again.
+func (j *journal) commit(offset uint64) error {
+	var payload [journalCommitWidth]byte
+	payload[0] = journalCommit
+	binary.BigEndian.PutUint64(payload[1:], offset)
+
+	if err := j.write(payload[:]); err != nil {
+		return err
+	}
+
+	// Everything in the journal is committed now, so it can be cleared once it gets large
+	if j.size > journalCompactBytes {
+		return j.reset()
+	}
+	return nil
+}
+
+func (j *journal) write(payload []byte) error {
+	frame := make([]byte, journalLenWidth+len(payload))
+	binary.BigEndian.PutUint64(frame, uint64(len(payload)))
+	copy(frame[journalLenWidth:], payload)
+
+	n, err := j.file.Write(frame)
+	j.size += uint64(n)
+	return err
+}
+
+// pending returns the last intent in the journal if it was never committed, or nil.
+// Appends run one at a time, so only the last intent can be left without a commit.
+func (j *journal) pending() (*journalIntentEntry, error) {
+	data, err := io.ReadAll(io.NewSectionReader(j.file, 0, int64(j.size)))
+	if err != nil {
+		return nil, err
+	}
+
+	var last *journalIntentEntry
+	for len(data) >= journalLenWidth {
+		n := binary.BigEndian.Uint64(data[:journalLenWidth])
+		if n == 0 || n > uint64(len(data)-journalLenWidth) {
+			// A frame cut off while it was written; nothing was appended for it yet
+			break
+		}
+		payload := data[journalLenWidth : journalLenWidth+n]
+		data = data[journalLenWidth+n:]
+
+		switch {
+		case payload[0] == journalIntent && len(payload) >= journalIntentWidth:
+			last = &journalIntentEntry{
+				offset:    binary.BigEndian.Uint64(payload[1:9]),
+				storeSize: binary.BigEndian.Uint64(payload[9:17]),
+				createdAt: binary.BigEndian.Uint64(payload[17:25]),
+				record:    payload[journalIntentWidth:],
+			}
+		case payload[0] == journalCommit && len(payload) == journalCommitWidth:
+			if last != nil && last.offset == binary.BigEndian.Uint64(payload[1:]) {
+				last = nil
+			}
+		default:
+			return nil, errors.New("invalid journal entry")
+		}
+	}
+
+	return last, nil
+}
+
+// reset clears the journal
+func (j *journal) reset() error {
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	j.size = 0
+	return nil
+}
+
+func (j *journal) Close() error {
+	return j.file.Close()
+}
+
+// replayJournal finishes an append the journal recorded but never committed.
+// Whatever the interrupted write left in the store and index is thrown away and the record is written again.
+func (s *Segment) replayJournal() error {
+	intent, err := s.journal.pending()
+	if err != nil || intent == nil {
+		return err
+	}
+	if intent.offset < s.baseOffset {
+		return errors.New("journal entry is below the segment's base offset")
+	}
+	rel := intent.offset - s.baseOffset
+
+	if err := s.store.Truncate(intent.storeSize); err != nil {
+		return err
+	}
+	if err := s.index.Truncate(rel); err != nil {
+		return err
+	}
+
+	_, pos, err := s.store.Append(intent.record)
+	if err != nil {
+		return err
+	}
+	if err := s.index.Write(index.Entry{
+		Off:       uint32(rel),
+		Pos:       pos,
+		CreatedAt: intent.createdAt,
+	}); err != nil {
+		return err
+	}
+	s.nextOffset = intent.offset + 1
+
+	// The journal is reset once this returns, so the rewritten record has to be on disk first
+	return s.Sync()
+}
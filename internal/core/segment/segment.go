@@ -1,39 +1,129 @@
 package segment
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"path"
+	"sync"
+	"time"
 
 	api "github.com/BryceDouglasJames/Cute-Logger/api"
 	"github.com/BryceDouglasJames/Cute-Logger/internal/core/index"
 	"github.com/BryceDouglasJames/Cute-Logger/internal/core/store"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/protobuf/proto"
 )
 
+// ErrSegmentFull is returned by AppendBatch when the segment fills up before the whole batch is written
+var ErrSegmentFull = errors.New("segment is full")
+
+// ErrOffsetNotFound is returned when the segment holds no record at Offset, because it is outside the segment
+// or was removed by compaction. It unwraps to io.EOF, which is what the index reports for a missing entry.
+type ErrOffsetNotFound struct {
+	Offset uint64
+}
+
+func (e *ErrOffsetNotFound) Error() string {
+	return fmt.Sprintf("no record at offset %d in the segment", e.Offset)
+}
+
+func (e *ErrOffsetNotFound) Unwrap() error {
+	return io.EOF
+}
+
+// ErrSegmentOffsetConflict is returned by NewSegment when the files already at the segment's path hold records
+// that belong to a different base offset than the one it was asked to open
+var ErrSegmentOffsetConflict = errors.New("existing segment files conflict with the initial offset")
+
 type Segment struct {
+	// Guards the index and nextOffset, so records can be read while another goroutine appends
+	mutex sync.RWMutex
+
 	store      *store.Store
 	index      *index.Index
 	baseOffset uint64
 	nextOffset uint64
 
 	config *Options
+
+	// Write-ahead journal of appends, nil unless the segment was opened WithJournal
+	journal *journal
+
+	// Recently read records, nil unless the segment was opened WithReadCache
+	cache *recordCache
+
+	// Bytes of a record AppendFromReader has only partly read so far, guarded by readerMutex
+	readerMutex sync.Mutex
+	pending     []byte
+
+	// Set once Close has run, so closing again does nothing
+	closed bool
 }
 
 type Options struct {
-	FilePath      string
-	MaxStoreBytes uint64
-	MaxIndexBytes uint64
-	InitialOffset uint64
+	FilePath       string
+	MaxStoreBytes  uint64
+	MaxIndexBytes  uint64
+	InitialOffset  uint64
+	RecordEncoding RecordEncoding
+
+	// File extensions of the segment's store and index, including the leading dot
+	StoreExtension string
+	IndexExtension string
+
+	// Record every append in a journal first, so a crash between the store and index writes can be recovered
+	Journal bool
+
+	// Number of decoded records kept in memory for repeated reads, zero disables the cache
+	ReadCacheEntries int
+
+	// Compression of a newly created store, a reopened one keeps what its metadata sidecar records
+	Compression store.CompressionAlgo
+
+	// Permissions of the files the segment creates, directories get the matching execute bits
+	FilePerm os.FileMode
+
+	// Size of the buffer in front of the store file, zero keeps the store's default of 4096 bytes
+	StoreBufferSize uint64
+
+	// Provides the tracers of the segment's store and index, nil leaves them untraced
+	TracerProvider trace.TracerProvider
+
+	// Tells the time a new segment's store records as its creation time, nil uses time.Now
+	Now func() time.Time
+
+	// Called between the store and index writes of an append, set by tests to simulate a crash there
+	afterStoreAppend func()
 }
 
+// RecordEncoding selects how records are serialized in the store.
+// Every record in a segment uses the same encoding; mixing encodings within a segment is not supported.
+type RecordEncoding int
+
+const (
+	// ProtobufEncoding stores records with proto.Marshal. This is the default.
+	ProtobufEncoding RecordEncoding = iota
+
+	// JSONEncoding stores records as api.RecordJSON documents.
+	JSONEncoding
+)
+
 // Default settings for segment
 func DefaultOptions() *Options {
 	return &Options{
 		FilePath:      "./default.txt", // destination of temp generate
 		MaxIndexBytes: 1024,
 		MaxStoreBytes: 1024,
+
+		StoreExtension: ".store",
+		IndexExtension: ".index",
+
+		FilePerm: 0644,
 	}
 }
 
@@ -54,13 +144,107 @@ func WithMaxStoreBytes(maxBytes uint64) SegmentOptions {
 	}
 }
 
-// WithMaxIndexBytes sets the maximum index bytes in the Options.
+// WithMaxIndexBytes sets how large the segment's index file may grow, which caps the number of records
+// the segment holds at maxBytes divided by the size of an index entry. The segment is full once either
+// this or WithMaxStoreBytes is reached.
 func WithMaxIndexBytes(maxBytes uint64) SegmentOptions {
 	return func(opts *Options) {
 		opts.MaxIndexBytes = maxBytes
 	}
 }
 
+// WithStoreBufferSize sets the size of the buffer appends go through on their way to the store file,
+// instead of the store's default of 4096 bytes. Segments taking large records benefit from a buffer that
+// holds a whole record, since each append is flushed before it returns either way.
+func WithStoreBufferSize(n uint64) SegmentOptions {
+	return func(opts *Options) {
+		opts.StoreBufferSize = n
+	}
+}
+
+// WithRecordEncoding sets how records are serialized in the Options.
+func WithRecordEncoding(enc RecordEncoding) SegmentOptions {
+	return func(opts *Options) {
+		opts.RecordEncoding = enc
+	}
+}
+
+// WithStoreExtension sets the file extension of the segment's store, e.g. ".cstore".
+func WithStoreExtension(ext string) SegmentOptions {
+	return func(opts *Options) {
+		opts.StoreExtension = ext
+	}
+}
+
+// WithIndexExtension sets the file extension of the segment's index, e.g. ".cindex".
+func WithIndexExtension(ext string) SegmentOptions {
+	return func(opts *Options) {
+		opts.IndexExtension = ext
+	}
+}
+
+// WithJournal records every append in a <baseOffset>.journal file before it is made.
+// Opening the segment finishes any append a crash cut off between the store and index writes.
+func WithJournal(enabled bool) SegmentOptions {
+	return func(opts *Options) {
+		opts.Journal = enabled
+	}
+}
+
+// WithReadCache keeps up to maxEntries recently read records in memory, so reading them again skips
+// the index, the store and decoding. Zero or less disables the cache.
+func WithReadCache(maxEntries int) SegmentOptions {
+	return func(opts *Options) {
+		opts.ReadCacheEntries = maxEntries
+	}
+}
+
+// WithSegmentCompression compresses the records of a newly created segment with algo.
+// The algorithm is recorded in the store's metadata sidecar, so a reopened segment always reads its
+// records back with the one it was created with, whatever is passed here.
+func WithSegmentCompression(algo store.CompressionAlgo) SegmentOptions {
+	return func(opts *Options) {
+		opts.Compression = algo
+	}
+}
+
+// WithFilePerm creates the segment's store, index and journal files with perm instead of the default 0644,
+// and the scratch directories of Compact and Merge with the matching execute bits. The umask still applies.
+func WithFilePerm(perm os.FileMode) SegmentOptions {
+	return func(opts *Options) {
+		opts.FilePerm = perm
+	}
+}
+
+// WithTracingProvider traces the writes and reads of the segment's store and index with tracers from tp,
+// see store.WithTracer and index.WithTracer. AppendContext and ReadContext make the spans children of the caller's.
+func WithTracingProvider(tp trace.TracerProvider) SegmentOptions {
+	return func(opts *Options) {
+		opts.TracerProvider = tp
+	}
+}
+
+// WithNow records the creation time of a new segment with now instead of time.Now, see store.WithNow.
+// A log passes its clock, so retention ages segments by the same clock it compares against.
+func WithNow(now func() time.Time) SegmentOptions {
+	return func(opts *Options) {
+		opts.Now = now
+	}
+}
+
+// withAfterStoreAppend calls fn between the store and index writes of every append
+func withAfterStoreAppend(fn func()) SegmentOptions {
+	return func(opts *Options) {
+		opts.afterStoreAppend = fn
+	}
+}
+
+// DirPerm returns the permissions for a directory holding files created with perm,
+// which can be searched by everyone who can read them, e.g. 0750 for 0640
+func DirPerm(perm os.FileMode) os.FileMode {
+	return perm | (perm&0444)>>2
+}
+
 // WithInitialOffset sets the initial offset in the Options.
 func WithInitialOffset(offset uint64) SegmentOptions {
 	return func(opts *Options) {
@@ -85,77 +269,193 @@ func NewSegment(optFns ...SegmentOptions) (*Segment, error) {
 	newSegment := &Segment{
 		baseOffset: opts.InitialOffset,
 		config:     opts,
+		cache:      newRecordCache(opts.ReadCacheEntries),
 	}
 
-	// Construct the file path for the store and create/open the file
-	storePath := path.Join(opts.FilePath, fmt.Sprintf("%d%s", opts.InitialOffset, ".store"))
-	storeFile, err := os.OpenFile(storePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
-	if err != nil {
-		return nil, err
-	}
-
-	// Initialize the store with the opened file
-	if newSegment.store, err = store.NewStore(
-		store.WithFile(storeFile),
-	); err != nil {
+	// Pick up files written before segment names were zero-padded
+	if err := renameLegacyFiles(opts); err != nil {
 		return nil, err
 	}
 
 	// Construct the file path for the index and create/open the file
-	indexPath := path.Join(opts.FilePath, fmt.Sprintf("%d%s", opts.InitialOffset, ".index"))
+	indexPath := opts.indexPath(opts.FilePath, opts.InitialOffset)
 	indexFile, err := os.OpenFile(
 		indexPath,
 		os.O_RDWR|os.O_CREATE,
-		0644,
+		opts.FilePerm,
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	// Initialize the index with the opened file and configuration options
-	if newSegment.index, err = index.NewIndex(
+	indexOpts := []index.IndexOptions{
 		index.WithFile(indexFile),
 		index.WithMaxIndexBytes(opts.MaxIndexBytes),
 		index.WithMemoryMapping(true),
-	); err != nil {
+	}
+	if opts.TracerProvider != nil {
+		indexOpts = append(indexOpts, index.WithTracer(opts.TracerProvider.Tracer(index.TracerName)))
+	}
+	if newSegment.index, err = index.NewIndex(indexOpts...); err != nil {
+		return nil, err
+	}
+
+	// Construct the file path for the store and create/open the file
+	storePath := opts.storePath(opts.FilePath, opts.InitialOffset)
+	storeFile, err := os.OpenFile(storePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, opts.FilePerm)
+	if err != nil {
+		newSegment.index.Close()
+		return nil, err
+	}
+
+	// Initialize the store with the opened file, dropping whatever a crash left of an unfinished append
+	storeOpts := []store.StoreOptions{
+		store.WithFile(storeFile),
+		store.WithCompression(opts.Compression),
+		store.WithFilePerm(opts.FilePerm),
+	}
+
+	// Everything up to the last indexed record was appended in full, only what follows it can be torn
+	if last, err := newSegment.index.Read(-1); err == nil {
+		storeOpts = append(storeOpts, store.WithTailStart(last.Pos))
+	}
+	if opts.StoreBufferSize > 0 {
+		storeOpts = append(storeOpts, store.WithBufferSize(opts.StoreBufferSize))
+	}
+	if opts.Now != nil {
+		storeOpts = append(storeOpts, store.WithNow(opts.Now))
+	}
+	if opts.TracerProvider != nil {
+		storeOpts = append(storeOpts, store.WithTracer(opts.TracerProvider.Tracer(store.TracerName)))
+	}
+	var truncated int64
+	if newSegment.store, truncated, err = store.OpenStore(storeOpts...); err != nil {
+		newSegment.index.Close()
+		return nil, err
+	}
+	if truncated > 0 {
+		log.Printf("warning: dropped %d bytes of a partially written entry from %s", truncated, storePath)
+	}
+
+	// Files left behind under this name must really belong to this base offset
+	if err := newSegment.checkBaseOffset(); err != nil {
+		newSegment.index.Close()
+		newSegment.store.Close()
 		return nil, err
 	}
 
 	// Determine the next offset based on the last entry in the index, if any
-	if off, _, err := newSegment.index.Read(-1); err != nil {
+	if last, err := newSegment.index.Read(-1); err != nil {
 		newSegment.nextOffset = newSegment.baseOffset
 	} else {
-		newSegment.nextOffset = newSegment.baseOffset + uint64(off) + 1
+		newSegment.nextOffset = newSegment.baseOffset + uint64(last.Off) + 1
+	}
+	newSegment.keepHighWaterMark()
+
+	// Finish any append the journal holds that never completed, then start it afresh
+	if opts.Journal {
+		if newSegment.journal, err = openJournal(opts.journalPath(opts.FilePath, opts.InitialOffset), opts.FilePerm); err != nil {
+			return nil, err
+		}
+		if err := newSegment.replayJournal(); err != nil {
+			return nil, err
+		}
+		if err := newSegment.journal.reset(); err != nil {
+			return nil, err
+		}
 	}
 
 	return newSegment, nil
 }
 
+// checkBaseOffset compares the offset stored in the last indexed record with the one its index entry implies
+// for the segment's base offset. Empty files have nothing to conflict with. Neither has a last entry that does
+// not point at a readable record: that is a corrupt index, which ValidateIndex finds and Repair fixes.
+func (s *Segment) checkBaseOffset() error {
+	last, err := s.index.Read(-1)
+	if err != nil {
+		return nil
+	}
+
+	p, err := s.store.Read(last.Pos)
+	if err != nil {
+		return nil
+	}
+	record, err := s.unmarshal(p)
+	if err != nil {
+		return nil
+	}
+	if want := s.baseOffset + uint64(last.Off); record.Offset != want {
+		return fmt.Errorf("%w: last record has offset %d, expected %d for base offset %d",
+			ErrSegmentOffsetConflict, record.Offset, want, s.baseOffset)
+	}
+
+	return nil
+}
+
 func (s *Segment) Append(record *api.Record) (offset uint64, err error) {
+	return s.AppendContext(context.Background(), record)
+}
+
+// AppendContext is Append, tracing the store and index writes as children of the span in ctx
+func (s *Segment) AppendContext(ctx context.Context, record *api.Record) (offset uint64, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// A tombstone only marks a deletion, it never carries data of its own
+	if record.Tombstone && len(record.Value) > 0 {
+		return 0, errors.New("tombstone records cannot carry a value")
+	}
+
 	// Determine the next offset for the new record based on the segment's state
 	current := s.nextOffset
 
 	// Assign the calculated offset to the record
 	record.Offset = current
 
-	// Marshal the record to a protobuf byte slice
-	p, err := proto.Marshal(record)
+	// Marshal the record using the segment's encoding
+	p, err := s.marshal(record)
 	if err != nil {
 		return 0, err // Return error if marshaling fails
 	}
 
+	// Record the append in the journal before touching the store
+	createdAt := s.timestamp(ctx, time.Now())
+	if s.journal != nil {
+		if err := s.journal.begin(current, s.store.Size(), createdAt, p); err != nil {
+			return 0, err
+		}
+	}
+
 	// Append the marshaled record to the store and retrieve the position where it was written
-	_, pos, err := s.store.Append(p)
+	_, pos, err := s.store.AppendContext(ctx, p)
 	if err != nil {
 		return 0, err
 	}
+	if s.config.afterStoreAppend != nil {
+		s.config.afterStoreAppend()
+	}
 
-	// Write the offset and position to the index.
+	// Write the offset, position and time of the append to the index.
 	// The offset is adjusted by the base offset of the segment.
-	if err = s.index.Write(uint32(s.nextOffset-uint64(s.baseOffset)), pos); err != nil {
+	if err = s.index.WriteContext(ctx, index.Entry{
+		Off:       uint32(s.nextOffset - uint64(s.baseOffset)),
+		Pos:       pos,
+		CreatedAt: createdAt,
+	}); err != nil {
 		return 0, err
 	}
 
+	if s.journal != nil {
+		if err := s.journal.commit(current); err != nil {
+			return 0, err
+		}
+	}
+
+	// Never serve a record this offset held before the segment was truncated
+	s.cache.remove(current)
+
 	// Increment the nextOffset for the next record to be appended
 	s.nextOffset++
 
@@ -163,31 +463,721 @@ func (s *Segment) Append(record *api.Record) (offset uint64, err error) {
 	return current, nil
 }
 
+// AppendBatch appends records in order and returns their offsets.
+// The whole batch goes to the store first and is flushed once, then the index entries are written, so a batch costs
+// one flush rather than one per record. Journaled segments append the records one at a time, like Append.
+// When the segment fills up part way through, it stops at that boundary and returns the offsets written so far
+// together with ErrSegmentFull, leaving the rest of the batch for the next segment. A record that cannot be
+// appended is returned as an error after the records before it.
+func (s *Segment) AppendBatch(records []*api.Record) ([]uint64, error) {
+	if s.journal != nil {
+		return s.appendEach(records)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.store.Position() >= s.config.MaxStoreBytes || s.index.IsFull() {
+		return nil, ErrSegmentFull
+	}
+
+	// Only as many records as the index has room for are encoded
+	batch := records
+	if remaining := s.index.Remaining(); uint64(len(batch)) > remaining {
+		batch = batch[:remaining]
+	}
+
+	var recordErr error
+	payloads := make([][]byte, 0, len(batch))
+	for i, record := range batch {
+		if record.Tombstone && len(record.Value) > 0 {
+			recordErr = errors.New("tombstone records cannot carry a value")
+		} else {
+			record.Offset = s.nextOffset + uint64(i)
+			var p []byte
+			if p, recordErr = s.marshal(record); recordErr == nil {
+				payloads = append(payloads, p)
+			}
+		}
+		if recordErr != nil {
+			break
+		}
+	}
+
+	// Every record in the batch gets the same time, taken once
+	createdAt := s.timestamp(context.Background(), time.Now())
+	positions, err := s.store.AppendBatch(payloads, s.config.MaxStoreBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make([]uint64, 0, len(positions))
+	for _, pos := range positions {
+		if err := s.index.Write(index.Entry{
+			Off:       uint32(s.nextOffset - s.baseOffset),
+			Pos:       pos,
+			CreatedAt: createdAt,
+		}); err != nil {
+			return offsets, err
+		}
+
+		// Never serve a record this offset held before the segment was truncated
+		s.cache.remove(s.nextOffset)
+		offsets = append(offsets, s.nextOffset)
+		s.nextOffset++
+	}
+
+	if len(positions) < len(payloads) {
+		return offsets, ErrSegmentFull
+	}
+	if recordErr != nil {
+		return offsets, recordErr
+	}
+	if len(offsets) < len(records) {
+		return offsets, ErrSegmentFull
+	}
+	return offsets, nil
+}
+
+// appendEach appends records one at a time, stopping with ErrSegmentFull once the segment fills up
+// part way through the batch
+func (s *Segment) appendEach(records []*api.Record) ([]uint64, error) {
+	if s.IsFull() {
+		return nil, ErrSegmentFull
+	}
+
+	offsets := make([]uint64, 0, len(records))
+	for i, record := range records {
+		off, err := s.Append(record)
+		if err != nil {
+			return offsets, err
+		}
+		offsets = append(offsets, off)
+
+		// Only stop early if there is more of the batch left to write
+		if i < len(records)-1 && s.IsFull() {
+			return offsets, ErrSegmentFull
+		}
+	}
+
+	return offsets, nil
+}
+
+// timestamp returns t in Unix nanoseconds, moved forward if needed so the index timestamps never go back
+// when the wall clock does. Reading the last timestamp is traced as part of the span in ctx.
+func (s *Segment) timestamp(ctx context.Context, t time.Time) uint64 {
+	ts := uint64(t.UnixNano())
+	if last, err := s.index.ReadContext(ctx, -1); err == nil && last.CreatedAt > ts {
+		return last.CreatedAt
+	}
+	return ts
+}
+
+// ReadByTimestamp returns the first record appended at or after t.
+// It returns io.EOF when every record in the segment is older than t.
+func (s *Segment) ReadByTimestamp(t time.Time) (*api.Record, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entry, err := s.index.ReadByTimestamp(uint64(t.UnixNano()))
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.store.Read(entry.Pos)
+	if err != nil {
+		return nil, err
+	}
+	return s.unmarshal(p)
+}
+
+// Read returns the record at off, or *ErrOffsetNotFound when the segment holds none there
 func (s *Segment) Read(off uint64) (*api.Record, error) {
-	// Read from the index using the provided offset adjusted by the base offset of the segment
-	_, pos, err := s.index.Read(int64(off - s.baseOffset))
+	return s.ReadContext(context.Background(), off)
+}
+
+// ReadContext is Read, tracing the index and store reads as children of the span in ctx
+func (s *Segment) ReadContext(ctx context.Context, off uint64) (*api.Record, error) {
+	if record, ok := s.cache.get(off); ok {
+		return record, nil
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entry, err := s.entry(ctx, off)
 	if err != nil {
 		return nil, err
 	}
 
 	// Read the actual data from the store using the position obtained from the index
-	p, err := s.store.Read(pos)
+	p, err := s.store.ReadContext(ctx, entry.Pos)
 	if err != nil {
 		return nil, err
 	}
 
 	// Unmarshal the data into a Record object
-	record := &api.Record{}
-	err = proto.Unmarshal(p, record)
+	record, err := s.unmarshal(p)
 	if err != nil {
 		return nil, err
 	}
+	s.cache.put(off, record)
+
+	return record, nil
+}
+
+// Position returns the byte position in the store where the record at off starts
+func (s *Segment) Position(off uint64) (uint64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entry, err := s.entry(context.Background(), off)
+	if err != nil {
+		return 0, err
+	}
+	return entry.Pos, nil
+}
+
+// Has reports whether the segment holds a record at off. Only the index is consulted, the store is never read.
+func (s *Segment) Has(off uint64) bool {
+	if off < s.baseOffset {
+		return false
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, err := s.entry(context.Background(), off)
+	return err == nil
+}
+
+// entry looks up the index entry of the record at off, tracing the read as a child of the span in ctx.
+// The caller holds the lock.
+func (s *Segment) entry(ctx context.Context, off uint64) (index.Entry, error) {
+	if off < s.baseOffset {
+		return index.Entry{}, &ErrOffsetNotFound{Offset: off}
+	}
+
+	// Read from the index using the provided offset adjusted by the base offset of the segment
+	rel := off - s.baseOffset
+	entry, err := s.index.ReadContext(ctx, int64(rel))
+
+	// A compacted segment may have gaps, in which case the entry is no longer at its own position
+	if err != nil || uint64(entry.Off) != rel {
+		entry, err = s.index.Find(uint32(rel))
+	}
+	if err == io.EOF {
+		return index.Entry{}, &ErrOffsetNotFound{Offset: off}
+	}
+	return entry, err
+}
+
+// ScanRaw calls fn with the offset, append time and stored bytes of every record in the segment, in offset order.
+// The bytes are the record as it is encoded in the store, ready to be passed to AppendRaw.
+func (s *Segment) ScanRaw(fn func(offset uint64, createdAt uint64, p []byte) error) error {
+	// Only the index needs the lock, records appended after it is read are simply not scanned
+	s.mutex.RLock()
+	var entries []index.Entry
+	for n := int64(0); ; n++ {
+		entry, err := s.index.Read(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.mutex.RUnlock()
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	s.mutex.RUnlock()
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// Read the whole store in one go when it holds exactly the indexed records, which it does
+	// unless a crash left an unindexed entry at the tail. Otherwise read entry by entry.
+	data, err := s.store.ReadRange(entries[0].Pos, s.store.Size())
+	if err != nil || len(data) != len(entries) {
+		data = nil
+	}
+
+	for i, entry := range entries {
+		var p []byte
+		if data != nil {
+			p = data[i]
+		} else if p, err = s.store.Read(entry.Pos); err != nil {
+			return err
+		}
+		if err := fn(s.baseOffset+uint64(entry.Off), entry.CreatedAt, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Walk calls fn with the offset and stored bytes of every record in the segment, in offset order,
+// without unmarshaling them. It stops at the first error fn returns and passes it back.
+func (s *Segment) Walk(fn func(offset uint64, data []byte) error) error {
+	return s.ScanRaw(func(offset uint64, _ uint64, p []byte) error {
+		return fn(offset, p)
+	})
+}
+
+// AppendRaw appends an already encoded record at offset, which may skip ahead of the next offset but never go back.
+// The bytes are stored as they are, so they must use the segment's record encoding. createdAt is the
+// record's original append time in Unix nanoseconds.
+func (s *Segment) AppendRaw(offset uint64, createdAt uint64, p []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if offset < s.nextOffset {
+		return fmt.Errorf("offset %d is below the segment's next offset %d", offset, s.nextOffset)
+	}
+
+	_, pos, err := s.store.Append(p)
+	if err != nil {
+		return err
+	}
+	if err := s.index.Write(index.Entry{
+		Off:       uint32(offset - s.baseOffset),
+		Pos:       pos,
+		CreatedAt: createdAt,
+	}); err != nil {
+		return err
+	}
+	s.cache.remove(offset)
+
+	s.nextOffset = offset + 1
+	return nil
+}
+
+// marshal serializes a record with the segment's configured encoding
+func (s *Segment) marshal(record *api.Record) ([]byte, error) {
+	if s.config.RecordEncoding == JSONEncoding {
+		return json.Marshal(record.ToJSON())
+	}
+	return proto.Marshal(record)
+}
 
-	// Return the unmarshaled record
+// unmarshal deserializes a record with the segment's configured encoding
+func (s *Segment) unmarshal(p []byte) (*api.Record, error) {
+	return UnmarshalRecord(s.config.RecordEncoding, p)
+}
+
+// UnmarshalRecord deserializes a record as it is stored in a segment using enc
+func UnmarshalRecord(enc RecordEncoding, p []byte) (*api.Record, error) {
+	if enc == JSONEncoding {
+		var j api.RecordJSON
+		if err := json.Unmarshal(p, &j); err != nil {
+			return nil, err
+		}
+		return j.ToRecord(), nil
+	}
+
+	record := &api.Record{}
+	if err := proto.Unmarshal(p, record); err != nil {
+		return nil, err
+	}
 	return record, nil
 }
 
+// TruncateAfter discards every record in the segment with an offset greater than off
+func (s *Segment) TruncateAfter(off uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if off < s.baseOffset {
+		return errors.New("offset is below the segment's base offset")
+	}
+
+	// Nothing to discard when off is already the last record
+	if off+1 >= s.nextOffset {
+		return nil
+	}
+
+	// Find where the first discarded record starts in the store
+	keep := off + 1 - s.baseOffset
+	entry, err := s.index.Read(int64(keep))
+	if err != nil {
+		return err
+	}
+
+	// Cut both the store and the index back to the retained records
+	if err := s.store.Truncate(entry.Pos); err != nil {
+		return err
+	}
+	if err := s.index.Truncate(keep); err != nil {
+		return err
+	}
+	s.cache.clear()
+
+	// A mark saved by compaction past the new end would skip the offsets this frees up
+	if s.store.Meta().NextOffset > off+1 {
+		if err := s.store.SetNextOffset(0); err != nil {
+			return err
+		}
+	}
+
+	s.nextOffset = off + 1
+	return nil
+}
+
+// Sync commits the segment's store and index to stable storage
+func (s *Segment) Sync() error {
+	if err := s.store.Sync(); err != nil {
+		return err
+	}
+
+	return s.index.Sync()
+}
+
+// ValidateIndex checks the segment's index for entries out of order or pointing past storeSize, normally StoreSize.
+// It returns the index's *index.ValidationError when Repair is needed.
+func (s *Segment) ValidateIndex(storeSize uint64) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.index.Validate(storeSize)
+}
+
+// Repair rebuilds the segment's index from the records in its store.
+// Use it when the segment may not have been closed cleanly: an entry that was only partially
+// written to the store is discarded, and the next offset is recovered from the last complete record.
+func (s *Segment) Repair() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// The store does not record append times, so keep the ones the index still has
+	timestamps := make(map[uint32]uint64)
+	for n := int64(0); ; n++ {
+		entry, err := s.index.Read(n)
+		if err != nil {
+			break
+		}
+		timestamps[entry.Off] = entry.CreatedAt
+	}
+
+	// Throw away the current index entirely, it is rebuilt from the store
+	if err := s.index.Truncate(0); err != nil {
+		return err
+	}
+	s.cache.clear()
+	s.nextOffset = s.baseOffset
+
+	end, err := s.store.Scan(func(pos uint64, data []byte) error {
+		record, err := s.unmarshal(data)
+		if err != nil {
+			return err
+		}
+		if record.Offset < s.baseOffset {
+			return fmt.Errorf("record offset %d is below the segment's base offset %d", record.Offset, s.baseOffset)
+		}
+
+		// Records carry their own offset, which also preserves gaps left by compaction.
+		// Records the index lost are stamped with the time of the repair.
+		rel := uint32(record.Offset - s.baseOffset)
+		createdAt, ok := timestamps[rel]
+		if !ok {
+			createdAt = s.timestamp(context.Background(), time.Now())
+		}
+		if err := s.index.Write(index.Entry{
+			Off:       rel,
+			Pos:       pos,
+			CreatedAt: createdAt,
+		}); err != nil {
+			return err
+		}
+		s.nextOffset = record.Offset + 1
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.keepHighWaterMark()
+
+	// Drop whatever partial entry trails the last complete record
+	if end < s.store.Size() {
+		return s.store.Truncate(end)
+	}
+
+	return nil
+}
+
+// keepHighWaterMark moves nextOffset up to the one saved by compaction, so offsets of records compacted away
+// at the tail are never handed out again. The caller holds the lock or has the segment to itself.
+func (s *Segment) keepHighWaterMark() {
+	if mark := s.store.Meta().NextOffset; mark > s.nextOffset {
+		s.nextOffset = mark
+	}
+}
+
+// Copy writes a copy of the segment's store and index files into destDir.
+// The segment stays writable at its original path. Callers must make sure nothing is
+// appended to the segment while the copy is in progress.
+func (s *Segment) Copy(destDir string) error {
+	// Make sure everything appended so far is on disk before copying it
+	if err := s.store.Sync(); err != nil {
+		return err
+	}
+	if err := s.index.Sync(); err != nil {
+		return err
+	}
+
+	// The store is copied whole
+	storeInfo, err := s.store.Stat()
+	if err != nil {
+		return err
+	}
+	if err := copyFile(
+		s.config.storePath(destDir, s.baseOffset),
+		io.NewSectionReader(s.store.File, 0, storeInfo.Size()),
+		storeInfo.Size(),
+		s.config.FilePerm,
+	); err != nil {
+		return err
+	}
+
+	// Only the header and written entries of the index are copied, the same way Close truncates it
+	indexSize := int64(index.HeaderLength + s.index.Size)
+	return copyFile(
+		s.config.indexPath(destDir, s.baseOffset),
+		io.NewSectionReader(s.index.File, 0, indexSize),
+		indexSize,
+		s.config.FilePerm,
+	)
+}
+
+// copyFile writes everything from src into a new file at dst and checks that size bytes were copied.
+func copyFile(dst string, src io.Reader, size int64, perm os.FileMode) error {
+	f, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, src)
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("copied %d bytes to %s, expected %d", n, dst, size)
+	}
+
+	return f.Sync()
+}
+
+// Compact rewrites the segment keeping only the records for which keep returns true.
+// Records keep their original offsets. The rewritten segment is written next to the original,
+// swapped in place of its files once complete, and returned reopened; s must not be used afterwards.
+func (s *Segment) Compact(keep func(*api.Record) bool) (*Segment, error) {
+	// Build the compacted copy in a scratch directory next to the segment
+	tmpDir := path.Join(s.config.FilePath, fmt.Sprintf(".compact-%d", s.baseOffset))
+	if err := os.MkdirAll(tmpDir, DirPerm(s.config.FilePerm)); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmpConfig := *s.config
+	tmpConfig.FilePath = tmpDir
+	compacted, err := NewSegment(withOptions(tmpConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy every record worth keeping at the offset it already had, walking the index in order
+	err = s.ScanRaw(func(offset uint64, createdAt uint64, p []byte) error {
+		record, err := s.unmarshal(p)
+		if err != nil {
+			return err
+		}
+		if !keep(record) {
+			return nil
+		}
+		return compacted.AppendRaw(offset, createdAt, p)
+	})
+	if err != nil {
+		compacted.Close()
+		return nil, err
+	}
+
+	// Dropped records at the tail must not hand their offsets out again, even once the segment is reopened
+	if err := compacted.saveHighWaterMark(s.NextOffset()); err != nil {
+		compacted.Close()
+		return nil, err
+	}
+
+	// The rewrite ages like the original, so retention by age is not restarted
+	if err := compacted.store.SetCreatedAt(s.store.Meta().CreatedAt); err != nil {
+		compacted.Close()
+		return nil, err
+	}
+
+	// Close both sides so everything is on disk before the files are swapped
+	if err := compacted.Close(); err != nil {
+		return nil, err
+	}
+	if err := s.Close(); err != nil {
+		return nil, err
+	}
+
+	// Reopen the compacted files in place of the original segment
+	return s.swapIn(tmpDir)
+}
+
+// saveHighWaterMark persists next as the segment's next offset if its own records end before it
+func (s *Segment) saveHighWaterMark(next uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if next <= s.nextOffset {
+		return nil
+	}
+	if err := s.store.SetNextOffset(next); err != nil {
+		return err
+	}
+	s.nextOffset = next
+	return nil
+}
+
+// Merge rewrites consecutive segments as a single segment starting at the first one's base offset.
+// Records keep their original offsets. The merged segment takes the place of the first segment's
+// files, the files of the others are removed, and none of segments may be used afterwards.
+// The others are only removed once the merged segment is in place. If removing one fails, the merged
+// segment is returned along with the error, since it already holds all their records. On any other error
+// the segments have to be reopened from their files; should the swap itself fail part way, the merged files
+// are left in the .merge-<offset> scratch directory.
+func Merge(segments []*Segment) (merged *Segment, err error) {
+	if len(segments) == 0 {
+		return nil, errors.New("no segments to merge")
+	}
+	first, last := segments[0], segments[len(segments)-1]
+
+	// Build the merged segment in a scratch directory next to the originals.
+	// Once the swap has started it may hold the only copy of some records, so it is kept on error.
+	tmpDir := path.Join(first.config.FilePath, fmt.Sprintf(".merge-%d", first.baseOffset))
+	if err := os.MkdirAll(tmpDir, DirPerm(first.config.FilePerm)); err != nil {
+		return nil, err
+	}
+	swapping := false
+	defer func() {
+		if err == nil || !swapping {
+			os.RemoveAll(tmpDir)
+		}
+	}()
+
+	tmpConfig := *first.config
+	tmpConfig.FilePath = tmpDir
+	built, err := NewSegment(withOptions(tmpConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	// Records are copied byte for byte, so they are never decoded along the way
+	for _, s := range segments {
+		if err := s.ScanRaw(built.AppendRaw); err != nil {
+			built.Close()
+			return nil, err
+		}
+	}
+
+	// Records compacted away at the tail must not hand their offsets out again
+	if err := built.saveHighWaterMark(last.NextOffset()); err != nil {
+		built.Close()
+		return nil, err
+	}
+
+	// The merged segment ages like the newest of the originals, so retention by age never drops a record early
+	var createdAt time.Time
+	for _, s := range segments {
+		if t := s.store.Meta().CreatedAt; t.After(createdAt) {
+			createdAt = t
+		}
+	}
+	if err := built.store.SetCreatedAt(createdAt); err != nil {
+		built.Close()
+		return nil, err
+	}
+
+	// Everything has to be on disk before any original is touched
+	if err := built.Close(); err != nil {
+		return nil, err
+	}
+	if err := first.Close(); err != nil {
+		return nil, err
+	}
+
+	swapping = true
+	reopened, err := first.swapIn(tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The merged segment holds every record now, so the others can go
+	for _, s := range segments[1:] {
+		if err := s.Remove(); err != nil {
+			return reopened, err
+		}
+	}
+
+	return reopened, nil
+}
+
+// swapIn moves the segment files built in tmpDir over the closed segment's files and reopens them.
+// The segment's journal is cleared first: its entries describe the old store, and replaying one
+// against the rewritten files would corrupt them.
+func (s *Segment) swapIn(tmpDir string) (*Segment, error) {
+	if s.config.Journal {
+		if err := os.Truncate(s.config.journalPath(s.config.FilePath, s.baseOffset), 0); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	for _, p := range []func(string, uint64) string{
+		s.config.storePath,
+		s.config.indexPath,
+		func(dir string, offset uint64) string { return store.MetaPath(s.config.storePath(dir, offset)) },
+	} {
+		if err := os.Rename(p(tmpDir, s.baseOffset), p(s.config.FilePath, s.baseOffset)); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewSegment(withOptions(*s.config))
+}
+
+// withOptions copies every field of opts into the Options being built.
+func withOptions(opts Options) SegmentOptions {
+	return func(o *Options) {
+		*o = opts
+	}
+}
+
+// ShrinkToFit gives back the space the segment has reserved but not used yet.
+// Only the index reserves space up front, stores grow as they are written.
+func (s *Segment) ShrinkToFit() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.index.ShrinkToFit()
+}
+
+// Close flushes and closes the segment's files. Closing a closed segment does nothing.
 func (s *Segment) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	// Shrinking first leaves the index nothing to truncate on close
+	if err := s.index.ShrinkToFit(); err != nil {
+		return err
+	}
+
 	if err := s.index.Close(); err != nil {
 		return err
 	}
@@ -196,6 +1186,10 @@ func (s *Segment) Close() error {
 		return err
 	}
 
+	if s.journal != nil {
+		return s.journal.Close()
+	}
+
 	return nil
 }
 
@@ -206,7 +1200,7 @@ func (s *Segment) Remove() error {
 	}
 
 	// Attempt to remove the index file
-	if err := os.Remove(s.index.File.Name()); err != nil {
+	if err := os.Remove(s.index.Name()); err != nil {
 		return err
 	}
 
@@ -215,13 +1209,56 @@ func (s *Segment) Remove() error {
 		return err
 	}
 
+	// Remove the store's metadata sidecar along with it
+	if err := os.Remove(store.MetaPath(s.store.Name())); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// And the journal, if the segment kept one
+	if s.journal != nil {
+		if err := os.Remove(s.journal.file.Name()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
 	// Return nil to indicate successful removal
 	return nil
 }
 
 func (s *Segment) IsFull() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	// Check to see if segement is at max capacity
-	return s.store.Size >= s.config.MaxStoreBytes || s.index.Size >= s.config.MaxIndexBytes
+	return s.store.Position() >= s.config.MaxStoreBytes || s.index.IsFull()
+}
+
+// SetMaxStoreBytes changes the store size at which the segment reports itself full
+func (s *Segment) SetMaxStoreBytes(maxBytes uint64) {
+	s.config.MaxStoreBytes = maxBytes
+}
+
+// StoreSize returns the number of bytes in the segment's store
+func (s *Segment) StoreSize() uint64 {
+	return s.store.Position()
+}
+
+// IndexSize returns the number of bytes of entries in the segment's index
+func (s *Segment) IndexSize() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.index.Size
+}
+
+// TotalSize returns the combined size of the segment's store and index in bytes
+func (s *Segment) TotalSize() uint64 {
+	return s.StoreSize() + s.IndexSize()
+}
+
+// Config returns a copy of the options the segment was opened with, so they cannot be changed through it
+func (s *Segment) Config() Options {
+	return *s.config
 }
 
 func (s *Segment) BaseOffset() uint64 {
@@ -229,9 +1266,78 @@ func (s *Segment) BaseOffset() uint64 {
 }
 
 func (s *Segment) NextOffset() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	return s.nextOffset
 }
 
+// LowestOffset returns the offset of the oldest record the segment holds, which is past BaseOffset once
+// compaction removed the first records, and false when the segment holds none
+func (s *Segment) LowestOffset() (uint64, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	first, err := s.index.Read(0)
+	if err != nil {
+		return 0, false
+	}
+	return s.baseOffset + uint64(first.Off), true
+}
+
 func (s *Segment) GetStore() *store.Store {
 	return s.store
 }
+
+// StorePath returns the path of the segment's store file
+func (s *Segment) StorePath() string {
+	return s.store.Name()
+}
+
+// IndexPath returns the path of the segment's index file
+func (s *Segment) IndexPath() string {
+	return s.index.Name()
+}
+
+// Segment files are named after their base offset, zero-padded to the width of the largest uint64
+// so that a lexicographic directory listing is also in offset order.
+const fileNameFormat = "%020d%s"
+
+// storePath returns the path of the store file for the segment starting at offset.
+func (o *Options) storePath(dir string, offset uint64) string {
+	return path.Join(dir, fmt.Sprintf(fileNameFormat, offset, o.StoreExtension))
+}
+
+// indexPath returns the path of the index file for the segment starting at offset.
+func (o *Options) indexPath(dir string, offset uint64) string {
+	return path.Join(dir, fmt.Sprintf(fileNameFormat, offset, o.IndexExtension))
+}
+
+// journalPath returns the path of the journal file for the segment starting at offset.
+func (o *Options) journalPath(dir string, offset uint64) string {
+	return path.Join(dir, fmt.Sprintf(fileNameFormat, offset, ".journal"))
+}
+
+// renameLegacyFiles moves segment files using the old unpadded names (e.g. 10.store) to the padded ones.
+func renameLegacyFiles(opts *Options) error {
+	dir, offset := opts.FilePath, opts.InitialOffset
+	for _, names := range [][2]string{
+		{path.Join(dir, fmt.Sprintf("%d%s", offset, opts.StoreExtension)), opts.storePath(dir, offset)},
+		{path.Join(dir, fmt.Sprintf("%d%s", offset, opts.IndexExtension)), opts.indexPath(dir, offset)},
+	} {
+		legacy, current := names[0], names[1]
+		if legacy == current {
+			continue
+		}
+
+		// Never clobber a file that already uses the new name
+		if _, err := os.Stat(current); err == nil {
+			continue
+		}
+		if err := os.Rename(legacy, current); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,15 +1,26 @@
 package segment
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"testing"
+	"time"
 
 	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/core/index"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/core/store"
 	"github.com/stretchr/testify/require"
 )
 
 func TestNewSegment(t *testing.T) {
+	// Registered first so it runs last, once every segment below is closed
+	checkNoLeakedFiles(t)
+
 	dir, err := os.MkdirTemp("", "segment-test")
 	require.NoError(t, err)
 
@@ -18,7 +29,7 @@ func TestNewSegment(t *testing.T) {
 		Offset: 0,
 	}
 
-	entryLength := uint64(12)
+	entryLength := uint64(20)
 
 	// Define options for the new segment
 	opts := []SegmentOptions{
@@ -28,9 +39,14 @@ func TestNewSegment(t *testing.T) {
 		WithInitialOffset(0),
 	}
 
-	// Create a new segment with the specified options
+	// Create a new segment with the specified options.
+	// Close it before removing its directory, Close still syncs the index mmap.
 	seg, err := NewSegment(opts...)
 	require.NoError(t, err)
+	t.Cleanup(func() {
+		seg.Close()
+		os.RemoveAll(dir)
+	})
 
 	// Verify the segment is initialized with expected values
 	require.Equal(t, uint64(0), seg.nextOffset)
@@ -61,22 +77,35 @@ func TestNewSegment(t *testing.T) {
 		WithInitialOffset(0),
 	}
 
-	// Recreate the segment with new options
-	seg, err = NewSegment(opts...)
+	// Create a second segment with the new options
+	seg2, err := NewSegment(opts...)
 	require.NoError(t, err)
-
-	defer func() {
-		os.RemoveAll(dir)
-	}()
-
-	defer func() {
+	t.Cleanup(func() {
+		require.NoError(t, seg2.Close())
 		os.RemoveAll(dir2)
-	}()
+	})
+}
 
-	defer func() {
-		require.NoError(t, seg.Close())
-	}()
+// checkNoLeakedFiles fails the test if it ends with more open file descriptors than it started with.
+// It counts the entries of /proc/self/fd, so it only checks anything on Linux.
+func checkNoLeakedFiles(t *testing.T) {
+	t.Helper()
+
+	count := func() int {
+		fds, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			return -1
+		}
+		return len(fds)
+	}
 
+	before := count()
+	if before < 0 {
+		return
+	}
+	t.Cleanup(func() {
+		require.Equal(t, before, count(), "file descriptors leaked")
+	})
 }
 
 func TestSegmentIsFull(t *testing.T) {
@@ -120,6 +149,363 @@ func TestSegmentIsFull(t *testing.T) {
 	}
 }
 
+func TestSegmentAppendBatch(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-batch-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// Room for three index entries
+	seg, err := NewSegment(
+		WithFilePath(tempDir),
+		WithMaxIndexBytes(60),
+		WithInitialOffset(16),
+	)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, seg.Close())
+	}()
+
+	records := make([]*api.Record, 5)
+	for i := range records {
+		records[i] = &api.Record{Value: []byte(fmt.Sprintf("batch %d", i))}
+	}
+
+	// The batch stops at the segment boundary
+	offsets, err := seg.AppendBatch(records)
+	require.ErrorIs(t, err, ErrSegmentFull)
+	require.Equal(t, []uint64{16, 17, 18}, offsets)
+	for i, off := range offsets {
+		got, err := seg.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, records[i].Value, got.Value)
+	}
+
+	// A full segment takes nothing
+	offsets, err = seg.AppendBatch(records[3:])
+	require.ErrorIs(t, err, ErrSegmentFull)
+	require.Empty(t, offsets)
+}
+
+func TestSegmentAppendBatchStops(t *testing.T) {
+	// The store fills up after two records
+	seg, err := NewSegment(WithFilePath(t.TempDir()), WithMaxStoreBytes(30))
+	require.NoError(t, err)
+	defer seg.Close()
+
+	records := []*api.Record{
+		{Value: []byte("first")},
+		{Value: []byte("second")},
+		{Value: []byte("third")},
+	}
+	offsets, err := seg.AppendBatch(records)
+	require.ErrorIs(t, err, ErrSegmentFull)
+	require.Equal(t, []uint64{0, 1}, offsets)
+	require.Equal(t, uint64(2), seg.NextOffset())
+
+	// A record that cannot be appended fails the batch after the records before it
+	other, err := NewSegment(WithFilePath(t.TempDir()))
+	require.NoError(t, err)
+	defer other.Close()
+
+	offsets, err = other.AppendBatch([]*api.Record{
+		{Value: []byte("kept")},
+		{Value: []byte("value"), Tombstone: true},
+		{Value: []byte("never reached")},
+	})
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrSegmentFull)
+	require.Equal(t, []uint64{0}, offsets)
+	got, err := other.Read(0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("kept"), got.Value)
+	require.False(t, other.Has(1))
+}
+
+func TestSegmentJournalRecovery(t *testing.T) {
+	// In the subprocess: append one record, then crash between the store and index writes of the next
+	if dir := os.Getenv("SEGMENT_JOURNAL_CRASH_DIR"); dir != "" {
+		crash := false
+		seg, err := NewSegment(WithFilePath(dir), WithJournal(true), withAfterStoreAppend(func() {
+			if crash {
+				os.Exit(1)
+			}
+		}))
+		if err != nil {
+			os.Exit(2)
+		}
+		if _, err := seg.Append(&api.Record{Value: []byte("committed")}); err != nil {
+			os.Exit(2)
+		}
+		crash = true
+		seg.Append(&api.Record{Value: []byte("interrupted")})
+		os.Exit(2)
+	}
+
+	tempDir, err := os.MkdirTemp("", "segment-journal-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSegmentJournalRecovery$")
+	cmd.Env = append(os.Environ(), "SEGMENT_JOURNAL_CRASH_DIR="+tempDir)
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 1, exitErr.ExitCode(), "the subprocess should crash mid-append")
+
+	// Reopening replays the interrupted append
+	seg, err := NewSegment(WithFilePath(tempDir), WithJournal(true))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, seg.Close())
+	}()
+	require.Equal(t, uint64(2), seg.NextOffset())
+
+	for off, want := range []string{"committed", "interrupted"} {
+		got, err := seg.Read(uint64(off))
+		require.NoError(t, err)
+		require.Equal(t, []byte(want), got.Value)
+	}
+
+	// Nothing is left for the journal to replay, and appends carry on
+	fi, err := os.Stat(seg.journal.file.Name())
+	require.NoError(t, err)
+	require.Zero(t, fi.Size())
+
+	off, err := seg.Append(&api.Record{Value: []byte("after")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), off)
+}
+
+func TestSegmentCompactResetsJournal(t *testing.T) {
+	seg, err := NewSegment(WithFilePath(t.TempDir()), WithJournal(true))
+	require.NoError(t, err)
+	for _, value := range []string{"zero", "one", "two"} {
+		_, err := seg.Append(&api.Record{Value: []byte(value)})
+		require.NoError(t, err)
+	}
+
+	// An intent left uncommitted describes the store as it is before compaction
+	p, err := seg.marshal(&api.Record{Offset: 3, Value: []byte("three")})
+	require.NoError(t, err)
+	require.NoError(t, seg.journal.begin(3, seg.store.Size(), 0, p))
+
+	compacted, err := seg.Compact(func(record *api.Record) bool {
+		return record.Offset != 0
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, compacted.Close())
+	}()
+
+	// It is not replayed against the rewritten files
+	require.Equal(t, uint64(3), compacted.NextOffset())
+	for off, want := range []string{"one", "two"} {
+		got, err := compacted.Read(uint64(off + 1))
+		require.NoError(t, err)
+		require.Equal(t, []byte(want), got.Value)
+	}
+	fi, err := os.Stat(compacted.journal.file.Name())
+	require.NoError(t, err)
+	require.Zero(t, fi.Size())
+}
+
+func TestSegmentSync(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-sync-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	seg, err := NewSegment(WithFilePath(tempDir))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, seg.Close())
+	}()
+
+	for i := 0; i < 3; i++ {
+		_, err := seg.Append(&api.Record{Value: []byte("durable")})
+		require.NoError(t, err)
+	}
+	require.NoError(t, seg.Sync())
+
+	// Without closing the segment, the store file already holds every record
+	fi, err := os.Stat(seg.store.Name())
+	require.NoError(t, err)
+	require.Equal(t, int64(seg.StoreSize()), fi.Size())
+
+	// And the index file holds their entries, right after the header
+	data, err := os.ReadFile(seg.index.Name())
+	require.NoError(t, err)
+	entryLen := seg.IndexSize() / 3
+	for i := uint64(0); i < 3; i++ {
+		entry := data[index.HeaderLength+i*entryLen:]
+		require.Equal(t, uint32(i), binary.BigEndian.Uint32(entry[:4]))
+	}
+}
+
+func TestSegmentWalk(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-walk-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	seg, err := NewSegment(WithFilePath(tempDir), WithInitialOffset(16))
+	require.NoError(t, err)
+	defer seg.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err := seg.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+
+	// Every record comes back as the bytes it was stored as, at its absolute offset
+	var offsets []uint64
+	err = seg.Walk(func(offset uint64, data []byte) error {
+		record, err := UnmarshalRecord(seg.Config().RecordEncoding, data)
+		require.NoError(t, err)
+		require.Equal(t, offset, record.Offset)
+		offsets = append(offsets, offset)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{16, 17, 18}, offsets)
+
+	// An error from fn stops the walk
+	stop := errors.New("stop")
+	calls := 0
+	err = seg.Walk(func(uint64, []byte) error {
+		calls++
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, 1, calls)
+}
+
+func TestSegmentReadCache(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-cache-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	seg, err := NewSegment(WithFilePath(tempDir), WithReadCache(2))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, seg.Close())
+	}()
+
+	for i := 0; i < 3; i++ {
+		_, err := seg.Append(&api.Record{Value: []byte(fmt.Sprintf("cached %d", i))})
+		require.NoError(t, err)
+	}
+
+	// Reads fill the cache, and changing a returned record does not change the cached one
+	got, err := seg.Read(0)
+	require.NoError(t, err)
+	got.Value = []byte("changed")
+	got, err = seg.Read(0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("cached 0"), got.Value)
+
+	// Only the two most recently read records are kept
+	_, err = seg.Read(1)
+	require.NoError(t, err)
+	_, err = seg.Read(2)
+	require.NoError(t, err)
+	_, ok := seg.cache.get(0)
+	require.False(t, ok)
+	_, ok = seg.cache.get(2)
+	require.True(t, ok)
+
+	// Truncating drops cached records, so the offset's new record is read once it is rewritten
+	require.NoError(t, seg.TruncateAfter(1))
+	_, err = seg.Append(&api.Record{Value: []byte("rewritten")})
+	require.NoError(t, err)
+	got, err = seg.Read(2)
+	require.NoError(t, err)
+	require.Equal(t, []byte("rewritten"), got.Value)
+}
+
+func BenchmarkSegmentRead(b *testing.B) {
+	for _, bench := range []struct {
+		name  string
+		cache int
+	}{
+		{"uncached", 0},
+		{"cached", 100},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			tempDir, err := os.MkdirTemp("", "segment-read-bench")
+			require.NoError(b, err)
+			defer os.RemoveAll(tempDir)
+
+			seg, err := NewSegment(
+				WithFilePath(tempDir),
+				WithMaxStoreBytes(1<<20),
+				WithMaxIndexBytes(1<<20),
+				WithReadCache(bench.cache),
+			)
+			require.NoError(b, err)
+			defer seg.Close()
+
+			for i := 0; i < 100; i++ {
+				_, err := seg.Append(&api.Record{Value: bytes.Repeat([]byte{'x'}, 256)})
+				require.NoError(b, err)
+			}
+
+			// Read the same 100 offsets over and over
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := seg.Read(uint64(i % 100)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestSegmentSizes(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-sizes-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	seg, err := NewSegment(WithFilePath(tempDir), WithInitialOffset(0))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, seg.Close())
+	}()
+
+	// An empty segment takes up no space
+	require.Equal(t, uint64(0), seg.TotalSize())
+
+	for i := 0; i < 3; i++ {
+		_, err := seg.Append(&api.Record{Value: []byte("test")})
+		require.NoError(t, err)
+	}
+
+	// Three index entries of 20 bytes, and the store grows with every record
+	require.Equal(t, uint64(60), seg.IndexSize())
+	require.Equal(t, seg.GetStore().Size(), seg.StoreSize())
+	require.Greater(t, seg.StoreSize(), uint64(0))
+	require.Equal(t, seg.StoreSize()+seg.IndexSize(), seg.TotalSize())
+}
+
+func TestSegmentConfig(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-config-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	seg, err := NewSegment(WithFilePath(tempDir), WithMaxStoreBytes(2048), WithInitialOffset(16))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, seg.Close())
+	}()
+
+	config := seg.Config()
+	require.Equal(t, uint64(2048), config.MaxStoreBytes)
+	require.Equal(t, uint64(1024), config.MaxIndexBytes)
+	require.Equal(t, uint64(16), config.InitialOffset)
+
+	// Changing the copy leaves the segment alone
+	config.MaxStoreBytes = 1
+	require.Equal(t, uint64(2048), seg.Config().MaxStoreBytes)
+}
+
 func TestSegmentRemove(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "segment_remove_test")
 	require.NoError(t, err)
@@ -133,7 +519,7 @@ func TestSegmentRemove(t *testing.T) {
 	require.NoError(t, err)
 
 	// Ensure the segment's files exist before attempting removal
-	_, err = os.Stat(segment.index.File.Name())
+	_, err = os.Stat(segment.index.Name())
 	require.NoError(t, err, "Index file should exist before removal")
 	_, err = os.Stat(segment.store.Name())
 	require.NoError(t, err, "Store file should exist before removal")
@@ -143,7 +529,7 @@ func TestSegmentRemove(t *testing.T) {
 	require.NoError(t, err, "Segment removal should not produce an error")
 
 	// Verify that the segment's files have been removed
-	_, err = os.Stat(segment.index.File.Name())
+	_, err = os.Stat(segment.index.Name())
 	require.Error(t, err, "Index file should not exist after removal")
 	require.True(t, os.IsNotExist(err), "Error should indicate that the index file does not exist")
 
@@ -151,3 +537,392 @@ func TestSegmentRemove(t *testing.T) {
 	require.Error(t, err, "Store file should not exist after removal")
 	require.True(t, os.IsNotExist(err), "Error should indicate that the store file does not exist")
 }
+
+func TestSegmentCopy(t *testing.T) {
+	srcDir, err := os.MkdirTemp("", "segment-copy-src")
+	require.NoError(t, err)
+	defer os.RemoveAll(srcDir)
+
+	destDir, err := os.MkdirTemp("", "segment-copy-dest")
+	require.NoError(t, err)
+	defer os.RemoveAll(destDir)
+
+	seg, err := NewSegment(
+		WithFilePath(srcDir),
+		WithInitialOffset(16),
+	)
+	require.NoError(t, err)
+
+	want := &api.Record{Value: []byte("copy me")}
+	for i := 0; i < 3; i++ {
+		_, err := seg.Append(want)
+		require.NoError(t, err)
+	}
+
+	// Copy the segment into the destination directory
+	require.NoError(t, seg.Copy(destDir))
+
+	// The original segment stays writable
+	off, err := seg.Append(want)
+	require.NoError(t, err)
+	require.Equal(t, uint64(19), off)
+	require.NoError(t, seg.Close())
+
+	// The copy can be opened as a segment of its own with the copied records
+	copied, err := NewSegment(
+		WithFilePath(destDir),
+		WithInitialOffset(16),
+	)
+	require.NoError(t, err)
+	defer copied.Close()
+
+	require.Equal(t, uint64(19), copied.NextOffset())
+	for off := uint64(16); off < 19; off++ {
+		got, err := copied.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, want.Value, got.Value)
+	}
+}
+
+func TestSegmentJSONEncoding(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-json-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	seg, err := NewSegment(
+		WithFilePath(tempDir),
+		WithRecordEncoding(JSONEncoding),
+	)
+	require.NoError(t, err)
+	defer seg.Close()
+
+	want := &api.Record{Value: []byte("json value")}
+	off, err := seg.Append(want)
+	require.NoError(t, err)
+
+	// Records round-trip through the JSON encoding
+	got, err := seg.Read(off)
+	require.NoError(t, err)
+	require.Equal(t, want.Value, got.Value)
+	require.Equal(t, off, got.Offset)
+
+	// The store holds a JSON document rather than protobuf bytes
+	raw, err := seg.store.Read(0)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"offset":0,"value":"anNvbiB2YWx1ZQ=="}`, string(raw))
+
+	// Headers are stored along with the record
+	withHeaders := &api.Record{Value: []byte("json value"), Headers: map[string][]byte{"key": []byte("value")}}
+	off, err = seg.Append(withHeaders)
+	require.NoError(t, err)
+	got, err = seg.Read(off)
+	require.NoError(t, err)
+	require.Equal(t, withHeaders.Headers, got.Headers)
+}
+
+func TestSegmentCompression(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-compression-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	want := &api.Record{Value: bytes.Repeat([]byte("compressible "), 64)}
+
+	// A cold segment compressed with gzip next to a hot one left uncompressed
+	cold, err := NewSegment(WithFilePath(tempDir), WithMaxStoreBytes(1<<16), WithSegmentCompression(store.GzipCompression))
+	require.NoError(t, err)
+	hot, err := NewSegment(WithFilePath(tempDir), WithMaxStoreBytes(1<<16), WithInitialOffset(16))
+	require.NoError(t, err)
+
+	for _, seg := range []*Segment{cold, hot} {
+		_, err := seg.Append(want)
+		require.NoError(t, err)
+	}
+	require.Less(t, cold.StoreSize(), hot.StoreSize())
+	require.NoError(t, cold.Close())
+	require.NoError(t, hot.Close())
+
+	// Reopened with the compression swapped, each segment still uses the one it was created with
+	cold, err = NewSegment(WithFilePath(tempDir), WithMaxStoreBytes(1<<16))
+	require.NoError(t, err)
+	defer cold.Close()
+	hot, err = NewSegment(WithFilePath(tempDir), WithMaxStoreBytes(1<<16), WithInitialOffset(16), WithSegmentCompression(store.GzipCompression))
+	require.NoError(t, err)
+	defer hot.Close()
+
+	require.Equal(t, store.GzipCompression, cold.store.Compression())
+	require.Equal(t, store.NoCompression, hot.store.Compression())
+	for _, seg := range []*Segment{cold, hot} {
+		got, err := seg.Read(seg.BaseOffset())
+		require.NoError(t, err)
+		require.Equal(t, want.Value, got.Value)
+	}
+}
+
+func TestSegmentOffsetConflict(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-offset-conflict-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	seg, err := NewSegment(WithFilePath(tempDir))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := seg.Append(&api.Record{Value: []byte("value")})
+		require.NoError(t, err)
+	}
+	require.NoError(t, seg.Close())
+
+	// Reopening at the offset the files were written for is fine
+	seg, err = NewSegment(WithFilePath(tempDir))
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), seg.NextOffset())
+	require.NoError(t, seg.Close())
+
+	// The same files under another base offset's names are refused
+	opts := DefaultOptions()
+	for _, p := range []func(string, uint64) string{opts.storePath, opts.indexPath} {
+		require.NoError(t, os.Rename(p(tempDir, 0), p(tempDir, 5)))
+	}
+	_, err = NewSegment(WithFilePath(tempDir), WithInitialOffset(5))
+	require.ErrorIs(t, err, ErrSegmentOffsetConflict)
+
+	// Empty files never conflict
+	empty, err := NewSegment(WithFilePath(tempDir), WithInitialOffset(10))
+	require.NoError(t, err)
+	require.NoError(t, empty.Close())
+	empty, err = NewSegment(WithFilePath(tempDir), WithInitialOffset(10))
+	require.NoError(t, err)
+	require.NoError(t, empty.Close())
+
+	// Neither does a last entry pointing past the store, that is left to ValidateIndex and Repair
+	seg, err = NewSegment(WithFilePath(tempDir), WithInitialOffset(20))
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err := seg.Append(&api.Record{Value: []byte("value")})
+		require.NoError(t, err)
+	}
+	require.NoError(t, seg.Close())
+	indexFile, err := os.OpenFile(opts.indexPath(tempDir, 20), os.O_RDWR, 0644)
+	require.NoError(t, err)
+	_, err = indexFile.WriteAt(bytes.Repeat([]byte{0xff}, 8), int64(index.HeaderLength)+20+4)
+	require.NoError(t, err)
+	require.NoError(t, indexFile.Close())
+
+	seg, err = NewSegment(WithFilePath(tempDir), WithInitialOffset(20))
+	require.NoError(t, err)
+	defer seg.Close()
+	require.Error(t, seg.ValidateIndex(seg.StoreSize()))
+	require.NoError(t, seg.Repair())
+	record, err := seg.Read(21)
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), record.Value)
+}
+
+func TestSegmentAppendFromReader(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-append-from-reader-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	seg, err := NewSegment(WithFilePath(tempDir), WithMaxIndexBytes(5*20))
+	require.NoError(t, err)
+	defer seg.Close()
+
+	// Frame more records than the segment can take, each behind its length like in the store
+	var stream bytes.Buffer
+	for i := 0; i < 7; i++ {
+		p, err := seg.marshal(&api.Record{Value: []byte(fmt.Sprintf("streamed %d", i))})
+		require.NoError(t, err)
+		require.NoError(t, binary.Write(&stream, binary.BigEndian, uint64(len(p))))
+		stream.Write(p)
+	}
+	total := stream.Len()
+
+	// Reads that end half way through a record pick it up on the next call
+	var offsets []uint64
+	consumed := 0
+	for consumed < total {
+		n, offs, err := seg.AppendFromReader(&stream, 13)
+		consumed += n
+		offsets = append(offsets, offs...)
+		if errors.Is(err, ErrSegmentFull) {
+			break
+		}
+		require.NoError(t, err)
+	}
+	require.Equal(t, []uint64{0, 1, 2, 3, 4}, offsets)
+	for i, off := range offsets {
+		record, err := seg.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, []byte(fmt.Sprintf("streamed %d", i)), record.Value)
+	}
+
+	// Once full, nothing more is read, and what was read but not appended is handed back
+	n, offs, err := seg.AppendFromReader(&stream, int64(total))
+	require.ErrorIs(t, err, ErrSegmentFull)
+	require.Empty(t, offs)
+	require.Zero(t, n)
+
+	next, err := NewSegment(WithFilePath(tempDir), WithInitialOffset(5))
+	require.NoError(t, err)
+	defer next.Close()
+	rest := io.MultiReader(bytes.NewReader(seg.Unread()), &stream)
+	require.Empty(t, seg.Unread())
+
+	// The next segment picks up the records the full one could not take
+	_, offs, err = next.AppendFromReader(rest, int64(total))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{5, 6}, offs)
+	for _, off := range offs {
+		record, err := next.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, []byte(fmt.Sprintf("streamed %d", off)), record.Value)
+	}
+}
+
+func TestSegmentStoreBufferSize(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-store-buffer-size-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// Without the option the store keeps its own default
+	seg, err := NewSegment(WithFilePath(tempDir), WithMaxStoreBytes(1<<20))
+	require.NoError(t, err)
+	require.Equal(t, 4096, seg.store.Buf().Size())
+	require.NoError(t, seg.Close())
+
+	// The buffer size reaches the store, and records larger than the default go through it intact
+	seg, err = NewSegment(WithFilePath(tempDir), WithMaxStoreBytes(1<<20), WithStoreBufferSize(64<<10))
+	require.NoError(t, err)
+	defer seg.Close()
+	require.Equal(t, 64<<10, seg.store.Buf().Size())
+
+	value := bytes.Repeat([]byte("buffered "), 1000)
+	off, err := seg.Append(&api.Record{Value: value})
+	require.NoError(t, err)
+	record, err := seg.Read(off)
+	require.NoError(t, err)
+	require.Equal(t, value, record.Value)
+}
+
+func TestSegmentIsFullUnevenIndexBytes(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-isfull-uneven-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// The index has room for two 20 byte entries and half of a third
+	seg, err := NewSegment(WithFilePath(tempDir), WithMaxStoreBytes(1<<20), WithMaxIndexBytes(50))
+	require.NoError(t, err)
+	defer seg.Close()
+
+	for i := 0; i < 2; i++ {
+		require.False(t, seg.IsFull())
+		_, err := seg.Append(&api.Record{Value: []byte("uneven")})
+		require.NoError(t, err)
+	}
+
+	// Full as soon as no whole entry fits, so the log rotates instead of failing the next append
+	require.True(t, seg.IsFull())
+	require.Equal(t, uint64(2), seg.index.EntryCount())
+}
+
+func TestSegmentReadOffsetNotFound(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-offset-not-found-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	seg, err := NewSegment(WithFilePath(tempDir), WithInitialOffset(16))
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		_, err := seg.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+
+	// Compaction leaves a hole at 17
+	compacted, err := seg.Compact(func(record *api.Record) bool { return record.Offset != 17 })
+	require.NoError(t, err)
+	defer compacted.Close()
+
+	// Below the segment, in the hole and past the end the error names the offset that was asked for
+	for _, off := range []uint64{15, 17, 20} {
+		_, err := compacted.Read(off)
+		var notFound *ErrOffsetNotFound
+		require.ErrorAs(t, err, &notFound)
+		require.Equal(t, off, notFound.Offset)
+		require.ErrorIs(t, err, io.EOF)
+
+		_, err = compacted.Position(off)
+		require.ErrorAs(t, err, &notFound)
+	}
+
+	// The records around the hole are still there
+	for _, off := range []uint64{16, 18, 19} {
+		record, err := compacted.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, off, record.Offset)
+	}
+}
+
+func TestSegmentCompactKeepsNextOffset(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "segment-compact-next-offset-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	seg, err := NewSegment(WithFilePath(tempDir), WithInitialOffset(16))
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		_, err := seg.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+
+	// Compact away the two records at the tail
+	compacted, err := seg.Compact(func(record *api.Record) bool { return record.Offset < 18 })
+	require.NoError(t, err)
+	require.Equal(t, uint64(20), compacted.NextOffset())
+	require.NoError(t, compacted.Close())
+
+	// Neither reopening nor rebuilding the index hands their offsets out again
+	reopened, err := NewSegment(WithFilePath(tempDir), WithInitialOffset(16))
+	require.NoError(t, err)
+	require.Equal(t, uint64(20), reopened.NextOffset())
+	require.NoError(t, reopened.Repair())
+	require.Equal(t, uint64(20), reopened.NextOffset())
+	off, err := reopened.Append(&api.Record{Value: []byte("record 4")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(20), off)
+
+	// Truncating back below the mark frees the offsets after it for good
+	require.NoError(t, reopened.TruncateAfter(16))
+	require.NoError(t, reopened.Close())
+	reopened, err = NewSegment(WithFilePath(tempDir), WithInitialOffset(16))
+	require.NoError(t, err)
+	defer reopened.Close()
+	require.Equal(t, uint64(17), reopened.NextOffset())
+}
+
+func TestSegmentRewriteKeepsCreatedAt(t *testing.T) {
+	dir := t.TempDir()
+	created := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+
+	// Two consecutive segments, created a day apart
+	segments := make([]*Segment, 2)
+	for i := range segments {
+		s, err := NewSegment(WithFilePath(dir), WithInitialOffset(uint64(i*2)))
+		require.NoError(t, err)
+		for j := 0; j < 2; j++ {
+			_, err := s.Append(&api.Record{Value: []byte("record")})
+			require.NoError(t, err)
+		}
+		require.NoError(t, s.GetStore().SetCreatedAt(created.Add(time.Duration(i)*24*time.Hour)))
+		segments[i] = s
+	}
+
+	// A compacted segment is as old as the original
+	compacted, err := segments[0].Compact(func(record *api.Record) bool { return record.Offset > 0 })
+	require.NoError(t, err)
+	require.True(t, created.Equal(compacted.GetStore().Meta().CreatedAt))
+
+	// A merged one is as old as the newest it was merged from
+	merged, err := Merge([]*Segment{compacted, segments[1]})
+	require.NoError(t, err)
+	defer merged.Close()
+	require.True(t, created.Add(24*time.Hour).Equal(merged.GetStore().Meta().CreatedAt))
+}
@@ -0,0 +1,78 @@
+package segment
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Width of the length prefix in front of every record AppendFromReader reads, the same as in the store
+const streamLenWidth = 8
+
+// AppendFromReader reads up to maxBytes from r and appends every complete record in it, so producers with
+// their records in a stream never have to hold all of it in memory. Records are framed like in the store,
+// as a big endian uint64 length followed by the record encoded with the segment's RecordEncoding.
+// A record cut off at the end of what was read is kept and completed by the next call.
+// It returns the number of bytes read from r and the offsets of the records appended. A record that cannot
+// be decoded or appended is dropped and its error returned, so later records still line up. Once the segment
+// is full nothing more is read from r and ErrSegmentFull is returned; the records read but not appended
+// are left for Unread, to be passed on to the next segment.
+func (s *Segment) AppendFromReader(r io.Reader, maxBytes int64) (n int, offsets []uint64, err error) {
+	s.readerMutex.Lock()
+	defer s.readerMutex.Unlock()
+
+	// A full segment takes nothing, so there is no point in reading more that would have to be handed back
+	if s.IsFull() {
+		return 0, nil, ErrSegmentFull
+	}
+
+	buf := bytes.NewBuffer(s.pending)
+	read, err := buf.ReadFrom(io.LimitReader(r, maxBytes))
+	n = int(read)
+
+	// Whatever was read before a failing reader gave up is still appended
+	data := buf.Bytes()
+	for len(data) >= streamLenWidth {
+		size := binary.BigEndian.Uint64(data[:streamLenWidth])
+		if uint64(len(data)-streamLenWidth) < size {
+			break
+		}
+
+		if s.IsFull() {
+			err = ErrSegmentFull
+			break
+		}
+
+		frame := data[streamLenWidth : streamLenWidth+size]
+		data = data[streamLenWidth+size:]
+
+		record, decodeErr := s.unmarshal(frame)
+		if decodeErr != nil {
+			err = decodeErr
+			break
+		}
+		off, appendErr := s.Append(record)
+		if appendErr != nil {
+			err = appendErr
+			break
+		}
+		offsets = append(offsets, off)
+	}
+
+	// Copied, since data shares its memory with the buffer the next call reads into
+	s.pending = append([]byte(nil), data...)
+
+	return n, offsets, err
+}
+
+// Unread returns the bytes AppendFromReader has read from its reader but not appended, and forgets them.
+// They start with the first record left out, so once the segment is full, reading them back ahead of the
+// rest of the stream, e.g. with io.MultiReader, carries on in the next segment without losing a record.
+func (s *Segment) Unread() []byte {
+	s.readerMutex.Lock()
+	defer s.readerMutex.Unlock()
+
+	pending := s.pending
+	s.pending = nil
+	return pending
+}
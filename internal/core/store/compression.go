@@ -0,0 +1,80 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// CompressionAlgo selects how entries are compressed in the store file.
+// It is recorded in the store's metadata sidecar when the store is created, and a reopened store
+// always uses the recorded one, whatever was asked for with WithCompression.
+type CompressionAlgo string
+
+const (
+	// NoCompression stores entries as they are. This is the default.
+	NoCompression CompressionAlgo = ""
+
+	// GzipCompression compresses every entry on its own with gzip
+	GzipCompression CompressionAlgo = "gzip"
+)
+
+// Compress entries with algo when the store is created
+func WithCompression(algo CompressionAlgo) StoreOptions {
+	return func(opts *Options) {
+		opts.Compression = algo
+	}
+}
+
+// compress returns entry as it is written to the store
+func (store *Store) compress(entry []byte) ([]byte, error) {
+	return Compress(store.meta.Compression, entry)
+}
+
+// decompress returns the entry stored as data
+func (store *Store) decompress(data []byte) ([]byte, error) {
+	return Decompress(store.meta.Compression, data)
+}
+
+// Compress returns p compressed with algo. With NoCompression p itself is returned.
+func Compress(algo CompressionAlgo, p []byte) ([]byte, error) {
+	switch algo {
+	case NoCompression:
+		return p, nil
+	case GzipCompression:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(p); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %q", algo)
+	}
+}
+
+// Decompress returns p, compressed with algo, as it was before compression
+func Decompress(algo CompressionAlgo, p []byte) ([]byte, error) {
+	switch algo {
+	case NoCompression:
+		return p, nil
+	case GzipCompression:
+		r, err := gzip.NewReader(bytes.NewReader(p))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("unknown compression %q", algo)
+	}
+}
+
+// Compression returns the algorithm the store's entries are compressed with
+func (store *Store) Compression() CompressionAlgo {
+	return store.meta.Compression
+}
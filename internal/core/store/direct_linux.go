@@ -0,0 +1,110 @@
+package store
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// O_DIRECT needs buffers, file offsets and write sizes aligned to the device's block size
+const directIOAlignment = 512
+
+// Size of the aligned buffer writes go through, a multiple of directIOAlignment
+const directIOBufferSize = 64 * 1024
+
+// alignedWriter writes to a file opened with O_DIRECT, bypassing the OS page cache.
+// Every write covers whole aligned blocks, so the last partial block is padded out on disk,
+// kept in memory, and written again together with the next data. The file is truncated back
+// to its logical size after each write so the padding never becomes part of the store.
+type alignedWriter struct {
+	f     *os.File
+	block []byte // Page aligned, allocated with mmap
+	off   int64  // Aligned file offset that block[0] is written to
+	tail  int    // Bytes of the partial last block held at the start of block
+}
+
+// openDirect opens path with O_DIRECT for writing, positioned at size.
+// Reads keep going through the store's regular file handle, since O_DIRECT reads
+// would need the same alignment as writes.
+func openDirect(path string, size uint64, r io.ReaderAt) (directWriter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|syscall.O_DIRECT, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// An anonymous mapping is page aligned, which satisfies O_DIRECT's buffer alignment
+	block, err := syscall.Mmap(-1, 0, directIOBufferSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	w := &alignedWriter{f: f, block: block}
+	if err := w.reset(size, r); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *alignedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		// Fill the buffer after whatever partial block it already holds
+		n := copy(w.block[w.tail:], p[written:])
+		end := w.tail + n
+
+		// Pad the write out to a whole number of blocks
+		padded := alignUp(end)
+		for i := end; i < padded; i++ {
+			w.block[i] = 0
+		}
+		if _, err := w.f.WriteAt(w.block[:padded], w.off); err != nil {
+			return written, err
+		}
+
+		// Cut the padding back off so the file only holds real data
+		if err := w.f.Truncate(w.off + int64(end)); err != nil {
+			return written, err
+		}
+
+		// Keep the trailing partial block around for the next write
+		full := end &^ (directIOAlignment - 1)
+		copy(w.block, w.block[full:end])
+		w.off += int64(full)
+		w.tail = end - full
+		written += n
+	}
+
+	return written, nil
+}
+
+// reset moves the writer to size, reloading the partial block it ends in from r.
+// The store calls it after the file was truncated underneath the writer.
+func (w *alignedWriter) reset(size uint64, r io.ReaderAt) error {
+	w.off = int64(size) &^ (directIOAlignment - 1)
+	w.tail = int(int64(size) - w.off)
+
+	if w.tail > 0 {
+		if _, err := r.ReadAt(w.block[:w.tail], w.off); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *alignedWriter) Close() error {
+	if err := syscall.Munmap(w.block); err != nil {
+		return err
+	}
+	w.block = nil
+
+	return w.f.Close()
+}
+
+// alignUp rounds n up to the next multiple of directIOAlignment
+func alignUp(n int) int {
+	return (n + directIOAlignment - 1) &^ (directIOAlignment - 1)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package store
+
+import (
+	"io"
+	"log"
+)
+
+// openDirect reports that direct I/O is unavailable, so the store falls back to buffered writes
+func openDirect(path string, size uint64, r io.ReaderAt) (directWriter, error) {
+	log.Printf("store: direct I/O is only supported on Linux, falling back to buffered writes for %s", path)
+	return nil, nil
+}
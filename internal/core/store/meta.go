@@ -0,0 +1,139 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Version of the store format recorded in new meta files
+const storeVersion = "1"
+
+// Suffix of the sidecar file holding a store's metadata
+const metaSuffix = ".meta.json"
+
+// StoreMeta describes a store without having to read its entries.
+// RecordCount is only written to disk on Sync and Close, so after a crash it may lag behind the store.
+type StoreMeta struct {
+	CreatedAt    time.Time `json:"created_at"`
+	RecordCount  uint64    `json:"record_count"`
+	StoreVersion string    `json:"store_version"`
+
+	// Algorithm every entry is compressed with, fixed when the store is created
+	Compression CompressionAlgo `json:"compression,omitempty"`
+
+	// Offset the segment on top of the store hands out next, when it is past the last entry's,
+	// e.g. after compaction dropped the entries at the tail. The store itself never reads it.
+	NextOffset uint64 `json:"next_offset,omitempty"`
+}
+
+// MetaPath returns the path of the metadata sidecar for the store file at path
+func MetaPath(path string) string {
+	return path + metaSuffix
+}
+
+// Meta returns the store's current metadata
+func (store *Store) Meta() StoreMeta {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	meta := store.meta
+	meta.RecordCount = atomic.LoadUint64(&store.meta.RecordCount)
+	return meta
+}
+
+// SetNextOffset records off as StoreMeta.NextOffset and saves the metadata right away
+func (store *Store) SetNextOffset(off uint64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.meta.NextOffset = off
+	return store.saveMeta()
+}
+
+// SetCreatedAt records t as StoreMeta.CreatedAt and saves the metadata right away,
+// e.g. for a store rewritten from an older one that should age like it
+func (store *Store) SetCreatedAt(t time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.meta.CreatedAt = t
+	return store.saveMeta()
+}
+
+// setupMeta loads the store's metadata sidecar, or creates it when there is none yet.
+// A store that already holds entries but has no sidecar gets its entries counted.
+// compression and createdAt are only recorded for a new sidecar, an existing one keeps what it says.
+func (store *Store) setupMeta(compression CompressionAlgo, createdAt time.Time) error {
+	data, err := os.ReadFile(MetaPath(store.File.Name()))
+	if err == nil {
+		return json.Unmarshal(data, &store.meta)
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	store.meta = StoreMeta{
+		CreatedAt:    createdAt,
+		StoreVersion: storeVersion,
+		Compression:  compression,
+	}
+	if size := store.size.Load(); size > 0 {
+		count, err := store.countEntries(size)
+		if err != nil {
+			return err
+		}
+		store.meta.RecordCount = count
+	}
+
+	return store.saveMeta()
+}
+
+// saveMeta atomically replaces the metadata sidecar with the in-memory metadata
+func (store *Store) saveMeta() error {
+	meta := store.meta
+	meta.RecordCount = atomic.LoadUint64(&store.meta.RecordCount)
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a torn meta file behind
+	metaPath := MetaPath(store.File.Name())
+	tmp := metaPath + ".tmp"
+	if err := writeFileSync(tmp, data, store.filePerm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, metaPath)
+}
+
+// writeFileSync is os.WriteFile, syncing the file before closing it. Without the sync a crash after a rename
+// can leave the renamed file empty, since the rename may reach the disk before the data does.
+func writeFileSync(name string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// countEntries returns the number of complete entries in the first size bytes of the store
+func (store *Store) countEntries(size uint64) (uint64, error) {
+	var count uint64
+	_, err := store.scan(size, func(pos uint64, data []byte) error {
+		count++
+		return nil
+	})
+	return count, err
+}
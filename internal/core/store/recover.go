@@ -0,0 +1,115 @@
+package store
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// OpenStore is NewStore for a store file a crash may have cut off in the middle of an append.
+// A trailing entry whose length prefix or data was only partly written is cut off the file before
+// the store is opened, and truncated reports how many bytes that dropped, so the caller can decide
+// whether to carry on. Only the length prefixes are read to find the end, never the entries themselves,
+// and only from the position set with WithTailStart on, so a caller that knows where the last complete
+// entry starts, like a segment from its index, does not pay for reading the whole file on every open.
+// A length prefix running past the end that complete entries still follow is corruption rather than
+// a torn append; OpenStore fails with ErrCorruptEntry instead of cutting those entries off.
+func OpenStore(optFns ...StoreOptions) (store *Store, truncated int64, err error) {
+	return newStore(true, optFns)
+}
+
+// WithTailStart tells OpenStore that an entry starts at pos, which everything before is known to be complete,
+// e.g. the last entry a segment's index points at. If no entry can be read there, the whole file is checked.
+func WithTailStart(pos uint64) StoreOptions {
+	return func(opts *Options) {
+		opts.TailStart = pos
+	}
+}
+
+// completeEnd returns where the last complete entry in the first size bytes of r ends, walking the length
+// prefixes forward from the entry starting at from. Entries carry nothing but their length prefix, so they
+// can only be walked from a known start; reading backwards from the end could mistake data for a prefix.
+func completeEnd(r io.ReaderAt, from, size uint64, enc binary.ByteOrder, pageSize uint64) (uint64, error) {
+	end, err := walkEntries(r, from, size, enc, pageSize)
+	if err != nil {
+		return 0, err
+	}
+
+	// Not even the entry the caller vouched for is there, so the hint cannot be trusted
+	if end == from && from > 0 && end < size {
+		return completeEnd(r, 0, size, enc, pageSize)
+	}
+	if end == size {
+		return end, nil
+	}
+
+	// Whatever follows the last complete entry should be a single entry an append left unfinished
+	tail := make([]byte, size-end)
+	if _, err := r.ReadAt(tail, int64(end)); err != nil && err != io.EOF {
+		return 0, err
+	}
+	if entriesFollow(tail, end, enc, pageSize) {
+		return 0, ErrCorruptEntry
+	}
+
+	return end, nil
+}
+
+// walkEntries returns where the last complete entry from from on ends, or from when there is none
+func walkEntries(r io.ReaderAt, from, size uint64, enc binary.ByteOrder, pageSize uint64) (uint64, error) {
+	pos, end := from, from
+	prefix := make([]byte, wordLength)
+	for pos+uint64(wordLength) <= size {
+		if _, err := r.ReadAt(prefix, int64(pos)); err != nil {
+			return end, err
+		}
+
+		// An entry running past the end was only partially written
+		dataSize := enc.Uint64(prefix)
+		if dataSize > size-pos-uint64(wordLength) {
+			break
+		}
+
+		// The next entry starts on the following page boundary when the store is aligned
+		end = pos + uint64(wordLength) + dataSize
+		pos = end
+		if pageSize > 0 && pos%pageSize != 0 {
+			pos += pageSize - pos%pageSize
+		}
+	}
+
+	return end, nil
+}
+
+// entriesFollow reports whether the tail of a store, starting at byte position start, holds a run of complete
+// entries with some data in them that ends exactly where the file does. A torn append leaves nothing like that
+// behind, but a corrupt length prefix in front of entries that were written in full does.
+func entriesFollow(tail []byte, start uint64, enc binary.ByteOrder, pageSize uint64) bool {
+	size := uint64(len(tail))
+	for q := uint64(1); q+uint64(wordLength) <= size; q++ {
+		// Entries of an aligned store only ever start on a page boundary
+		if pageSize > 0 && (start+q)%pageSize != 0 {
+			continue
+		}
+
+		pos, data := q, false
+		for pos+uint64(wordLength) <= size {
+			dataSize := enc.Uint64(tail[pos : pos+uint64(wordLength)])
+			if dataSize > size-pos-uint64(wordLength) {
+				break
+			}
+			data = data || dataSize > 0
+			pos += uint64(wordLength) + dataSize
+			if pos == size {
+				break
+			}
+			if pageSize > 0 && (start+pos)%pageSize != 0 {
+				pos += pageSize - (start+pos)%pageSize
+			}
+		}
+		if pos == size && data {
+			return true
+		}
+	}
+
+	return false
+}
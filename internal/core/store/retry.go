@@ -0,0 +1,41 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"syscall"
+	"time"
+)
+
+// retryWriter wraps the store's backing writer and retries writes that were interrupted by a signal.
+// bufio.Writer remembers the first error it sees and fails every flush after it,
+// so retries have to happen underneath the buffer rather than around Flush.
+type retryWriter struct {
+	w          io.Writer
+	maxRetries int
+}
+
+func (r *retryWriter) Write(p []byte) (int, error) {
+	written := 0
+	for attempt := 0; ; attempt++ {
+		// Resume from wherever the previous attempt left off
+		n, err := r.w.Write(p[written:])
+		written += n
+		if err == nil {
+			return written, nil
+		}
+
+		// Only interrupted or would-block writes are worth another try
+		if !isRetryable(err) || attempt >= r.maxRetries {
+			return written, err
+		}
+
+		// Back off a little longer on every attempt
+		time.Sleep(time.Duration(attempt+1) * time.Millisecond)
+	}
+}
+
+// isRetryable reports whether err is a transient EINTR or EAGAIN from the OS.
+func isRetryable(err error) bool {
+	return errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN)
+}
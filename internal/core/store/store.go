@@ -2,22 +2,38 @@ package store
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
+	"io"
 	"os"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	enc        = binary.BigEndian
 	wordLength = 8
 )
 
+// ErrCorruptEntry is returned when an entry's length prefix points past the end of the store.
+// This is what reading a store with a different byte order than it was written with looks like.
+var ErrCorruptEntry = errors.New("entry length exceeds store bounds")
+
+// ErrStoreFull is returned by Append once the store has reached the size set with WithMaxFileSize
+var ErrStoreFull = errors.New("store has reached its maximum file size")
+
+// ErrSeekOutOfBounds is returned by Seek for a position past the end of the store
+var ErrSeekOutOfBounds = errors.New("seek position is past the end of the store")
+
 // These options are good to start with
 // Will look into other options as time moves on.
 // Options like:
 //	- Asynchronous Writing
-//	- Compression
 //	- File Rollover
 //	- Auto-Flush Interval
 
@@ -26,25 +42,85 @@ type Options struct {
 	File       *os.File
 	FilePath   string
 	IsOpen     bool
+	MaxRetries int
+	Encoding   binary.ByteOrder
+	DirectIO   bool
+
+	PageAlignment uint64
+	MaxFileSize   uint64
+	Compression   CompressionAlgo
+	Tracer        trace.Tracer
+	FilePerm      os.FileMode
+
+	// How far apart positions can be for MultiRead to read them with one ReadAt
+	MultiReadWindow uint64
+
+	// Where OpenStore starts looking for a torn tail, see WithTailStart
+	TailStart uint64
+
+	// Tells the time a new store's metadata records as its creation time, see WithNow
+	Now func() time.Time
 }
 
 // Represents a function that applies configuration options to an Options instance
 type StoreOptions func(*Options)
 
 type Store struct {
-	Mutex sync.Mutex
-	Buf   *bufio.Writer
-	Size  uint64
+	mutex sync.Mutex
+	buf   *bufio.Writer
+	out   io.Writer // What buf writes to
+	enc   binary.ByteOrder
+
+	// Bytes in the store, written under mutex but atomic so Position can read it without the lock
+	size atomic.Uint64
+
+	// Set when writes bypass the page cache, see WithDirectIO
+	direct directWriter
+
+	// Entries start on multiples of pageSize when it is set, see WithPageAlignment
+	pageSize uint64
+	zeros    []byte
+
+	// Append refuses new entries once the store is this large, see WithMaxFileSize
+	maxSize uint64
+
+	// Kept in the sidecar file at MetaPath
+	meta StoreMeta
+
+	// Traces Append and Read when set, see WithTracer
+	tracer trace.Tracer
+
+	// Permissions of the files the store creates, see WithFilePerm
+	filePerm os.FileMode
+
+	// See WithMultiReadWindow
+	multiReadWindow uint64
+
+	// Where ReadNext reads from, set with Seek. Kept apart from the file's offset, which appends may write at.
+	cursor uint64
 
 	*os.File // File pointer to write logs to; if nil, the store will not be associated with a file initially
 }
 
+// directWriter is the platform specific writer behind WithDirectIO
+type directWriter interface {
+	io.WriteCloser
+
+	// reset repositions the writer at size after the file was truncated underneath it
+	reset(size uint64, r io.ReaderAt) error
+}
+
 // Default settings for store
 func DefaultOptions() *Options {
 	return &Options{
 		BufferSize: 4096,              // Default buffer size
 		File:       nil,               // nil pointer
 		FilePath:   "./default.store", // destination of temp generate
+		Encoding:   binary.BigEndian,  // Byte order of the length prefixes
+		FilePerm:   0644,              // Permissions of created files
+		Now:        time.Now,          // Creation time of a new store
+
+		MultiReadWindow: 64 << 10, // Nearby reads are merged up to 64 KiB apart
 	}
 }
 
@@ -69,10 +145,82 @@ func WithBufferSize(size uint64) StoreOptions {
 	}
 }
 
+// Set the byte order used for length prefixes.
+// A store must always be read with the same byte order it was written with.
+func WithEncoding(order binary.ByteOrder) StoreOptions {
+	return func(opts *Options) {
+		opts.Encoding = order
+	}
+}
+
+// Retry flushes that fail with EINTR or EAGAIN up to maxRetries times
+func WithRetryOnInterrupt(maxRetries int) StoreOptions {
+	return func(opts *Options) {
+		opts.MaxRetries = maxRetries
+	}
+}
+
+// Open the store file with O_DIRECT so writes bypass the OS page cache, which the store's own buffer makes redundant.
+// Reads still go through the page cache. Only Linux supports this, other platforms log a warning and write normally.
+func WithDirectIO(direct bool) StoreOptions {
+	return func(opts *Options) {
+		opts.DirectIO = direct
+	}
+}
+
+// Start every entry on a multiple of pageSize, padding the store with null bytes as needed.
+// Reads of randomly accessed entries then touch as few pages as possible, at the cost of the padding.
+// A store must always be reopened with the same alignment, since Scan skips the padding based on it.
+// The default of 0 disables alignment.
+func WithPageAlignment(pageSize uint64) StoreOptions {
+	return func(opts *Options) {
+		opts.PageAlignment = pageSize
+	}
+}
+
+// Stop accepting entries once the store file holds n bytes, with Append returning ErrStoreFull.
+// The entry that crosses n is still written whole, so the file can end up somewhat larger than n.
+// The default of 0 lets the store grow without bound.
+func WithMaxFileSize(n uint64) StoreOptions {
+	return func(opts *Options) {
+		opts.MaxFileSize = n
+	}
+}
+
+// Create the store file, when opened by path, and the metadata sidecar with perm instead of the default 0644.
+// The process umask still applies on top of it.
+func WithFilePerm(perm os.FileMode) StoreOptions {
+	return func(opts *Options) {
+		opts.FilePerm = perm
+	}
+}
+
+// Record the creation time of a new store in its metadata with now instead of time.Now, so it follows the
+// clock of whoever ages the store out. A reopened store keeps the time its metadata already records.
+func WithNow(now func() time.Time) StoreOptions {
+	return func(opts *Options) {
+		opts.Now = now
+	}
+}
+
+// Set how far apart, in bytes, positions passed to MultiRead can be to still be read with a single ReadAt.
+// A larger window trades bytes read for syscalls saved; 0 reads every entry on its own.
+func WithMultiReadWindow(n uint64) StoreOptions {
+	return func(opts *Options) {
+		opts.MultiReadWindow = n
+	}
+}
+
 // Creates a new store with the given options.
 // It initializes a store with a buffer of the specified size and associates it with the provided file, if any.
 // The function applies a series of StoreOptions functions to configure the store.
-func NewStore(optFns ...StoreOptions) (filestore *Store, err error) {
+func NewStore(optFns ...StoreOptions) (*Store, error) {
+	store, _, err := newStore(false, optFns)
+	return store, err
+}
+
+// newStore opens the store, first cutting off a partially written last entry when trimTail is set
+func newStore(trimTail bool, optFns []StoreOptions) (filestore *Store, truncated int64, err error) {
 	// Initialize with default options.
 	opts := DefaultOptions()
 
@@ -86,75 +234,248 @@ func NewStore(optFns ...StoreOptions) (filestore *Store, err error) {
 	// Check if a custom file is provided in options
 	if opts.File == nil {
 		// Open the default file, create if it does not exist, and set it to append mode
-		file, err = os.OpenFile(opts.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		// The file has to be readable too, entries are read back from it and counted for the metadata
+		file, err = os.OpenFile(opts.FilePath, os.O_APPEND|os.O_CREATE|os.O_RDWR, opts.FilePerm)
 		if err != nil {
-			return nil, err // Return an error if the file cannot be opened or created
+			return nil, 0, err // Return an error if the file cannot be opened or created
 		}
 	} else if opts.File != nil {
 		// If the file is already open, check if it's usable
 		if _, err := opts.File.Stat(); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
 		// Optionally, reset the file's offset or ensure it's ready for use
-		if _, err := opts.File.Seek(0, os.SEEK_END); err != nil {
-			return nil, err
+		if _, err := opts.File.Seek(0, io.SeekEnd); err != nil {
+			return nil, 0, err
 		}
 
 		file = opts.File
 	}
 
+	// Pick up where an existing store file left off, since new entries are appended after it
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	size := uint64(fileInfo.Size())
+
+	// Cut off a partially written last entry before anything is positioned after it
+	if trimTail {
+		from := opts.TailStart
+		if from > size {
+			from = 0
+		}
+		end, err := completeEnd(file, from, size, opts.Encoding, opts.PageAlignment)
+		if err != nil {
+			return nil, 0, err
+		}
+		if end < size {
+			if err := file.Truncate(int64(end)); err != nil {
+				return nil, 0, err
+			}
+			if _, err := file.Seek(0, io.SeekEnd); err != nil {
+				return nil, 0, err
+			}
+			truncated = int64(size - end)
+			size = end
+		}
+	}
+
+	// Write through a separate O_DIRECT handle when requested
+	var w io.Writer = file
+	var direct directWriter
+	if opts.DirectIO {
+		if direct, err = openDirect(file.Name(), size, file); err != nil {
+			return nil, 0, err
+		}
+		if direct != nil {
+			w = direct
+		}
+	}
+
+	// Wrap the writer so interrupted writes are retried when requested
+	if opts.MaxRetries > 0 {
+		w = &retryWriter{w: w, maxRetries: opts.MaxRetries}
+	}
+
 	// Create a buffered writer with the specified buffer size
-	buf := bufio.NewWriterSize(file, int(opts.BufferSize))
+	buf := bufio.NewWriterSize(w, int(opts.BufferSize))
 
 	// Return a new Store instance
-	return &Store{
+	store := &Store{
 		File:  file,
-		Buf:   buf,
-		Mutex: sync.Mutex{},
-		Size:  0, // Initial store size is 0.
-	}, nil
+		buf:   buf,
+		out:   w,
+		mutex: sync.Mutex{},
+		enc:   opts.Encoding,
+
+		direct: direct,
+
+		pageSize: opts.PageAlignment,
+		zeros:    make([]byte, opts.PageAlignment),
+
+		maxSize: opts.MaxFileSize,
+		tracer:  opts.Tracer,
 
+		filePerm:        opts.FilePerm,
+		multiReadWindow: opts.MultiReadWindow,
+	}
+
+	// Initial store size is whatever the file already holds.
+	store.size.Store(size)
+
+	// Load or create the metadata sidecar
+	if err := store.setupMeta(opts.Compression, opts.Now()); err != nil {
+		return nil, 0, err
+	}
+
+	return store, truncated, nil
 }
 
 func (store *Store) Append(entry []byte) (size uint64, pos uint64, err error) {
+	return store.AppendContext(context.Background(), entry)
+}
+
+// AppendContext is Append, tracing the append as a child of the span in ctx
+func (store *Store) AppendContext(ctx context.Context, entry []byte) (size uint64, pos uint64, err error) {
+	if store.tracer == nil {
+		return store.append(entry)
+	}
+	err = store.traced(ctx, "append", func() uint64 { return pos }, func() error {
+		size, pos, err = store.append(entry)
+		return err
+	})
+	return size, pos, err
+}
+
+// append is Append without tracing
+func (store *Store) append(entry []byte) (size uint64, pos uint64, err error) {
 	// Lock the store to prevent concurrent writes
-	store.Mutex.Lock()
-	defer store.Mutex.Unlock()
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// A full store takes nothing more
+	end := store.size.Load()
+	if store.maxSize > 0 && end >= store.maxSize {
+		return 0, 0, ErrStoreFull
+	}
+
+	totalWritten, position, err := store.write(entry, end)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Flush the buffer to ensure all data is written to the underlying writer
+	// Flushing is important to maintain data integrity
+	if err := store.buf.Flush(); err != nil {
+		return 0, 0, err
+	}
 
-	// Position holds the current size of the store,
+	// Only count the entry once it has actually reached the file
+	store.size.Add(totalWritten)
+	atomic.AddUint64(&store.meta.RecordCount, 1)
+
+	return totalWritten, position, nil
+}
+
+// AppendBatch appends entries like Append, but flushes them to the file once for the whole batch.
+// It stops before an entry that would start at or past limit, zero meaning no limit, or once the store is full,
+// and returns the positions of the entries written. Should the flush fail, none of them count as written.
+func (store *Store) AppendBatch(entries [][]byte, limit uint64) ([]uint64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	start := store.size.Load()
+	end := start
+	positions := make([]uint64, 0, len(entries))
+	for _, entry := range entries {
+		if (limit > 0 && end >= limit) || (store.maxSize > 0 && end >= store.maxSize) {
+			break
+		}
+
+		written, position, err := store.write(entry, end)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, position)
+		end += written
+	}
+	if len(positions) == 0 && len(entries) > 0 {
+		return nil, ErrStoreFull
+	}
+
+	if err := store.buf.Flush(); err != nil {
+		return nil, err
+	}
+
+	store.size.Add(end - start)
+	atomic.AddUint64(&store.meta.RecordCount, uint64(len(positions)))
+
+	return positions, nil
+}
+
+// write buffers entry, along with its length prefix and any padding in front of it, to go into the store at end.
+// It returns the number of bytes buffered and the position the entry starts at. The caller holds the mutex
+// and flushes the buffer.
+func (store *Store) write(entry []byte, end uint64) (size uint64, pos uint64, err error) {
+	// Compress the entry, if the store compresses. It is flushed to the file before Append or AppendBatch returns,
+	// so nothing holds on to the caller's buffer.
+	data, err := store.compress(entry)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Pad the store up to the next page boundary so the entry starts on one
+	var padding uint64
+	if store.pageSize > 0 && end%store.pageSize != 0 {
+		padding = store.pageSize - end%store.pageSize
+		if _, err := store.buf.Write(store.zeros[:padding]); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// Position holds the current size of the store plus any padding,
 	// which is also the position where new data will be appended.
-	position := store.Size
+	position := end + padding
 
 	// Write the length of the page first as a prefix
 	// This length prefix allows for knowing how much to read during retrieval
-	if err := binary.Write(store.Buf, enc, uint64(len(entry))); err != nil {
+	if err := binary.Write(store.buf, store.enc, uint64(len(data))); err != nil {
 		return 0, 0, err
 	}
 
 	// Write the contents of the page to the store
-	written, err := store.Buf.Write(entry)
+	written, err := store.buf.Write(data)
 	if err != nil {
 		return 0, 0, err
 	}
 
-	// Calculate the total number of bytes written (data + length prefix)
-	totalWritten := uint64(written + wordLength)
-	store.Size += totalWritten
+	// Calculate the total number of bytes written (padding + data + length prefix)
+	return padding + uint64(written+wordLength), position, nil
+}
+
+func (store *Store) Read(pos uint64) (data []byte, err error) {
+	return store.ReadContext(context.Background(), pos)
+}
 
-	// Flush the buffer to ensure all data is written to the underlying writer
-	// Flushing is important to maintain data integrity
-	if err := store.Buf.Flush(); err != nil {
-		return 0, 0, err
+// ReadContext is Read, tracing the read as a child of the span in ctx
+func (store *Store) ReadContext(ctx context.Context, pos uint64) (data []byte, err error) {
+	if store.tracer == nil {
+		return store.read(pos)
 	}
-
-	return totalWritten, position, nil
+	err = store.traced(ctx, "read", func() uint64 { return pos }, func() error {
+		data, err = store.read(pos)
+		return err
+	})
+	return data, err
 }
 
-func (store *Store) Read(pos uint64) ([]byte, error) {
+// read is Read without tracing
+func (store *Store) read(pos uint64) ([]byte, error) {
 	// Lock the store to prevent concurrent reads
-	store.Mutex.Lock()
-	defer store.Mutex.Unlock()
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
 
 	// Even if the client gave the option to not have a file initially,
 	// there still must be a file to read from they they have designated
@@ -180,7 +501,12 @@ func (store *Store) Read(pos uint64) ([]byte, error) {
 	}
 
 	// Decode the size using the same encoding used in writing
-	dataSize := enc.Uint64(sizeBuffer)
+	dataSize := store.enc.Uint64(sizeBuffer)
+
+	// A length running past the end of the file means the entry cannot be trusted
+	if dataSize > uint64(fileInfo.Size())-pos-uint64(wordLength) {
+		return nil, ErrCorruptEntry
+	}
 
 	// Allocate a slice to hold the actual data
 	data := make([]byte, dataSize)
@@ -190,20 +516,372 @@ func (store *Store) Read(pos uint64) ([]byte, error) {
 		return nil, err
 	}
 
-	return data, nil
+	return store.decompress(data)
+}
+
+// ReadRange returns the data of every entry from startPos up to endPos, which must be the position right after an entry.
+// The range is read with a single ReadAt and split in memory, so reading n consecutive entries costs one syscall
+// instead of n. Padding from WithPageAlignment is skipped. The returned slices share one buffer.
+func (store *Store) ReadRange(startPos, endPos uint64) ([][]byte, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.File == nil {
+		return nil, errors.New("store file is nil")
+	}
+	if startPos > endPos || endPos > store.size.Load() {
+		return nil, errors.New("range out of store bounds")
+	}
+
+	buf := make([]byte, endPos-startPos)
+	if _, err := store.File.ReadAt(buf, int64(startPos)); err != nil {
+		return nil, err
+	}
+
+	var entries [][]byte
+	for rel := uint64(0); rel < uint64(len(buf)); {
+		// Every length prefix has to fit in the range, along with the entry it announces
+		if uint64(len(buf))-rel < uint64(wordLength) {
+			return nil, ErrCorruptEntry
+		}
+		dataSize := store.enc.Uint64(buf[rel:])
+		if dataSize > uint64(len(buf))-rel-uint64(wordLength) {
+			return nil, ErrCorruptEntry
+		}
+
+		start := rel + uint64(wordLength)
+		data, err := store.decompress(buf[start : start+dataSize : start+dataSize])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, data)
+
+		// The next entry starts on the following page boundary when the store is aligned
+		rel = start + dataSize
+		if pos := startPos + rel; store.pageSize > 0 && pos%store.pageSize != 0 && rel < uint64(len(buf)) {
+			rel += store.pageSize - pos%store.pageSize
+		}
+	}
+
+	return entries, nil
+}
+
+// MultiRead returns the data of the entries at positions, in the same order as positions.
+// Positions are visited in ascending order, and those within the multi-read window of the first one of a group
+// are read with a single ReadAt and split in memory, see WithMultiReadWindow. Positions further apart are read
+// on their own. Entries of one group may share a buffer.
+func (store *Store) MultiRead(positions []uint64) ([][]byte, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.File == nil {
+		return nil, errors.New("store file is nil")
+	}
+	size := store.size.Load()
+
+	// Visit the positions in ascending order while remembering where each result goes
+	order := make([]int, len(positions))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return positions[order[a]] < positions[order[b]] })
+
+	entries := make([][]byte, len(positions))
+	for len(order) > 0 {
+		start := positions[order[0]]
+		n := 1
+		for n < len(order) && positions[order[n]]-start <= store.multiReadWindow {
+			n++
+		}
+		group := order[:n]
+		order = order[n:]
+
+		last := positions[group[n-1]]
+		if last+uint64(wordLength) > size {
+			return nil, errors.New("position out of store bounds")
+		}
+
+		// Read a window past the last position too, which usually takes in the whole of its entry
+		end := last + uint64(wordLength) + store.multiReadWindow
+		if end > size {
+			end = size
+		}
+		buf := make([]byte, end-start)
+		if _, err := store.File.ReadAt(buf, int64(start)); err != nil {
+			return nil, err
+		}
+
+		for _, i := range group {
+			rel := positions[i] - start
+			dataSize := store.enc.Uint64(buf[rel:])
+			if dataSize > size-positions[i]-uint64(wordLength) {
+				return nil, ErrCorruptEntry
+			}
+
+			// An entry running past what was read is read up to its end
+			dataEnd := rel + uint64(wordLength) + dataSize
+			if dataEnd > uint64(len(buf)) {
+				rest := make([]byte, dataEnd-uint64(len(buf)))
+				if _, err := store.File.ReadAt(rest, int64(start)+int64(len(buf))); err != nil {
+					return nil, err
+				}
+				buf = append(buf, rest...)
+			}
+
+			data, err := store.decompress(buf[rel+uint64(wordLength) : dataEnd : dataEnd])
+			if err != nil {
+				return nil, err
+			}
+			entries[i] = data
+		}
+	}
+
+	return entries, nil
+}
+
+// Scan calls fn with the position and data of every complete entry in the store, in order.
+// Padding from WithPageAlignment is skipped.
+// It stops early if fn returns an error, and stops quietly at an incomplete entry at the tail,
+// which is what a crash in the middle of an Append leaves behind.
+// It returns the position right after the last complete entry that was scanned.
+func (store *Store) Scan(fn func(pos uint64, data []byte) error) (uint64, error) {
+	// Flush so the scan sees everything appended so far, then read without holding the lock
+	store.mutex.Lock()
+	if err := store.buf.Flush(); err != nil {
+		store.mutex.Unlock()
+		return 0, err
+	}
+	size := store.size.Load()
+	store.mutex.Unlock()
+
+	return store.scan(size, func(pos uint64, data []byte) error {
+		data, err := store.decompress(data)
+		if err != nil {
+			return err
+		}
+		return fn(pos, data)
+	})
+}
+
+// scan is Scan over the first size bytes of the store, without flushing, locking or decompressing
+func (store *Store) scan(size uint64, fn func(pos uint64, data []byte) error) (uint64, error) {
+	var pos, end uint64
+	sizeBuffer := make([]byte, wordLength)
+	for pos+uint64(wordLength) <= size {
+		// Read the length prefix of the entry
+		if _, err := store.File.ReadAt(sizeBuffer, int64(pos)); err != nil {
+			return end, err
+		}
+		dataSize := store.enc.Uint64(sizeBuffer)
+
+		// An entry running past the end was only partially written
+		if dataSize > size-pos-uint64(wordLength) {
+			break
+		}
+
+		data := make([]byte, dataSize)
+		if _, err := store.File.ReadAt(data, int64(pos)+int64(wordLength)); err != nil {
+			return end, err
+		}
+		if err := fn(pos, data); err != nil {
+			return end, err
+		}
+
+		// The next entry starts on the following page boundary when the store is aligned
+		end = pos + uint64(wordLength) + dataSize
+		pos = end
+		if store.pageSize > 0 && pos%store.pageSize != 0 {
+			pos += store.pageSize - pos%store.pageSize
+		}
+	}
+
+	return end, nil
+}
+
+// errFound stops a Scan once PositionOf found what it was looking for
+var errFound = errors.New("entry found")
+
+// PositionOf returns the position of the first entry whose data equals needle.
+// It is a linear scan over the whole store, meant for debugging and recovery tooling only;
+// everything else should look positions up through the index.
+func (store *Store) PositionOf(needle []byte) (uint64, bool) {
+	var found uint64
+	_, err := store.Scan(func(pos uint64, data []byte) error {
+		if bytes.Equal(data, needle) {
+			found = pos
+			return errFound
+		}
+		return nil
+	})
+	if err != errFound {
+		return 0, false
+	}
+
+	return found, true
+}
+
+// ReadAt reads len(p) raw bytes starting at byte offset off in the store file.
+// It bypasses the length-prefix framing and does not take the store's lock, since
+// os.File.ReadAt is safe for concurrent use. Only diagnostic and recovery tooling
+// should need it; everything else should go through Read.
+func (store *Store) ReadAt(p []byte, off int64) (int, error) {
+	return store.File.ReadAt(p, off)
+}
+
+// Truncate discards everything in the store from pos onwards
+func (store *Store) Truncate(pos uint64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// Nothing beyond the end of the store to discard
+	if pos > store.size.Load() {
+		return errors.New("position out of store bounds")
+	}
+
+	// Appends flush before they are counted, so anything still buffered belongs to an append that failed.
+	// Drop it along with the buffer's error, so it never lands past the new end later on.
+	store.buf.Reset(store.out)
+
+	if err := store.File.Truncate(int64(pos)); err != nil {
+		return err
+	}
+	store.size.Store(pos)
+
+	// Recount what is left, there is no telling how many entries were cut off
+	count, err := store.countEntries(pos)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint64(&store.meta.RecordCount, count)
+
+	// The direct writer keeps the last partial block in memory, which may now be stale
+	if store.direct != nil {
+		return store.direct.reset(pos, store.File)
+	}
+
+	return nil
+}
+
+// Seek moves the cursor ReadNext reads from to pos, which should be the position of an entry or the end of the store.
+// Buffered data is flushed first so the cursor can be placed anywhere in what was appended.
+// The file's own offset is left alone, so appends are never written at the cursor.
+func (store *Store) Seek(pos uint64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if err := store.buf.Flush(); err != nil {
+		return err
+	}
+	if pos > store.size.Load() {
+		return ErrSeekOutOfBounds
+	}
+	store.cursor = pos
+
+	return nil
+}
+
+// ReadNext returns the data of the entry at the cursor and moves the cursor past it, skipping padding from
+// WithPageAlignment, so a store can be scanned entry by entry without knowing positions in advance.
+// It returns io.EOF once the cursor reaches the end of the store.
+func (store *Store) ReadNext() ([]byte, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	size := store.size.Load()
+	if store.cursor >= size {
+		return nil, io.EOF
+	}
+
+	// Read the length prefix, then the data right behind it
+	sizeBuffer := make([]byte, wordLength)
+	if _, err := store.File.ReadAt(sizeBuffer, int64(store.cursor)); err != nil {
+		return nil, err
+	}
+	dataSize := store.enc.Uint64(sizeBuffer)
+	if dataSize > size-store.cursor-uint64(wordLength) {
+		return nil, ErrCorruptEntry
+	}
+
+	data := make([]byte, dataSize)
+	if _, err := store.File.ReadAt(data, int64(store.cursor)+int64(wordLength)); err != nil {
+		return nil, err
+	}
+
+	// The next entry starts on the following page boundary when the store is aligned
+	next := store.cursor + uint64(wordLength) + dataSize
+	if store.pageSize > 0 && next%store.pageSize != 0 && next < size {
+		next += store.pageSize - next%store.pageSize
+	}
+	store.cursor = next
+
+	return store.decompress(data)
+}
+
+// Sync flushes any buffered data and commits the store file to stable storage
+func (store *Store) Sync() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if err := store.buf.Flush(); err != nil {
+		return err
+	}
+	if err := store.saveMeta(); err != nil {
+		return err
+	}
+
+	return store.File.Sync()
+}
+
+// Buf returns the buffered writer that sits in front of the store's file
+func (store *Store) Buf() *bufio.Writer {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.buf
+}
+
+// PageAlignment returns the page size entries are aligned to, or zero when they are written back to back
+func (store *Store) PageAlignment() uint64 {
+	return store.pageSize
+}
+
+// Size returns the number of bytes appended to the store
+func (store *Store) Size() uint64 {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.size.Load()
+}
+
+// Position returns the store's size without taking the lock. That is where the next entry will be written,
+// unless WithPageAlignment is set: then the entry starts at Position padded up to the next multiple of the page size.
+// It is safe to call while other goroutines append, for example to decide whether a segment should roll over.
+func (store *Store) Position() uint64 {
+	return store.size.Load()
 }
 
 func (store *Store) Close() error {
 	// Lock the store to prevent any more actions
-	store.Mutex.Lock()
-	defer store.Mutex.Unlock()
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
 
 	// First, flush any data in the buffer to ensure all
 	// written data is saved to the file.
-	if err := store.Buf.Flush(); err != nil {
+	if err := store.buf.Flush(); err != nil {
+		return err
+	}
+	store.buf = nil
+
+	// Persist the record count gathered while the store was open
+	if err := store.saveMeta(); err != nil {
 		return err
 	}
-	store.Buf = nil
+
+	// Release the direct I/O handle and its buffer
+	if store.direct != nil {
+		if err := store.direct.Close(); err != nil {
+			return err
+		}
+		store.direct = nil
+	}
 
 	// Close the file after flushing the buffer
 	//This ensures that all buffered data is safely written to the file
@@ -1,9 +1,23 @@
 package store
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sync"
+	"syscall"
 	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestNewStoreWithValidFileFirst(t *testing.T) {
@@ -11,14 +25,11 @@ func TestNewStoreWithValidFileFirst(t *testing.T) {
 	expectedBufferSize := 4096
 
 	// Create a temporary file
-	tmpFile, err := os.CreateTemp("", "*.store")
+	tmpFile, err := os.CreateTemp(t.TempDir(), "*.store")
 	if err != nil {
 		t.Fatalf("Failed to create temp file: %v", err)
 	}
 
-	// Schedule cleanup of the file
-	defer os.Remove(tmpFile.Name())
-
 	// Ensure the file is closed after setup
 	defer tmpFile.Close()
 
@@ -29,8 +40,8 @@ func TestNewStoreWithValidFileFirst(t *testing.T) {
 	}
 
 	// Check if the buffer size is set as expected
-	if !reflect.DeepEqual(store.Buf.Size(), expectedBufferSize) {
-		t.Errorf("Expected buffer size to be %d, got %d", expectedBufferSize, store.Buf.Size())
+	if !reflect.DeepEqual(store.Buf().Size(), expectedBufferSize) {
+		t.Errorf("Expected buffer size to be %d, got %d", expectedBufferSize, store.Buf().Size())
 	}
 
 	// Validate the file association
@@ -39,8 +50,8 @@ func TestNewStoreWithValidFileFirst(t *testing.T) {
 	}
 
 	// Check the initial size of the store
-	if store.Size != 0 {
-		t.Errorf("Expected initial store size to be 0, got %d", store.Size)
+	if store.Size() != 0 {
+		t.Errorf("Expected initial store size to be 0, got %d", store.Size())
 	}
 }
 
@@ -62,14 +73,11 @@ func TestNewStoreWithNilFileFirst(t *testing.T) {
 	//...
 
 	// Create a temporary file for testing
-	tmpFile, err := os.CreateTemp("", "0.store")
+	tmpFile, err := os.CreateTemp(t.TempDir(), "0.store")
 	if err != nil {
 		t.Fatalf("Failed to create temp file: %v", err)
 	}
 
-	// Clean up
-	defer os.Remove(tmpFile.Name())
-
 	// Assign the temporary file to the store
 	store.File = tmpFile
 
@@ -81,14 +89,11 @@ func TestNewStoreWithNilFileFirst(t *testing.T) {
 
 func TestStoreAppend(t *testing.T) {
 	// Create a temporary file for testing
-	tmpfile, err := os.CreateTemp("", "store_append_test.*.store")
+	tmpfile, err := os.CreateTemp(t.TempDir(), "store_append_test.*.store")
 	if err != nil {
 		t.Fatalf("Failed to create temp file: %v", err)
 	}
 
-	// Clean up the file after the test
-	defer os.Remove(tmpfile.Name())
-
 	// Create a new store with the temporary file
 	store, err := NewStore(WithFile(tmpfile))
 	if err != nil {
@@ -115,18 +120,16 @@ func TestStoreAppend(t *testing.T) {
 	}
 
 	defer os.Remove("default.store")
+	defer os.Remove(MetaPath("default.store"))
 }
 
 func TestStoreRead(t *testing.T) {
 	// Create a temporary file for testing
-	tmpfile, err := os.CreateTemp("", "0.store")
+	tmpfile, err := os.CreateTemp(t.TempDir(), "0.store")
 	if err != nil {
 		t.Fatalf("Failed to create temp file: %v", err)
 	}
 
-	// Clean up after the test
-	defer os.Remove(tmpfile.Name())
-
 	// Initialize a new Store with the temporary file
 	store, err := NewStore(WithFile(tmpfile))
 	if err != nil {
@@ -162,15 +165,12 @@ func TestStoreRead(t *testing.T) {
 
 func TestStoreClose(t *testing.T) {
 	// Create a temporary file path
-	tmpFile, err := os.CreateTemp("", "0.store")
+	tmpFile, err := os.CreateTemp(t.TempDir(), "0.store")
 	if err != nil {
 		t.Fatalf("Failed to create temporary file: %v", err)
 	}
 	tmpFilePath := tmpFile.Name()
 
-	// Clean up after the test
-	defer os.Remove(tmpFilePath)
-
 	// Initialize the Store with the file path
 	store, err := NewStore(WithFile(tmpFile), WithBufferSize(4096))
 	if err != nil {
@@ -220,14 +220,11 @@ func TestStoreClose(t *testing.T) {
 
 func TestStoreInitializationWithFilePath(t *testing.T) {
 	// Create a temporary file for testing
-	tmpFile, err := os.CreateTemp("", "0.store")
+	tmpFile, err := os.CreateTemp(t.TempDir(), "0.store")
 	if err != nil {
 		t.Fatalf("Failed to create temporary file: %v", err)
 	}
 
-	// Clean up
-	defer os.Remove(tmpFile.Name())
-
 	// Close the file as NewStore will open it
 	tmpFile.Close()
 
@@ -240,3 +237,1230 @@ func TestStoreInitializationWithFilePath(t *testing.T) {
 		t.Errorf("Failed to close store: %v", err)
 	}
 }
+
+// flakyWriter fails with the given error a fixed number of times before writing successfully
+type flakyWriter struct {
+	failures int
+	err      error
+	calls    int
+	data     []byte
+}
+
+func (f *flakyWriter) Write(p []byte) (int, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return 0, f.err
+	}
+	f.data = append(f.data, p...)
+	return len(p), nil
+}
+
+func TestRetryWriter(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int
+		err        error
+		maxRetries int
+		wantErr    bool
+		wantCalls  int
+	}{
+		{name: "Recovers From EINTR", failures: 2, err: syscall.EINTR, maxRetries: 3, wantErr: false, wantCalls: 3},
+		{name: "Recovers From EAGAIN", failures: 1, err: syscall.EAGAIN, maxRetries: 3, wantErr: false, wantCalls: 2},
+		{name: "Gives Up After Max Retries", failures: 5, err: syscall.EINTR, maxRetries: 2, wantErr: true, wantCalls: 3},
+		{name: "Does Not Retry Other Errors", failures: 1, err: syscall.ENOSPC, maxRetries: 3, wantErr: true, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaky := &flakyWriter{failures: tt.failures, err: tt.err}
+			w := &retryWriter{w: flaky, maxRetries: tt.maxRetries}
+
+			_, err := w.Write([]byte("test data"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if flaky.calls != tt.wantCalls {
+				t.Errorf("Expected %d write attempts, got %d", tt.wantCalls, flaky.calls)
+			}
+			if !tt.wantErr && string(flaky.data) != "test data" {
+				t.Errorf("Expected data to be written after retrying, got %q", flaky.data)
+			}
+		})
+	}
+}
+
+func TestStoreEncoding(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp(t.TempDir(), "0.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	// Write and read back with little-endian length prefixes
+	little, err := NewStore(WithFile(tmpFile), WithEncoding(binary.LittleEndian))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+
+	testPage := []byte("test log data")
+	_, pos, err := little.Append(testPage)
+	if err != nil {
+		t.Fatalf("Failed to append to store: %v", err)
+	}
+
+	readData, err := little.Read(pos)
+	if err != nil {
+		t.Fatalf("Failed to read from store: %v", err)
+	}
+	if !reflect.DeepEqual(readData, testPage) {
+		t.Errorf("Read data does not match written data. Got %v, want %v", readData, testPage)
+	}
+
+	// Open the same file with the default big-endian encoding
+	otherFile, err := os.OpenFile(tmpFile.Name(), os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("Failed to reopen temp file: %v", err)
+	}
+	big, err := NewStore(WithFile(otherFile))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+
+	// The misread length prefix should be reported as corruption
+	if _, err := big.Read(pos); !errors.Is(err, ErrCorruptEntry) {
+		t.Errorf("Expected %v when mixing encodings, got %v", ErrCorruptEntry, err)
+	}
+}
+
+func TestStoreReadAt(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp(t.TempDir(), "0.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	store, err := NewStore(WithFile(tmpFile))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+
+	testPage := []byte("test log data")
+	_, pos, err := store.Append(testPage)
+	if err != nil {
+		t.Fatalf("Failed to append to store: %v", err)
+	}
+
+	// The raw bytes start with the length prefix
+	prefix := make([]byte, wordLength)
+	if _, err := store.ReadAt(prefix, int64(pos)); err != nil {
+		t.Fatalf("Failed to read length prefix: %v", err)
+	}
+	if got := binary.BigEndian.Uint64(prefix); got != uint64(len(testPage)) {
+		t.Errorf("Expected length prefix %d, got %d", len(testPage), got)
+	}
+
+	// Followed directly by the entry itself
+	data := make([]byte, len(testPage))
+	if _, err := store.ReadAt(data, int64(pos)+int64(wordLength)); err != nil {
+		t.Fatalf("Failed to read entry bytes: %v", err)
+	}
+	if !reflect.DeepEqual(data, testPage) {
+		t.Errorf("Raw bytes do not match written data. Got %v, want %v", data, testPage)
+	}
+}
+
+func TestStoreDirectIO(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp(t.TempDir(), "direct.*.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	store, err := NewStore(WithFile(tmpFile), WithDirectIO(true))
+	if errors.Is(err, syscall.EINVAL) {
+		t.Skip("Filesystem does not support direct I/O")
+	}
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+
+	// Entries that end mid-block, span blocks, and overflow the aligned buffer
+	pages := [][]byte{
+		[]byte("short"),
+		bytes.Repeat([]byte("a"), 700),
+		bytes.Repeat([]byte("b"), 100*1024),
+		[]byte("after the big one"),
+	}
+	var positions []uint64
+	for _, page := range pages {
+		_, pos, err := store.Append(page)
+		if err != nil {
+			t.Fatalf("Failed to append to store: %v", err)
+		}
+		positions = append(positions, pos)
+	}
+
+	// The file holds exactly the entries, without any alignment padding
+	fileInfo, err := os.Stat(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to stat store file: %v", err)
+	}
+	if uint64(fileInfo.Size()) != store.Size() {
+		t.Errorf("Expected file size %d, got %d", store.Size(), fileInfo.Size())
+	}
+
+	for i, pos := range positions {
+		data, err := store.Read(pos)
+		if err != nil {
+			t.Fatalf("Failed to read from store: %v", err)
+		}
+		if !bytes.Equal(data, pages[i]) {
+			t.Errorf("Entry %d does not match what was written", i)
+		}
+	}
+
+	// Writes after a truncate land right after the new end
+	if err := store.Truncate(positions[2]); err != nil {
+		t.Fatalf("Failed to truncate store: %v", err)
+	}
+	_, pos, err := store.Append([]byte("replacement"))
+	if err != nil {
+		t.Fatalf("Failed to append to store: %v", err)
+	}
+	if pos != positions[2] {
+		t.Errorf("Expected append at %d, got %d", positions[2], pos)
+	}
+	for i, want := range [][]byte{pages[0], pages[1], []byte("replacement")} {
+		data, err := store.Read(positions[i])
+		if err != nil {
+			t.Fatalf("Failed to read from store: %v", err)
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("Entry %d does not match what was written", i)
+		}
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+}
+
+func TestStoreTruncate(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp(t.TempDir(), "truncate.*.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	store, err := NewStore(WithFile(tmpFile))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+	defer store.Close()
+
+	var positions []uint64
+	for i := 0; i < 10; i++ {
+		_, pos, err := store.Append([]byte(fmt.Sprintf("entry %d", i)))
+		if err != nil {
+			t.Fatalf("Failed to append to store: %v", err)
+		}
+		positions = append(positions, pos)
+	}
+
+	// Cut the store right after the 5th entry
+	if err := store.Truncate(positions[5]); err != nil {
+		t.Fatalf("Failed to truncate store: %v", err)
+	}
+	if store.Size() != positions[5] {
+		t.Errorf("Expected size %d, got %d", positions[5], store.Size())
+	}
+	fileInfo, err := tmpFile.Stat()
+	if err != nil {
+		t.Fatalf("Failed to stat store file: %v", err)
+	}
+	if uint64(fileInfo.Size()) != positions[5] {
+		t.Errorf("Expected file size %d, got %d", positions[5], fileInfo.Size())
+	}
+
+	// The 5th entry is still there, the 6th is past the end
+	if _, err := store.Read(positions[4]); err != nil {
+		t.Errorf("Failed to read the 5th entry: %v", err)
+	}
+	if _, err := store.Read(positions[5]); err == nil {
+		t.Error("Expected reading the 6th entry to fail after truncating")
+	}
+
+	// Truncating past the end is not allowed
+	if err := store.Truncate(positions[9]); err == nil {
+		t.Error("Expected truncating past the end to fail")
+	}
+}
+
+func TestStorePageAlignment(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp(t.TempDir(), "aligned.*.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	const pageSize = 64
+	store, err := NewStore(WithFile(tmpFile), WithPageAlignment(pageSize))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+
+	pages := [][]byte{[]byte("first"), bytes.Repeat([]byte("x"), 100), []byte("third")}
+	var positions []uint64
+	for _, page := range pages {
+		_, pos, err := store.Append(page)
+		if err != nil {
+			t.Fatalf("Failed to append to store: %v", err)
+		}
+
+		// Every entry starts on a page boundary
+		if pos%pageSize != 0 {
+			t.Errorf("Expected entry at a multiple of %d, got %d", pageSize, pos)
+		}
+		positions = append(positions, pos)
+	}
+
+	// Reads work from the returned positions as usual
+	for i, pos := range positions {
+		data, err := store.Read(pos)
+		if err != nil {
+			t.Fatalf("Failed to read from store: %v", err)
+		}
+		if !bytes.Equal(data, pages[i]) {
+			t.Errorf("Entry %d does not match what was written", i)
+		}
+	}
+
+	// Scan skips the padding and only reports the entries
+	var scanned []uint64
+	end, err := store.Scan(func(pos uint64, data []byte) error {
+		scanned = append(scanned, pos)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if !reflect.DeepEqual(scanned, positions) {
+		t.Errorf("Scan got positions %v, want %v", scanned, positions)
+	}
+	if end != store.Size() {
+		t.Errorf("Expected scan to end at %d, got %d", store.Size(), end)
+	}
+}
+
+func TestStorePositionOf(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp(t.TempDir(), "position.*.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	store, err := NewStore(WithFile(tmpFile))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+
+	var positions []uint64
+	for i := 0; i < 100; i++ {
+		_, pos, err := store.Append([]byte(fmt.Sprintf("record %d", i)))
+		if err != nil {
+			t.Fatalf("Failed to append to store: %v", err)
+		}
+		positions = append(positions, pos)
+	}
+
+	// The 50th record is found where it was appended
+	pos, ok := store.PositionOf([]byte("record 49"))
+	if !ok {
+		t.Fatal("Expected to find the 50th record")
+	}
+	if pos != positions[49] {
+		t.Errorf("Expected position %d, got %d", positions[49], pos)
+	}
+
+	// Data that was never appended is not found
+	if pos, ok := store.PositionOf([]byte("record 100")); ok || pos != 0 {
+		t.Errorf("Expected not to find a missing record, got %d, %v", pos, ok)
+	}
+}
+
+func TestStoreAppendFlushFailure(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp(t.TempDir(), "flush.*.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	store, err := NewStore(WithFile(tmpFile))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+	if _, _, err := store.Append([]byte("persisted")); err != nil {
+		t.Fatalf("Failed to append to store: %v", err)
+	}
+	size := store.Size()
+
+	// Closing the file underneath the store makes the next flush fail
+	tmpFile.Close()
+	if _, _, err := store.Append([]byte("lost")); err == nil {
+		t.Fatal("Expected append to fail when the flush fails")
+	}
+
+	// The failed entry is not counted
+	if store.Size() != size {
+		t.Errorf("Expected size to stay %d after a failed flush, got %d", size, store.Size())
+	}
+	if count := store.Meta().RecordCount; count != 1 {
+		t.Errorf("Expected 1 record after a failed flush, got %d", count)
+	}
+}
+
+func TestStoreAppendBatch(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp(t.TempDir(), "batch.*.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	store, err := NewStore(WithFile(tmpFile))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+	defer store.Close()
+
+	// Entries are written until one would start at the limit
+	entries := [][]byte{[]byte("first"), []byte("second"), []byte("third")}
+	limit := uint64(2*wordLength + len("first") + len("second"))
+	positions, err := store.AppendBatch(entries, limit)
+	if err != nil {
+		t.Fatalf("Failed to append batch: %v", err)
+	}
+	if len(positions) != 2 {
+		t.Fatalf("Expected 2 entries written, got %d", len(positions))
+	}
+
+	// Everything written reached the file and reads back
+	info, err := os.Stat(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to stat store file: %v", err)
+	}
+	if uint64(info.Size()) != store.Size() || store.Size() != limit {
+		t.Errorf("Expected %d bytes in the store and its file, got %d and %d", limit, store.Size(), info.Size())
+	}
+	for i, pos := range positions {
+		got, err := store.Read(pos)
+		if err != nil {
+			t.Fatalf("Failed to read entry %d: %v", i, err)
+		}
+		if !bytes.Equal(got, entries[i]) {
+			t.Errorf("Expected entry %q, got %q", entries[i], got)
+		}
+	}
+	if count := store.Meta().RecordCount; count != 2 {
+		t.Errorf("Expected 2 records, got %d", count)
+	}
+
+	// A store already at the limit takes nothing
+	if _, err := store.AppendBatch(entries[2:], limit); !errors.Is(err, ErrStoreFull) {
+		t.Errorf("Expected ErrStoreFull, got %v", err)
+	}
+}
+
+func TestStoreMeta(t *testing.T) {
+	// Create a temporary file for testing
+	tmpFile, err := os.CreateTemp(t.TempDir(), "meta.*.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	path := tmpFile.Name()
+	tmpFile.Close()
+
+	store, err := NewStore(WithFilePath(path))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+
+	// A new store writes its sidecar straight away
+	if _, err := os.Stat(MetaPath(path)); err != nil {
+		t.Fatalf("Expected meta file to exist: %v", err)
+	}
+	created := store.Meta().CreatedAt
+	if created.IsZero() || store.Meta().StoreVersion != storeVersion {
+		t.Errorf("Unexpected meta for a new store: %+v", store.Meta())
+	}
+
+	var positions []uint64
+	for i := 0; i < 3; i++ {
+		_, pos, err := store.Append([]byte(fmt.Sprintf("record %d", i)))
+		if err != nil {
+			t.Fatalf("Failed to append to store: %v", err)
+		}
+		positions = append(positions, pos)
+	}
+	if count := store.Meta().RecordCount; count != 3 {
+		t.Errorf("Expected 3 records, got %d", count)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	// Reopening reads the persisted meta back
+	store, err = NewStore(WithFilePath(path))
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	meta := store.Meta()
+	if meta.RecordCount != 3 {
+		t.Errorf("Expected 3 records after reopening, got %d", meta.RecordCount)
+	}
+	if !meta.CreatedAt.Equal(created) {
+		t.Errorf("Expected creation time %v, got %v", created, meta.CreatedAt)
+	}
+
+	// Truncating recounts what is left
+	if err := store.Truncate(positions[1]); err != nil {
+		t.Fatalf("Failed to truncate store: %v", err)
+	}
+	if count := store.Meta().RecordCount; count != 1 {
+		t.Errorf("Expected 1 record after truncating, got %d", count)
+	}
+}
+
+func FuzzStoreRoundTrip(f *testing.F) {
+	// Seed with the edge cases of the length-prefixed framing
+	f.Add([]byte{})
+	f.Add([]byte{0x01})
+	f.Add([]byte("8 bytes!"))
+	f.Add(make([]byte, 1024*1024))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Create a temporary file for testing
+		tmpFile, err := os.CreateTemp(t.TempDir(), "fuzz.*.store")
+		if err != nil {
+			t.Fatalf("Failed to create temp file: %v", err)
+		}
+
+		store, err := NewStore(WithFile(tmpFile))
+		if err != nil {
+			t.Fatalf("Failed to create new store: %v", err)
+		}
+		defer store.Close()
+
+		// Whatever goes in must come back out byte for byte
+		_, pos, err := store.Append(data)
+		if err != nil {
+			t.Fatalf("Failed to append to store: %v", err)
+		}
+
+		readData, err := store.Read(pos)
+		if err != nil {
+			t.Fatalf("Failed to read from store: %v", err)
+		}
+		if !bytes.Equal(readData, data) {
+			t.Errorf("Round trip mismatch. Got %v, want %v", readData, data)
+		}
+	})
+}
+
+func TestStorePosition(t *testing.T) {
+	tmpfile, err := os.CreateTemp(t.TempDir(), "store_position_test.*.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	store, err := NewStore(WithFile(tmpfile))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+	defer store.Close()
+
+	// Each append lands where Position said the next entry would go
+	var positions []uint64
+	for i := 0; i < 3; i++ {
+		before := store.Position()
+		_, pos, err := store.Append([]byte("test log data"))
+		if err != nil {
+			t.Fatalf("Failed to append to store: %v", err)
+		}
+		if pos != before {
+			t.Errorf("Expected append at position %d, got %d", before, pos)
+		}
+		positions = append(positions, pos)
+	}
+	if store.Position() != store.Size() {
+		t.Errorf("Expected position %d to match size %d", store.Position(), store.Size())
+	}
+
+	// Truncating moves the position back
+	if err := store.Truncate(positions[1]); err != nil {
+		t.Fatalf("Failed to truncate store: %v", err)
+	}
+	if store.Position() != positions[1] {
+		t.Errorf("Expected position %d after truncate, got %d", positions[1], store.Position())
+	}
+}
+
+// BenchmarkStoreSizeUnderAppend compares reading the store's size with and without the lock
+// while 100 goroutines append to it.
+func BenchmarkStoreSizeUnderAppend(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		size func(*Store) uint64
+	}{
+		{"Size", (*Store).Size},
+		{"Position", (*Store).Position},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			tmpfile, err := os.CreateTemp(b.TempDir(), "store_bench.*.store")
+			if err != nil {
+				b.Fatalf("Failed to create temp file: %v", err)
+			}
+
+			store, err := NewStore(WithFile(tmpfile))
+			if err != nil {
+				b.Fatalf("Failed to create new store: %v", err)
+			}
+			defer store.Close()
+
+			// Keep appenders busy for the whole measurement
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+							store.Append([]byte("test log data"))
+						}
+					}
+				}()
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bc.size(store)
+			}
+			b.StopTimer()
+
+			close(stop)
+			wg.Wait()
+		})
+	}
+}
+
+// newBenchStore opens a store in a temporary directory that is removed when the benchmark ends
+func newBenchStore(b *testing.B) *Store {
+	b.Helper()
+
+	tmpfile, err := os.CreateTemp(b.TempDir(), "store_bench.*.store")
+	if err != nil {
+		b.Fatalf("Failed to create temp file: %v", err)
+	}
+	store, err := NewStore(WithFile(tmpfile))
+	if err != nil {
+		b.Fatalf("Failed to create new store: %v", err)
+	}
+	b.Cleanup(func() {
+		store.Close()
+	})
+
+	return store
+}
+
+// benchmarkStoreAppend appends size byte entries, reporting throughput in MB/s
+func benchmarkStoreAppend(b *testing.B, size int) {
+	store := newBenchStore(b)
+	data := bytes.Repeat([]byte{'x'}, size)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := store.Append(data); err != nil {
+			b.Fatalf("Failed to append to store: %v", err)
+		}
+
+		// Keep the file from filling the disk on long runs
+		if store.Position() > 64<<20 {
+			b.StopTimer()
+			if err := store.Truncate(0); err != nil {
+				b.Fatalf("Failed to truncate store: %v", err)
+			}
+			b.StartTimer()
+		}
+	}
+}
+
+func BenchmarkStoreAppend1B(b *testing.B)  { benchmarkStoreAppend(b, 1) }
+func BenchmarkStoreAppend1KB(b *testing.B) { benchmarkStoreAppend(b, 1<<10) }
+func BenchmarkStoreAppend1MB(b *testing.B) { benchmarkStoreAppend(b, 1<<20) }
+
+// BenchmarkStoreRead1KB reads back 1000 entries of 1KB in turn, reporting throughput in MB/s
+func BenchmarkStoreRead1KB(b *testing.B) {
+	store := newBenchStore(b)
+	data := bytes.Repeat([]byte{'x'}, 1<<10)
+
+	positions := make([]uint64, 1000)
+	for i := range positions {
+		_, pos, err := store.Append(data)
+		if err != nil {
+			b.Fatalf("Failed to append to store: %v", err)
+		}
+		positions[i] = pos
+	}
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.Read(positions[i%len(positions)]); err != nil {
+			b.Fatalf("Failed to read from store: %v", err)
+		}
+	}
+}
+
+// benchmarkStoreReads reads 100 nearby 1KB entries per iteration with readAll
+func benchmarkStoreReads(b *testing.B, readAll func(store *Store, positions []uint64) error) {
+	store := newBenchStore(b)
+	data := bytes.Repeat([]byte{'x'}, 1<<10)
+
+	positions := make([]uint64, 100)
+	for i := range positions {
+		_, pos, err := store.Append(data)
+		if err != nil {
+			b.Fatalf("Failed to append to store: %v", err)
+		}
+		positions[i] = pos
+	}
+
+	b.SetBytes(int64(len(data) * len(positions)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := readAll(store, positions); err != nil {
+			b.Fatalf("Failed to read from store: %v", err)
+		}
+	}
+}
+
+func BenchmarkStoreSequentialReads(b *testing.B) {
+	benchmarkStoreReads(b, func(store *Store, positions []uint64) error {
+		for _, pos := range positions {
+			if _, err := store.Read(pos); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func BenchmarkStoreMultiRead(b *testing.B) {
+	benchmarkStoreReads(b, func(store *Store, positions []uint64) error {
+		_, err := store.MultiRead(positions)
+		return err
+	})
+}
+
+// TestStoreBenchmarkBaseline fails when a store benchmark's throughput drops more than 20% below the
+// MB/s recorded in testdata/benchmark_baseline.json. Throughput depends on the machine the baseline was
+// captured on, so it only runs when STORE_BENCHMARK_BASELINE is set.
+func TestStoreBenchmarkBaseline(t *testing.T) {
+	if os.Getenv("STORE_BENCHMARK_BASELINE") == "" {
+		t.Skip("set STORE_BENCHMARK_BASELINE to compare store throughput against the baseline")
+	}
+
+	data, err := os.ReadFile("testdata/benchmark_baseline.json")
+	if err != nil {
+		t.Fatalf("Failed to read baseline: %v", err)
+	}
+	var baseline map[string]float64
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		t.Fatalf("Failed to parse baseline: %v", err)
+	}
+
+	for name, fn := range map[string]func(*testing.B){
+		"BenchmarkStoreAppend1B":  BenchmarkStoreAppend1B,
+		"BenchmarkStoreAppend1KB": BenchmarkStoreAppend1KB,
+		"BenchmarkStoreAppend1MB": BenchmarkStoreAppend1MB,
+		"BenchmarkStoreRead1KB":   BenchmarkStoreRead1KB,
+	} {
+		want, ok := baseline[name]
+		if !ok {
+			t.Errorf("No baseline for %s", name)
+			continue
+		}
+
+		result := testing.Benchmark(fn)
+		got := float64(result.Bytes) * float64(result.N) / 1e6 / result.T.Seconds()
+		if got < want*0.8 {
+			t.Errorf("%s: %.2f MB/s is more than 20%% below the baseline of %.2f MB/s", name, got, want)
+		}
+	}
+}
+
+func TestStoreReadRange(t *testing.T) {
+	for _, pageSize := range []uint64{0, 64} {
+		t.Run(fmt.Sprintf("page size %d", pageSize), func(t *testing.T) {
+			tmpFile, err := os.CreateTemp(t.TempDir(), "read_range.*.store")
+			if err != nil {
+				t.Fatalf("Failed to create temp file: %v", err)
+			}
+
+			store, err := NewStore(WithFile(tmpFile), WithPageAlignment(pageSize))
+			if err != nil {
+				t.Fatalf("Failed to create new store: %v", err)
+			}
+			defer store.Close()
+
+			pages := [][]byte{[]byte("first"), bytes.Repeat([]byte("x"), 100), []byte("third")}
+			var positions []uint64
+			for _, page := range pages {
+				_, pos, err := store.Append(page)
+				if err != nil {
+					t.Fatalf("Failed to append to store: %v", err)
+				}
+				positions = append(positions, pos)
+			}
+
+			// The whole store, and a range starting part way through it
+			got, err := store.ReadRange(0, store.Size())
+			if err != nil {
+				t.Fatalf("Failed to read range: %v", err)
+			}
+			if !reflect.DeepEqual(got, pages) {
+				t.Errorf("Expected %q, got %q", pages, got)
+			}
+			got, err = store.ReadRange(positions[1], store.Size())
+			if err != nil {
+				t.Fatalf("Failed to read range: %v", err)
+			}
+			if !reflect.DeepEqual(got, pages[1:]) {
+				t.Errorf("Expected %q, got %q", pages[1:], got)
+			}
+
+			// A range past the end of the store, or ending inside an entry, is refused
+			if _, err := store.ReadRange(0, store.Size()+1); err == nil {
+				t.Errorf("Expected an error reading past the end of the store")
+			}
+			if _, err := store.ReadRange(positions[1], store.Size()-1); !errors.Is(err, ErrCorruptEntry) {
+				t.Errorf("Expected ErrCorruptEntry for a range ending inside an entry, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreMaxFileSize(t *testing.T) {
+	tmpFile, err := os.CreateTemp(t.TempDir(), "max_size.*.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	// Room for two 8 byte entries and their length prefixes
+	store, err := NewStore(WithFile(tmpFile), WithMaxFileSize(32))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+	defer store.Close()
+
+	var positions []uint64
+	for i := 0; i < 2; i++ {
+		_, pos, err := store.Append([]byte("12345678"))
+		if err != nil {
+			t.Fatalf("Failed to append to store: %v", err)
+		}
+		positions = append(positions, pos)
+	}
+
+	// Once full, every append is refused and the store stays as it was
+	for i := 0; i < 2; i++ {
+		if _, _, err := store.Append([]byte("12345678")); !errors.Is(err, ErrStoreFull) {
+			t.Fatalf("Expected ErrStoreFull, got %v", err)
+		}
+	}
+	if store.Size() != 32 {
+		t.Errorf("Expected store size 32, got %d", store.Size())
+	}
+
+	// What was written before is still readable
+	for _, pos := range positions {
+		data, err := store.Read(pos)
+		if err != nil {
+			t.Fatalf("Failed to read from store: %v", err)
+		}
+		if string(data) != "12345678" {
+			t.Errorf("Expected %q, got %q", "12345678", data)
+		}
+	}
+}
+
+func TestStoreCompression(t *testing.T) {
+	tmpFile, err := os.CreateTemp(t.TempDir(), "compression.*.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	store, err := NewStore(WithFile(tmpFile), WithCompression(GzipCompression))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+
+	entry := bytes.Repeat([]byte("compressible "), 64)
+	size, pos, err := store.Append(entry)
+	if err != nil {
+		t.Fatalf("Failed to append to store: %v", err)
+	}
+	if size >= uint64(len(entry)) {
+		t.Errorf("Expected the entry to shrink below %d bytes, got %d", len(entry), size)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	// The reopened store reads with the recorded algorithm, not the one asked for now
+	f, err := os.OpenFile(tmpFile.Name(), os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to reopen store file: %v", err)
+	}
+	store, err = NewStore(WithFile(f))
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	if store.Compression() != GzipCompression {
+		t.Errorf("Expected compression %q, got %q", GzipCompression, store.Compression())
+	}
+	data, err := store.Read(pos)
+	if err != nil {
+		t.Fatalf("Failed to read from store: %v", err)
+	}
+	if !bytes.Equal(data, entry) {
+		t.Errorf("Expected %q, got %q", entry, data)
+	}
+}
+
+func TestStoreTracing(t *testing.T) {
+	tmpFile, err := os.CreateTemp(t.TempDir(), "tracing.*.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	store, err := NewStore(WithFile(tmpFile), WithTracer(tp.Tracer(TracerName)))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+	defer store.Close()
+
+	_, pos, err := store.Append([]byte("traced"))
+	if err != nil {
+		t.Fatalf("Failed to append to store: %v", err)
+	}
+	if _, err := store.Read(pos); err != nil {
+		t.Fatalf("Failed to read from store: %v", err)
+	}
+
+	// One span per call, carrying the position and the size after it
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	for i, name := range []string{"store.append", "store.read"} {
+		if spans[i].Name != name {
+			t.Errorf("Expected span %q, got %q", name, spans[i].Name)
+		}
+		attrs := attribute.NewSet(spans[i].Attributes...)
+		if v, _ := attrs.Value("store.position"); v.AsInt64() != int64(pos) {
+			t.Errorf("Expected position %d on %s, got %d", pos, name, v.AsInt64())
+		}
+		if v, _ := attrs.Value("store.size"); v.AsInt64() != int64(store.size.Load()) {
+			t.Errorf("Expected size %d on %s, got %d", store.size.Load(), name, v.AsInt64())
+		}
+	}
+
+	// A failed read is marked as an error
+	exporter.Reset()
+	if _, err := store.Read(store.size.Load() + 100); err == nil {
+		t.Fatal("Expected reading past the end to fail")
+	}
+	spans = exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Status.Code != otelcodes.Error {
+		t.Errorf("Expected one errored span, got %+v", spans)
+	}
+}
+
+func TestStoreMultiRead(t *testing.T) {
+	tmpFile, err := os.CreateTemp(t.TempDir(), "multiread.*.store")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	// A window small enough that some positions are grouped and others are read on their own
+	store, err := NewStore(WithFile(tmpFile), WithMultiReadWindow(64))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+	defer store.Close()
+
+	var entries [][]byte
+	var positions []uint64
+	for i := 0; i < 10; i++ {
+		// Every fourth entry is larger than the window, so reading it runs past the group's buffer
+		entry := []byte(fmt.Sprintf("entry %d", i))
+		if i%4 == 0 {
+			entry = bytes.Repeat(entry, 20)
+		}
+		_, pos, err := store.Append(entry)
+		if err != nil {
+			t.Fatalf("Failed to append to store: %v", err)
+		}
+		entries = append(entries, entry)
+		positions = append(positions, pos)
+	}
+
+	// Results come back in the order asked for, duplicates included
+	order := []int{9, 0, 3, 2, 8, 4, 1, 5, 7, 6, 3}
+	var asked []uint64
+	for _, i := range order {
+		asked = append(asked, positions[i])
+	}
+	got, err := store.MultiRead(asked)
+	if err != nil {
+		t.Fatalf("Failed to multi-read: %v", err)
+	}
+	for n, i := range order {
+		if !bytes.Equal(got[n], entries[i]) {
+			t.Errorf("Expected %q at %d, got %q", entries[i], n, got[n])
+		}
+	}
+
+	if _, err := store.MultiRead([]uint64{positions[0], store.size.Load()}); err == nil {
+		t.Error("Expected a position past the end to fail")
+	}
+}
+
+func TestOpenStoreTruncatesPartialEntry(t *testing.T) {
+	for name, tail := range map[string][]byte{
+		"nothing trailing":     nil,
+		"partial length":       {0, 0, 0},
+		"length without data":  {0, 0, 0, 0, 0, 0, 0, 16},
+		"partial data":         {0, 0, 0, 0, 0, 0, 0, 16, 'h', 'a', 'l', 'f'},
+		"length past the file": {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 'x'},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "0.store")
+
+			store, err := NewStore(WithFilePath(path))
+			if err != nil {
+				t.Fatalf("Failed to create new store: %v", err)
+			}
+			entries := [][]byte{[]byte("first"), []byte("second"), []byte("third")}
+			var positions []uint64
+			for _, entry := range entries {
+				_, pos, err := store.Append(entry)
+				if err != nil {
+					t.Fatalf("Failed to append to store: %v", err)
+				}
+				positions = append(positions, pos)
+			}
+			complete := store.Size()
+			if err := store.Close(); err != nil {
+				t.Fatalf("Failed to close store: %v", err)
+			}
+
+			// A crash in the middle of the next append leaves part of it behind
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatalf("Failed to open store file: %v", err)
+			}
+			if _, err := f.Write(tail); err != nil {
+				t.Fatalf("Failed to write partial entry: %v", err)
+			}
+			f.Close()
+
+			store, truncated, err := OpenStore(WithFilePath(path))
+			if err != nil {
+				t.Fatalf("Failed to open store: %v", err)
+			}
+			defer store.Close()
+			if truncated != int64(len(tail)) {
+				t.Errorf("Expected %d bytes truncated, got %d", len(tail), truncated)
+			}
+			if store.Size() != complete {
+				t.Errorf("Expected size %d after recovery, got %d", complete, store.Size())
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("Failed to stat store file: %v", err)
+			}
+			if uint64(info.Size()) != complete {
+				t.Errorf("Expected the file cut to %d bytes, got %d", complete, info.Size())
+			}
+
+			// Everything written before the crash is intact and appends carry on right after it
+			for i, pos := range positions {
+				got, err := store.Read(pos)
+				if err != nil {
+					t.Fatalf("Failed to read entry %d: %v", i, err)
+				}
+				if !bytes.Equal(got, entries[i]) {
+					t.Errorf("Expected entry %q, got %q", entries[i], got)
+				}
+			}
+			_, pos, err := store.Append([]byte("fourth"))
+			if err != nil {
+				t.Fatalf("Failed to append after recovery: %v", err)
+			}
+			if pos != complete {
+				t.Errorf("Expected the next entry at %d, got %d", complete, pos)
+			}
+			got, err := store.Read(pos)
+			if err != nil || string(got) != "fourth" {
+				t.Errorf("Expected to read back %q, got %q (%v)", "fourth", got, err)
+			}
+		})
+	}
+}
+
+func TestStoreSeekReadNext(t *testing.T) {
+	for name, opts := range map[string][]StoreOptions{
+		"unaligned":    nil,
+		"page aligned": {WithPageAlignment(64)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tmpFile, err := os.CreateTemp(t.TempDir(), "seek.*.store")
+			if err != nil {
+				t.Fatalf("Failed to create temp file: %v", err)
+			}
+
+			store, err := NewStore(append([]StoreOptions{WithFile(tmpFile)}, opts...)...)
+			if err != nil {
+				t.Fatalf("Failed to create new store: %v", err)
+			}
+			defer store.Close()
+
+			entries := [][]byte{[]byte("first"), []byte("second"), []byte("third")}
+			var positions []uint64
+			for _, entry := range entries {
+				_, pos, err := store.Append(entry)
+				if err != nil {
+					t.Fatalf("Failed to append to store: %v", err)
+				}
+				positions = append(positions, pos)
+			}
+
+			// The cursor starts at the beginning and walks every entry in order
+			for _, want := range entries {
+				got, err := store.ReadNext()
+				if err != nil {
+					t.Fatalf("ReadNext() failed: %v", err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("Expected %q, got %q", want, got)
+				}
+			}
+			if _, err := store.ReadNext(); err != io.EOF {
+				t.Errorf("Expected io.EOF at the end, got %v", err)
+			}
+
+			// Seeking back does not move where appends go
+			if err := store.Seek(positions[1]); err != nil {
+				t.Fatalf("Seek() failed: %v", err)
+			}
+			if _, pos, err := store.Append([]byte("fourth")); err != nil || pos < positions[2] {
+				t.Fatalf("Expected the append after the last entry, got %d, %v", pos, err)
+			}
+			for _, want := range append(entries[1:], []byte("fourth")) {
+				got, err := store.ReadNext()
+				if err != nil {
+					t.Fatalf("ReadNext() failed: %v", err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("Expected %q, got %q", want, got)
+				}
+			}
+
+			if err := store.Seek(store.Size() + 1); !errors.Is(err, ErrSeekOutOfBounds) {
+				t.Errorf("Expected %v, got %v", ErrSeekOutOfBounds, err)
+			}
+		})
+	}
+}
+
+func TestOpenStoreCorruptPrefix(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "0.store")
+
+	store, err := NewStore(WithFilePath(path))
+	if err != nil {
+		t.Fatalf("Failed to create new store: %v", err)
+	}
+	var positions []uint64
+	for _, entry := range []string{"first", "second", "third"} {
+		_, pos, err := store.Append([]byte(entry))
+		if err != nil {
+			t.Fatalf("Failed to append to store: %v", err)
+		}
+		positions = append(positions, pos)
+	}
+	complete := store.Size()
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	// Corrupt the second entry's length prefix, with the third still complete behind it
+	f, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open store file: %v", err)
+	}
+	if _, err := f.WriteAt(bytes.Repeat([]byte{0xff}, 8), int64(positions[1])); err != nil {
+		t.Fatalf("Failed to corrupt the store file: %v", err)
+	}
+	f.Close()
+
+	// That is no torn append, so nothing is cut off
+	if _, _, err := OpenStore(WithFilePath(path)); !errors.Is(err, ErrCorruptEntry) {
+		t.Errorf("Expected %v, got %v", ErrCorruptEntry, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat store file: %v", err)
+	}
+	if uint64(info.Size()) != complete {
+		t.Errorf("Expected the file to keep its %d bytes, got %d", complete, info.Size())
+	}
+
+	// Starting from an entry known to be complete, the corruption before it is not even looked at,
+	// while a torn append after it is still cut off
+	f, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open store file: %v", err)
+	}
+	if _, err := f.Write([]byte{0, 0, 0, 0, 0, 0, 0, 16, 'h', 'a', 'l', 'f'}); err != nil {
+		t.Fatalf("Failed to write partial entry: %v", err)
+	}
+	f.Close()
+
+	store, truncated, err := OpenStore(WithFilePath(path), WithTailStart(positions[2]))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+	if truncated != 12 || store.Size() != complete {
+		t.Errorf("Expected the 12 byte tail cut off leaving %d bytes, got %d cut and %d left", complete, truncated, store.Size())
+	}
+	got, err := store.Read(positions[2])
+	if err != nil || string(got) != "third" {
+		t.Errorf("Expected to read back %q, got %q (%v)", "third", got, err)
+	}
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name to get the store's tracer from a provider under, e.g. otel.Tracer(store.TracerName)
+const TracerName = "cute-logger/store"
+
+// Traces every Append and Read with a span from tracer, recording the operation, the position and the store size.
+// Without it, the default, the store does no tracing work at all.
+func WithTracer(tracer trace.Tracer) StoreOptions {
+	return func(opts *Options) {
+		opts.Tracer = tracer
+	}
+}
+
+// traced runs fn inside a span named after op, a child of the span in ctx if there is one.
+// Callers skip it when the store has no tracer.
+// pos is the position the operation is about, as known once fn has run.
+func (store *Store) traced(ctx context.Context, op string, pos func() uint64, fn func() error) error {
+	_, span := store.tracer.Start(ctx, "store."+op)
+	defer span.End()
+
+	err := fn()
+	span.SetAttributes(
+		attribute.String("store.operation", op),
+		attribute.Int64("store.position", int64(pos())),
+		attribute.Int64("store.size", int64(store.size.Load())),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,170 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBackpressureTimeout is returned by appends when the slowest consumer stayed too far behind for longer
+// than the timeout set with WithBackpressureTimeout
+var ErrBackpressureTimeout = errors.New("timed out waiting for consumers to catch up")
+
+// backpressure holds producers back while consumers are too far behind, see WithMaxConsumerLag
+type backpressure struct {
+	maxLag  uint64
+	timeout time.Duration
+
+	// Notified on every checkpoint change, waking every Append waiting on it
+	checkpointed broadcast
+
+	// The offset each consumer last checkpointed, kept in memory so producers never read the checkpoint files
+	mutex   sync.Mutex
+	offsets map[string]uint64
+}
+
+// WithMaxConsumerLag makes every append block while the highest offset is more than n past the slowest consumer's
+// checkpoint, so producers cannot outrun consumers indefinitely. Only consumers that call Checkpoint count,
+// and they have to keep calling it for blocked producers to move on. Zero, the default, never blocks.
+func WithMaxConsumerLag(n uint64) LogOption {
+	return func(l *Log) {
+		l.backpressure.maxLag = n
+	}
+}
+
+// WithBackpressureTimeout bounds how long an append blocks for WithMaxConsumerLag before it gives up with
+// ErrBackpressureTimeout. Zero, the default, waits until the consumers catch up or the log is closed.
+func WithBackpressureTimeout(d time.Duration) LogOption {
+	return func(l *Log) {
+		l.backpressure.timeout = d
+	}
+}
+
+// checkpointSignal returns a channel that is closed the next time a consumer checkpoint changes
+func (b *backpressure) checkpointSignal() <-chan struct{} {
+	return b.checkpointed.wait()
+}
+
+// set records offset as the checkpoint of consumerID and wakes every append waiting for it
+func (b *backpressure) set(consumerID string, offset uint64) {
+	b.mutex.Lock()
+	if b.offsets == nil {
+		b.offsets = make(map[string]uint64)
+	}
+	b.offsets[consumerID] = offset
+	b.mutex.Unlock()
+
+	b.checkpointed.notify()
+}
+
+// remove forgets the checkpoint of consumerID and wakes every append waiting for it
+func (b *backpressure) remove(consumerID string) {
+	b.mutex.Lock()
+	delete(b.offsets, consumerID)
+	b.mutex.Unlock()
+
+	b.checkpointed.notify()
+}
+
+// clear forgets every consumer's checkpoint and wakes every append waiting for one
+func (b *backpressure) clear() {
+	b.mutex.Lock()
+	b.offsets = nil
+	b.mutex.Unlock()
+
+	b.checkpointed.notify()
+}
+
+// slowest returns the lowest offset any consumer has checkpointed, and false when none has
+func (b *backpressure) slowest() (uint64, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var slowest uint64
+	found := false
+	for _, offset := range b.offsets {
+		if !found || offset < slowest {
+			slowest, found = offset, true
+		}
+	}
+	return slowest, found
+}
+
+// waitForConsumers blocks until the slowest consumer is within the maximum lag. Every append waits here
+// before taking the log's lock, since consumers need the read lock to catch up. The caller holds no lock.
+func (l *Log) waitForConsumers() error {
+	if l.backpressure.maxLag == 0 {
+		return nil
+	}
+
+	// A nil channel never fires, which means no timeout was configured
+	var timeout <-chan time.Time
+	if l.backpressure.timeout > 0 {
+		timer := time.NewTimer(l.backpressure.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		// Take the signal before checking, so a checkpoint in between is never missed
+		checkpointed := l.backpressure.checkpointSignal()
+		lagging, err := l.consumersLagging()
+		if err != nil || !lagging {
+			return err
+		}
+
+		select {
+		case <-checkpointed:
+		case <-timeout:
+			return ErrBackpressureTimeout
+		case <-l.ctx.Done():
+			return ErrLogClosed
+		}
+	}
+}
+
+// consumersLagging reports whether the slowest consumer is more than the maximum lag behind the highest offset
+func (l *Log) consumersLagging() (bool, error) {
+	slowest, ok := l.backpressure.slowest()
+	if !ok {
+		return false, nil
+	}
+	highest, err := l.HighestOffset()
+	if err != nil {
+		return false, err
+	}
+
+	return highest > slowest && highest-slowest > l.backpressure.maxLag, nil
+}
+
+// loadConsumerOffsets reads every checkpoint left by an earlier run into memory. It runs once, on open.
+func (l *Log) loadConsumerOffsets() error {
+	entries, err := os.ReadDir(path.Join(l.dir, consumerCheckpointDir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		consumerID := strings.TrimSuffix(entry.Name(), ".json")
+
+		offset, err := l.RestoreFromCheckpoint(consumerID)
+		if errors.Is(err, ErrCheckpointNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		l.backpressure.set(consumerID, offset)
+	}
+
+	return nil
+}
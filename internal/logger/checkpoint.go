@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Name of the file that records how far the log was cleanly synced
+const checkpointFileName = "checkpoint"
+
+// Sync commits every segment to stable storage and records the checkpoint.
+// On the next open, segments covered by the checkpoint are trusted as they are,
+// while anything written after it is repaired from its store.
+func (l *Log) Sync() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.closed.Load() {
+		return ErrLogClosed
+	}
+
+	for _, s := range l.segmentList {
+		if err := s.Sync(); err != nil {
+			return err
+		}
+	}
+
+	// Every offset below the active segment's next offset is now durable
+	return l.writeCheckpoint(l.activeSegment.NextOffset())
+}
+
+// writeCheckpoint atomically replaces the checkpoint file with offset.
+func (l *Log) writeCheckpoint(offset uint64) error {
+	checkpointPath := path.Join(l.dir, checkpointFileName)
+
+	// Write to a temporary file first so a crash never leaves a torn checkpoint behind
+	tmp := checkpointPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(offset, 10)), l.filePerm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, checkpointPath)
+}
+
+// readCheckpoint returns the offset recorded by the last Sync, and whether there was one.
+func (l *Log) readCheckpoint() (uint64, bool, error) {
+	data, err := os.ReadFile(path.Join(l.dir, checkpointFileName))
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	offset, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return offset, true, nil
+}
+
+// repairSegments repairs every segment that the checkpoint does not vouch for.
+// The active segment is always repaired since it is the one that was being written to.
+// Without a checkpoint, segments are opened as they are.
+func (l *Log) repairSegments() error {
+	checkpoint, ok, err := l.readCheckpoint()
+	if err != nil || !ok {
+		return err
+	}
+
+	for _, s := range l.segmentList {
+		if s != l.activeSegment && s.NextOffset() <= checkpoint {
+			continue
+		}
+		if err := s.Repair(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package logger
+
+import "time"
+
+// Clock is where the log gets the time from for retention and idle tracking, see WithClock
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock a log uses unless WithClock sets another
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// WithClock has the log tell time by c instead of the system clock, so tests of time based features
+// like WithRetentionPolicy can move time forward instead of sleeping. See testutil.MockClock.
+// Segments record their creation time by c as well, which is what retention ages them by.
+func WithClock(c Clock) LogOption {
+	return func(l *Log) {
+		l.clock = c
+	}
+}
@@ -0,0 +1,177 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+
+	seg "github.com/BryceDouglasJames/Cute-Logger/internal/core/segment"
+)
+
+// Name of the file that persists a log's configuration inside its directory
+const configFileName = "config.json"
+
+// ErrConfigMismatch is returned when an explicitly provided option disagrees with the config stored on disk.
+var ErrConfigMismatch = errors.New("log option conflicts with the config stored in the log directory")
+
+// LogConfig holds the settings that decide how a log lays out its segments.
+// It is persisted alongside the segments so a directory is always reopened with the parameters it was created with.
+type LogConfig struct {
+	MaxStoreBytes  uint64             `json:"max_store_bytes"`
+	MaxIndexBytes  uint64             `json:"max_index_bytes"`
+	RecordEncoding seg.RecordEncoding `json:"record_encoding"`
+	StoreExtension string             `json:"store_extension,omitempty"`
+	IndexExtension string             `json:"index_extension,omitempty"`
+}
+
+// WithMaxStoreBytes sets the maximum store bytes for every segment in the log.
+func WithMaxStoreBytes(maxBytes uint64) LogOption {
+	return func(l *Log) {
+		l.config.MaxStoreBytes = maxBytes
+	}
+}
+
+// WithMaxIndexBytes sets the maximum index bytes for every segment in the log.
+func WithMaxIndexBytes(maxBytes uint64) LogOption {
+	return func(l *Log) {
+		l.config.MaxIndexBytes = maxBytes
+	}
+}
+
+// WithJSONEncoding stores records as JSON instead of protobuf, for REST and JavaScript interop.
+// The encoding is persisted with the log, so an existing protobuf log cannot be reopened as JSON or vice versa.
+func WithJSONEncoding() LogOption {
+	return func(l *Log) {
+		l.config.RecordEncoding = seg.JSONEncoding
+	}
+}
+
+// WithStoreExtension names segment store files with ext instead of ".store", e.g. ".cstore" for custom serialization.
+// Files with any other extension are ignored when the log is opened.
+func WithStoreExtension(ext string) LogOption {
+	return func(l *Log) {
+		l.config.StoreExtension = ext
+	}
+}
+
+// WithIndexExtension names segment index files with ext instead of ".index", e.g. ".cindex" for custom serialization.
+// Files with any other extension are ignored when the log is opened.
+func WithIndexExtension(ext string) LogOption {
+	return func(l *Log) {
+		l.config.IndexExtension = ext
+	}
+}
+
+// Config returns a copy of the effective configuration of the log.
+func (l *Log) Config() LogConfig {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.config
+}
+
+// setupConfig reconciles the options given to NewLog with the config persisted in the log directory.
+// Fields left unset by the caller are filled from disk, or from the segment defaults for a brand new log.
+func (l *Log) setupConfig() error {
+	configPath := path.Join(l.dir, configFileName)
+
+	data, err := os.ReadFile(configPath)
+	switch {
+	case err == nil:
+		// A config already exists, so the log must be reopened with it
+		var stored LogConfig
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return err
+		}
+
+		// Configs written before extensions were configurable use the defaults
+		stored.fillExtensions()
+
+		// Anything the caller set explicitly has to agree with what is on disk
+		if l.config.MaxStoreBytes != 0 && l.config.MaxStoreBytes != stored.MaxStoreBytes {
+			return ErrConfigMismatch
+		}
+		if l.config.MaxIndexBytes != 0 && l.config.MaxIndexBytes != stored.MaxIndexBytes {
+			return ErrConfigMismatch
+		}
+		if l.config.RecordEncoding != seg.ProtobufEncoding && l.config.RecordEncoding != stored.RecordEncoding {
+			return ErrConfigMismatch
+		}
+		if l.config.StoreExtension != "" && l.config.StoreExtension != stored.StoreExtension {
+			return ErrConfigMismatch
+		}
+		if l.config.IndexExtension != "" && l.config.IndexExtension != stored.IndexExtension {
+			return ErrConfigMismatch
+		}
+
+		l.config = stored
+		return nil
+
+	case os.IsNotExist(err):
+		// No config yet, fill in any unset fields with the segment defaults
+		defaults := seg.DefaultOptions()
+		if l.config.MaxStoreBytes == 0 {
+			l.config.MaxStoreBytes = defaults.MaxStoreBytes
+		}
+		if l.config.MaxIndexBytes == 0 {
+			l.config.MaxIndexBytes = defaults.MaxIndexBytes
+		}
+		l.config.fillExtensions()
+
+		// Persist the effective config for the next time the directory is opened
+		return l.saveConfig()
+
+	default:
+		return err
+	}
+}
+
+// fillExtensions sets any unset file extension to the segment default.
+func (c *LogConfig) fillExtensions() {
+	defaults := seg.DefaultOptions()
+	if c.StoreExtension == "" {
+		c.StoreExtension = defaults.StoreExtension
+	}
+	if c.IndexExtension == "" {
+		c.IndexExtension = defaults.IndexExtension
+	}
+}
+
+// saveConfig writes the current config to the log directory.
+func (l *Log) saveConfig() error {
+	data, err := json.MarshalIndent(l.config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(l.dir, configFileName), data, l.filePerm)
+}
+
+// SetSegmentMaxBytes changes the store size at which segments rotate, without restarting the log.
+// The active segment picks up the new threshold immediately and is rotated right away if it is already past it.
+// The threshold is only kept in memory, the config on disk keeps the one the log was created with, so reopening
+// the log with that same WithMaxStoreBytes keeps working.
+func (l *Log) SetSegmentMaxBytes(n uint64) error {
+	if n == 0 {
+		return errors.New("segment max bytes must be greater than zero")
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Every segment created from now on uses the new threshold
+	l.config.MaxStoreBytes = n
+
+	l.activeSegment.SetMaxStoreBytes(n)
+	if l.activeSegment.IsFull() {
+		return l.rotateFull()
+	}
+
+	return nil
+}
+
+// GetSegmentMaxBytes returns the store size at which segments rotate.
+func (l *Log) GetSegmentMaxBytes() uint64 {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.config.MaxStoreBytes
+}
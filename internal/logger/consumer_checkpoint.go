@@ -0,0 +1,115 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Directory inside the log directory that holds one checkpoint file per consumer
+const consumerCheckpointDir = "checkpoints"
+
+// ErrCheckpointNotFound is returned when a consumer has never checkpointed its offset.
+var ErrCheckpointNotFound = errors.New("no checkpoint for consumer")
+
+// Checkpoint durably records offset as the progress of consumerID, replacing any earlier checkpoint.
+// Consumers only ever contend with themselves, so concurrent consumers never wait on each other.
+func (l *Log) Checkpoint(consumerID string, offset uint64) error {
+	checkpointPath, err := l.consumerCheckpointPath(consumerID)
+	if err != nil {
+		return err
+	}
+
+	mutex := l.consumerMutex(consumerID)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if err := os.MkdirAll(path.Dir(checkpointPath), l.dirPerm()); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(map[string]uint64{consumerID: offset})
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a torn checkpoint behind
+	tmp := checkpointPath + ".tmp"
+	if err := os.WriteFile(tmp, data, l.filePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, checkpointPath); err != nil {
+		return err
+	}
+
+	// Producers held back by a lagging consumer may be free to go now
+	l.backpressure.set(consumerID, offset)
+	return nil
+}
+
+// RestoreFromCheckpoint returns the offset consumerID last checkpointed.
+// It returns ErrCheckpointNotFound when the consumer has no checkpoint.
+func (l *Log) RestoreFromCheckpoint(consumerID string) (uint64, error) {
+	checkpointPath, err := l.consumerCheckpointPath(consumerID)
+	if err != nil {
+		return 0, err
+	}
+
+	mutex := l.consumerMutex(consumerID)
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	data, err := os.ReadFile(checkpointPath)
+	if os.IsNotExist(err) {
+		return 0, ErrCheckpointNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var checkpoint map[string]uint64
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return 0, err
+	}
+	offset, ok := checkpoint[consumerID]
+	if !ok {
+		return 0, ErrCheckpointNotFound
+	}
+	return offset, nil
+}
+
+// DeleteCheckpoint removes the checkpoint of consumerID. Deleting a checkpoint that does not exist is not an error.
+func (l *Log) DeleteCheckpoint(consumerID string) error {
+	checkpointPath, err := l.consumerCheckpointPath(consumerID)
+	if err != nil {
+		return err
+	}
+
+	mutex := l.consumerMutex(consumerID)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if err := os.Remove(checkpointPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	l.backpressure.remove(consumerID)
+	return nil
+}
+
+// consumerMutex returns the lock guarding the checkpoint of consumerID, creating it on first use
+func (l *Log) consumerMutex(consumerID string) *sync.RWMutex {
+	mutex, _ := l.consumerMutexes.LoadOrStore(consumerID, &sync.RWMutex{})
+	return mutex.(*sync.RWMutex)
+}
+
+// consumerCheckpointPath returns where the checkpoint of consumerID is stored.
+// IDs are used as file names, so they cannot be empty or contain path separators.
+func (l *Log) consumerCheckpointPath(consumerID string) (string, error) {
+	if consumerID == "" || strings.ContainsAny(consumerID, `/\`) || consumerID == "." || consumerID == ".." {
+		return "", errors.New("consumer ID must be a non-empty file name")
+	}
+	return path.Join(l.dir, consumerCheckpointDir, consumerID+".json"), nil
+}
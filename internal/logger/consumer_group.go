@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMemberNotFound is returned when a consumer group has no committed offset for a member.
+var ErrMemberNotFound = errors.New("no committed offset for consumer group member")
+
+// Separates a member from its group in the consumer ID the member's offset is checkpointed under
+const groupMemberSeparator = "@"
+
+// ConsumerGroup tracks the committed offset of each member independently.
+// A member's offset is the checkpoint of the consumer "<member>@<group>", see Log.Checkpoint, so members
+// resume where they left off and count as consumers for WithMaxConsumerLag like any other.
+type ConsumerGroup struct {
+	GroupID string
+
+	log *Log
+}
+
+// NewConsumerGroup returns the consumer group with the given ID. Offsets its members committed before are
+// picked up from their checkpoints.
+// Group IDs are used in file names, so they cannot contain path separators or "@", nor be "." or "..".
+func (l *Log) NewConsumerGroup(groupID string) (*ConsumerGroup, error) {
+	if groupID == "" {
+		return nil, errors.New("consumer group ID is mandatory")
+	}
+	if strings.ContainsAny(groupID, `/\`+groupMemberSeparator) || groupID == "." || groupID == ".." {
+		return nil, fmt.Errorf("consumer group ID %q must be a file name without %q", groupID, groupMemberSeparator)
+	}
+
+	return &ConsumerGroup{
+		GroupID: groupID,
+		log:     l,
+	}, nil
+}
+
+// CommitOffset durably records the offset for a member.
+func (g *ConsumerGroup) CommitOffset(memberID string, offset uint64) error {
+	consumerID, err := g.consumerID(memberID)
+	if err != nil {
+		return err
+	}
+	return g.log.Checkpoint(consumerID, offset)
+}
+
+// GetOffset returns the last offset committed by a member.
+func (g *ConsumerGroup) GetOffset(memberID string) (uint64, error) {
+	consumerID, err := g.consumerID(memberID)
+	if err != nil {
+		return 0, err
+	}
+
+	offset, err := g.log.RestoreFromCheckpoint(consumerID)
+	if errors.Is(err, ErrCheckpointNotFound) {
+		return 0, ErrMemberNotFound
+	}
+	return offset, err
+}
+
+// consumerID returns the ID the offset of memberID is checkpointed under
+func (g *ConsumerGroup) consumerID(memberID string) (string, error) {
+	if memberID == "" {
+		return "", errors.New("consumer group member ID is mandatory")
+	}
+	return memberID + groupMemberSeparator + g.GroupID, nil
+}
@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+)
+
+// dedupIndex maps the hash of each record's key and value to the newest offset holding it.
+// It covers every record from offset from onwards, and is only kept once AppendIfAbsent has been used.
+// The log's write lock guards it, or its read lock together with appendMutex while appending.
+type dedupIndex struct {
+	offsets map[[sha256.Size]byte]uint64
+	from    uint64
+}
+
+// recordHash hashes a record's key and value, length prefixing the key so a key and value can never run together
+func recordHash(record *api.Record) [sha256.Size]byte {
+	h := sha256.New()
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(record.Key)))
+	h.Write(n[:])
+	h.Write(record.Key)
+	h.Write(record.Value)
+
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// add remembers record at offset, unless a newer copy of it is already known. A nil index does nothing.
+func (d *dedupIndex) add(offset uint64, record *api.Record) {
+	if d == nil {
+		return
+	}
+	hash := recordHash(record)
+	if known, ok := d.offsets[hash]; !ok || known < offset {
+		d.offsets[hash] = offset
+	}
+}
+
+// AppendIfAbsent appends record unless a record with the same key and value is among the last dedupWindow offsets.
+// It returns the offset of the existing record and true for a duplicate, or the new record's offset and false.
+// Once warm, the check is a hash lookup, only the first call over a window reads the records in it.
+func (l *Log) AppendIfAbsent(record *api.Record, dedupWindow uint64) (uint64, bool, error) {
+	if err := l.lockForAppend(); err != nil {
+		return 0, false, err
+	}
+	defer l.mutex.Unlock()
+
+	next := l.activeSegment.NextOffset()
+	if l.dedup == nil {
+		l.dedup = &dedupIndex{offsets: make(map[[sha256.Size]byte]uint64), from: next}
+	}
+
+	windowStart := l.segmentList[0].BaseOffset()
+	if next-windowStart > dedupWindow {
+		windowStart = next - dedupWindow
+	}
+
+	// Warm the index with the part of the window it does not cover yet, skipping offsets that cannot be read
+	if windowStart < l.dedup.from {
+		for offset := windowStart; offset < l.dedup.from && offset < next; offset++ {
+			if existing, err := l.read(context.Background(), offset); err == nil {
+				l.dedup.add(offset, existing)
+			}
+		}
+		l.dedup.from = windowStart
+	}
+
+	// The log may have changed under an entry since, e.g. by DeleteRange, so a hit is checked against the record itself
+	if offset, ok := l.dedup.offsets[recordHash(record)]; ok && offset >= windowStart {
+		existing, err := l.read(context.Background(), offset)
+		if err == nil && bytes.Equal(existing.Key, record.Key) && bytes.Equal(existing.Value, record.Value) {
+			return offset, true, nil
+		}
+	}
+
+	// Entries older than any window seen lately only take up memory
+	if uint64(len(l.dedup.offsets)) > 2*dedupWindow {
+		for hash, offset := range l.dedup.offsets {
+			if offset < windowStart {
+				delete(l.dedup.offsets, hash)
+			}
+		}
+		l.dedup.from = windowStart
+	}
+
+	offset, err := l.append(context.Background(), record)
+	if err != nil {
+		return 0, false, err
+	}
+	return offset, false, nil
+}
@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+)
+
+// exportedRecord is one line of an NDJSON export, the value is base64 encoded by encoding/json
+type exportedRecord struct {
+	Offset uint64 `json:"offset"`
+	Value  []byte `json:"value"`
+}
+
+// Export writes every record in the log to w, so operators can inspect a log without writing Go.
+// format is either "ndjson", one {"offset":N,"value":"<base64>"} object per line,
+// or "csv", an offset,value_b64 header followed by one row per record.
+func (l *Log) Export(format string, w io.Writer) error {
+	switch format {
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		return l.ForEach(func(offset uint64, record *api.Record) error {
+			return enc.Encode(exportedRecord{Offset: offset, Value: record.Value})
+		})
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"offset", "value_b64"}); err != nil {
+			return err
+		}
+		err := l.ForEach(func(offset uint64, record *api.Record) error {
+			return cw.Write([]string{
+				strconv.FormatUint(offset, 10),
+				base64.StdEncoding.EncodeToString(record.Value),
+			})
+		})
+		if err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
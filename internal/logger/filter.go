@@ -0,0 +1,61 @@
+package logger
+
+import (
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	seg "github.com/BryceDouglasJames/Cute-Logger/internal/core/segment"
+)
+
+// Filter returns the offsets of every record in the log for which predicate returns true, in offset order.
+// It reads and decodes every record, so it costs O(n) in the size of the log; use FilterRange when only
+// part of the log is of interest. The log is read locked throughout, so predicate must not write to it.
+func (l *Log) Filter(predicate func(*api.Record) bool) ([]uint64, error) {
+	var offsets []uint64
+	err := l.ForEach(func(offset uint64, record *api.Record) error {
+		if predicate(record) {
+			offsets = append(offsets, offset)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
+
+// FilterRange is Filter over the records with offsets from lo to hi inclusive.
+// Only the segments overlapping the range are read.
+func (l *Log) FilterRange(lo, hi uint64, predicate func(*api.Record) bool) ([]uint64, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return nil, ErrLogClosed
+	}
+
+	var offsets []uint64
+	for _, s := range l.segmentList {
+		if s.NextOffset() <= lo || s.BaseOffset() > hi {
+			continue
+		}
+
+		encoding := s.Config().RecordEncoding
+		err := s.ScanRaw(func(offset uint64, _ uint64, p []byte) error {
+			if offset < lo || offset > hi {
+				return nil
+			}
+			record, err := seg.UnmarshalRecord(encoding, p)
+			if err != nil {
+				return err
+			}
+			if predicate(record) {
+				offsets = append(offsets, offset)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return offsets, nil
+}
@@ -0,0 +1,86 @@
+//go:build go1.23
+
+package logger
+
+import (
+	"context"
+	"errors"
+	"iter"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+)
+
+// All iterates over every record in the log in offset order, for use with range.
+// Iteration stops at the highest offset at the time it started, or at the first record that cannot be read.
+func (l *Log) All() iter.Seq2[uint64, *api.Record] {
+	return func(yield func(uint64, *api.Record) bool) {
+		lowest, err := l.LowestOffset()
+		if err != nil {
+			return
+		}
+		l.From(lowest)(yield)
+	}
+}
+
+// From iterates over the records from startOffset onwards in offset order, for use with range.
+// Iteration stops at the highest offset at the time it started, or at the first record that cannot be read.
+func (l *Log) From(startOffset uint64) iter.Seq2[uint64, *api.Record] {
+	return func(yield func(uint64, *api.Record) bool) {
+		highest, err := l.HighestOffset()
+		if err != nil {
+			return
+		}
+
+		for offset := startOffset; offset <= highest; offset++ {
+			record, err := l.Read(offset)
+			if err != nil || !yield(offset, record) {
+				return
+			}
+		}
+	}
+}
+
+// Messages iterates over the records from startOffset onwards in offset order, for use with range, and unlike From
+// waits at the end of the log for new ones. Records removed by retention or compaction are skipped.
+// Once ctx is done, the log is closed, or a record cannot be read for any other reason, the error is yielded
+// with a nil record and iteration ends.
+// Breaking out of the loop is all it takes to stop, there is nothing to clean up.
+func (l *Log) Messages(ctx context.Context, startOffset uint64) iter.Seq2[*api.Record, error] {
+	return func(yield func(*api.Record, error) bool) {
+		offset := startOffset
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			// Take the signal before reading, so an append in between is never missed
+			changed := l.changed.wait()
+
+			record, err := l.Read(offset)
+			if err == nil {
+				if !yield(record, nil) {
+					return
+				}
+				offset++
+				continue
+			}
+			var outOfRange api.ErrOffsetOutOfRange
+			if !errors.As(err, &outOfRange) {
+				yield(nil, err)
+				return
+			}
+
+			next, wait := l.skip(offset)
+			if !wait {
+				offset = next
+				continue
+			}
+
+			select {
+			case <-changed:
+			case <-ctx.Done():
+			}
+		}
+	}
+}
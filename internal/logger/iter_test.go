@@ -0,0 +1,135 @@
+//go:build go1.23
+
+package logger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogIterators(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_iter")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	log, err := NewLog(tempDir)
+	require.NoError(t, err)
+	defer log.Close()
+
+	// An empty log yields nothing
+	for range log.All() {
+		t.Fatal("empty log should not yield records")
+	}
+
+	for i := 0; i < 500; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+
+	// Every record comes back in order
+	want := uint64(0)
+	for offset, record := range log.All() {
+		require.Equal(t, want, offset)
+		require.Equal(t, []byte(fmt.Sprintf("record %d", offset)), record.Value)
+		want++
+	}
+	require.Equal(t, uint64(500), want)
+
+	// From starts part way through and stops early when the loop breaks
+	var offsets []uint64
+	for offset := range log.From(495) {
+		offsets = append(offsets, offset)
+		if offset == 497 {
+			break
+		}
+	}
+	require.Equal(t, []uint64{495, 496, 497}, offsets)
+}
+
+func TestLogMessages(t *testing.T) {
+	log := NewTestLog(t)
+
+	for i := 0; i < 3; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+
+	// Records already in the log come first, then ones appended while waiting at the end
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		for i := 3; i < 5; i++ {
+			if _, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))}); err != nil {
+				t.Errorf("Append failed: %v", err)
+			}
+		}
+	}()
+	want := uint64(1)
+	for record, err := range log.Messages(ctx, 1) {
+		require.NoError(t, err)
+		require.Equal(t, want, record.Offset)
+		require.Equal(t, []byte(fmt.Sprintf("record %d", want)), record.Value)
+		want++
+		if want == 5 {
+			break
+		}
+	}
+
+	// Cancelling the context while waiting ends the loop with its error
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var errs []error
+	for record, err := range log.Messages(ctx, 5) {
+		require.Nil(t, record)
+		errs = append(errs, err)
+	}
+	require.Equal(t, []error{context.DeadlineExceeded}, errs)
+
+	// So does closing the log
+	done := make(chan error, 1)
+	go func() {
+		for _, err := range log.Messages(context.Background(), 5) {
+			done <- err
+		}
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, log.Close())
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, ErrLogClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Messages did not end when the log was closed")
+	}
+}
+
+func TestLogMessagesReadError(t *testing.T) {
+	log := NewTestLog(t)
+	for i := 0; i < 3; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+	corruptRecord(t, log, 1)
+
+	// The unreadable record ends the loop with its error instead of being skipped
+	var offsets []uint64
+	var errs []error
+	for record, err := range log.Messages(context.Background(), 0) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		offsets = append(offsets, record.Offset)
+	}
+	require.Equal(t, []uint64{0}, offsets)
+	require.Len(t, errs, 1)
+	require.NotErrorIs(t, errs[0], ErrLogClosed)
+}
@@ -0,0 +1,215 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	seg "github.com/BryceDouglasJames/Cute-Logger/internal/core/segment"
+)
+
+// ErrKeyNotFound is returned when no live record in the log has the requested key
+var ErrKeyNotFound = errors.New("no record with that key")
+
+// TombstoneOffsetHeader names the header in which a tombstone written by AppendOrReplace
+// carries the decimal offset of the record it replaces
+const TombstoneOffsetHeader = "tombstone-offset"
+
+// keyIndex maps every key in the log to its newest record, so keyed lookups never scan the log.
+// It is built when the log is opened and kept up to date by every append.
+type keyIndex struct {
+	mutex sync.Mutex
+	keys  map[string]keyEntry
+}
+
+// keyEntry is what the log knows about the records with one key
+type keyEntry struct {
+	// Offset of the newest record with the key that is not a tombstone
+	latest uint64
+
+	// Set when a tombstone deleting the key came after that record
+	deleted bool
+
+	// Offset of the oldest record with the key of any kind, which tells Compact whether a
+	// tombstone deleting the key still shadows records in sealed segments
+	first uint64
+}
+
+// add notes record, appended at offset. A tombstone carrying TombstoneOffsetHeader only replaces the record
+// at that offset, any other tombstone deletes its key.
+func (k *keyIndex) add(offset uint64, record *api.Record) {
+	if len(record.Key) == 0 {
+		return
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.keys == nil {
+		k.keys = make(map[string]keyEntry)
+	}
+	entry, ok := k.keys[string(record.Key)]
+	if !ok {
+		entry.first = offset
+	}
+	if _, replaces := record.Headers[TombstoneOffsetHeader]; !record.Tombstone {
+		entry.latest, entry.deleted = offset, false
+	} else if !replaces {
+		entry.deleted = true
+	}
+	k.keys[string(record.Key)] = entry
+}
+
+// get returns what is known about key
+func (k *keyIndex) get(key []byte) (keyEntry, bool) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	entry, ok := k.keys[string(key)]
+	return entry, ok
+}
+
+// rebuildKeys indexes the keys of every record in the log from scratch.
+// It reads the whole log, so it only runs on open and after records were removed from the middle or the end.
+// The caller holds the write lock, or has not shared the log yet.
+func (l *Log) rebuildKeys() error {
+	keys := &keyIndex{}
+	for _, s := range l.segmentList {
+		encoding := s.Config().RecordEncoding
+		err := s.ScanRaw(func(offset uint64, _ uint64, p []byte) error {
+			record, err := seg.UnmarshalRecord(encoding, p)
+			if err != nil {
+				return err
+			}
+			keys.add(offset, record)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	l.keys.mutex.Lock()
+	l.keys.keys = keys.keys
+	l.keys.mutex.Unlock()
+	return nil
+}
+
+// LatestByKey returns the most recently appended record with key. Tombstones are not returned:
+// one deleting the key makes it ErrKeyNotFound until a record with the key is appended again.
+// The newest record of every key is kept in memory, so the lookup is a single read.
+func (l *Log) LatestByKey(key []byte) (*api.Record, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return nil, ErrLogClosed
+	}
+
+	offset, err := l.latestByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return l.read(context.Background(), offset)
+}
+
+// latestByKey returns the offset of the newest live record with key. The caller holds at least the read lock.
+func (l *Log) latestByKey(key []byte) (uint64, error) {
+	entry, ok := l.keys.get(key)
+	if !ok || entry.deleted {
+		return 0, ErrKeyNotFound
+	}
+
+	// Retention or Truncate may have removed the record, and with it every older one
+	if entry.latest < l.segmentList[0].BaseOffset() {
+		return 0, ErrKeyNotFound
+	}
+	return entry.latest, nil
+}
+
+// AppendOrReplace appends record and returns its offset, treating the log as a key-value store.
+// When a record with the same key already exists, a tombstone carrying the old record's offset in
+// TombstoneOffsetHeader is appended after the new record, so Compact can drop the old one.
+// Without an existing record it behaves exactly like Append. The record must have a key.
+func (l *Log) AppendOrReplace(record *api.Record) (uint64, error) {
+	if len(record.Key) == 0 {
+		return 0, errors.New("record has no key to replace by")
+	}
+
+	if err := l.lockForAppend(); err != nil {
+		return 0, err
+	}
+	defer l.mutex.Unlock()
+
+	existing, err := l.latestByKey(record.Key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return l.append(context.Background(), record)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	offset, err := l.append(context.Background(), record)
+	if err != nil {
+		return 0, err
+	}
+
+	// The new record is already in, a failed tombstone only leaves the old one around for longer
+	tombstone := &api.Record{
+		Key:       record.Key,
+		Tombstone: true,
+		Headers:   map[string][]byte{TombstoneOffsetHeader: []byte(strconv.FormatUint(existing, 10))},
+	}
+	if _, err := l.append(context.Background(), tombstone); err != nil {
+		return offset, err
+	}
+
+	return offset, nil
+}
+
+// compactable returns what Compact can drop from the active segment: the records replaced by tombstones in it,
+// and the keys deleted by tombstones in it, each with the offset of its newest such tombstone.
+// A tombstone only goes once everything it shadows is in the active segment too, or already gone, since
+// sealed segments are never rewritten. The caller holds the write lock.
+func (l *Log) compactable() (replaced map[uint64]bool, deleted map[string]uint64, kept map[uint64]bool, err error) {
+	replaced, deleted, kept = make(map[uint64]bool), make(map[string]uint64), make(map[uint64]bool)
+	base, lowest := l.activeSegment.BaseOffset(), l.segmentList[0].BaseOffset()
+	encoding := l.activeSegment.Config().RecordEncoding
+
+	err = l.activeSegment.Walk(func(offset uint64, p []byte) error {
+		record, err := seg.UnmarshalRecord(encoding, p)
+		if err != nil {
+			return err
+		}
+		if !record.Tombstone {
+			return nil
+		}
+
+		// A tombstone left by AppendOrReplace shadows a single record
+		if header, ok := record.Headers[TombstoneOffsetHeader]; ok {
+			replacedOffset, err := strconv.ParseUint(string(header), 10, 64)
+			switch {
+			case err != nil || replacedOffset < lowest:
+			case replacedOffset >= base:
+				replaced[replacedOffset] = true
+			default:
+				kept[offset] = true
+			}
+			return nil
+		}
+
+		// Any other tombstone shadows every older record with its key, a tombstone without a key shadows nothing
+		if len(record.Key) == 0 {
+			return nil
+		}
+		if entry, ok := l.keys.get(record.Key); ok && entry.first < base {
+			kept[offset] = true
+			return nil
+		}
+		deleted[string(record.Key)] = offset
+		return nil
+	})
+	return replaced, deleted, kept, err
+}
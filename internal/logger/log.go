@@ -1,26 +1,126 @@
 package logger
 
 import (
+	"context"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"log"
 	"os"
-	"path"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	api "github.com/BryceDouglasJames/Cute-Logger/api"
 	seg "github.com/BryceDouglasJames/Cute-Logger/internal/core/segment"
 	"github.com/BryceDouglasJames/Cute-Logger/internal/core/store"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var (
+	ErrSegmentNotFound   = errors.New("no segment with that base offset")
+	ErrEmptyLog          = errors.New("log holds no records")
+	ErrRangeNotSupported = errors.New("range reaches into the active segment")
+	ErrLogClosed         = errors.New("log is closed")
+	ErrTooManySegments   = errors.New("segment count exceeds threshold")
+
+	// ErrExportUnsupported is returned by WriteTo for a segment whose store entries cannot be copied as they are,
+	// because they are compressed or padded to page boundaries
+	ErrExportUnsupported = errors.New("segment store is compressed or page aligned")
+)
+
+var _ io.Closer = (*Log)(nil)
+
 type Log struct {
-	mutex     sync.RWMutex
-	Directory string
+	mutex sync.RWMutex
+
+	// Serializes appends made under the read lock, see Append
+	appendMutex sync.Mutex
+
+	// Directory holding the log's files, cleaned so paths built from it compare equal
+	dir string
 
 	activeSegment *seg.Segment
 	segmentList   []*seg.Segment
+	config        LogConfig
+
+	// Time of the most recent successful Append, read without taking the mutex
+	lastProducedAt atomic.Pointer[time.Time]
+
+	// Sequence number of the most recently appended record. It only ever grows, even across Reset and Truncate.
+	seq atomic.Uint64
+
+	// Set by Close so later calls fail with ErrLogClosed instead of touching closed files
+	closed atomic.Bool
+
+	// Lifecycle of the log's background goroutines, cancelled and waited on by Close
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	appendHook   func(offset uint64, record *api.Record)
+	readHook     func(offset uint64)
+	rotationHook func(base, next uint64)
+	fullObserver func(sealed *SegmentInfo)
+
+	// Tells the time for retention and idle tracking, see WithClock
+	clock Clock
+
+	// Offsets of recent records by content, set up by the first AppendIfAbsent
+	dedup *dedupIndex
+
+	// Newest record of every key, see LatestByKey
+	keys keyIndex
+
+	// Rebuild the index of a segment whose index file is missing instead of skipping the segment
+	recoverOnCorruption bool
+
+	// One lock per consumer checkpoint, keyed by consumer ID
+	consumerMutexes sync.Map
+
+	// Which sealed segments Compact removes, see WithRetentionPolicy
+	retention retentionPolicy
+
+	// Stamped on every appended record, see WithProducerID
+	producerID string
+
+	// Holds Append back while consumers are too far behind, see WithMaxConsumerLag
+	backpressure backpressure
+
+	// Wakes subscriptions and watermark watchers whenever records are appended or removed
+	changed broadcast
+
+	// Permissions of every file the log creates, see WithFilePerm
+	filePerm os.FileMode
+
+	// Permissions of the directories the log creates when set, see WithDirPerm
+	dirMode os.FileMode
+
+	// Create the log's directory on open if it is missing, see WithAutoCreateDir
+	autoCreateDir bool
+
+	// Segment count past which appends warn, see WithSegmentCountWarningThreshold
+	segmentWarnThreshold int
+
+	// Provides the tracers of every segment's store and index, see WithTracingProvider
+	tracerProvider trace.TracerProvider
+}
+
+// Represents a function that applies configuration options to a Log instance
+type LogOption func(*Log)
+
+// Buffers WriteTo copies segments through, shared so copies do not allocate every call
+var writeToBufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 32*1024)
+		return &buf
+	},
 }
 
 type originSegmentReader struct {
@@ -28,30 +128,184 @@ type originSegmentReader struct {
 	offset       int64
 }
 
-func NewLog(dir string) (log *Log, err error) {
+// WithSegmentRotationHook registers a function that is called whenever the active segment fills up and the log
+// rotates to a new one. base and next are the base and next offsets of the segment that was just sealed, next being
+// where the new active segment starts. Segments created on open, by Truncate and the like do not count as rotations.
+// The hook runs on its own goroutine so it never blocks producers, which makes it a good place to start compacting
+// or uploading sealed segments.
+func WithSegmentRotationHook(fn func(base, next uint64)) LogOption {
+	return func(l *Log) {
+		l.rotationHook = fn
+	}
+}
+
+// WithSegmentFullObserver registers a function that is called once for every segment sealed because it filled up,
+// just like WithSegmentRotationHook.
+// sealed describes the segment as it was sealed and is the observer's to keep. The observer runs on its own
+// goroutine, so it can hand the segment to a background compaction queue without blocking producers.
+func WithSegmentFullObserver(fn func(sealed *SegmentInfo)) LogOption {
+	return func(l *Log) {
+		l.fullObserver = fn
+	}
+}
+
+// WithProducerID stamps every record the log appends with id, so records can be traced back to the node
+// that wrote them, e.g. when debugging a split brain. Without it records keep whatever producer ID they carry.
+func WithProducerID(id string) LogOption {
+	return func(l *Log) {
+		l.producerID = id
+	}
+}
+
+// WithFilePerm creates the log's segment, config and checkpoint files with perm instead of the default 0644,
+// and its directories with the matching execute bits, e.g. 0750 for 0640, unless WithDirPerm says otherwise.
+// The process umask still applies.
+func WithFilePerm(perm os.FileMode) LogOption {
+	return func(l *Log) {
+		l.filePerm = perm
+	}
+}
+
+// WithDirPerm creates the log's directories with perm, instead of deriving it from WithFilePerm's permissions.
+// The process umask still applies.
+func WithDirPerm(perm os.FileMode) LogOption {
+	return func(l *Log) {
+		l.dirMode = perm
+	}
+}
+
+// WithAutoCreateDir has NewLog create the log's directory, along with any missing parents, when it does not
+// exist yet. It is on by default; turned off, opening a log in a missing directory fails.
+func WithAutoCreateDir(create bool) LogOption {
+	return func(l *Log) {
+		l.autoCreateDir = create
+	}
+}
+
+// dirPerm returns the permissions for the directories of a log, those set with WithDirPerm or else ones
+// matching filePerm, see seg.DirPerm
+func (l *Log) dirPerm() os.FileMode {
+	if l.dirMode != 0 {
+		return l.dirMode
+	}
+	return seg.DirPerm(l.filePerm)
+}
+
+// WithSegmentCountWarningThreshold warns whenever an append leaves the log with more than n segments,
+// which piles up with rapid writes and truncations and is undone by Defragment. Each warning is logged
+// with ErrTooManySegments and the current count, and the append hook, if any, is called with the offset
+// of the append and a nil record, which hooks have to check for once this is set. Zero, the default, never warns.
+func WithSegmentCountWarningThreshold(n int) LogOption {
+	return func(l *Log) {
+		l.segmentWarnThreshold = n
+	}
+}
+
+// WithRecoverOnCorruption rebuilds the index of any segment found with a store but no index when the log is opened,
+// along with any index that fails Segment.ValidateIndex. Without it a store missing its index is skipped with a
+// warning and every index is used as it is found.
+func WithRecoverOnCorruption(recover bool) LogOption {
+	return func(l *Log) {
+		l.recoverOnCorruption = recover
+	}
+}
+
+// WithAppendHook registers a function that is called after every successful Append.
+// The hook runs on its own goroutine so instrumentation never blocks producers.
+func WithAppendHook(fn func(offset uint64, record *api.Record)) LogOption {
+	return func(l *Log) {
+		l.appendHook = fn
+	}
+}
+
+// WithReadHook registers a function that is called after every successful Read.
+// The hook runs on its own goroutine so instrumentation never blocks consumers.
+func WithReadHook(fn func(offset uint64)) LogOption {
+	return func(l *Log) {
+		l.readHook = fn
+	}
+}
+
+func NewLog(dir string, opts ...LogOption) (log *Log, err error) {
 	l := &Log{
-		Directory: dir,
+		dir:           filepath.Clean(dir),
+		filePerm:      0644,
+		autoCreateDir: true,
+		clock:         realClock{},
+	}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+
+	// Apply each option to the log
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if l.autoCreateDir {
+		if err := os.MkdirAll(l.dir, l.dirPerm()); err != nil {
+			return l, err
+		}
+	}
+
+	if err := l.setup(); err != nil {
+		return l, err
+	}
+	if err := l.loadConsumerOffsets(); err != nil {
+		return l, err
 	}
+	l.startRetention()
 
-	return l, l.setup()
+	return l, nil
 }
 
 func (l *Log) setup() error {
 	// Attempt to read the directory for any existing log files
-	logFiles, err := os.ReadDir(l.Directory)
+	logFiles, err := os.ReadDir(l.dir)
 	if err != nil {
 		return err
 	}
 
-	// Parse the starting offsets from the filenames of log files
+	// Load the persisted config, or persist the effective one for a new log
+	if err := l.setupConfig(); err != nil {
+		return err
+	}
+
+	// Parse the starting offsets from the filenames of log files, noting which of the
+	// store and index each offset has
 	var startingOffsets []uint64
+	hasStore := make(map[uint64]bool)
+	hasIndex := make(map[uint64]bool)
+	seenFiles := make(map[string]struct{})
+	// Only files named by a bare offset and one of the configured extensions belong to segments,
+	// anything else in the directory, e.g. config or checkpoint files, is left alone
+	segmentFile := regexp.MustCompile(`^(\d+)(` + regexp.QuoteMeta(l.config.StoreExtension) + `|` + regexp.QuoteMeta(l.config.IndexExtension) + `)$`)
 	for _, file := range logFiles {
-		offsetString := strings.TrimSuffix(file.Name(), path.Ext(file.Name()))
-		offset, _ := strconv.ParseUint(offsetString, 10, 0)
-		if err != nil {
-			return errors.New("failed to parse offset")
+		match := segmentFile.FindStringSubmatch(file.Name())
+		if file.IsDir() || match == nil {
+			continue
+		}
+
+		offsetString, ext := match[1], match[2]
+		offset, parseErr := strconv.ParseUint(offsetString, 10, 64)
+		if parseErr != nil {
+			return fmt.Errorf("failed to parse offset from %q: %w", offsetString, parseErr)
+		}
+
+		// A second store or index for the same offset, e.g. "0.store" next to "00.store", is left alone
+		fileKey := strconv.FormatUint(offset, 10) + ext
+		if _, ok := seenFiles[fileKey]; ok {
+			log.Printf("warning: skipping %s, another %s file already exists for offset %d", file.Name(), ext, offset)
+			continue
+		}
+		seenFiles[fileKey] = struct{}{}
+
+		if !hasStore[offset] && !hasIndex[offset] {
+			startingOffsets = append(startingOffsets, offset)
+		}
+		if ext == l.config.StoreExtension {
+			hasStore[offset] = true
+		} else {
+			hasIndex[offset] = true
 		}
-		startingOffsets = append(startingOffsets, offset)
 	}
 
 	// Sort the offsets to ensure segments are processed in order.
@@ -61,11 +315,35 @@ func (l *Log) setup() error {
 		},
 	)
 
-	// Create segments for each starting offset.
-	// Skip every other offset since they are duplicated for index and store.
-	for i := 0; i < len(startingOffsets); i += 2 {
-		if err = l.newSegment(startingOffsets[i]); err != nil {
-			return err
+	// Create segments for each starting offset that has both of its files.
+	// A store whose index was lost can be rebuilt from its records, an index without its store cannot.
+	for _, offset := range startingOffsets {
+		switch {
+		case hasStore[offset] && hasIndex[offset]:
+			if err = l.newSegment(offset); err != nil {
+				return err
+			}
+			if !l.recoverOnCorruption {
+				break
+			}
+			if invalid := l.activeSegment.ValidateIndex(l.activeSegment.StoreSize()); invalid != nil {
+				log.Printf("warning: rebuilding the index for the segment at offset %d: %v", offset, invalid)
+				if err = l.activeSegment.Repair(); err != nil {
+					return err
+				}
+			}
+		case hasStore[offset] && l.recoverOnCorruption:
+			log.Printf("warning: rebuilding the missing index for the segment at offset %d", offset)
+			if err = l.newSegment(offset); err != nil {
+				return err
+			}
+			if err = l.activeSegment.Repair(); err != nil {
+				return err
+			}
+		case hasStore[offset]:
+			log.Printf("warning: skipping the segment at offset %d, its store has no index", offset)
+		default:
+			log.Printf("warning: skipping the segment at offset %d, its index has no store", offset)
 		}
 	}
 
@@ -76,51 +354,680 @@ func (l *Log) setup() error {
 		}
 	}
 
-	return nil
+	// Only replay the segments written after the last checkpoint
+	if err := l.repairSegments(); err != nil {
+		return err
+	}
+
+	// Carry the sequence on from the newest record
+	newest, err := l.highestRecord()
+	if err != nil {
+		return err
+	}
+	if newest != nil && newest.Seq > l.seq.Load() {
+		l.seq.Store(newest.Seq)
+	}
+
+	return l.rebuildKeys()
 }
 
+// Append adds record to the active segment and returns its offset.
+// Appends only take the read lock, so reads carry on alongside them. Only rotating to a new segment once
+// the active one fills up takes the write lock, after the read lock is released.
+// With WithMaxConsumerLag it first blocks, holding no lock, until the slowest consumer has caught up.
+// An error creating the next segment after the record filled the active one is returned along with the
+// record's offset: the record was written and can be read at that offset, only the rotation failed.
 func (l *Log) Append(record *api.Record) (offset uint64, err error) {
-	// Protect Read/Write
+	return l.AppendContext(context.Background(), record)
+}
+
+// AppendContext is Append, tracing the segment's store and index writes as children of the span in ctx
+// when the log was opened WithTracingProvider
+func (l *Log) AppendContext(ctx context.Context, record *api.Record) (offset uint64, err error) {
+	if err := l.waitForConsumers(); err != nil {
+		return 0, err
+	}
+
+	l.mutex.RLock()
+	if l.closed.Load() {
+		l.mutex.RUnlock()
+		return 0, ErrLogClosed
+	}
+
+	// A full segment is waiting for another append to rotate it, so append under the write lock instead
+	if l.activeSegment.IsFull() {
+		l.mutex.RUnlock()
+		return l.appendLocked(ctx, record)
+	}
+
+	// Another append may have filled the segment while this one waited for its turn
+	l.appendMutex.Lock()
+	if l.activeSegment.IsFull() {
+		l.appendMutex.Unlock()
+		l.mutex.RUnlock()
+		return l.appendLocked(ctx, record)
+	}
+	off, err := l.appendActive(ctx, record)
+	l.appendMutex.Unlock()
+	full := l.activeSegment.IsFull()
+	l.mutex.RUnlock()
+	if err != nil {
+		return 0, err
+	}
+
+	// Upgrade to the write lock to rotate. Another append may have rotated already while no lock was held.
+	if full {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+
+		if !l.closed.Load() && l.activeSegment.IsFull() {
+			if err = l.rotateFull(); err == nil {
+				l.warnSegmentCount(off)
+			}
+		}
+	}
+
+	// Notify the append hook, if any, once the append has fully succeeded
+	if err == nil && l.appendHook != nil {
+		runHook("append", func() { l.appendHook(off, record) })
+	}
+
+	return off, err
+}
+
+// appendLocked is Append under the write lock
+func (l *Log) appendLocked(ctx context.Context, record *api.Record) (uint64, error) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
-	// Append record to active segment
-	off, err := l.activeSegment.Append(record)
+	if l.closed.Load() {
+		return 0, ErrLogClosed
+	}
+
+	return l.append(ctx, record)
+}
+
+// lockForAppend waits for lagging consumers like Append does, then takes the write lock for an append.
+// Once the log is closed it returns ErrLogClosed without holding the lock.
+func (l *Log) lockForAppend() error {
+	if err := l.waitForConsumers(); err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	if l.closed.Load() {
+		l.mutex.Unlock()
+		return ErrLogClosed
+	}
+	return nil
+}
+
+// AppendAt appends record only if it lands at offset, checking and appending under one lock.
+// Otherwise it returns api.ErrOffsetConflict with the offset the record would have landed at.
+func (l *Log) AppendAt(record *api.Record, offset uint64) (uint64, error) {
+	if err := l.lockForAppend(); err != nil {
+		return 0, err
+	}
+	defer l.mutex.Unlock()
+
+	if next := l.activeSegment.NextOffset(); next != offset {
+		return 0, api.ErrOffsetConflict{Expected: offset, Actual: next}
+	}
+	return l.append(context.Background(), record)
+}
+
+// append adds record to the active segment, rotating it once full. The caller holds the write lock.
+// Like Append, it returns the record's offset along with an error from the rotation.
+func (l *Log) append(ctx context.Context, record *api.Record) (offset uint64, err error) {
+	off, err := l.appendActive(ctx, record)
 	if err != nil {
 		return 0, err
 	}
 
 	// If the active segment is now full, create a new one.
 	if l.activeSegment.IsFull() {
-		err = l.newSegment(off + 1)
+		if err = l.rotateFull(); err == nil {
+			l.warnSegmentCount(off)
+		}
+	}
+
+	// Notify the append hook, if any, once the append has fully succeeded
+	if err == nil && l.appendHook != nil {
+		runHook("append", func() { l.appendHook(off, record) })
+	}
+
+	return off, err
+}
+
+// appendActive adds record to the active segment without ever rotating it.
+// The caller holds either the write lock, or the read lock along with appendMutex.
+func (l *Log) appendActive(ctx context.Context, record *api.Record) (uint64, error) {
+	// Stamp the record with the next sequence number, only using it up once the append succeeds
+	seq := l.seq.Load() + 1
+	record.Seq = seq
+	l.stampProducer(record)
+
+	// Append record to active segment
+	off, err := l.activeSegment.AppendContext(ctx, record)
+	if err != nil {
+		return 0, err
+	}
+	l.seq.Store(seq)
+	l.dedup.add(off, record)
+	l.keys.add(off, record)
+	l.changed.notify()
+
+	// Record when the log last saw a producer
+	now := l.clock.Now()
+	l.lastProducedAt.Store(&now)
+
+	return off, nil
+}
+
+// stampProducer sets the record's producer ID to the log's, if it has one
+func (l *Log) stampProducer(record *api.Record) {
+	if l.producerID != "" {
+		record.ProducerId = l.producerID
+	}
+}
+
+// ProducerID returns the ID the log stamps on every record it appends, empty without WithProducerID
+func (l *Log) ProducerID() string {
+	return l.producerID
+}
+
+// Lock takes the log's write lock and returns the function that releases it.
+// Callers coordinating several operations defer the returned function; appends and reads wait until then.
+// Calling the log's own methods while holding the lock deadlocks.
+func (l *Log) Lock() (unlock func()) {
+	l.mutex.Lock()
+	return l.mutex.Unlock
+}
+
+// Dir returns the directory the log keeps its files in
+func (l *Log) Dir() string {
+	return l.dir
+}
+
+// AppendBatch appends records in order under a single lock and returns their offsets.
+// A batch that fills the active segment carries on in a new one. On error the offsets of the records
+// already written are returned along with it.
+func (l *Log) AppendBatch(records []*api.Record) ([]uint64, error) {
+	if err := l.lockForAppend(); err != nil {
+		return nil, err
+	}
+	defer l.mutex.Unlock()
+
+	offsets := make([]uint64, 0, len(records))
+	rotated := false
+	for len(offsets) < len(records) {
+		remaining := records[len(offsets):]
+
+		// Stamp the sequence numbers up front, they are only used up by the records actually written
+		seq := l.seq.Load()
+		for i, record := range remaining {
+			record.Seq = seq + uint64(i) + 1
+			l.stampProducer(record)
+		}
+
+		written, err := l.activeSegment.AppendBatch(remaining)
+		l.seq.Add(uint64(len(written)))
+		for i, off := range written {
+			l.dedup.add(off, remaining[i])
+			l.keys.add(off, remaining[i])
+		}
+		offsets = append(offsets, written...)
+		l.changed.notify()
+
+		// Move on to a new segment at the boundary, starting right after the last record written.
+		// A segment too small to take a single record is reported rather than retried forever.
+		if errors.Is(err, seg.ErrSegmentFull) && len(written) > 0 {
+			err = l.rotateFull()
+			rotated = true
+		}
+		if err != nil {
+			return offsets, err
+		}
+	}
+
+	// Like Append, leave a fresh segment behind if the batch filled the active one
+	if l.activeSegment.IsFull() {
+		if err := l.rotateFull(); err != nil {
+			return offsets, err
+		}
+		rotated = true
+	}
+	if rotated {
+		l.warnSegmentCount(offsets[len(offsets)-1])
+	}
+
+	// Record when the log last saw a producer
+	now := l.clock.Now()
+	l.lastProducedAt.Store(&now)
+
+	if l.appendHook != nil {
+		for i, off := range offsets {
+			off, record := off, records[i]
+			runHook("append", func() { l.appendHook(off, record) })
+		}
+	}
+
+	return offsets, nil
+}
+
+// AppendWithSeq appends record like Append and also returns the sequence number it was given.
+// Consumers that remember the last sequence number they handled can skip any record at or below it.
+func (l *Log) AppendWithSeq(record *api.Record) (offset uint64, seq uint64, err error) {
+	offset, err = l.Append(record)
+	if err != nil {
+		return 0, 0, err
+	}
+	return offset, record.Seq, nil
+}
+
+// LastProducedAt returns when the most recent record was appended,
+// or the zero time if nothing has been appended since the log was opened.
+// It does not take the log's lock, so health checks can call it freely.
+func (l *Log) LastProducedAt() time.Time {
+	if t := l.lastProducedAt.Load(); t != nil {
+		return *t
+	}
+
+	return time.Time{}
+}
+
+// IdleDuration returns how long it has been since the most recent record was appended.
+// Alerting when it passes a threshold detects a stalled producer.
+func (l *Log) IdleDuration() time.Duration {
+	return l.clock.Now().Sub(l.LastProducedAt())
+}
+
+// LowestOffset returns the offset of the oldest record the log holds, or the offset the next record gets when it is empty
+func (l *Log) LowestOffset() (uint64, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return l.lowestOffset(), nil
+}
+
+// lowestOffset returns the offset of the oldest record the log holds, skipping records compaction or DeleteRange
+// removed from the front of a segment. The caller holds the lock.
+func (l *Log) lowestOffset() uint64 {
+	for _, s := range l.segmentList {
+		if off, ok := s.LowestOffset(); ok {
+			return off
+		}
+	}
+	return l.activeSegment.NextOffset()
+}
+
+// HighestOffset returns the offset of the newest record the log holds, or 0 when it is empty
+func (l *Log) HighestOffset() (uint64, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	next := l.activeSegment.NextOffset()
+	if next == 0 {
+		return 0, nil
+	}
+	return next - 1, nil
+}
+
+// PeekLatest returns the newest record in the log under a single read lock, or ErrEmptyLog when there is none.
+// It is cheaper than Read(HighestOffset()), which takes the lock twice and searches the segments.
+func (l *Log) PeekLatest() (*api.Record, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return nil, ErrLogClosed
+	}
+
+	// The newest record is in the active segment unless it was only just rolled over
+	record, err := l.highestRecord()
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrEmptyLog
+	}
+	return record, nil
+}
+
+// LowestSeq returns the sequence number of the oldest record the log holds, or 0 when it is empty
+func (l *Log) LowestSeq() (uint64, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return 0, ErrLogClosed
+	}
+
+	record, err := l.lowestRecord()
+	if err != nil || record == nil {
+		return 0, err
+	}
+	return record.Seq, nil
+}
+
+// HighestSeq returns the sequence number of the newest record the log holds, or 0 when it is empty
+func (l *Log) HighestSeq() (uint64, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return 0, ErrLogClosed
+	}
+
+	record, err := l.highestRecord()
+	if err != nil || record == nil {
+		return 0, err
+	}
+	return record.Seq, nil
+}
+
+// lowestRecord returns the oldest record in the log, or nil when the log is empty
+func (l *Log) lowestRecord() (*api.Record, error) {
+	for _, s := range l.segmentList {
+		if off, ok := s.LowestOffset(); ok {
+			return s.Read(off)
+		}
+	}
+	return nil, nil
+}
+
+// highestRecord returns the newest record in the log, or nil when the log is empty
+func (l *Log) highestRecord() (*api.Record, error) {
+	for i := len(l.segmentList) - 1; i >= 0; i-- {
+		if s := l.segmentList[i]; s.NextOffset() > s.BaseOffset() {
+			return s.Read(s.NextOffset() - 1)
+		}
+	}
+	return nil, nil
+}
+
+func (l *Log) Read(offset uint64) (*api.Record, error) {
+	return l.ReadContext(context.Background(), offset)
+}
+
+// ReadContext is Read, tracing the segment's index and store reads as children of the span in ctx
+// when the log was opened WithTracingProvider
+func (l *Log) ReadContext(ctx context.Context, offset uint64) (*api.Record, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return nil, ErrLogClosed
+	}
+
+	record, err := l.read(ctx, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	// Notify the read hook, if any
+	if l.readHook != nil {
+		runHook("read", func() { l.readHook(offset) })
+	}
+
+	return record, nil
+}
+
+// read returns the record at offset. The caller holds the lock.
+func (l *Log) read(ctx context.Context, offset uint64) (*api.Record, error) {
+	s, err := l.segmentContaining(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the record from the found segment, a record it no longer holds is out of range like any other
+	record, err := s.ReadContext(ctx, offset)
+	var notFound *seg.ErrOffsetNotFound
+	if errors.As(err, &notFound) {
+		return nil, api.ErrOffsetOutOfRange{Offset: notFound.Offset}
+	}
+	return record, err
+}
+
+// Exists reports whether the log holds a record at offset. Unlike Read it only looks the offset up in the index,
+// without reading or decoding the record, so checking before fetching stays cheap.
+func (l *Log) Exists(offset uint64) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return false
+	}
+
+	s, err := l.segmentContaining(offset)
+	if err != nil {
+		return false
+	}
+	return s.Has(offset)
+}
+
+// segmentContaining returns the segment whose offset range includes offset. The caller holds the lock.
+func (l *Log) segmentContaining(offset uint64) (*seg.Segment, error) {
+	for _, s := range l.segmentList {
+		if s.BaseOffset() <= offset && offset < s.NextOffset() {
+			return s, nil
+		}
+	}
+
+	return nil, api.ErrOffsetOutOfRange{Offset: offset}
+}
+
+// SegmentInfo describes one segment of a log without handing out the segment itself
+type SegmentInfo struct {
+	StorePath string
+	IndexPath string
+
+	// Offsets of the segment's first record and of the record it will append next
+	BaseOffset uint64
+	NextOffset uint64
+
+	StoreBytes uint64
+	IndexBytes uint64
+}
+
+// SegmentAt describes the segment holding the record at offset, for tests and tooling that inspect segments directly.
+// It returns api.ErrOffsetOutOfRange when no segment holds offset.
+func (l *Log) SegmentAt(offset uint64) (SegmentInfo, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return SegmentInfo{}, ErrLogClosed
+	}
+
+	s, err := l.segmentContaining(offset)
+	if err != nil {
+		return SegmentInfo{}, err
+	}
+
+	return segmentInfo(s), nil
+}
+
+// segmentInfo describes s as it is right now
+func segmentInfo(s *seg.Segment) SegmentInfo {
+	return SegmentInfo{
+		StorePath:  s.StorePath(),
+		IndexPath:  s.IndexPath(),
+		BaseOffset: s.BaseOffset(),
+		NextOffset: s.NextOffset(),
+		StoreBytes: s.StoreSize(),
+		IndexBytes: s.IndexSize(),
+	}
+}
+
+// ForEach calls fn with every record in the log in offset order, stopping at the first error fn returns.
+// The log is read locked throughout, so fn must not write to it.
+func (l *Log) ForEach(fn func(offset uint64, record *api.Record) error) error {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return ErrLogClosed
+	}
+
+	for _, s := range l.segmentList {
+		encoding := s.Config().RecordEncoding
+		err := s.ScanRaw(func(offset uint64, _ uint64, p []byte) error {
+			record, err := seg.UnmarshalRecord(encoding, p)
+			if err != nil {
+				return err
+			}
+			return fn(offset, record)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Replay calls handler with every record from startOffset on, in offset order, so event sourced applications
+// can rebuild their state from a known point. It stops at the first error handler returns, which it returns,
+// or once the newest record at the time of the call is delivered. Offsets that were removed are skipped.
+// The log is read locked throughout, so handler must not write to it.
+func (l *Log) Replay(startOffset uint64, handler func(*api.Record) error) error {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return ErrLogClosed
+	}
+
+	for _, s := range l.segmentList {
+		offset := s.BaseOffset()
+		if offset < startOffset {
+			offset = startOffset
+		}
+		for end := s.NextOffset(); offset < end; offset++ {
+			if !s.Has(offset) {
+				continue
+			}
+			record, err := s.Read(offset)
+			if err != nil {
+				return err
+			}
+			if err := handler(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReadByTimestamp returns the first record appended at or after ts, so consumers can seek to a point in time.
+// It returns io.EOF when every record in the log is older than ts.
+func (l *Log) ReadByTimestamp(ts time.Time) (*api.Record, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return nil, ErrLogClosed
+	}
+
+	// Segments are in append order, so the first one with a record new enough holds the answer
+	for _, s := range l.segmentList {
+		record, err := s.ReadByTimestamp(ts)
+		if err == io.EOF {
+			continue
+		}
+		return record, err
+	}
+
+	return nil, io.EOF
+}
+
+// PartialReadError is returned by ReadMulti when some of the offsets could not be read.
+// It wraps the error of every offset that failed.
+type PartialReadError struct {
+	Errs map[uint64]error
+}
+
+func (e *PartialReadError) Error() string {
+	offsets := e.offsets()
+	msgs := make([]string, len(offsets))
+	for i, off := range offsets {
+		msgs[i] = fmt.Sprintf("offset %d: %v", off, e.Errs[off])
+	}
+	return fmt.Sprintf("failed to read %d offsets: %s", len(offsets), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the individual errors, ordered by offset
+func (e *PartialReadError) Unwrap() []error {
+	offsets := e.offsets()
+	errs := make([]error, len(offsets))
+	for i, off := range offsets {
+		errs[i] = e.Errs[off]
 	}
+	return errs
+}
 
-	return off, err
+// offsets returns the failed offsets in ascending order
+func (e *PartialReadError) offsets() []uint64 {
+	offsets := make([]uint64, 0, len(e.Errs))
+	for off := range e.Errs {
+		offsets = append(offsets, off)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	return offsets
 }
 
-func (l *Log) Read(offset uint64) (*api.Record, error) {
+// ReadMulti reads the records at every offset under a single lock acquisition.
+// Records are returned in the same order as offsets. Offsets that could not be read are nil
+// in the result, and their errors are collected in a *PartialReadError.
+func (l *Log) ReadMulti(offsets []uint64) ([]*api.Record, error) {
 	l.mutex.RLock()
 	defer l.mutex.RUnlock()
 
-	// Declare a pointer to hold the segment containing the offset
-	var s *seg.Segment
+	if l.closed.Load() {
+		return nil, ErrLogClosed
+	}
 
-	// Iterate through all segments to find the one containing the offset
-	for _, segment := range l.segmentList {
+	// Visit the offsets in ascending order, remembering where each belongs in the result
+	order := make([]int, len(offsets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return offsets[order[i]] < offsets[order[j]] })
 
-		// Check if the current segment's range includes the offset
-		if segment.BaseOffset() <= offset && offset < segment.NextOffset() {
-			s = segment
-			break
+	records := make([]*api.Record, len(offsets))
+	errs := make(map[uint64]error)
+	for _, i := range order {
+		offset := offsets[i]
+
+		// Segments are sorted by base offset, so the last one starting at or before offset holds it
+		n := sort.Search(len(l.segmentList), func(j int) bool {
+			return l.segmentList[j].BaseOffset() > offset
+		}) - 1
+		if n < 0 || offset >= l.segmentList[n].NextOffset() {
+			errs[offset] = api.ErrOffsetOutOfRange{Offset: offset}
+			continue
+		}
+
+		record, err := l.segmentList[n].Read(offset)
+		if err != nil {
+			errs[offset] = err
+			continue
+		}
+		records[i] = record
+
+		// Notify the read hook, if any, the same way Read does
+		if l.readHook != nil {
+			runHook("read", func() { l.readHook(offset) })
 		}
 	}
 
-	// Check if segment is found or the found segment's next offset is not greater than the given offset
-	if s == nil || s.NextOffset() <= offset {
-		return nil, errors.New("offset is out of range when reading segments")
+	if len(errs) > 0 {
+		return records, &PartialReadError{Errs: errs}
 	}
 
-	return s.Read(offset) // Read and return the record from the found segment
+	return records, nil
 }
 
 func (l *Log) Reader() io.Reader {
@@ -137,6 +1044,153 @@ func (l *Log) Reader() io.Reader {
 	return io.MultiReader(readers...)
 }
 
+// ReaderFrom returns a reader over the raw store bytes of the log starting at the record at startOffset,
+// so consumers that have already handled the records before it do not read them again.
+func (l *Log) ReaderFrom(startOffset uint64) (io.Reader, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return nil, ErrLogClosed
+	}
+
+	// Find the segment holding startOffset
+	first := -1
+	for i, s := range l.segmentList {
+		if s.BaseOffset() <= startOffset && startOffset < s.NextOffset() {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return nil, api.ErrOffsetOutOfRange{Offset: startOffset}
+	}
+
+	pos, err := l.segmentList[first].Position(startOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start part way into the first segment and read every later segment in full
+	readers := make([]io.Reader, 0, len(l.segmentList)-first)
+	for i, s := range l.segmentList[first:] {
+		reader := &originSegmentReader{storePointer: s.GetStore()}
+		if i == 0 {
+			reader.offset = int64(pos)
+		}
+		readers = append(readers, reader)
+	}
+
+	return io.MultiReader(readers...), nil
+}
+
+// WriteTo writes the raw bytes of every segment to w, in order, and returns the number of bytes written.
+// The log is only locked while taking a snapshot of the segments and while reading each chunk,
+// so a slow writer never holds up appends. A segment whose store is compressed or page aligned stops the
+// export with ErrExportUnsupported, since ReadFrom could not read it back.
+func (l *Log) WriteTo(w io.Writer) (int64, error) {
+	// Snapshot the segment list, appends after this point may or may not be included
+	l.mutex.RLock()
+	if l.closed.Load() {
+		l.mutex.RUnlock()
+		return 0, ErrLogClosed
+	}
+	segments := make([]*seg.Segment, len(l.segmentList))
+	copy(segments, l.segmentList)
+	l.mutex.RUnlock()
+
+	bufPtr := writeToBufferPool.Get().(*[]byte)
+	defer writeToBufferPool.Put(bufPtr)
+	buf := *bufPtr
+
+	var total int64
+	for _, s := range segments {
+		// Only copy what the segment held when we got to it
+		storePointer := s.GetStore()
+		size := int64(storePointer.Size())
+
+		// ReadFrom has no way of telling compressed or padded entries apart, so they are never written
+		if storePointer.Compression() != store.NoCompression || storePointer.PageAlignment() > 0 {
+			return total, fmt.Errorf("%w: segment at offset %d", ErrExportUnsupported, s.BaseOffset())
+		}
+
+		for pos := int64(0); pos < size; {
+			chunk := buf
+			if remaining := size - pos; remaining < int64(len(chunk)) {
+				chunk = chunk[:remaining]
+			}
+
+			// The log may have been closed while the last chunk was being written
+			l.mutex.RLock()
+			if l.closed.Load() {
+				l.mutex.RUnlock()
+				return total, ErrLogClosed
+			}
+			n, err := storePointer.ReadAt(chunk, pos)
+			l.mutex.RUnlock()
+			if err != nil && err != io.EOF {
+				return total, err
+			}
+			if n == 0 {
+				return total, io.ErrUnexpectedEOF
+			}
+
+			written, err := w.Write(chunk[:n])
+			total += int64(written)
+			if err != nil {
+				return total, err
+			}
+			pos += int64(n)
+		}
+	}
+
+	return total, nil
+}
+
+// ReadFrom appends every record in r to the log and returns the number of bytes consumed.
+// r holds length-prefixed records in the same format WriteTo produces, so neither compressed entries nor
+// padding between them. A length of zero, which is what padding reads as, or one larger than a segment's
+// store can hold is rejected before anything is allocated for it. If a record cannot be read or decoded,
+// the records before it stay appended and the error is returned.
+func (l *Log) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	var prefix [8]byte
+	for {
+		// A clean end of the stream can only fall between records
+		n, err := io.ReadFull(r, prefix[:])
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+
+		size := binary.BigEndian.Uint64(prefix[:])
+		if size == 0 || size > l.config.MaxStoreBytes {
+			return total, fmt.Errorf("record length %d at byte %d: %w", size, total-int64(len(prefix)), store.ErrCorruptEntry)
+		}
+
+		p := make([]byte, size)
+		n, err = io.ReadFull(r, p)
+		total += int64(n)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return total, err
+		}
+
+		record, err := seg.UnmarshalRecord(l.Config().RecordEncoding, p)
+		if err != nil {
+			return total, err
+		}
+		if _, err := l.Append(record); err != nil {
+			return total, err
+		}
+	}
+}
+
 func (o *originSegmentReader) Read(p []byte) (int, error) {
 	// Read from segment from the origin reader offset
 	n, err := o.storePointer.ReadAt(p, o.offset)
@@ -152,6 +1206,11 @@ func (l *Log) Truncate(lowest uint64) error {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
+	if l.closed.Load() {
+		return ErrLogClosed
+	}
+	defer l.changed.notify()
+
 	// Prepare a slice to hold segments that are not removed
 	var retainedSegments []*seg.Segment
 
@@ -176,13 +1235,349 @@ func (l *Log) Truncate(lowest uint64) error {
 	// Update the log's segments to only include those that have been retained
 	l.segmentList = retainedSegments
 
+	// If the active segment went too, start a fresh one right after the truncated records
+	if len(retainedSegments) == 0 || retainedSegments[len(retainedSegments)-1] != l.activeSegment {
+		return l.newSegment(lowest + 1)
+	}
+
+	return nil
+}
+
+// CompactTo appends every record for which keep returns true to dst, in offset order, leaving the log itself
+// untouched so a crash part way never loses data. Once it returns, the caller can rename dst.Dir() over the
+// log's directory to swap the compacted log in. The records get dst's offsets rather than their own.
+// Only the read lock is held, so appends carry on meanwhile; records appended after CompactTo starts
+// are not copied. A keep that always returns true copies the log.
+func (l *Log) CompactTo(dst *Log, keep func(*api.Record) bool) error {
+	if dst == l {
+		return errors.New("cannot compact a log into itself")
+	}
+
+	end := l.CurrentWatermark().High
+	return l.ForEach(func(offset uint64, record *api.Record) error {
+		if offset >= end || !keep(record) {
+			return nil
+		}
+		_, err := dst.Append(record)
+		return err
+	})
+}
+
+// Compact removes the sealed segments the retention policy no longer keeps, see WithRetentionPolicy,
+// then rewrites the active segment without its tombstone records, or the records they replace or delete, to reclaim
+// their space. A tombstone shadowing records in a sealed segment is kept, or they would come back once it was gone.
+// The remaining records keep their offsets.
+func (l *Log) Compact() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.closed.Load() {
+		return ErrLogClosed
+	}
+
+	if err := l.applyRetention(); err != nil {
+		return err
+	}
+
+	replaced, deleted, kept, err := l.compactable()
+	if err != nil {
+		return err
+	}
+	compacted, err := l.activeSegment.Compact(func(record *api.Record) bool {
+		if record.Tombstone {
+			return kept[record.Offset]
+		}
+		if deletedAt, ok := deleted[string(record.Key)]; ok && len(record.Key) > 0 && record.Offset < deletedAt {
+			return false
+		}
+		return !replaced[record.Offset]
+	})
+	if err != nil {
+		return err
+	}
+
+	// The active segment is always the last one in the list
+	l.segmentList[len(l.segmentList)-1] = compacted
+	l.activeSegment = compacted
+
+	return nil
+}
+
+// DeleteRange removes the records with offsets from low to high inclusive, e.g. to expunge data that must not be kept.
+// Segments entirely inside the range are removed, and segments partly inside it are rewritten without those records,
+// so it costs O(n) in the records of the affected segments. The remaining records keep their offsets.
+// The active segment is never rewritten, a range reaching into it returns ErrRangeNotSupported.
+func (l *Log) DeleteRange(low, high uint64) (err error) {
+	if low > high {
+		return fmt.Errorf("invalid range [%d, %d]", low, high)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	defer l.changed.notify()
+
+	if l.closed.Load() {
+		return ErrLogClosed
+	}
+
+	if high >= l.activeSegment.BaseOffset() {
+		return ErrRangeNotSupported
+	}
+
+	// Keys may have lost their newest records, even if only some segments were rewritten
+	defer func() {
+		if keysErr := l.rebuildKeys(); err == nil {
+			err = keysErr
+		}
+	}()
+
+	// The list is rebuilt as segments are handled, and whatever was done is kept even if a later segment fails
+	var retainedSegments []*seg.Segment
+	for i, s := range l.segmentList {
+		first, next := s.BaseOffset(), s.NextOffset()
+
+		switch {
+		// Segments outside the range are left alone
+		case next <= low || first > high:
+			retainedSegments = append(retainedSegments, s)
+
+		// Segments entirely inside the range are removed.
+		// One that could not be removed completely is dropped anyway, its records were to go.
+		case low <= first && next-1 <= high:
+			if err := s.Remove(); err != nil {
+				l.segmentList = append(retainedSegments, l.segmentList[i+1:]...)
+				return err
+			}
+
+		// Segments partly inside the range are rewritten without its records.
+		// If that fails, the segment is reopened from whichever files are in place.
+		default:
+			rewritten, err := s.Compact(func(record *api.Record) bool {
+				return record.Offset < low || record.Offset > high
+			})
+			if err != nil {
+				s.Close()
+				if reopened, openErr := l.openSegment(first); openErr != nil {
+					log.Printf("warning: could not reopen the segment at offset %d after a failed delete: %v", first, openErr)
+				} else {
+					retainedSegments = append(retainedSegments, reopened)
+				}
+				l.segmentList = append(retainedSegments, l.segmentList[i+1:]...)
+				return err
+			}
+			retainedSegments = append(retainedSegments, rewritten)
+		}
+	}
+
+	l.segmentList = retainedSegments
+	return nil
+}
+
+// warnSegmentCount warns when an append at offset left the log with more segments than the configured
+// threshold, see WithSegmentCountWarningThreshold. The caller holds the write lock.
+func (l *Log) warnSegmentCount(offset uint64) {
+	if l.segmentWarnThreshold <= 0 || len(l.segmentList) <= l.segmentWarnThreshold {
+		return
+	}
+
+	log.Printf("warning: %v, %d segments are over the threshold of %d, consider calling Defragment",
+		ErrTooManySegments, len(l.segmentList), l.segmentWarnThreshold)
+	if l.appendHook != nil {
+		runHook("append", func() { l.appendHook(offset, nil) })
+	}
+}
+
+// Defragment merges runs of consecutive small segments into single segments, so the log keeps
+// fewer files open. A run is merged as long as its combined store and index stay below the
+// configured maximums. Records keep their offsets, and the active segment is left alone.
+func (l *Log) Defragment() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	defer l.changed.notify()
+
+	if l.closed.Load() {
+		return ErrLogClosed
+	}
+
+	var (
+		segments   []*seg.Segment
+		run        []*seg.Segment
+		storeBytes uint64
+		indexBytes uint64
+	)
+
+	// Merge the current run into one segment, a run of one is kept as it is.
+	// A merged segment can come back along with an error, it replaces the run either way.
+	flush := func() error {
+		var err error
+		if len(run) > 1 {
+			var merged *seg.Segment
+			if merged, err = seg.Merge(run); merged != nil {
+				run = []*seg.Segment{merged}
+			}
+		}
+		if len(run) > 1 {
+			return err
+		}
+		segments = append(segments, run...)
+		run, storeBytes, indexBytes = nil, 0, 0
+		return err
+	}
+
+	// After a failed merge, put the list back together from what is on disk: the segments done so far,
+	// the run that failed reopened from its files, and the ones not reached yet
+	fail := func(err error, rest []*seg.Segment) error {
+		for _, s := range run {
+			s.Close()
+			reopened, openErr := l.openSegment(s.BaseOffset())
+			if openErr != nil {
+				log.Printf("warning: could not reopen the segment at offset %d after a failed merge: %v", s.BaseOffset(), openErr)
+				continue
+			}
+			segments = append(segments, reopened)
+		}
+		l.segmentList = append(append(segments, rest...), l.activeSegment)
+		return err
+	}
+
+	// The segment list is kept sorted by base offset, so neighbours hold contiguous offsets
+	sealed := l.segmentList[:len(l.segmentList)-1]
+	for i, s := range sealed {
+		if len(run) > 0 && (storeBytes+s.StoreSize() >= l.config.MaxStoreBytes || indexBytes+s.IndexSize() >= l.config.MaxIndexBytes) {
+			if err := flush(); err != nil {
+				return fail(err, sealed[i:])
+			}
+		}
+		run = append(run, s)
+		storeBytes += s.StoreSize()
+		indexBytes += s.IndexSize()
+	}
+	if err := flush(); err != nil {
+		return fail(err, nil)
+	}
+
+	l.segmentList = append(segments, l.activeSegment)
+	return nil
+}
+
+// ShrinkToFit truncates the active segment's index to the entries it holds.
+// The index takes its reserved space back on the next append.
+func (l *Log) ShrinkToFit() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.closed.Load() {
+		return ErrLogClosed
+	}
+
+	return l.activeSegment.ShrinkToFit()
+}
+
+// BackfillIndex rebuilds the index of the segment starting at segmentBaseOffset from its store,
+// so a single corrupt index can be fixed without reopening the log.
+func (l *Log) BackfillIndex(segmentBaseOffset uint64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.closed.Load() {
+		return ErrLogClosed
+	}
+
+	for _, s := range l.segmentList {
+		if s.BaseOffset() == segmentBaseOffset {
+			return s.Repair()
+		}
+	}
+
+	return fmt.Errorf("%w: %d", ErrSegmentNotFound, segmentBaseOffset)
+}
+
+// ResetToOffset rolls the log back so offset is the last record it contains.
+// Unlike Reset, everything up to and including offset is preserved.
+func (l *Log) ResetToOffset(offset uint64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.closed.Load() {
+		return ErrLogClosed
+	}
+
+	if err := l.truncateAfter(offset); err != nil {
+		return err
+	}
+
+	// Everything was discarded, so start over right after the checkpoint
+	if len(l.segmentList) == 0 {
+		return l.newSegment(offset + 1)
+	}
+
+	// The retained tail may still be full, in which case it cannot take new records
+	if l.activeSegment.IsFull() {
+		return l.newSegment(l.activeSegment.NextOffset())
+	}
+
+	return nil
+}
+
+// truncateAfter discards every record with an offset greater than offset.
+// The caller must hold the write lock.
+func (l *Log) truncateAfter(offset uint64) (err error) {
+	defer l.changed.notify()
+
+	// Keys may have lost their newest records, even if only some segments were truncated
+	defer func() {
+		if keysErr := l.rebuildKeys(); err == nil {
+			err = keysErr
+		}
+	}()
+
+	// Prepare a slice to hold segments that are not removed
+	var retainedSegments []*seg.Segment
+
+	for _, s := range l.segmentList {
+		// Segments that start after the offset are removed entirely
+		if s.BaseOffset() > offset {
+			if err := s.Remove(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// The segment holding the offset only loses the records after it
+		if err := s.TruncateAfter(offset); err != nil {
+			return err
+		}
+		retainedSegments = append(retainedSegments, s)
+	}
+
+	// Update the segment list and point the active segment at the new tail
+	l.segmentList = retainedSegments
+	l.activeSegment = nil
+	if len(retainedSegments) > 0 {
+		l.activeSegment = retainedSegments[len(retainedSegments)-1]
+	}
+
 	return nil
 }
 
+// Close stops the log's background work and closes every segment. Calling it again does nothing.
 func (l *Log) Close() error {
+	// Stop background work first, without holding the lock in case it needs it to wind down
+	l.cancel()
+	l.wg.Wait()
+
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
+	// Closing twice is harmless, the segments are only closed once
+	if l.closed.Load() {
+		return nil
+	}
+	l.closed.Store(true)
+
+	// Wake anything waiting for records, so it finds the log closed instead of waiting forever
+	l.changed.notify()
+
 	// Iterate through all segments and attempt to close them.
 	for _, seg := range l.segmentList {
 		if err := seg.Close(); err != nil {
@@ -200,7 +1595,7 @@ func (l *Log) Delete() error {
 	}
 
 	// Remove the log directory along with all its contents.
-	if err := os.RemoveAll(l.Directory); err != nil {
+	if err := os.RemoveAll(l.dir); err != nil {
 		return errors.New("failed to remove log directory")
 	}
 
@@ -214,20 +1609,39 @@ func (l *Log) Reset() error {
 	}
 
 	// Ensure the log directory is recreated after deletion
-	if err := os.MkdirAll(l.Directory, 0755); err != nil {
+	if err := os.MkdirAll(l.dir, l.dirPerm()); err != nil {
 		return errors.New("failed to recreate log directory")
 	}
 
-	// Reinitialize the log to its initial state
-	return l.setup()
-}
+	// Reinitialize the log to its initial state under the write lock. It stays closed if that fails.
+	l.mutex.Lock()
+	l.segmentList = nil
+	l.activeSegment = nil
+	l.dedup = nil
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	err := l.setup()
+	if err == nil {
+		l.closed.Store(false)
+	}
+	l.mutex.Unlock()
+	if err != nil {
+		return err
+	}
 
-func (l *Log) newSegment(offset uint64) error {
-	s, err := seg.NewSegment(
-		seg.WithFilePath(l.Directory),
-		seg.WithInitialOffset(offset),
-	)
+	// Restart the background work Close stopped, like NewLog starts it. The checkpoints went with the directory.
+	l.backpressure.clear()
+	if err := l.loadConsumerOffsets(); err != nil {
+		return err
+	}
+	l.startRetention()
+
+	return nil
+}
 
+// newSegment creates a segment at offset and makes it the active one.
+// The segment is configured from the log's config, and any extra options are applied on top of it.
+func (l *Log) newSegment(offset uint64, extra ...seg.SegmentOptions) error {
+	s, err := l.openSegment(offset, extra...)
 	if err != nil {
 		return err
 	}
@@ -236,3 +1650,67 @@ func (l *Log) newSegment(offset uint64) error {
 	l.activeSegment = s
 	return nil
 }
+
+// openSegment opens the segment at offset with the log's config, without adding it to the log
+func (l *Log) openSegment(offset uint64, extra ...seg.SegmentOptions) (*seg.Segment, error) {
+	opts := append([]seg.SegmentOptions{
+		seg.WithFilePath(l.dir),
+		seg.WithInitialOffset(offset),
+		seg.WithMaxStoreBytes(l.config.MaxStoreBytes),
+		seg.WithMaxIndexBytes(l.config.MaxIndexBytes),
+		seg.WithRecordEncoding(l.config.RecordEncoding),
+		seg.WithStoreExtension(l.config.StoreExtension),
+		seg.WithIndexExtension(l.config.IndexExtension),
+		seg.WithFilePerm(l.filePerm),
+		seg.WithNow(l.clock.Now),
+	}, extra...)
+	if l.tracerProvider != nil {
+		opts = append(opts, seg.WithTracingProvider(l.tracerProvider))
+	}
+
+	return seg.NewSegment(opts...)
+}
+
+// rotateFull seals the full active segment and starts a new one right after it, letting the rotation hook
+// and the segment full observer know. The caller holds the write lock.
+func (l *Log) rotateFull() error {
+	sealed := l.activeSegment
+	if err := l.newSegment(sealed.NextOffset()); err != nil {
+		return err
+	}
+
+	if l.rotationHook != nil {
+		base, next := sealed.BaseOffset(), sealed.NextOffset()
+		runHook("segment rotation", func() { l.rotationHook(base, next) })
+	}
+
+	// Describe the segment now, while nothing else can touch it
+	if l.fullObserver != nil {
+		info := segmentInfo(sealed)
+		runHook("segment full", func() { l.fullObserver(&info) })
+	}
+	return nil
+}
+
+// goBackground runs fn on a goroutine tied to the log's lifetime.
+// fn must return once ctx is done, since Close waits for it before closing the segments.
+func (l *Log) goBackground(fn func(ctx context.Context)) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		fn(l.ctx)
+	}()
+}
+
+// runHook invokes fn on its own goroutine so a slow observer never blocks the caller.
+// A hook that panics is recovered and logged instead of taking the process down with it.
+func runHook(name string, fn func()) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("recovered from panic in %s hook: %v", name, r)
+			}
+		}()
+		fn()
+	}()
+}
@@ -1,15 +1,35 @@
 package logger
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	stdlog "log"
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/core/index"
+	seg "github.com/BryceDouglasJames/Cute-Logger/internal/core/segment"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/core/store"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/logger/testutil"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -55,14 +75,7 @@ func TestNewLogAndNewSegment(t *testing.T) {
 }
 
 func TestNewLogAppend(t *testing.T) {
-	// Create a temporary directory for testing
-	tempDir, err := os.MkdirTemp("", "log_test_again")
-	require.NoError(t, err)
-	defer os.RemoveAll(tempDir)
-
-	// Create a new Log instance with the temporary directory
-	log, err := NewLog(tempDir)
-	require.NoError(t, err)
+	log := NewTestLog(t)
 
 	// Verify that a new segment is created if no log files exist
 	require.Len(t, log.segmentList, 1, "Expected exactly one segment in the segment list")
@@ -102,16 +115,320 @@ func TestNewLogAppend(t *testing.T) {
 
 }
 
-func TestLogRead(t *testing.T) {
+func TestLogAppendBatch(t *testing.T) {
+	// Segments of three records each
+	log := NewTestLog(t, WithMaxIndexBytes(60))
+
+	records := make([]*api.Record, 7)
+	for i := range records {
+		records[i] = &api.Record{Value: []byte(fmt.Sprintf("batch %d", i))}
+	}
+
+	// The batch carries on across segment boundaries
+	offsets, err := log.AppendBatch(records)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 1, 2, 3, 4, 5, 6}, offsets)
+	require.Len(t, log.segmentList, 3)
+
+	for i, off := range offsets {
+		got, err := log.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, records[i].Value, got.Value)
+		require.Equal(t, uint64(i+1), got.Seq)
+	}
+
+	// Single appends continue where the batch left off
+	off, err := log.Append(&api.Record{Value: []byte("after")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), off)
+}
+
+func TestLogAppendAt(t *testing.T) {
+	log := NewTestLog(t)
+
+	off, err := log.AppendAt(&api.Record{Value: []byte("first")}, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), off)
+
+	// The record is only appended where the caller expects it
+	_, err = log.AppendAt(&api.Record{Value: []byte("stale")}, 0)
+	require.Equal(t, api.ErrOffsetConflict{Expected: 0, Actual: 1}, err)
+	highest, err := log.HighestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), highest)
+}
+
+func TestLogAppendIfAbsent(t *testing.T) {
+	log := NewTestLog(t)
+
+	// Records appended before the first AppendIfAbsent are found by scanning the window
+	_, err := log.Append(&api.Record{Key: []byte("a"), Value: []byte("one")})
+	require.NoError(t, err)
+
+	off, dup, err := log.AppendIfAbsent(&api.Record{Key: []byte("a"), Value: []byte("one")}, 10)
+	require.NoError(t, err)
+	require.True(t, dup)
+	require.Equal(t, uint64(0), off)
+
+	// Same value under another key is a different record
+	off, dup, err = log.AppendIfAbsent(&api.Record{Key: []byte("b"), Value: []byte("one")}, 10)
+	require.NoError(t, err)
+	require.False(t, dup)
+	require.Equal(t, uint64(1), off)
+
+	// Records appended since are found through the index
+	_, err = log.Append(&api.Record{Key: []byte("c"), Value: []byte("two")})
+	require.NoError(t, err)
+	off, dup, err = log.AppendIfAbsent(&api.Record{Key: []byte("c"), Value: []byte("two")}, 10)
+	require.NoError(t, err)
+	require.True(t, dup)
+	require.Equal(t, uint64(2), off)
+
+	// A duplicate older than the window is appended again
+	off, dup, err = log.AppendIfAbsent(&api.Record{Key: []byte("a"), Value: []byte("one")}, 2)
+	require.NoError(t, err)
+	require.False(t, dup)
+	require.Equal(t, uint64(3), off)
+}
+
+func TestLogLock(t *testing.T) {
+	log := NewTestLog(t)
+
+	unlock := log.Lock()
+
+	// An append started while the lock is held waits for it
+	appended := make(chan error, 1)
+	go func() {
+		_, err := log.Append(&api.Record{Value: []byte("waiting")})
+		appended <- err
+	}()
+	select {
+	case <-appended:
+		t.Fatal("append should block while the log is locked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Releasing the lock lets it through
+	unlock()
+	select {
+	case err := <-appended:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("append should finish once the log is unlocked")
+	}
+}
+
+func TestLogConcurrentAppendAndRead(t *testing.T) {
+	log := NewTestLog(t)
+
+	// Appends from several goroutines rotate through segments while reads run alongside them
+	const writers, perWriter = 4, 50
+	offsets := make(chan uint64, writers*perWriter)
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				off, err := log.Append(&api.Record{Value: []byte("concurrent")})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				offsets <- off
+				if _, err := log.Read(off); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(offsets)
+
+	// Every append got an offset of its own, with none skipped
+	seen := make(map[uint64]bool)
+	for off := range offsets {
+		require.False(t, seen[off], "offset %d handed out twice", off)
+		seen[off] = true
+	}
+	for off := uint64(0); off < writers*perWriter; off++ {
+		require.True(t, seen[off], "offset %d never handed out", off)
+	}
+	require.Greater(t, len(log.segmentList), 1)
+}
+
+func TestLogAppendWithSeq(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := os.MkdirTemp("", "log_test_dir")
+	tempDir, err := os.MkdirTemp("", "log_seq_test")
 	require.NoError(t, err)
 	defer os.RemoveAll(tempDir)
 
-	// Create a new log instance with the temporary directory
 	log, err := NewLog(tempDir)
 	require.NoError(t, err)
 
+	// An empty log has no sequence numbers
+	lowest, err := log.LowestSeq()
+	require.NoError(t, err)
+	require.Zero(t, lowest)
+
+	// Sequence numbers start at 1 and follow the appends
+	for i := uint64(0); i < 3; i++ {
+		off, seq, err := log.AppendWithSeq(&api.Record{Value: []byte("seq")})
+		require.NoError(t, err)
+		require.Equal(t, i, off)
+		require.Equal(t, i+1, seq)
+
+		got, err := log.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, seq, got.Seq)
+	}
+	lowest, err = log.LowestSeq()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), lowest)
+	highest, err := log.HighestSeq()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), highest)
+
+	// The sequence carries on when the log is reopened
+	require.NoError(t, log.Close())
+	log, err = NewLog(tempDir)
+	require.NoError(t, err)
+	_, seq, err := log.AppendWithSeq(&api.Record{Value: []byte("seq")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), seq)
+
+	// Offsets start over after a reset, sequence numbers do not
+	require.NoError(t, log.Reset())
+	off, seq, err := log.AppendWithSeq(&api.Record{Value: []byte("seq")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), off)
+	require.Equal(t, uint64(5), seq)
+	require.NoError(t, log.Close())
+}
+
+func TestLogPeekLatest(t *testing.T) {
+	log := NewTestLog(t)
+
+	_, err := log.PeekLatest()
+	require.ErrorIs(t, err, ErrEmptyLog)
+
+	for i := 0; i < 3; i++ {
+		_, err = log.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+	record, err := log.PeekLatest()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), record.Offset)
+	require.Equal(t, []byte("2"), record.Value)
+
+	// A freshly rolled, empty active segment still finds the newest record behind it
+	require.NoError(t, log.newSegment(3))
+	record, err = log.PeekLatest()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), record.Offset)
+}
+
+func BenchmarkLogPeekLatest(b *testing.B) {
+	log := NewTestLog(b)
+	for i := 0; i < 10000; i++ {
+		if _, err := log.Append(&api.Record{Value: []byte("hello world")}); err != nil {
+			b.Fatalf("Failed to append: %v", err)
+		}
+	}
+
+	b.Run("PeekLatest", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := log.PeekLatest(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("ReadHighestOffset", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			offset, err := log.HighestOffset()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := log.Read(offset); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestLogSegmentAt(t *testing.T) {
+	// Segments of three records each
+	log := NewTestLog(t, WithMaxIndexBytes(60))
+	for i := 0; i < 5; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+
+	info, err := log.SegmentAt(4)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), info.BaseOffset)
+	require.Equal(t, uint64(5), info.NextOffset)
+	require.Equal(t, filepath.Join(log.Dir(), "00000000000000000003.store"), info.StorePath)
+	require.Equal(t, filepath.Join(log.Dir(), "00000000000000000003.index"), info.IndexPath)
+	require.Equal(t, log.activeSegment.StoreSize(), info.StoreBytes)
+	require.Equal(t, uint64(40), info.IndexBytes)
+
+	_, err = log.SegmentAt(5)
+	require.ErrorAs(t, err, &api.ErrOffsetOutOfRange{})
+}
+
+func TestLogExists(t *testing.T) {
+	log := NewTestLog(t)
+
+	require.False(t, log.Exists(0))
+	for _, record := range []*api.Record{{Value: []byte("0")}, {Tombstone: true}, {Value: []byte("2")}} {
+		_, err := log.Append(record)
+		require.NoError(t, err)
+	}
+	require.True(t, log.Exists(0))
+	require.True(t, log.Exists(1))
+	require.False(t, log.Exists(3))
+
+	// Records dropped by compaction are gone from the index too
+	require.NoError(t, log.Compact())
+	require.False(t, log.Exists(1))
+	require.True(t, log.Exists(2))
+}
+
+func TestLogProducerID(t *testing.T) {
+	nodeA := NewTestLog(t, WithProducerID("node-a"))
+	nodeB := NewTestLog(t, WithProducerID("node-b"))
+	require.Equal(t, "node-a", nodeA.ProducerID())
+
+	// Each log stamps its own ID, whatever the record came in with
+	for _, l := range []*Log{nodeA, nodeB} {
+		_, err := l.Append(&api.Record{Value: []byte("single"), ProducerId: "someone else"})
+		require.NoError(t, err)
+		_, err = l.AppendBatch([]*api.Record{{Value: []byte("batched")}})
+		require.NoError(t, err)
+	}
+	for l, want := range map[*Log]string{nodeA: "node-a", nodeB: "node-b"} {
+		for off := uint64(0); off < 2; off++ {
+			record, err := l.Read(off)
+			require.NoError(t, err)
+			require.Equal(t, want, record.ProducerId)
+		}
+	}
+
+	// Without an ID of its own a log keeps the one a record carries
+	plain := NewTestLog(t)
+	off, err := plain.Append(&api.Record{Value: []byte("replicated"), ProducerId: "node-a"})
+	require.NoError(t, err)
+	record, err := plain.Read(off)
+	require.NoError(t, err)
+	require.Equal(t, "node-a", record.ProducerId)
+}
+
+func TestLogRead(t *testing.T) {
+	log := NewTestLog(t)
+
 	// Append a record to ensure there is at least one segment
 	initialRecord := &api.Record{Value: []byte("initial record")}
 	offset, err := log.Append(initialRecord)
@@ -125,20 +442,103 @@ func TestLogRead(t *testing.T) {
 	require.Equal(t, initialRecord.Value, readRecord.Value, "The read record should match the initial record")
 }
 
-func TestLogClose(t *testing.T) {
-	// Create a temporary directory for testing
-	tempDir, err := os.MkdirTemp("", "log_test_dir")
-	require.NoError(t, err)
-	defer os.RemoveAll(tempDir)
+func TestLogReadByTimestamp(t *testing.T) {
+	// Small segments so the search has to skip whole segments
+	log := NewTestLog(t, WithMaxStoreBytes(64))
 
-	// Create a new log instance with the temporary directory
-	log, err := NewLog(tempDir)
+	// Remember the time before each append
+	var times []time.Time
+	for i := 0; i < 10; i++ {
+		times = append(times, time.Now())
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond)
+	}
+	require.Greater(t, len(log.segmentList), 1)
+
+	// Seeking to the time before an append finds that record
+	for i, ts := range times {
+		record, err := log.ReadByTimestamp(ts)
+		require.NoError(t, err)
+		require.Equal(t, uint64(i), record.Offset)
+	}
+
+	// Nothing was appended after now
+	_, err := log.ReadByTimestamp(time.Now())
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestLogReadMulti(t *testing.T) {
+	// Small segments so the offsets are spread over several of them
+	log := NewTestLog(t, WithMaxStoreBytes(64))
+
+	for i := 0; i < 10; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+	require.Greater(t, len(log.segmentList), 1)
+
+	// Records come back in the order they were asked for
+	records, err := log.ReadMulti([]uint64{7, 0, 3, 9})
 	require.NoError(t, err)
+	for i, off := range []uint64{7, 0, 3, 9} {
+		require.Equal(t, off, records[i].Offset)
+		require.Equal(t, []byte(fmt.Sprintf("record %d", off)), records[i].Value)
+	}
+
+	// Missing offsets are nil and reported, while the rest are still read
+	records, err = log.ReadMulti([]uint64{2, 42, 5})
+	require.Error(t, err)
+	var partial *PartialReadError
+	require.ErrorAs(t, err, &partial)
+	require.Len(t, partial.Errs, 1)
+	require.Equal(t, api.ErrOffsetOutOfRange{Offset: 42}, partial.Errs[42])
+	require.Equal(t, uint64(2), records[0].Offset)
+	require.Nil(t, records[1])
+	require.Equal(t, uint64(5), records[2].Offset)
+}
+
+func TestLogClose(t *testing.T) {
+	log := NewTestLog(t)
 
 	// Attempt to close without errors
 	require.NoError(t, log.Close(), "closing log should not produce an error")
 }
 
+func TestLogUseAfterClose(t *testing.T) {
+	log := NewTestLog(t)
+
+	_, err := log.Append(&api.Record{Value: []byte("hello world")})
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+
+	// Every call fails cleanly instead of touching the closed files
+	_, err = log.Append(&api.Record{Value: []byte("hello world")})
+	require.ErrorIs(t, err, ErrLogClosed)
+	_, err = log.Read(0)
+	require.ErrorIs(t, err, ErrLogClosed)
+	require.ErrorIs(t, log.Truncate(0), ErrLogClosed)
+	require.ErrorIs(t, log.Sync(), ErrLogClosed)
+	_, err = log.LowestSeq()
+	require.ErrorIs(t, err, ErrLogClosed)
+	_, err = log.HighestSeq()
+	require.ErrorIs(t, err, ErrLogClosed)
+	_, err = log.ReaderFrom(0)
+	require.ErrorIs(t, err, ErrLogClosed)
+	_, err = log.WriteTo(io.Discard)
+	require.ErrorIs(t, err, ErrLogClosed)
+	_, err = log.ReadReverse(0)
+	require.ErrorIs(t, err, ErrLogClosed)
+
+	// Closing again is harmless
+	require.NoError(t, log.Close())
+
+	// Reset reopens the log
+	require.NoError(t, log.Reset())
+	_, err = log.Append(&api.Record{Value: []byte("hello world")})
+	require.NoError(t, err)
+}
+
 func TestLogDelete(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "log_test_dir")
@@ -156,18 +556,11 @@ func TestLogDelete(t *testing.T) {
 }
 
 func TestLogReset(t *testing.T) {
-	// Create a temporary directory for testing
-	tempDir, err := os.MkdirTemp("", "log_test_dir")
-	require.NoError(t, err)
-	defer os.RemoveAll(tempDir)
-
-	// Create a new log instance with the temporary directory
-	log, err := NewLog(tempDir)
-	require.NoError(t, err)
+	log := NewTestLog(t)
 
 	// Simulate adding data to the log
 	dummyRecord := &api.Record{Value: []byte("test")}
-	_, err = log.Append(dummyRecord)
+	_, err := log.Append(dummyRecord)
 	require.NoError(t, err)
 
 	// Reset the logger
@@ -178,14 +571,7 @@ func TestLogReset(t *testing.T) {
 }
 
 func TestLogTruncate(t *testing.T) {
-	// Create a temporary directory for testing
-	tempDir, err := os.MkdirTemp("", "log_truncate_test")
-	require.NoError(t, err)
-	defer os.RemoveAll(tempDir)
-
-	// Initialize the log with the temporary directory
-	log, err := NewLog(tempDir)
-	require.NoError(t, err)
+	log := NewTestLog(t)
 
 	// Append some records to generate segments
 	for i := 0; i < 5; i++ {
@@ -194,7 +580,7 @@ func TestLogTruncate(t *testing.T) {
 	}
 
 	// Simulate truncating the log to remove early segments
-	err = log.Truncate(2)
+	err := log.Truncate(2)
 	require.NoError(t, err)
 
 	// Verify that segments with nextOffset <= 3 are removed
@@ -204,15 +590,31 @@ func TestLogTruncate(t *testing.T) {
 
 }
 
-func TestLogReader(t *testing.T) {
-	// Create a temporary directory for the log
-	tempDir, err := os.MkdirTemp("", "log_test_reader")
+func TestLogTruncatePastActiveSegment(t *testing.T) {
+	log := NewTestLog(t)
+
+	for i := 0; i < 5; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+
+	// Every record goes, the active segment included
+	require.NoError(t, log.Truncate(9))
+	require.Len(t, log.segmentList, 1)
+	require.Equal(t, uint64(10), log.activeSegment.BaseOffset())
+
+	// Appends carry on in a fresh segment after the truncated records
+	off, err := log.Append(&api.Record{Value: []byte("after truncate")})
 	require.NoError(t, err)
-	defer os.RemoveAll(tempDir)
+	require.Equal(t, uint64(10), off)
 
-	// Create a new log instance.
-	log, err := NewLog(tempDir)
+	record, err := log.Read(off)
 	require.NoError(t, err)
+	require.Equal(t, []byte("after truncate"), record.Value)
+}
+
+func TestLogReader(t *testing.T) {
+	log := NewTestLog(t)
 
 	// Create a record to append to the log
 	append := &api.Record{
@@ -241,3 +643,2249 @@ func TestLogReader(t *testing.T) {
 	// Verify the original and read records are equal
 	require.Equal(t, append.Value, read.Value, "Read value should match the original appended value.")
 }
+
+func TestLogReaderFrom(t *testing.T) {
+	// Segments of three records each
+	log := NewTestLog(t, WithMaxIndexBytes(60))
+
+	for i := 0; i < 5; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+
+	// Start part way into the first segment and run on into the next
+	reader, err := log.ReaderFrom(1)
+	require.NoError(t, err)
+	b, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	// Walk the length prefixed entries
+	for i := 1; i < 5; i++ {
+		require.GreaterOrEqual(t, len(b), 8)
+		n := binary.BigEndian.Uint64(b[:8])
+		read := &api.Record{}
+		require.NoError(t, proto.Unmarshal(b[8:8+n], read))
+		require.Equal(t, []byte(fmt.Sprintf("record %d", i)), read.Value)
+		b = b[8+n:]
+	}
+	require.Empty(t, b)
+
+	// Offsets the log does not hold are rejected
+	_, err = log.ReaderFrom(5)
+	require.Error(t, err)
+}
+
+func TestRateLimitedReader(t *testing.T) {
+	log := NewTestLog(t)
+
+	// About 64KB of records
+	for i := 0; i < 64; i++ {
+		_, err := log.Append(&api.Record{Value: bytes.Repeat([]byte{'x'}, 1024)})
+		require.NoError(t, err)
+	}
+	all, err := log.ReaderFrom(0)
+	require.NoError(t, err)
+	want, err := io.ReadAll(all)
+	require.NoError(t, err)
+
+	_, err = NewRateLimitedReader(log, 0, 0)
+	require.Error(t, err)
+
+	// At 32KB/s the first second's worth is free, the rest takes just over a second
+	const rateBytes = 32 * 1024
+	reader, err := NewRateLimitedReader(log, 0, rateBytes)
+	require.NoError(t, err)
+
+	start := time.Now()
+	got, err := io.ReadAll(reader)
+	elapsed := time.Since(start)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+	require.Equal(t, int64(len(want)), reader.BytesRead())
+
+	expected := time.Duration(float64(len(want)-rateBytes) / rateBytes * float64(time.Second))
+	require.GreaterOrEqual(t, elapsed, expected*9/10)
+	require.Less(t, elapsed, expected*3/2)
+}
+
+// burstShrinkingReader lowers its limiter's burst while reading, so waiting for what it read fails
+type burstShrinkingReader struct {
+	io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *burstShrinkingReader) Read(p []byte) (int, error) {
+	r.limiter.SetBurst(1)
+	return r.Reader.Read(p)
+}
+
+func TestRateLimitedReaderWaitFailure(t *testing.T) {
+	limiter := rate.NewLimiter(rate.Limit(1024), 1024)
+	reader := &RateLimitedReader{
+		reader:  &burstShrinkingReader{Reader: bytes.NewReader([]byte("read before waiting")), limiter: limiter},
+		limiter: limiter,
+	}
+
+	// The bytes read are handed out along with the error of the wait
+	buf := make([]byte, 64)
+	n, err := reader.Read(buf)
+	require.Error(t, err)
+	require.Equal(t, "read before waiting", string(buf[:n]))
+	require.Equal(t, int64(n), reader.BytesRead())
+}
+
+func TestLogWriteTo(t *testing.T) {
+	// Use small segments so the copy spans several of them
+	log := NewTestLog(t, WithMaxStoreBytes(64))
+
+	for i := 0; i < 10; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+	require.Greater(t, len(log.segmentList), 1)
+
+	// WriteTo produces the same bytes as the Reader
+	var buf bytes.Buffer
+	n, err := log.WriteTo(&buf)
+	require.NoError(t, err)
+
+	expected, err := io.ReadAll(log.Reader())
+	require.NoError(t, err)
+	require.Equal(t, int64(len(expected)), n)
+	require.Equal(t, expected, buf.Bytes())
+
+	// Log satisfies io.WriterTo, so io.Copy picks it up
+	var _ io.WriterTo = log
+}
+
+func TestLogReadFrom(t *testing.T) {
+	// Create temporary directories for both logs
+	srcDir, err := os.MkdirTemp("", "log_test_read_from_src")
+	require.NoError(t, err)
+	defer os.RemoveAll(srcDir)
+	dstDir, err := os.MkdirTemp("", "log_test_read_from_dst")
+	require.NoError(t, err)
+	defer os.RemoveAll(dstDir)
+
+	src, err := NewLog(srcDir)
+	require.NoError(t, err)
+	defer src.Close()
+
+	for i := 0; i < 1000; i++ {
+		_, err := src.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+
+	// Export the source log and import it into an empty one
+	var buf bytes.Buffer
+	written, err := src.WriteTo(&buf)
+	require.NoError(t, err)
+
+	dst, err := NewLog(dstDir)
+	require.NoError(t, err)
+	defer dst.Close()
+
+	read, err := dst.ReadFrom(&buf)
+	require.NoError(t, err)
+	require.Equal(t, written, read)
+
+	// Every record comes across unchanged
+	for i := uint64(0); i < 1000; i++ {
+		want, err := src.Read(i)
+		require.NoError(t, err)
+		got, err := dst.Read(i)
+		require.NoError(t, err)
+		require.True(t, proto.Equal(want, got), "record %d differs", i)
+	}
+
+	// A truncated stream keeps the records before the break and reports it
+	truncated, err := NewLog(t.TempDir())
+	require.NoError(t, err)
+	defer truncated.Close()
+	_, err = truncated.ReadFrom(bytes.NewReader([]byte{0, 0, 0, 0, 0, 0, 0, 9, 1}))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// Lengths no segment could hold are rejected without reading on, as is padding
+	for _, prefix := range [][]byte{
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		{0, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		rejected, err := NewLog(t.TempDir())
+		require.NoError(t, err)
+		n, err := rejected.ReadFrom(bytes.NewReader(append(prefix, 1, 2, 3)))
+		require.ErrorIs(t, err, store.ErrCorruptEntry)
+		require.Equal(t, int64(8), n)
+		require.NoError(t, rejected.Close())
+	}
+
+	// A compressed segment cannot be written in a form ReadFrom understands
+	compressed, err := src.openSegment(1000, seg.WithSegmentCompression(store.GzipCompression))
+	require.NoError(t, err)
+	_, err = compressed.Append(&api.Record{Value: []byte("compressed")})
+	require.NoError(t, err)
+	src.segmentList = append(src.segmentList, compressed)
+	src.activeSegment = compressed
+	_, err = src.WriteTo(io.Discard)
+	require.ErrorIs(t, err, ErrExportUnsupported)
+}
+
+func TestLogLastProducedAt(t *testing.T) {
+	clock := testutil.NewMockClock(time.Now())
+	log := NewTestLog(t, WithClock(clock))
+
+	// Nothing produced yet
+	require.True(t, log.LastProducedAt().IsZero())
+
+	_, err := log.Append(&api.Record{Value: []byte("hello")})
+	require.NoError(t, err)
+	require.Equal(t, clock.Now(), log.LastProducedAt())
+
+	// The idle time keeps growing until the next append
+	clock.Advance(10 * time.Millisecond)
+	require.Equal(t, 10*time.Millisecond, log.IdleDuration())
+	_, err = log.Append(&api.Record{Value: []byte("hello")})
+	require.NoError(t, err)
+	require.Zero(t, log.IdleDuration())
+}
+
+func TestLogCloseStopsBackgroundWork(t *testing.T) {
+	log := NewTestLog(t)
+
+	// Start a few goroutines that only stop once the log shuts them down
+	var running int32
+	for i := 0; i < 3; i++ {
+		atomic.AddInt32(&running, 1)
+		log.goBackground(func(ctx context.Context) {
+			defer atomic.AddInt32(&running, -1)
+			<-ctx.Done()
+		})
+	}
+
+	require.NoError(t, log.Close())
+
+	// Close already waited, so the WaitGroup unblocks right away
+	done := make(chan struct{})
+	go func() {
+		log.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("background goroutines still running after Close")
+	}
+	require.Equal(t, int32(0), atomic.LoadInt32(&running))
+}
+
+func TestLogAppendAndReadHooks(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_hooks")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// Count every invocation of the hooks
+	var appends, reads atomic.Int64
+	log, err := NewLog(tempDir,
+		WithAppendHook(func(offset uint64, record *api.Record) { appends.Add(1) }),
+		WithReadHook(func(offset uint64) { reads.Add(1) }),
+	)
+	require.NoError(t, err)
+	defer log.Close()
+
+	// Append and read back a handful of records
+	total := 5
+	for i := 0; i < total; i++ {
+		off, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+
+		_, err = log.Read(off)
+		require.NoError(t, err)
+	}
+
+	// Hooks run asynchronously, so wait for them to catch up
+	require.Eventually(t, func() bool {
+		return appends.Load() == int64(total) && reads.Load() == int64(total)
+	}, time.Second, 10*time.Millisecond)
+
+	// Give any stray invocations a chance to show up and make sure there are none
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int64(total), appends.Load(), "append hook should fire exactly once per append")
+	require.Equal(t, int64(total), reads.Load(), "read hook should fire exactly once per read")
+}
+
+func TestLogSegmentRotationHook(t *testing.T) {
+	type rotation struct{ base, next uint64 }
+	rotations := make(chan rotation, 10)
+	log := NewTestLog(t, WithMaxStoreBytes(64), WithSegmentRotationHook(func(base, next uint64) {
+		rotations <- rotation{base: base, next: next}
+	}))
+
+	// Nothing fires until the active segment fills up, opening the log included
+	var last uint64
+	for log.activeSegment.BaseOffset() == 0 {
+		require.Empty(t, rotations)
+		off, err := log.Append(&api.Record{Value: []byte("hello world")})
+		require.NoError(t, err)
+		last = off
+	}
+	select {
+	case r := <-rotations:
+		require.Equal(t, rotation{base: 0, next: last + 1}, r)
+	case <-time.After(time.Second):
+		t.Fatal("rotation hook was not called when the segment filled up")
+	}
+
+	// Starting over after a truncation is not a rotation
+	require.NoError(t, log.Truncate(last+1))
+	time.Sleep(50 * time.Millisecond)
+	require.Empty(t, rotations)
+}
+
+func TestLogHookPanicIsRecovered(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_hook_panic")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// Register a hook that always panics
+	done := make(chan struct{})
+	log, err := NewLog(tempDir, WithAppendHook(func(offset uint64, record *api.Record) {
+		defer close(done)
+		panic("boom")
+	}))
+	require.NoError(t, err)
+	defer log.Close()
+
+	// The append itself should still succeed
+	_, err = log.Append(&api.Record{Value: []byte("panic please")})
+	require.NoError(t, err)
+
+	// Wait for the hook to run; reaching this point means the panic was recovered
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("append hook was never called")
+	}
+}
+
+func TestLogConfigPersistence(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_config")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// Create a log with a non-default store size
+	maxStoreBytes := uint64(10 * 1024 * 1024)
+	log, err := NewLog(tempDir, WithMaxStoreBytes(maxStoreBytes))
+	require.NoError(t, err)
+	_, err = log.Append(&api.Record{Value: []byte("configured")})
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+
+	// The effective config should have been written next to the segments
+	_, err = os.Stat(filepath.Join(tempDir, configFileName))
+	require.NoError(t, err, "config file should exist after creating a log")
+
+	// Reopening without options should pick up the original parameters
+	reopened, err := NewLog(tempDir)
+	require.NoError(t, err)
+	require.Equal(t, maxStoreBytes, reopened.Config().MaxStoreBytes)
+	require.Len(t, reopened.segmentList, 1, "config file should not be mistaken for a segment")
+	require.NoError(t, reopened.Close())
+
+	// Options that agree with the stored config are accepted
+	matching, err := NewLog(tempDir, WithMaxStoreBytes(maxStoreBytes))
+	require.NoError(t, err)
+	require.NoError(t, matching.Close())
+
+	// Options that disagree with the stored config are rejected
+	_, err = NewLog(tempDir, WithMaxStoreBytes(1024))
+	require.ErrorIs(t, err, ErrConfigMismatch)
+}
+
+func TestLogFileExtensions(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_extensions")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// A file with the default extension is not part of a log using custom ones
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, fmt.Sprintf("%020d.store", 5)), nil, 0644))
+
+	log, err := NewLog(tempDir, WithStoreExtension(".cstore"), WithIndexExtension(".cindex"))
+	require.NoError(t, err)
+	require.Len(t, log.segmentList, 1)
+	require.Equal(t, uint64(0), log.activeSegment.BaseOffset())
+
+	_, err = log.Append(&api.Record{Value: []byte("custom")})
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+
+	// Segment files are named with the configured extensions
+	for _, ext := range []string{".cstore", ".cindex"} {
+		_, err := os.Stat(filepath.Join(tempDir, fmt.Sprintf("%020d%s", 0, ext)))
+		require.NoError(t, err)
+	}
+
+	// The extensions are persisted, so reopening without the options finds the segment again
+	reopened, err := NewLog(tempDir)
+	require.NoError(t, err)
+	record, err := reopened.Read(0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("custom"), record.Value)
+	require.NoError(t, reopened.Close())
+
+	// Reopening with different extensions is rejected
+	_, err = NewLog(tempDir, WithStoreExtension(".store"))
+	require.ErrorIs(t, err, ErrConfigMismatch)
+}
+
+func TestLogConsumerGroup(t *testing.T) {
+	log := NewTestLog(t)
+
+	group, err := log.NewConsumerGroup("billing")
+	require.NoError(t, err)
+
+	// Members without a commit have no offset
+	_, err = group.GetOffset("worker-1")
+	require.ErrorIs(t, err, ErrMemberNotFound)
+
+	// Each member tracks its own position
+	require.NoError(t, group.CommitOffset("worker-1", 5))
+	require.NoError(t, group.CommitOffset("worker-2", 9))
+
+	off, err := group.GetOffset("worker-1")
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), off)
+
+	// Loading the group again should restore the committed offsets from disk
+	reloaded, err := log.NewConsumerGroup("billing")
+	require.NoError(t, err)
+
+	off, err = reloaded.GetOffset("worker-2")
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), off)
+
+	// Other groups are independent
+	other, err := log.NewConsumerGroup("audit")
+	require.NoError(t, err)
+	_, err = other.GetOffset("worker-1")
+	require.ErrorIs(t, err, ErrMemberNotFound)
+
+	// Members are checkpointed consumers
+	off, err = log.RestoreFromCheckpoint("worker-1@billing")
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), off)
+
+	// IDs that would leave the log directory or be mistaken for another group are refused
+	for _, id := range []string{"../escape", `..\escape`, "..", "a@b"} {
+		_, err := log.NewConsumerGroup(id)
+		require.Error(t, err, id)
+	}
+	require.Error(t, group.CommitOffset("../escape", 1))
+	require.NoFileExists(t, filepath.Join(filepath.Dir(log.Dir()), "escape.json"))
+}
+
+func TestLogConsumerCheckpoints(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_consumer_checkpoints")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	log, err := NewLog(tempDir)
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+
+	// Nothing has been checkpointed yet
+	_, err = log.RestoreFromCheckpoint("consumer-0")
+	require.ErrorIs(t, err, ErrCheckpointNotFound)
+	require.Error(t, log.Checkpoint("../escape", 0))
+
+	// Three consumers stop at different points, checkpointing every 100 records
+	stops := map[string]uint64{"consumer-0": 300, "consumer-1": 650, "consumer-2": 1000}
+	var wg sync.WaitGroup
+	for id, stop := range stops {
+		wg.Add(1)
+		go func(id string, stop uint64) {
+			defer wg.Done()
+			for offset := uint64(0); offset < stop; offset++ {
+				if _, err := log.Read(offset); err != nil {
+					t.Error(err)
+					return
+				}
+				if (offset+1)%100 == 0 {
+					if err := log.Checkpoint(id, offset+1); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(id, stop)
+	}
+	wg.Wait()
+	require.NoError(t, log.Close())
+
+	// After a restart each consumer resumes from its last full hundred
+	reopened, err := NewLog(tempDir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	for id, stop := range stops {
+		offset, err := reopened.RestoreFromCheckpoint(id)
+		require.NoError(t, err)
+		require.Equal(t, stop/100*100, offset, id)
+	}
+
+	// Deleted checkpoints are gone, deleting twice is fine
+	require.NoError(t, reopened.DeleteCheckpoint("consumer-0"))
+	require.NoError(t, reopened.DeleteCheckpoint("consumer-0"))
+	_, err = reopened.RestoreFromCheckpoint("consumer-0")
+	require.ErrorIs(t, err, ErrCheckpointNotFound)
+}
+
+func TestLogMaxConsumerLag(t *testing.T) {
+	log := NewTestLog(t, WithMaxConsumerLag(2))
+
+	// Nothing holds producers back before any consumer has checkpointed
+	for i := 0; i < 4; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+	require.NoError(t, log.Checkpoint("slow", 0))
+	require.NoError(t, log.Checkpoint("fast", 3))
+
+	// The slow consumer is 3 offsets behind, so the next append waits for it
+	appended := make(chan error, 1)
+	go func() {
+		_, err := log.Append(&api.Record{Value: []byte("waiting")})
+		appended <- err
+	}()
+	select {
+	case <-appended:
+		t.Fatal("append should block while a consumer lags too far behind")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Catching up lets it through
+	require.NoError(t, log.Checkpoint("slow", 1))
+	select {
+	case err := <-appended:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("append should finish once the consumer catches up")
+	}
+}
+
+func TestLogBackpressureTimeout(t *testing.T) {
+	log := NewTestLog(t, WithMaxConsumerLag(1), WithBackpressureTimeout(50*time.Millisecond))
+	for i := 0; i < 3; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+	require.NoError(t, log.Checkpoint("slow", 0))
+
+	_, err := log.Append(&api.Record{Value: []byte("too far ahead")})
+	require.ErrorIs(t, err, ErrBackpressureTimeout)
+
+	// A consumer that goes away no longer holds anyone back
+	require.NoError(t, log.DeleteCheckpoint("slow"))
+	_, err = log.Append(&api.Record{Value: []byte("no consumers")})
+	require.NoError(t, err)
+}
+
+func TestLogBackpressureEveryAppend(t *testing.T) {
+	dir := t.TempDir()
+	opts := []LogOption{WithMaxConsumerLag(1), WithBackpressureTimeout(20 * time.Millisecond)}
+	log, err := NewLog(dir, opts...)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+	require.NoError(t, log.Checkpoint("slow", 0))
+
+	// The checkpoint is loaded again when the log is reopened
+	require.NoError(t, log.Close())
+	log, err = NewLog(dir, opts...)
+	require.NoError(t, err)
+	defer log.Close()
+
+	other := NewTestLog(t)
+	_, err = other.Append(&api.Record{Value: []byte("merged")})
+	require.NoError(t, err)
+
+	appends := map[string]func() error{
+		"AppendBatch": func() error {
+			_, err := log.AppendBatch([]*api.Record{{Value: []byte("batch")}})
+			return err
+		},
+		"AppendAt": func() error {
+			_, err := log.AppendAt(&api.Record{Value: []byte("at")}, 3)
+			return err
+		},
+		"AppendIfAbsent": func() error {
+			_, _, err := log.AppendIfAbsent(&api.Record{Value: []byte("absent")}, 10)
+			return err
+		},
+		"AppendOrReplace": func() error {
+			_, err := log.AppendOrReplace(&api.Record{Key: []byte("key"), Value: []byte("replace")})
+			return err
+		},
+		"Merge": func() error {
+			_, err := log.Merge(other)
+			return err
+		},
+		"Producer": func() error {
+			return log.NewProducer(WithBatch(1)).Send(&api.Record{Value: []byte("produced")})
+		},
+	}
+	for name, appendFn := range appends {
+		require.ErrorIs(t, appendFn(), ErrBackpressureTimeout, name)
+	}
+
+	// Once the consumer catches up they all go through
+	require.NoError(t, log.Checkpoint("slow", 2))
+	require.NoError(t, appends["AppendAt"]())
+}
+
+func TestLogSubscribeReadError(t *testing.T) {
+	log := NewTestLog(t)
+	for i := 0; i < 3; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+	corruptRecord(t, log, 1)
+
+	// A record that cannot be read is not skipped like a removed one, it ends the subscription
+	sub := log.Subscribe(0, 0)
+	record := <-sub.C
+	require.Equal(t, []byte("0"), record.Value)
+	select {
+	case record, ok := <-sub.C:
+		require.False(t, ok, "expected the subscription to end, got offset %d", record.GetOffset())
+	case <-time.After(time.Second):
+		t.Fatal("subscription should end at the unreadable record")
+	}
+	require.Error(t, sub.Err())
+
+	// Stopping it any other way is not an error
+	other := log.Subscribe(3, 0)
+	other.Unsubscribe()
+	require.NoError(t, other.Err())
+}
+
+func TestLogSubscribe(t *testing.T) {
+	log := NewTestLog(t)
+
+	// Subscriptions open before anything is appended, each with a small buffer so they keep waiting on appends
+	const subscribers, records = 5, 1000
+	subs := make([]*Subscription, subscribers)
+	for i := range subs {
+		subs[i] = log.Subscribe(0, 8)
+	}
+
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub *Subscription) {
+			defer wg.Done()
+			for i := 0; i < records; i++ {
+				select {
+				case record := <-sub.C:
+					if record.Offset != uint64(i) || string(record.Value) != strconv.Itoa(i) {
+						t.Errorf("expected record %d, got offset %d with %q", i, record.Offset, record.Value)
+						return
+					}
+				case <-time.After(5 * time.Second):
+					t.Errorf("subscription stopped after %d records", i)
+					return
+				}
+			}
+		}(sub)
+	}
+
+	for i := 0; i < records; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+	wg.Wait()
+
+	// Unsubscribing closes the channel and leaves the other subscriptions alone
+	subs[0].Unsubscribe()
+	_, ok := <-subs[0].C
+	require.False(t, ok)
+	subs[0].Unsubscribe()
+
+	_, err := log.Append(&api.Record{Value: []byte("late")})
+	require.NoError(t, err)
+	for _, sub := range subs[1:] {
+		record := <-sub.C
+		require.Equal(t, []byte("late"), record.Value)
+	}
+
+	// A subscription can start anywhere in the log, and is closed along with the log
+	sub := log.Subscribe(records, 1)
+	record := <-sub.C
+	require.Equal(t, []byte("late"), record.Value)
+	require.NoError(t, log.Close())
+	for _, sub := range append(subs[1:], sub) {
+		_, ok := <-sub.C
+		require.False(t, ok)
+	}
+}
+
+func TestLogFilePerm(t *testing.T) {
+	log := NewTestLog(t, WithFilePerm(0640))
+
+	_, err := log.Append(&api.Record{Value: []byte("private")})
+	require.NoError(t, err)
+	require.NoError(t, log.Checkpoint("consumer", 0))
+
+	// Segment files, their metadata, the config and checkpoints are all created with the configured mode
+	files, err := filepath.Glob(filepath.Join(log.Dir(), "*"))
+	require.NoError(t, err)
+	files = append(files, filepath.Join(log.Dir(), consumerCheckpointDir, "consumer.json"))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		if info.IsDir() {
+			require.Equal(t, os.FileMode(0750), info.Mode().Perm(), file)
+		} else {
+			require.Equal(t, os.FileMode(0640), info.Mode().Perm(), file)
+		}
+	}
+
+	// Reset recreates the directory with the matching directory mode
+	require.NoError(t, log.Reset())
+	info, err := os.Stat(log.Dir())
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0750), info.Mode().Perm())
+}
+
+func TestLogAutoCreateDir(t *testing.T) {
+	base, err := os.MkdirTemp("", "log_test_auto_create")
+	require.NoError(t, err)
+	defer os.RemoveAll(base)
+
+	// A missing directory is created, parents and all, with the configured mode
+	dir := filepath.Join(base, "nested", "log")
+	log, err := NewLog(dir, WithDirPerm(0700))
+	require.NoError(t, err)
+	defer log.Close()
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+	require.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	_, err = log.Append(&api.Record{Value: []byte("hello")})
+	require.NoError(t, err)
+
+	// Without it the directory has to exist already
+	_, err = NewLog(filepath.Join(base, "missing"), WithAutoCreateDir(false))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLogWatchWatermark(t *testing.T) {
+	// Use small segments so Truncate can drop whole ones
+	log := NewTestLog(t, WithMaxStoreBytes(64))
+	require.Equal(t, Watermark{}, log.CurrentWatermark())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watermarks := log.WatchWatermark(ctx)
+
+	receive := func() Watermark {
+		t.Helper()
+		select {
+		case w := <-watermarks:
+			return w
+		case <-time.After(time.Second):
+			t.Fatal("expected a watermark")
+			return Watermark{}
+		}
+	}
+
+	_, err := log.Append(&api.Record{Value: []byte("first")})
+	require.NoError(t, err)
+	require.Equal(t, Watermark{Low: 0, High: 1}, receive())
+
+	// A receiver that lags behind only sees the latest watermark
+	for i := 0; i < 9; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+	require.Eventually(t, func() bool {
+		return len(watermarks) == 1 && log.CurrentWatermark().High == 10
+	}, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, Watermark{Low: 0, High: 10}, receive())
+
+	// Truncating moves the low watermark
+	require.NoError(t, log.Truncate(4))
+	w := receive()
+	require.Equal(t, log.CurrentWatermark(), w)
+	require.Greater(t, w.Low, uint64(0))
+
+	// Deleting the oldest record moves it as well, even though its segment keeps its base offset
+	require.Less(t, w.Low+1, log.activeSegment.BaseOffset())
+	require.NoError(t, log.DeleteRange(w.Low, w.Low))
+	require.Equal(t, Watermark{Low: w.Low + 1, High: 10}, receive())
+	lowest, err := log.LowestOffset()
+	require.NoError(t, err)
+	require.Equal(t, w.Low+1, lowest)
+
+	// Cancelling the context closes the channel
+	cancel()
+	for range watermarks {
+	}
+}
+
+func TestLogSegmentCountWarning(t *testing.T) {
+	var out bytes.Buffer
+	stdlog.SetOutput(&out)
+	defer stdlog.SetOutput(os.Stderr)
+
+	// The hook sees a nil record for every warning
+	var warnings atomic.Int64
+	log := NewTestLog(t, WithMaxStoreBytes(64), WithSegmentCountWarningThreshold(3),
+		WithAppendHook(func(offset uint64, record *api.Record) {
+			if record == nil {
+				warnings.Add(1)
+			}
+		}))
+
+	// Rotating up to the threshold stays quiet
+	for len(log.segmentList) < 3 {
+		_, err := log.Append(&api.Record{Value: []byte("fill")})
+		require.NoError(t, err)
+	}
+	require.NotContains(t, out.String(), ErrTooManySegments.Error())
+
+	// The append creating the fourth segment warns, with the count
+	for len(log.segmentList) < 4 {
+		_, err := log.Append(&api.Record{Value: []byte("fill")})
+		require.NoError(t, err)
+	}
+	require.Contains(t, out.String(), ErrTooManySegments.Error())
+	require.Contains(t, out.String(), "4 segments")
+	require.Eventually(t, func() bool { return warnings.Load() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestLogResetToOffset(t *testing.T) {
+	// Use small segments so the records span several of them
+	log := NewTestLog(t, WithMaxStoreBytes(64))
+
+	for i := 0; i < 10; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+	require.True(t, len(log.segmentList) > 2, "records should span several segments")
+
+	// Roll back to the middle of the log
+	require.NoError(t, log.ResetToOffset(4))
+
+	// Everything up to the checkpoint is preserved
+	for i := uint64(0); i <= 4; i++ {
+		record, err := log.Read(i)
+		require.NoError(t, err)
+		require.Equal(t, []byte(fmt.Sprintf("record %d", i)), record.Value)
+	}
+
+	// Everything after it is gone
+	_, err := log.Read(5)
+	require.Error(t, err)
+
+	// The log keeps accepting records right after the checkpoint
+	off, err := log.Append(&api.Record{Value: []byte("replacement")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), off)
+
+	record, err := log.Read(5)
+	require.NoError(t, err)
+	require.Equal(t, []byte("replacement"), record.Value)
+}
+
+func TestLogSetSegmentMaxBytes(t *testing.T) {
+	log := NewTestLog(t)
+
+	// Zero is not a valid threshold
+	require.Error(t, log.SetSegmentMaxBytes(0))
+
+	for i := 0; i < 3; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+	require.Len(t, log.segmentList, 1)
+
+	// Shrinking below the active segment's size rotates it immediately
+	require.NoError(t, log.SetSegmentMaxBytes(16))
+	require.Equal(t, uint64(16), log.GetSegmentMaxBytes())
+	require.Len(t, log.segmentList, 2, "active segment should rotate once it is over the new threshold")
+	require.Equal(t, uint64(3), log.activeSegment.BaseOffset())
+
+	// New segments use the new threshold as well
+	_, err := log.Append(&api.Record{Value: []byte("fills the new segment")})
+	require.NoError(t, err)
+	require.Len(t, log.segmentList, 3)
+
+	// The threshold is not persisted, so the log reopens with the one it was created with
+	dir := t.TempDir()
+	tuned, err := NewLog(dir, WithMaxStoreBytes(1024))
+	require.NoError(t, err)
+	require.NoError(t, tuned.SetSegmentMaxBytes(16))
+	require.NoError(t, tuned.Close())
+	reopened, err := NewLog(dir, WithMaxStoreBytes(1024))
+	require.NoError(t, err)
+	defer reopened.Close()
+	require.Equal(t, uint64(1024), reopened.GetSegmentMaxBytes())
+}
+
+func TestLogReadReverse(t *testing.T) {
+	// Small segments so the iterator has to cross segment boundaries
+	log := NewTestLog(t, WithMaxStoreBytes(256))
+
+	for i := 0; i < 100; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+	require.Greater(t, len(log.segmentList), 1)
+
+	highest, err := log.HighestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(99), highest)
+
+	it, err := log.ReadReverse(highest)
+	require.NoError(t, err)
+	defer it.Close()
+
+	// Records come back newest first, down to the lowest offset
+	want := 99
+	for record, ok := it.Next(); ok; record, ok = it.Next() {
+		require.Equal(t, []byte(fmt.Sprintf("record %d", want)), record.Value)
+		want--
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, -1, want)
+
+	// Starting past the end of the log is out of range
+	_, err = log.ReadReverse(100)
+	require.Error(t, err)
+
+	// Records DeleteRange removed are skipped, including the one the iterator starts at
+	require.NoError(t, log.DeleteRange(1, 1))
+	require.NoError(t, log.DeleteRange(5, 5))
+	it, err = log.ReadReverse(5)
+	require.NoError(t, err)
+	var offsets []uint64
+	for record, ok := it.Next(); ok; record, ok = it.Next() {
+		offsets = append(offsets, record.Offset)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []uint64{4, 3, 2, 0}, offsets)
+
+	// An iterator outliving the log stops with ErrLogClosed
+	it, err = log.ReadReverse(highest)
+	require.NoError(t, err)
+	_, ok := it.Next()
+	require.True(t, ok)
+	require.NoError(t, log.Close())
+	_, ok = it.Next()
+	require.False(t, ok)
+	require.ErrorIs(t, it.Err(), ErrLogClosed)
+}
+
+func TestLogDefragment(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_defragment")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// Tiny segments leave the log with a lot of them
+	log, err := NewLog(tempDir, WithMaxStoreBytes(64))
+	require.NoError(t, err)
+	for i := 0; i < 40; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+	require.Greater(t, len(log.segmentList), 5)
+	active := log.activeSegment
+
+	// Once segments may be larger, every sealed segment fits into one
+	require.NoError(t, log.SetSegmentMaxBytes(1024))
+	require.NoError(t, log.Defragment())
+	require.Len(t, log.segmentList, 2)
+	require.Equal(t, uint64(0), log.segmentList[0].BaseOffset())
+	require.Same(t, active, log.activeSegment)
+
+	for i := uint64(0); i < 40; i++ {
+		record, err := log.Read(i)
+		require.NoError(t, err)
+		require.Equal(t, []byte(fmt.Sprintf("record %d", i)), record.Value)
+	}
+
+	// Only the merged files are left behind, and the log reopens from them
+	require.NoError(t, log.Close())
+	reopened, err := NewLog(tempDir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	require.Len(t, reopened.segmentList, 2)
+
+	off, err := reopened.Append(&api.Record{Value: []byte("record 40")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(40), off)
+}
+
+func TestLogDefragmentFailedMerge(t *testing.T) {
+	log := NewTestLog(t, WithMaxStoreBytes(64))
+	for i := 0; i < 20; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+	require.Greater(t, len(log.segmentList), 3)
+	second := log.segmentList[1].BaseOffset()
+
+	// A directory in place of the first segment's index makes swapping the merged files in fail half way
+	indexPath := log.segmentList[0].IndexPath()
+	require.NoError(t, os.Remove(indexPath))
+	require.NoError(t, os.MkdirAll(filepath.Join(indexPath, "blocker"), 0755))
+
+	require.NoError(t, log.SetSegmentMaxBytes(1024))
+	require.Error(t, log.Defragment())
+
+	// The merged files that could not be swapped in are kept
+	_, err := os.Stat(filepath.Join(log.Dir(), ".merge-0"))
+	require.NoError(t, err)
+
+	// The other segments were not removed, and the log only holds segments it reopened from disk
+	require.Equal(t, second, log.segmentList[0].BaseOffset())
+	for i := second; i < 20; i++ {
+		record, err := log.Read(i)
+		require.NoError(t, err)
+		require.Equal(t, []byte(fmt.Sprintf("record %d", i)), record.Value)
+	}
+	off, err := log.Append(&api.Record{Value: []byte("record 20")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(20), off)
+}
+
+func TestLogShrinkToFit(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_shrink")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	log, err := NewLog(tempDir)
+	require.NoError(t, err)
+	defer log.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+
+	// Only the header and the three entries are left in the index file
+	require.NoError(t, log.ShrinkToFit())
+	fi, err := os.Stat(filepath.Join(tempDir, fmt.Sprintf("%020d.index", 0)))
+	require.NoError(t, err)
+	require.Equal(t, int64(index.HeaderLength+3*20), fi.Size())
+
+	// Appending after shrinking still works and everything reads back
+	off, err := log.Append(&api.Record{Value: []byte("record 3")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), off)
+	for i := uint64(0); i <= off; i++ {
+		record, err := log.Read(i)
+		require.NoError(t, err)
+		require.Equal(t, []byte(fmt.Sprintf("record %d", i)), record.Value)
+	}
+}
+
+func TestLogStats(t *testing.T) {
+	log := NewTestLog(t)
+
+	stats, err := log.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.Segments)
+	require.Zero(t, stats.Records)
+	require.Zero(t, stats.WriteAmplification())
+
+	value := make([]byte, 100)
+	for i := 0; i < 10; i++ {
+		_, err := log.Append(&api.Record{Value: value})
+		require.NoError(t, err)
+	}
+
+	stats, err = log.Stats()
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), stats.Records)
+	require.Equal(t, uint64(1000), stats.OriginalValueBytes)
+	require.Equal(t, len(log.segmentList), stats.Segments)
+
+	var storeBytes, indexBytes uint64
+	for _, s := range log.segmentList {
+		storeBytes += s.StoreSize()
+		indexBytes += s.IndexSize()
+	}
+	require.Equal(t, storeBytes, stats.StoreBytes)
+	require.Equal(t, indexBytes, stats.IndexBytes)
+
+	// Every entry pays for its length prefix and record framing on top of the value
+	require.Equal(t, float64(stats.StoreBytes)/1000, stats.WriteAmplification())
+	require.Greater(t, stats.WriteAmplification(), 1.0)
+}
+
+func TestLogForEach(t *testing.T) {
+	log := NewTestLog(t)
+
+	for i := 0; i < 3; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+	require.NoError(t, log.newSegment(3))
+	_, err := log.Append(&api.Record{Value: []byte("3")})
+	require.NoError(t, err)
+
+	var offsets []uint64
+	err = log.ForEach(func(offset uint64, record *api.Record) error {
+		require.Equal(t, []byte(strconv.FormatUint(offset, 10)), record.Value)
+		offsets = append(offsets, offset)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 1, 2, 3}, offsets)
+
+	// An error from fn stops the walk and is passed back
+	stop := fmt.Errorf("stop")
+	calls := 0
+	err = log.ForEach(func(uint64, *api.Record) error {
+		calls++
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, 1, calls)
+}
+
+func TestLogFilter(t *testing.T) {
+	// Use small segments so the records span several of them
+	log := NewTestLog(t, WithMaxStoreBytes(64))
+
+	values := []string{"match-a", "other", "match-bbbbbbbb", "other-cccccccc", "match-c", "tiny"}
+	for _, value := range values {
+		_, err := log.Append(&api.Record{Value: []byte(value)})
+		require.NoError(t, err)
+	}
+	require.True(t, len(log.segmentList) > 2, "records should span several segments")
+
+	// By value prefix
+	offsets, err := log.Filter(func(record *api.Record) bool {
+		return bytes.HasPrefix(record.Value, []byte("match-"))
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 2, 4}, offsets)
+
+	// By record size
+	large := func(record *api.Record) bool { return len(record.Value) > 10 }
+	offsets, err = log.Filter(large)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 3}, offsets)
+
+	// A range only sees the records inside it, bounds included
+	offsets, err = log.FilterRange(3, 5, large)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3}, offsets)
+	offsets, err = log.FilterRange(10, 20, large)
+	require.NoError(t, err)
+	require.Empty(t, offsets)
+}
+
+func TestLogReplay(t *testing.T) {
+	// Use small segments so the records span several of them
+	log := NewTestLog(t, WithMaxStoreBytes(64))
+	for i := 0; i < 10; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+
+	// Replay starts at the requested offset and runs to the newest record
+	var replayed []string
+	require.NoError(t, log.Replay(4, func(record *api.Record) error {
+		replayed = append(replayed, string(record.Value))
+		return nil
+	}))
+	require.Equal(t, []string{"4", "5", "6", "7", "8", "9"}, replayed)
+
+	// The handler's error stops it
+	stop := errors.New("stop")
+	replayed = nil
+	err := log.Replay(0, func(record *api.Record) error {
+		replayed = append(replayed, string(record.Value))
+		if record.Offset == 2 {
+			return stop
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, []string{"0", "1", "2"}, replayed)
+
+	// Starting past the end replays nothing
+	require.NoError(t, log.Replay(100, func(*api.Record) error {
+		t.Fatal("nothing should be replayed")
+		return nil
+	}))
+}
+
+func TestLogAppendProto(t *testing.T) {
+	log := NewTestLog(t)
+
+	// Any message works, here one of the API's own
+	want := &api.ProduceRequest{Record: &api.Record{Value: []byte("nested")}, Headers: map[string][]byte{"k": []byte("v")}}
+	offset, err := log.AppendProto(want)
+	require.NoError(t, err)
+
+	got := &api.ProduceRequest{}
+	require.NoError(t, log.ReadProto(offset, got))
+	require.True(t, proto.Equal(want, got))
+
+	// The value is the plain marshaled message
+	record, err := log.Read(offset)
+	require.NoError(t, err)
+	expected, err := proto.Marshal(want)
+	require.NoError(t, err)
+	require.Equal(t, expected, record.Value)
+
+	// Reading past the end fails like Read does
+	require.Error(t, log.ReadProto(offset+1, got))
+}
+
+func TestLogProducer(t *testing.T) {
+	log := NewTestLog(t)
+
+	// Records stay buffered until a full batch is sent
+	producer := log.NewProducer(WithBatch(3), WithFlushInterval(0))
+	records := make([]*api.Record, 5)
+	for i := range records {
+		records[i] = &api.Record{Value: []byte(fmt.Sprintf("record %d", i))}
+		require.NoError(t, producer.Send(records[i]))
+		if i == 1 {
+			_, err := log.Read(0)
+			require.Error(t, err)
+		}
+	}
+	require.Equal(t, uint64(3), producer.Appended())
+
+	// Flush writes the partial batch, and every record knows where it landed
+	require.NoError(t, producer.Flush())
+	require.Equal(t, uint64(5), producer.Appended())
+	require.Equal(t, records[4].Seq, producer.LastSeq())
+	for i, record := range records {
+		require.Equal(t, uint64(i), record.Offset)
+		got, err := log.Read(uint64(i))
+		require.NoError(t, err)
+		require.Equal(t, []byte(fmt.Sprintf("record %d", i)), got.Value)
+	}
+
+	// Close flushes what is left and nothing can be sent afterwards
+	require.NoError(t, producer.Send(&api.Record{Value: []byte("last")}))
+	require.NoError(t, producer.Close())
+	require.Equal(t, uint64(6), producer.Appended())
+	require.ErrorIs(t, producer.Send(&api.Record{Value: []byte("late")}), ErrProducerClosed)
+	require.NoError(t, producer.Close())
+
+	// A partial batch is written once the flush interval passes
+	timed := log.NewProducer(WithBatch(100), WithFlushInterval(10*time.Millisecond))
+	defer timed.Close()
+	require.NoError(t, timed.Send(&api.Record{Value: []byte("timed")}))
+	require.Eventually(t, func() bool { return timed.Appended() == 1 }, time.Second, 5*time.Millisecond)
+
+	// Compressed values are marked and restored by Decompress
+	compressing := log.NewProducer(WithBatch(1), WithCompression(store.GzipCompression))
+	defer compressing.Close()
+	value := bytes.Repeat([]byte("compressible "), 100)
+	require.NoError(t, compressing.Send(&api.Record{Value: append([]byte(nil), value...)}))
+	got, err := log.Read(7)
+	require.NoError(t, err)
+	require.Less(t, len(got.Value), len(value))
+	require.Equal(t, []byte(store.GzipCompression), got.Headers[CompressionHeader])
+	require.NoError(t, Decompress(got))
+	require.Equal(t, value, got.Value)
+	require.NotContains(t, got.Headers, CompressionHeader)
+
+	// Unknown compressions are refused before anything is buffered
+	require.Error(t, log.NewProducer(WithCompression("lz4")).Send(&api.Record{Value: []byte("x")}))
+}
+
+func TestLogProducerDropsFailedRecord(t *testing.T) {
+	log := NewTestLog(t)
+
+	// The second record cannot be encoded, since header names must be valid UTF-8
+	producer := log.NewProducer(WithBatch(3), WithFlushInterval(0))
+	bad := &api.Record{Value: []byte("bad"), Headers: map[string][]byte{"\xff": nil}}
+	require.NoError(t, producer.Send(&api.Record{Value: []byte("first")}))
+	require.NoError(t, producer.Send(bad))
+
+	// The failing record is handed back and dropped from the buffer
+	var recordErr *RecordError
+	err := producer.Send(&api.Record{Value: []byte("third")})
+	require.ErrorAs(t, err, &recordErr)
+	require.Same(t, bad, recordErr.Record)
+	require.Equal(t, uint64(1), producer.Appended())
+
+	// The records behind it are still written
+	require.NoError(t, producer.Flush())
+	require.Equal(t, uint64(2), producer.Appended())
+	got, err := log.Read(1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("third"), got.Value)
+}
+
+func TestLogProducerFlushesAfterFailure(t *testing.T) {
+	log := NewTestLog(t)
+	bad := func() *api.Record {
+		return &api.Record{Value: []byte("bad"), Headers: map[string][]byte{"\xff": nil}}
+	}
+
+	// The record left behind a failed full batch is written once the flush interval passes
+	producer := log.NewProducer(WithBatch(2), WithFlushInterval(10*time.Millisecond))
+	require.NoError(t, producer.Send(bad()))
+	require.Error(t, producer.Send(&api.Record{Value: []byte("left behind")}))
+	require.Eventually(t, func() bool {
+		return producer.Appended() == 1
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, producer.Close())
+
+	// Close reports the error of a timed flush and still writes what is buffered behind the failed record
+	producer = log.NewProducer(WithFlushInterval(time.Hour))
+	require.NoError(t, producer.Send(bad()))
+	require.NoError(t, producer.Send(&api.Record{Value: []byte("buffered")}))
+	producer.timedFlush()
+	var recordErr *RecordError
+	require.ErrorAs(t, producer.Close(), &recordErr)
+	require.Equal(t, []byte("bad"), recordErr.Record.Value)
+	require.Equal(t, uint64(1), producer.Appended())
+	got, err := log.Read(1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("buffered"), got.Value)
+}
+
+func TestLogReadMissingOffset(t *testing.T) {
+	log := NewTestLog(t)
+
+	for i := 0; i < 5; i++ {
+		record := &api.Record{Value: []byte(fmt.Sprintf("record %d", i))}
+		if i == 2 {
+			record = &api.Record{Tombstone: true}
+		}
+		_, err := log.Append(record)
+		require.NoError(t, err)
+	}
+
+	// Compacting drops the tombstone and leaves a hole in the middle of the segment
+	require.NoError(t, log.Compact())
+
+	// The hole and the end of the log both report the offset that was asked for
+	for _, off := range []uint64{2, 5} {
+		_, err := log.Read(off)
+		var outOfRange api.ErrOffsetOutOfRange
+		require.ErrorAs(t, err, &outOfRange)
+		require.Equal(t, off, outOfRange.Offset)
+		require.Equal(t, codes.OutOfRange, status.Code(err))
+	}
+
+	record, err := log.Read(3)
+	require.NoError(t, err)
+	require.Equal(t, []byte("record 3"), record.Value)
+}
+
+func TestLogExport(t *testing.T) {
+	log := NewTestLog(t)
+
+	for i := 0; i < 100; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, log.Export("ndjson", &buf))
+
+	// Every line decodes back into the record at its offset
+	dec := json.NewDecoder(&buf)
+	for i := 0; i < 100; i++ {
+		var line struct {
+			Offset uint64 `json:"offset"`
+			Value  []byte `json:"value"`
+		}
+		require.NoError(t, dec.Decode(&line))
+		require.Equal(t, uint64(i), line.Offset)
+		require.Equal(t, []byte(fmt.Sprintf("record %d", i)), line.Value)
+	}
+	require.False(t, dec.More())
+
+	buf.Reset()
+	require.NoError(t, log.Export("csv", &buf))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 101)
+	require.Equal(t, []string{"offset", "value_b64"}, rows[0])
+	require.Equal(t, []string{"5", base64.StdEncoding.EncodeToString([]byte("record 5"))}, rows[6])
+
+	require.EqualError(t, log.Export("xml", &buf), `unsupported format "xml"`)
+}
+
+func TestLogJSONEncoding(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_json")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	log, err := NewLog(tempDir, WithJSONEncoding())
+	require.NoError(t, err)
+
+	off, err := log.Append(&api.Record{Value: []byte("json record")})
+	require.NoError(t, err)
+
+	record, err := log.Read(off)
+	require.NoError(t, err)
+	require.Equal(t, []byte("json record"), record.Value)
+	require.NoError(t, log.Close())
+
+	// Reopening without options keeps the JSON encoding
+	reopened, err := NewLog(tempDir)
+	require.NoError(t, err)
+	require.Equal(t, seg.JSONEncoding, reopened.Config().RecordEncoding)
+	require.NoError(t, reopened.Close())
+}
+
+func TestRegistry(t *testing.T) {
+	// Create temporary directories for two logs
+	ordersDir, err := os.MkdirTemp("", "log_test_registry_orders")
+	require.NoError(t, err)
+	defer os.RemoveAll(ordersDir)
+
+	usersDir, err := os.MkdirTemp("", "log_test_registry_users")
+	require.NoError(t, err)
+	defer os.RemoveAll(usersDir)
+
+	registry := NewRegistry()
+
+	orders, err := registry.Open("orders", ordersDir)
+	require.NoError(t, err)
+	users, err := registry.Open("users", usersDir)
+	require.NoError(t, err)
+	require.NotSame(t, orders, users)
+
+	// Opening an already open log returns the cached instance
+	again, err := registry.Open("orders", ordersDir)
+	require.NoError(t, err)
+	require.Same(t, orders, again)
+
+	// Unnormalized paths to the same directory find it too
+	again, err = registry.Open("orders", ordersDir+"/../"+filepath.Base(ordersDir)+"/")
+	require.NoError(t, err)
+	require.Same(t, orders, again)
+	require.Equal(t, ordersDir, orders.Dir())
+
+	// The same name cannot point at a different directory
+	_, err = registry.Open("orders", usersDir)
+	require.Error(t, err)
+
+	got, err := registry.Get("users")
+	require.NoError(t, err)
+	require.Same(t, users, got)
+
+	// Closing removes the log from the registry
+	require.NoError(t, registry.Close("users"))
+	_, err = registry.Get("users")
+	require.ErrorIs(t, err, ErrLogNotFound)
+	require.ErrorIs(t, registry.Close("users"), ErrLogNotFound)
+
+	require.NoError(t, registry.CloseAll())
+	_, err = registry.Get("orders")
+	require.ErrorIs(t, err, ErrLogNotFound)
+}
+
+func TestLogSetupSegmentOrder(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_segment_order")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	log, err := NewLog(tempDir)
+	require.NoError(t, err)
+
+	// Offsets whose unpadded names would sort incorrectly
+	offsets := []uint64{9, 10, 100}
+	for _, offset := range offsets {
+		require.NoError(t, log.newSegment(offset))
+	}
+	require.NoError(t, log.Close())
+
+	// Segment files are zero-padded so the directory listing is already in order
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	var stores []string
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".store" {
+			stores = append(stores, entry.Name())
+		}
+	}
+	require.Equal(t, []string{
+		"00000000000000000000.store",
+		"00000000000000000009.store",
+		"00000000000000000010.store",
+		"00000000000000000100.store",
+	}, stores)
+
+	// Reopening restores the segments in offset order
+	reopened, err := NewLog(tempDir)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	var baseOffsets []uint64
+	for _, s := range reopened.segmentList {
+		baseOffsets = append(baseOffsets, s.BaseOffset())
+	}
+	require.Equal(t, []uint64{0, 9, 10, 100}, baseOffsets)
+	require.Equal(t, uint64(100), reopened.activeSegment.BaseOffset())
+}
+
+func TestLogSetupSkipsDuplicateOffsets(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_duplicate_offsets")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	log, err := NewLog(tempDir)
+	require.NoError(t, err)
+	require.NoError(t, log.newSegment(10))
+	require.NoError(t, log.Close())
+
+	// Leave a stray third file behind for offset 0
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "00.store"), nil, 0644))
+
+	reopened, err := NewLog(tempDir)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	// Still exactly one segment per offset
+	require.Len(t, reopened.segmentList, 2)
+	require.Equal(t, uint64(0), reopened.segmentList[0].BaseOffset())
+	require.Equal(t, uint64(10), reopened.segmentList[1].BaseOffset())
+}
+
+func TestLogSetupRequiresPairedFiles(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_paired_files")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	log, err := NewLog(tempDir)
+	require.NoError(t, err)
+	_, err = log.Append(&api.Record{Value: []byte("first")})
+	require.NoError(t, err)
+	require.NoError(t, log.newSegment(10))
+	_, err = log.Append(&api.Record{Value: []byte("second")})
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+
+	// Lose the index of the second segment and leave an index without a store behind
+	require.NoError(t, os.Remove(filepath.Join(tempDir, fmt.Sprintf("%020d%s", 10, log.config.IndexExtension))))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, fmt.Sprintf("%020d%s", 20, log.config.IndexExtension)), nil, 0644))
+
+	// Unpaired files are skipped
+	reopened, err := NewLog(tempDir)
+	require.NoError(t, err)
+	require.Len(t, reopened.segmentList, 1)
+	require.Equal(t, uint64(0), reopened.segmentList[0].BaseOffset())
+	require.NoError(t, reopened.Close())
+
+	// With recovery the missing index is rebuilt from the store
+	recovered, err := NewLog(tempDir, WithRecoverOnCorruption(true))
+	require.NoError(t, err)
+	defer recovered.Close()
+
+	require.Len(t, recovered.segmentList, 2)
+	require.Equal(t, uint64(10), recovered.segmentList[1].BaseOffset())
+	record, err := recovered.Read(10)
+	require.NoError(t, err)
+	require.Equal(t, []byte("second"), record.Value)
+}
+
+func TestLogBackfillIndex(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_backfill_index")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	log, err := NewLog(tempDir)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = log.Append(&api.Record{Value: []byte("hello world")})
+		require.NoError(t, err)
+	}
+	require.NoError(t, log.newSegment(3))
+	_, err = log.Append(&api.Record{Value: []byte("newest")})
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+
+	// Drop every entry from the first segment's index, keeping only its header
+	indexPath := filepath.Join(tempDir, fmt.Sprintf("%020d%s", 0, log.config.IndexExtension))
+	require.NoError(t, os.Truncate(indexPath, int64(index.HeaderLength)))
+
+	reopened, err := NewLog(tempDir)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	_, err = reopened.Read(1)
+	require.Error(t, err)
+
+	require.NoError(t, reopened.BackfillIndex(0))
+	for off := uint64(0); off < 3; off++ {
+		record, err := reopened.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, []byte("hello world"), record.Value)
+	}
+	record, err := reopened.Read(3)
+	require.NoError(t, err)
+	require.Equal(t, []byte("newest"), record.Value)
+
+	require.ErrorIs(t, reopened.BackfillIndex(42), ErrSegmentNotFound)
+}
+
+func TestLogSetupIgnoresNonSegmentFiles(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_non_segment_files")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	log, err := NewLog(tempDir)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+	require.NoError(t, log.Close())
+
+	// Files that only look like segment files sit next to the real ones
+	for _, name := range []string{"notanoffset.store", "0.store.bak", "-1.index", "5.meta.json", "notes.txt", "0.store.tmp"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, name), []byte("junk"), 0644))
+	}
+
+	log, err = NewLog(tempDir)
+	require.NoError(t, err)
+	defer log.Close()
+	require.Len(t, log.segmentList, 1)
+	for i := uint64(0); i < 3; i++ {
+		record, err := log.Read(i)
+		require.NoError(t, err)
+		require.Equal(t, []byte(fmt.Sprintf("record %d", i)), record.Value)
+	}
+}
+
+func TestLogSetupInvalidOffset(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_invalid_offset")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// A store file named by an offset that does not fit in a uint64
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "99999999999999999999999.store"), nil, 0644))
+
+	_, err = NewLog(tempDir)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `"99999999999999999999999"`)
+	require.ErrorIs(t, err, strconv.ErrRange)
+}
+
+func TestLogNewSegmentOverrides(t *testing.T) {
+	log := NewTestLog(t)
+
+	// Start a segment that only has room for two index entries
+	require.NoError(t, log.newSegment(1, seg.WithMaxIndexBytes(40)))
+
+	// The third record lands in a fresh segment with the log's own sizes
+	var offsets []uint64
+	for i := 0; i < 3; i++ {
+		off, err := log.Append(&api.Record{Value: []byte("boundary")})
+		require.NoError(t, err)
+		offsets = append(offsets, off)
+	}
+	require.Equal(t, []uint64{1, 2, 3}, offsets)
+	require.Len(t, log.segmentList, 3)
+	require.Equal(t, uint64(3), log.activeSegment.BaseOffset())
+	require.False(t, log.activeSegment.IsFull())
+}
+
+func TestLogDeleteRange(t *testing.T) {
+	log := NewTestLog(t)
+
+	// Segments holding 0-2, 3-5 and 6-8, followed by the active segment holding 9
+	for i := uint64(0); i < 10; i++ {
+		if i > 0 && i%3 == 0 {
+			require.NoError(t, log.newSegment(i))
+		}
+		_, err := log.Append(&api.Record{Value: []byte(strconv.FormatUint(i, 10))})
+		require.NoError(t, err)
+	}
+
+	// The active segment cannot be rewritten
+	require.ErrorIs(t, log.DeleteRange(8, 9), ErrRangeNotSupported)
+
+	require.NoError(t, log.DeleteRange(2, 6))
+	require.Len(t, log.segmentList, 3)
+
+	for i := uint64(0); i < 10; i++ {
+		record, err := log.Read(i)
+		if i >= 2 && i <= 6 {
+			require.Error(t, err, "offset %d should have been deleted", i)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, []byte(strconv.FormatUint(i, 10)), record.Value)
+	}
+
+	// New records carry on after the existing ones
+	off, err := log.Append(&api.Record{Value: []byte("10")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), off)
+}
+
+func TestLogDeleteRangeFailedRewrite(t *testing.T) {
+	log := NewTestLog(t)
+
+	// Segments holding 0-2, 3-5 and 6-8, followed by the active segment holding 9
+	for i := uint64(0); i < 10; i++ {
+		if i > 0 && i%3 == 0 {
+			require.NoError(t, log.newSegment(i))
+		}
+		_, err := log.Append(&api.Record{Value: []byte(strconv.FormatUint(i, 10))})
+		require.NoError(t, err)
+	}
+
+	// A file in place of the second segment's scratch directory makes rewriting it fail
+	require.NoError(t, os.WriteFile(filepath.Join(log.Dir(), ".compact-3"), nil, 0644))
+	require.Error(t, log.DeleteRange(2, 4))
+	require.Len(t, log.segmentList, 4)
+
+	// The first segment was rewritten before the failure, the others are all still open and readable
+	for i := uint64(0); i < 10; i++ {
+		record, err := log.Read(i)
+		if i == 2 {
+			require.Error(t, err, "offset %d should have been deleted", i)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, []byte(strconv.FormatUint(i, 10)), record.Value)
+	}
+}
+
+func TestLogCompact(t *testing.T) {
+	log := NewTestLog(t)
+
+	// Append a record followed by a tombstone
+	recordOff, err := log.Append(&api.Record{Value: []byte("keep me")})
+	require.NoError(t, err)
+	tombstoneOff, err := log.Append(&api.Record{Tombstone: true})
+	require.NoError(t, err)
+
+	// Tombstones cannot carry data
+	_, err = log.Append(&api.Record{Value: []byte("nope"), Tombstone: true})
+	require.Error(t, err)
+
+	require.NoError(t, log.Compact())
+
+	// The record survives with its original offset
+	record, err := log.Read(recordOff)
+	require.NoError(t, err)
+	require.Equal(t, []byte("keep me"), record.Value)
+
+	// The tombstone is gone
+	_, err = log.Read(tombstoneOff)
+	require.Error(t, err)
+
+	// Offsets keep increasing after compaction
+	off, err := log.Append(&api.Record{Value: []byte("after compaction")})
+	require.NoError(t, err)
+	require.Equal(t, tombstoneOff+1, off)
+
+	record, err = log.Read(off)
+	require.NoError(t, err)
+	require.Equal(t, []byte("after compaction"), record.Value)
+}
+
+func TestLogCompactTo(t *testing.T) {
+	src := NewTestLog(t, WithMaxStoreBytes(64))
+	for i := 0; i < 10; i++ {
+		_, err := src.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+
+	// Only the even records survive, renumbered from dst's first offset
+	dst := NewTestLog(t)
+	require.NoError(t, src.CompactTo(dst, func(record *api.Record) bool {
+		return record.Offset%2 == 0
+	}))
+	require.Equal(t, Watermark{Low: 0, High: 5}, dst.CurrentWatermark())
+	for i := uint64(0); i < 5; i++ {
+		record, err := dst.Read(i)
+		require.NoError(t, err)
+		require.Equal(t, []byte(strconv.Itoa(int(i*2))), record.Value)
+	}
+
+	// Keeping everything copies the log, and the source is left as it was
+	copied := NewTestLog(t)
+	require.NoError(t, src.CompactTo(copied, func(*api.Record) bool { return true }))
+	require.Equal(t, src.CurrentWatermark(), copied.CurrentWatermark())
+
+	require.Error(t, src.CompactTo(src, func(*api.Record) bool { return true }))
+}
+
+func TestLogCompactRetention(t *testing.T) {
+	// Segments of three records each, all of them old enough to expire
+	log := NewTestLog(t, WithMaxIndexBytes(60), WithRetentionPolicy(time.Hour, 1))
+	for i := 0; i < 10; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+	require.Len(t, log.segmentList, 4)
+
+	// Over the size limit, but nothing is old enough yet
+	require.NoError(t, log.Compact())
+	require.Len(t, log.segmentList, 4)
+
+	// Old enough, but the size limit still protects everything
+	log.retention = retentionPolicy{maxAge: time.Nanosecond, maxBytes: 1 << 30}
+	require.NoError(t, log.Compact())
+	require.Len(t, log.segmentList, 4)
+
+	// Over both, the oldest segments go until the rest fits
+	var active uint64
+	for _, s := range log.segmentList[2:] {
+		active += s.TotalSize()
+	}
+	log.retention = retentionPolicy{maxAge: time.Nanosecond, maxBytes: active}
+	require.NoError(t, log.Compact())
+	require.Len(t, log.segmentList, 2)
+	lowest, err := log.LowestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), lowest)
+
+	// The active segment is never removed
+	log.retention = retentionPolicy{maxAge: time.Nanosecond}
+	require.NoError(t, log.Compact())
+	require.Len(t, log.segmentList, 1)
+	require.Equal(t, log.activeSegment, log.segmentList[0])
+}
+
+func TestLogBackgroundRetention(t *testing.T) {
+	// Segments of three records each, kept for an hour and compacted every six minutes.
+	// The clock is far from the system clock, segments have to be aged by it alone.
+	clock := testutil.NewMockClock(time.Unix(0, 0))
+	log := NewTestLog(t, WithMaxIndexBytes(60), WithRetentionPolicy(time.Hour, 0), WithClock(clock))
+	for i := 0; i < 7; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+	require.Len(t, log.segmentList, 3)
+
+	// A compaction runs, but nothing is old enough yet. Waiting for the next one means it is done.
+	clock.BlockUntil(1)
+	clock.Advance(6 * time.Minute)
+	clock.BlockUntil(1)
+	lowest, err := log.LowestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), lowest)
+
+	// Once past the maximum age the sealed segments go, without waiting for it in real time
+	clock.Advance(time.Hour)
+	clock.BlockUntil(1)
+	lowest, err = log.LowestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), lowest)
+
+	// A reset log keeps compacting in the background. The stopped compaction's channel is still handed out.
+	require.NoError(t, log.Reset())
+	for i := 0; i < 7; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(strconv.Itoa(i))})
+		require.NoError(t, err)
+	}
+	clock.BlockUntil(2)
+	clock.Advance(time.Hour + 6*time.Minute)
+	clock.BlockUntil(1)
+	lowest, err = log.LowestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), lowest)
+}
+
+func TestLogAppendOrReplace(t *testing.T) {
+	log := NewTestLog(t)
+
+	// Without a prior record it is a plain append
+	first, err := log.AppendOrReplace(&api.Record{Key: []byte("k"), Value: []byte("v1")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), first)
+	_, err = log.AppendOrReplace(&api.Record{Value: []byte("no key")})
+	require.Error(t, err)
+
+	// Replacing appends the new record followed by a tombstone for the old one
+	second, err := log.AppendOrReplace(&api.Record{Key: []byte("k"), Value: []byte("v2")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), second)
+	tombstone, err := log.Read(2)
+	require.NoError(t, err)
+	require.True(t, tombstone.Tombstone)
+	require.Equal(t, []byte("0"), tombstone.Headers[TombstoneOffsetHeader])
+
+	latest, err := log.LatestByKey([]byte("k"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("v2"), latest.Value)
+	_, err = log.LatestByKey([]byte("missing"))
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	// Compaction drops the replaced record along with the tombstone
+	require.NoError(t, log.Compact())
+	_, err = log.Read(first)
+	require.Error(t, err)
+	record, err := log.Read(second)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v2"), record.Value)
+}
+
+func TestLogKeyTombstones(t *testing.T) {
+	dir := t.TempDir()
+	log, err := NewLog(dir)
+	require.NoError(t, err)
+
+	// A tombstone without a replaced offset deletes its key, until the key is written again
+	_, err = log.Append(&api.Record{Key: []byte("deleted"), Value: []byte("v1")})
+	require.NoError(t, err)
+	_, err = log.Append(&api.Record{Key: []byte("deleted"), Tombstone: true})
+	require.NoError(t, err)
+	_, err = log.LatestByKey([]byte("deleted"))
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	_, err = log.Append(&api.Record{Key: []byte("revived"), Value: []byte("v1")})
+	require.NoError(t, err)
+	revived, err := log.Append(&api.Record{Key: []byte("revived"), Tombstone: true})
+	require.NoError(t, err)
+	_, err = log.Append(&api.Record{Key: []byte("revived"), Value: []byte("v2")})
+	require.NoError(t, err)
+
+	// Records in sealed segments are shadowed by tombstones in the active one
+	sealed, err := log.AppendOrReplace(&api.Record{Key: []byte("replaced"), Value: []byte("v1")})
+	require.NoError(t, err)
+	require.NoError(t, log.newSegment(log.activeSegment.NextOffset()))
+	_, err = log.AppendOrReplace(&api.Record{Key: []byte("replaced"), Value: []byte("v2")})
+	require.NoError(t, err)
+	replaceTombstone := log.activeSegment.NextOffset() - 1
+	deleteTombstone, err := log.Append(&api.Record{Key: []byte("deleted"), Tombstone: true})
+	require.NoError(t, err)
+
+	// Only the tombstones whose records all sit in the active segment go, the others have to stay
+	require.NoError(t, log.Compact())
+	for _, offset := range []uint64{replaceTombstone, deleteTombstone} {
+		record, err := log.Read(offset)
+		require.NoError(t, err)
+		require.True(t, record.Tombstone)
+	}
+	_, err = log.Read(sealed)
+	require.NoError(t, err)
+	_, err = log.Read(revived)
+	require.NoError(t, err)
+
+	// The keys come back the same once the log is reopened
+	require.NoError(t, log.Close())
+	log, err = NewLog(dir)
+	require.NoError(t, err)
+	defer log.Close()
+	_, err = log.LatestByKey([]byte("deleted"))
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	for key, want := range map[string]string{"revived": "v2", "replaced": "v2"} {
+		record, err := log.LatestByKey([]byte(key))
+		require.NoError(t, err)
+		require.Equal(t, []byte(want), record.Value)
+	}
+
+	// A key deleted within the active segment is compacted away entirely
+	_, err = log.Append(&api.Record{Key: []byte("short lived"), Value: []byte("v1")})
+	require.NoError(t, err)
+	gone, err := log.Append(&api.Record{Key: []byte("short lived"), Tombstone: true})
+	require.NoError(t, err)
+	require.NoError(t, log.Compact())
+	_, err = log.Read(gone - 1)
+	require.Error(t, err)
+	_, err = log.Read(gone)
+	require.Error(t, err)
+	_, err = log.LatestByKey([]byte("short lived"))
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestLogSyncCheckpoint(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	log, err := NewLog(tempDir)
+	require.NoError(t, err)
+	defer log.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("synced %d", i))})
+		require.NoError(t, err)
+	}
+	require.NoError(t, log.Sync())
+
+	// The checkpoint records the next offset after everything that was synced
+	data, err := os.ReadFile(filepath.Join(tempDir, checkpointFileName))
+	require.NoError(t, err)
+	require.Equal(t, "3", string(data))
+
+	// Records written after the checkpoint are not vouched for by it
+	for i := 0; i < 2; i++ {
+		_, err := log.Append(&api.Record{Value: []byte(fmt.Sprintf("unsynced %d", i))})
+		require.NoError(t, err)
+	}
+
+	// Simulate a crash in the middle of an append by leaving half an entry at the end of the store
+	storeFile, err := os.OpenFile(filepath.Join(tempDir, fmt.Sprintf("%020d.store", 0)), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = storeFile.Write([]byte{0, 0, 0, 0, 0, 0, 0, 42, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, storeFile.Close())
+
+	// Reopen without closing the first log, its index files were never truncated
+	reopened, err := NewLog(tempDir)
+	require.NoError(t, err)
+
+	// The active segment was repaired from its store
+	require.Equal(t, uint64(5), reopened.activeSegment.NextOffset())
+	for i := uint64(0); i < 5; i++ {
+		record, err := reopened.Read(i)
+		require.NoError(t, err)
+		require.Equal(t, i, record.Offset)
+	}
+
+	// The partial entry was discarded, so appends pick up right after the last record
+	off, err := reopened.Append(&api.Record{Value: []byte("after repair")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), off)
+
+	record, err := reopened.Read(off)
+	require.NoError(t, err)
+	require.Equal(t, []byte("after repair"), record.Value)
+}
+
+func TestLogSetupRepairsInvalidIndex(t *testing.T) {
+	for name, entry := range map[string]int64{"middle entry": 1, "last entry": 2} {
+		t.Run(name, func(t *testing.T) {
+			// Create a temporary directory for testing
+			tempDir, err := os.MkdirTemp("", "log_test_invalid_index")
+			require.NoError(t, err)
+			defer os.RemoveAll(tempDir)
+
+			log, err := NewLog(tempDir)
+			require.NoError(t, err)
+			for i := 0; i < 3; i++ {
+				_, err = log.Append(&api.Record{Value: []byte("hello world")})
+				require.NoError(t, err)
+			}
+			require.NoError(t, log.Close())
+
+			// Point the entry far past the end of the store
+			indexPath := filepath.Join(tempDir, fmt.Sprintf("%020d%s", 0, log.config.IndexExtension))
+			file, err := os.OpenFile(indexPath, os.O_RDWR, 0644)
+			require.NoError(t, err)
+			_, err = file.WriteAt(bytes.Repeat([]byte{0xff}, 8), int64(index.HeaderLength)+entry*20+4)
+			require.NoError(t, err)
+			require.NoError(t, file.Close())
+
+			// Without recovery the index is used as found, so the entry fails to read, or for the last entry,
+			// which opening reads to pick up the sequence, already fails to open
+			reopened, err := NewLog(tempDir)
+			if err == nil {
+				_, err = reopened.Read(uint64(entry))
+				require.NoError(t, reopened.Close())
+			}
+			require.Error(t, err)
+
+			// With recovery it fails validation and is rebuilt from the store
+			recovered, err := NewLog(tempDir, WithRecoverOnCorruption(true))
+			require.NoError(t, err)
+			defer recovered.Close()
+
+			for off := uint64(0); off < 3; off++ {
+				record, err := recovered.Read(off)
+				require.NoError(t, err)
+				require.Equal(t, []byte("hello world"), record.Value)
+			}
+		})
+	}
+}
+
+func TestLogMerge(t *testing.T) {
+	dst := NewTestLog(t, WithMaxIndexBytes(4096))
+	src := NewTestLog(t, WithMaxIndexBytes(4096))
+	for i := 0; i < 500; i++ {
+		_, err := dst.Append(&api.Record{Value: []byte(fmt.Sprintf("dst-%d", i))})
+		require.NoError(t, err)
+		_, err = src.Append(&api.Record{Value: []byte(fmt.Sprintf("src-%d", i))})
+		require.NoError(t, err)
+	}
+
+	merged, err := dst.Merge(src)
+	require.NoError(t, err)
+	require.Equal(t, 500, merged)
+
+	// The merged records carry on from the destination's offsets, in their original order
+	var offsets []uint64
+	require.NoError(t, dst.ForEach(func(offset uint64, record *api.Record) error {
+		want := fmt.Sprintf("dst-%d", offset)
+		if offset >= 500 {
+			want = fmt.Sprintf("src-%d", offset-500)
+		}
+		require.Equal(t, []byte(want), record.Value)
+		offsets = append(offsets, offset)
+		return nil
+	}))
+	require.Len(t, offsets, 1000)
+	for i, offset := range offsets {
+		require.Equal(t, uint64(i), offset)
+	}
+
+	// The source is left as it was, and merging an empty log changes nothing
+	highest, err := src.HighestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(499), highest)
+
+	merged, err = dst.Merge(NewTestLog(t))
+	require.NoError(t, err)
+	require.Zero(t, merged)
+
+	_, err = dst.Merge(dst)
+	require.Error(t, err)
+}
+
+func TestLogAppendRotationFailure(t *testing.T) {
+	// Room for three records per segment
+	log := NewTestLog(t, WithMaxIndexBytes(60))
+	for i := 0; i < 2; i++ {
+		_, err := log.Append(&api.Record{Value: []byte("hello world")})
+		require.NoError(t, err)
+	}
+
+	// A directory where the next segment's store goes keeps it from being created
+	storePath := filepath.Join(log.Dir(), fmt.Sprintf("%020d%s", 3, log.config.StoreExtension))
+	require.NoError(t, os.Mkdir(storePath, 0755))
+
+	// The record that fills the segment is written even though the rotation after it fails
+	off, err := log.Append(&api.Record{Value: []byte("last")})
+	require.Error(t, err)
+	require.Equal(t, uint64(2), off)
+
+	record, err := log.Read(off)
+	require.NoError(t, err)
+	require.Equal(t, []byte("last"), record.Value)
+	require.Len(t, log.segmentList, 1)
+}
+
+func TestLogMergeRotationFailure(t *testing.T) {
+	// Room for three records per segment
+	dst := NewTestLog(t, WithMaxIndexBytes(60))
+	src := NewTestLog(t)
+	for i := 0; i < 5; i++ {
+		_, err := src.Append(&api.Record{Value: []byte(fmt.Sprintf("src-%d", i))})
+		require.NoError(t, err)
+	}
+
+	// The third record fills the segment, and the rotation after it fails
+	storePath := filepath.Join(dst.Dir(), fmt.Sprintf("%020d%s", 3, dst.config.StoreExtension))
+	require.NoError(t, os.Mkdir(storePath, 0755))
+
+	merged, err := dst.Merge(src)
+	require.Error(t, err)
+	require.Equal(t, 3, merged)
+	record, err := dst.Read(2)
+	require.NoError(t, err)
+	require.Equal(t, []byte("src-2"), record.Value)
+}
+
+func TestLogSegmentFullObserver(t *testing.T) {
+	sealed := make(chan *SegmentInfo, 200)
+	// Room for five records per segment
+	log := NewTestLog(t, WithMaxIndexBytes(100), WithSegmentFullObserver(func(info *SegmentInfo) {
+		sealed <- info
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 50; n++ {
+				_, err := log.Append(&api.Record{Value: []byte("hello world")})
+				require.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 400 records fill 80 segments, each of which is reported exactly once, and never the active one
+	seen := make(map[uint64]bool)
+	for len(seen) < 80 {
+		select {
+		case info := <-sealed:
+			require.False(t, seen[info.BaseOffset], "segment at %d reported twice", info.BaseOffset)
+			seen[info.BaseOffset] = true
+			require.Equal(t, info.BaseOffset+5, info.NextOffset)
+			require.Equal(t, uint64(100), info.IndexBytes)
+		case <-time.After(time.Second):
+			t.Fatalf("only %d of 80 sealed segments were reported", len(seen))
+		}
+	}
+	for off := uint64(0); off < 400; off += 5 {
+		require.True(t, seen[off], "segment at %d was not reported", off)
+	}
+	require.Equal(t, uint64(400), log.activeSegment.BaseOffset())
+
+	// Nothing else is reported, not even the segment created on open
+	time.Sleep(10 * time.Millisecond)
+	require.Empty(t, sealed)
+}
+
+func TestLogTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	log := NewTestLog(t, WithTracingProvider(tp))
+	exporter.Reset()
+
+	// Appends and reads made with a context are traced as part of the caller's span
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	off, err := log.AppendContext(ctx, &api.Record{Value: []byte("traced")})
+	require.NoError(t, err)
+	_, err = log.ReadContext(ctx, off)
+	require.NoError(t, err)
+	parent.End()
+
+	names := make(map[string]bool)
+	for _, span := range exporter.GetSpans() {
+		if span.Name == "request" {
+			continue
+		}
+		names[span.Name] = true
+		require.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID(), "span %s", span.Name)
+		require.Equal(t, parent.SpanContext().TraceID(), span.SpanContext.TraceID(), "span %s", span.Name)
+	}
+	for _, name := range []string{"store.append", "index.write", "index.read", "store.read"} {
+		require.True(t, names[name], "no %s span", name)
+	}
+
+	// Without a context every call starts a trace of its own
+	exporter.Reset()
+	_, err = log.Append(&api.Record{Value: []byte("untraced")})
+	require.NoError(t, err)
+	spans := exporter.GetSpans()
+	require.NotEmpty(t, spans)
+	for _, span := range spans {
+		require.False(t, span.Parent.IsValid(), "span %s has a parent", span.Name)
+	}
+}
@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// NewTestLog opens a log in a fresh temporary directory.
+// The log is closed and the directory removed when the test finishes, even if it fails part way.
+func NewTestLog(t testing.TB, opts ...LogOption) *Log {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "log_test")
+	if err != nil {
+		t.Fatalf("Failed to create log directory: %v", err)
+	}
+
+	log, err := NewLog(dir, opts...)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	t.Cleanup(func() {
+		log.Close()
+		os.RemoveAll(dir)
+	})
+
+	return log
+}
+
+// corruptRecord overwrites the data of the record at offset on disk, so reading it fails to decode
+func corruptRecord(t testing.TB, log *Log, offset uint64) {
+	t.Helper()
+
+	if err := log.Sync(); err != nil {
+		t.Fatalf("Failed to sync log: %v", err)
+	}
+	s, err := log.segmentContaining(offset)
+	if err != nil {
+		t.Fatalf("Failed to find segment: %v", err)
+	}
+	pos, err := s.Position(offset)
+	if err != nil {
+		t.Fatalf("Failed to find record: %v", err)
+	}
+
+	f, err := os.OpenFile(s.StorePath(), os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(bytes.Repeat([]byte{0xff}, 4), int64(pos)+8); err != nil {
+		t.Fatalf("Failed to corrupt record: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"context"
+	"errors"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+)
+
+// Merge appends every record in other to the log, in offset order, and returns how many were merged.
+// Records get new offsets and sequence numbers in the log, their keys and values are kept as they are.
+// With WithMaxConsumerLag it waits for lagging consumers once, before anything is merged.
+// The log is write locked and other read locked throughout, so merging two logs into each other at the
+// same time deadlocks. If a record cannot be appended, the ones before it stay merged and the error is returned.
+// A record that was written before rotating to a new segment failed counts as merged.
+func (l *Log) Merge(other *Log) (int, error) {
+	if l == other {
+		return 0, errors.New("cannot merge a log into itself")
+	}
+
+	if err := l.lockForAppend(); err != nil {
+		return 0, err
+	}
+	defer l.mutex.Unlock()
+
+	merged := 0
+	err := other.ForEach(func(_ uint64, record *api.Record) error {
+		// A record that fills the segment is written even if rotating after it fails, so count it by its
+		// sequence number being used up rather than by the error
+		seq := l.seq.Load()
+		_, err := l.append(context.Background(), record)
+		if l.seq.Load() != seq {
+			merged++
+		}
+		return err
+	})
+
+	return merged, err
+}
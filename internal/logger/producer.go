@@ -0,0 +1,277 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/core/store"
+)
+
+var ErrProducerClosed = errors.New("producer is closed")
+
+// RecordError is returned when a Producer fails to write a record. The record is dropped from the buffer so the
+// records after it are still written by the next flush, and handed back for the caller to retry or give up on.
+type RecordError struct {
+	Record *api.Record
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("failed to write record: %v", e.Err)
+}
+
+// Unwrap returns the error the record failed with
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Default number of records a Producer buffers before writing them
+const defaultProducerBatch = 100
+
+// CompressionHeader is the record header a Producer sets to the algorithm of every value it compresses.
+// Decompress undoes the compression of records carrying it.
+const CompressionHeader = "cute-logger-compression"
+
+// Producer appends records to a log in batches, for callers that produce a stream of records rather than one at a time.
+// Send buffers records and the buffer is written with a single AppendBatch once it holds a full batch, when the flush
+// interval passes, or on Flush and Close. Every record sent is stamped with its offset and sequence number once written.
+// A Producer is safe for concurrent use.
+type Producer struct {
+	log           *Log
+	batchSize     int
+	compression   store.CompressionAlgo
+	flushInterval time.Duration
+
+	mutex   sync.Mutex
+	pending []*api.Record
+	timer   *time.Timer
+	closed  bool
+
+	// Error of the last flush the timer made, returned by the next Send, Flush or Close
+	err error
+
+	// Records written so far and the sequence number of the last one
+	appended uint64
+	lastSeq  uint64
+}
+
+// Represents a function that applies configuration options to a Producer
+type ProducerOption func(*Producer)
+
+// WithBatch writes records once n of them are buffered, instead of the default 100. 1 writes every record as it is sent.
+func WithBatch(n int) ProducerOption {
+	return func(p *Producer) {
+		p.batchSize = n
+	}
+}
+
+// WithCompression compresses the value of every record sent with algo and marks it with CompressionHeader
+func WithCompression(algo store.CompressionAlgo) ProducerOption {
+	return func(p *Producer) {
+		p.compression = algo
+	}
+}
+
+// WithFlushInterval writes a partial batch once its oldest record has been buffered for d, instead of the default 100ms.
+// Zero leaves partial batches buffered until Flush or Close.
+func WithFlushInterval(d time.Duration) ProducerOption {
+	return func(p *Producer) {
+		p.flushInterval = d
+	}
+}
+
+// NewProducer returns a Producer appending to the log
+func (l *Log) NewProducer(opts ...ProducerOption) *Producer {
+	p := &Producer{
+		log:           l,
+		batchSize:     defaultProducerBatch,
+		flushInterval: 100 * time.Millisecond,
+	}
+
+	// Apply each option to the producer
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.batchSize < 1 {
+		p.batchSize = 1
+	}
+
+	return p
+}
+
+// Send buffers record, compressing its value first if the producer compresses, and writes the buffer once it holds
+// a full batch. The record is modified in place, so it must not be changed until it is written.
+func (p *Producer) Send(record *api.Record) error {
+	if err := compress(record, p.compression); err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return ErrProducerClosed
+	}
+	if err := p.takeErr(); err != nil {
+		return err
+	}
+
+	p.pending = append(p.pending, record)
+	if len(p.pending) >= p.batchSize {
+		return p.flush()
+	}
+
+	p.startTimer()
+	return nil
+}
+
+// Flush writes every buffered record
+func (p *Producer) Flush() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err := p.takeErr(); err != nil {
+		return err
+	}
+	return p.flush()
+}
+
+// Close flushes the buffered records and stops the flush timer. Sending afterwards fails with ErrProducerClosed.
+// An error kept from a timed flush is returned along with, and ahead of, any error of this last flush.
+func (p *Producer) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+
+	err := p.takeErr()
+	flushErr := p.flush()
+	switch {
+	case err == nil:
+		return flushErr
+	case flushErr != nil:
+		return fmt.Errorf("%w, then flushing the buffer failed: %v", err, flushErr)
+	}
+	return err
+}
+
+// Appended returns how many records the producer has written to the log
+func (p *Producer) Appended() uint64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.appended
+}
+
+// LastSeq returns the sequence number of the last record the producer wrote, or 0 before the first
+func (p *Producer) LastSeq() uint64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.lastSeq
+}
+
+// flush writes the buffered records in one batch. The first record the batch failed on is dropped and returned in
+// a RecordError, and the records after it stay buffered for the next flush. The caller holds the mutex.
+func (p *Producer) flush() error {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	if len(p.pending) == 0 {
+		return nil
+	}
+
+	offsets, err := p.log.AppendBatch(p.pending)
+	if len(offsets) > 0 {
+		p.appended += uint64(len(offsets))
+		p.lastSeq = p.pending[len(offsets)-1].Seq
+	}
+	p.pending = p.pending[len(offsets):]
+
+	if err != nil && len(p.pending) > 0 {
+		err = &RecordError{Record: p.pending[0], Err: err}
+		p.pending = p.pending[1:]
+	}
+	if len(p.pending) == 0 {
+		p.pending = nil
+	}
+
+	// The records left behind a failed one still get written once the flush interval passes
+	p.startTimer()
+	return err
+}
+
+// startTimer starts the clock on a partial batch unless it is already running. The caller holds the mutex.
+func (p *Producer) startTimer() {
+	if p.timer == nil && len(p.pending) > 0 && p.flushInterval > 0 && !p.closed {
+		p.timer = time.AfterFunc(p.flushInterval, p.timedFlush)
+	}
+}
+
+// timedFlush flushes a partial batch once the flush interval has passed, keeping any error for the next call
+func (p *Producer) timedFlush() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// A flush in the meantime already wrote the batch this timer was started for
+	if p.timer == nil {
+		return
+	}
+	if err := p.flush(); err != nil && p.err == nil {
+		p.err = err
+	}
+}
+
+// takeErr returns and clears the error of the last timed flush. The caller holds the mutex.
+func (p *Producer) takeErr() error {
+	err := p.err
+	p.err = nil
+	return err
+}
+
+// compress replaces the record's value with its compressed form and marks the record with CompressionHeader
+func compress(record *api.Record, algo store.CompressionAlgo) error {
+	if algo == store.NoCompression {
+		return nil
+	}
+
+	value, err := store.Compress(algo, record.Value)
+	if err != nil {
+		return err
+	}
+	record.Value = value
+
+	if record.Headers == nil {
+		record.Headers = make(map[string][]byte, 1)
+	}
+	record.Headers[CompressionHeader] = []byte(algo)
+	return nil
+}
+
+// Decompress restores the value of a record written by a compressing Producer and removes its CompressionHeader.
+// Records without the header are left alone.
+func Decompress(record *api.Record) error {
+	algo, ok := record.Headers[CompressionHeader]
+	if !ok {
+		return nil
+	}
+	if store.CompressionAlgo(algo) == store.NoCompression {
+		return fmt.Errorf("unknown compression %q", algo)
+	}
+
+	value, err := store.Decompress(store.CompressionAlgo(algo), record.Value)
+	if err != nil {
+		return err
+	}
+	record.Value = value
+
+	delete(record.Headers, CompressionHeader)
+	return nil
+}
@@ -0,0 +1,24 @@
+package logger
+
+import (
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"google.golang.org/protobuf/proto"
+)
+
+// AppendProto marshals msg and appends it as the value of a new record, returning the record's offset
+func (l *Log) AppendProto(msg proto.Message) (uint64, error) {
+	value, err := proto.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+	return l.Append(&api.Record{Value: value})
+}
+
+// ReadProto reads the record at offset and unmarshals its value into dst, the inverse of AppendProto
+func (l *Log) ReadProto(offset uint64, dst proto.Message) error {
+	record, err := l.Read(offset)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(record.Value, dst)
+}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitedReader reads the raw bytes of a log no faster than a fixed number of bytes per second,
+// so exports and replication do not saturate the disk or network.
+type RateLimitedReader struct {
+	reader  io.Reader
+	limiter *rate.Limiter
+
+	// Bytes handed out so far, read without any locking for progress reporting
+	bytesRead int64
+}
+
+// NewRateLimitedReader returns a reader over the log from startOffset, like Log.ReaderFrom, limited to rateBytes per second
+func NewRateLimitedReader(log *Log, startOffset uint64, rateBytes int64) (*RateLimitedReader, error) {
+	if rateBytes <= 0 {
+		return nil, errors.New("rate must be a positive number of bytes per second")
+	}
+
+	reader, err := log.ReaderFrom(startOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	// Allow up to a second's worth of bytes at once
+	return &RateLimitedReader{
+		reader:  reader,
+		limiter: rate.NewLimiter(rate.Limit(rateBytes), int(rateBytes)),
+	}, nil
+}
+
+// Read reads up to len(p) bytes and then waits until the rate allows them.
+// Waiting for what was actually read keeps short reads, e.g. at segment boundaries, from being overcharged.
+// Reads larger than the limiter's burst are shortened to it.
+func (r *RateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.Burst() {
+		p = p[:r.limiter.Burst()]
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		// The bytes are already read, so they are handed out even if waiting for them fails
+		atomic.AddInt64(&r.bytesRead, int64(n))
+		if waitErr := r.limiter.WaitN(context.Background(), n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// BytesRead returns how many bytes have been read so far
+func (r *RateLimitedReader) BytesRead() int64 {
+	return atomic.LoadInt64(&r.bytesRead)
+}
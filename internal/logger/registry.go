@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// ErrLogNotFound is returned when no log is registered under the requested name.
+var ErrLogNotFound = errors.New("no log registered with that name")
+
+// Registry manages a set of named logs so a process can share them without passing *Log around.
+// It is safe for concurrent use.
+type Registry struct {
+	mutex sync.RWMutex
+	logs  map[string]*Log
+}
+
+// DefaultRegistry is the process-wide registry, in the spirit of database/sql's driver registry.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		logs: make(map[string]*Log),
+	}
+}
+
+// Open returns the log registered under name, creating it in dir if it is not open yet.
+func (r *Registry) Open(name, dir string, opts ...LogOption) (*Log, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Hand back the cached log, as long as it lives where the caller expects it to
+	if l, ok := r.logs[name]; ok {
+		if l.dir != filepath.Clean(dir) {
+			return nil, fmt.Errorf("log %q is already open in %s", name, l.dir)
+		}
+		return l, nil
+	}
+
+	l, err := NewLog(dir, opts...)
+	if err != nil {
+		return nil, err
+	}
+	r.logs[name] = l
+
+	return l, nil
+}
+
+// Get returns the log registered under name.
+func (r *Registry) Get(name string) (*Log, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	l, ok := r.logs[name]
+	if !ok {
+		return nil, ErrLogNotFound
+	}
+
+	return l, nil
+}
+
+// Close closes the log registered under name and removes it from the registry.
+func (r *Registry) Close(name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	l, ok := r.logs[name]
+	if !ok {
+		return ErrLogNotFound
+	}
+	delete(r.logs, name)
+
+	return l.Close()
+}
+
+// CloseAll closes every registered log and empties the registry.
+// All logs are closed even if some fail; the first error is returned.
+func (r *Registry) CloseAll() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var firstErr error
+	for name, l := range r.logs {
+		if err := l.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(r.logs, name)
+	}
+
+	return firstErr
+}
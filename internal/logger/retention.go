@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"context"
+	"log"
+	"time"
+
+	seg "github.com/BryceDouglasJames/Cute-Logger/internal/core/segment"
+)
+
+// retentionPolicy decides which sealed segments Compact removes. A zero field places no limit of its own.
+type retentionPolicy struct {
+	maxAge   time.Duration
+	maxBytes uint64
+}
+
+// WithRetentionPolicy has Compact remove sealed segments, oldest first, that are both older than maxAge
+// and needed to bring the log's total size down to maxBytes. Since both have to hold, neither limit alone
+// deletes data the other still protects. Zero leaves that limit out, so the other one decides alone.
+// With a maxAge set, Compact also runs in the background every maxAge/10 until the log is closed.
+func WithRetentionPolicy(maxAge time.Duration, maxBytes uint64) LogOption {
+	return func(l *Log) {
+		l.retention = retentionPolicy{maxAge: maxAge, maxBytes: maxBytes}
+	}
+}
+
+// startRetention compacts the log every maxAge/10 in the background, if a maximum age is set
+func (l *Log) startRetention() {
+	interval := l.retention.maxAge / 10
+	if interval <= 0 {
+		return
+	}
+
+	l.goBackground(func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-l.clock.After(interval):
+				if err := l.Compact(); err != nil {
+					log.Printf("warning: background compaction failed: %v", err)
+				}
+			}
+		}
+	})
+}
+
+// applyRetention removes the sealed segments the retention policy no longer keeps. The caller holds the write lock.
+func (l *Log) applyRetention() error {
+	policy := l.retention
+	if policy.maxAge == 0 && policy.maxBytes == 0 {
+		return nil
+	}
+	defer l.changed.notify()
+
+	var total uint64
+	for _, s := range l.segmentList {
+		total += s.TotalSize()
+	}
+
+	// Only a prefix of sealed segments ever goes, so the log's offsets stay contiguous.
+	// Segments get younger and the total smaller further along, so the first one kept ends it.
+	var err error
+	removed := 0
+	for _, s := range l.segmentList {
+		if s == l.activeSegment {
+			break
+		}
+		expired := policy.maxAge == 0 || l.clock.Now().Sub(s.GetStore().Meta().CreatedAt) > policy.maxAge
+		oversized := policy.maxBytes == 0 || total > policy.maxBytes
+		if !expired || !oversized {
+			break
+		}
+
+		size := s.TotalSize()
+		if err = s.Remove(); err != nil {
+			break
+		}
+		total -= size
+		removed++
+	}
+
+	// Whatever was removed before an error is gone either way
+	l.segmentList = append([]*seg.Segment(nil), l.segmentList[removed:]...)
+
+	return err
+}
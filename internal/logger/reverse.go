@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"errors"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	seg "github.com/BryceDouglasJames/Cute-Logger/internal/core/segment"
+)
+
+// LogReverseIterator walks the log from a starting offset back to its lowest offset.
+// Like database/sql.Rows, call Next until it reports false, check Err, and Close the iterator when done.
+type LogReverseIterator struct {
+	log *Log
+
+	// Highest offset the next call to Next may return
+	offset uint64
+
+	done bool
+	err  error
+}
+
+// ReadReverse returns an iterator over the records from offset from down to the log's lowest offset.
+// Records appended later have higher offsets and are not visited, while offsets compaction or DeleteRange
+// removed are skipped.
+func (l *Log) ReadReverse(from uint64) (*LogReverseIterator, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return nil, ErrLogClosed
+	}
+	if from >= l.activeSegment.NextOffset() {
+		return nil, api.ErrOffsetOutOfRange{Offset: from}
+	}
+
+	return &LogReverseIterator{
+		log:    l,
+		offset: from,
+	}, nil
+}
+
+// Next returns the record at the current offset and moves back to the record before it.
+// It reports false once the lowest offset has been returned, or when a read fails.
+func (it *LogReverseIterator) Next() (*api.Record, bool) {
+	if it.done {
+		return nil, false
+	}
+
+	// Hold the read lock only for this record so appends are never held up. The segments are looked up
+	// again on every call, since Compact, DeleteRange and Truncate may have replaced them in between.
+	it.log.mutex.RLock()
+	record, err := it.log.readAtOrBelow(it.offset)
+	it.log.mutex.RUnlock()
+	if err != nil || record == nil {
+		it.err = err
+		it.done = true
+		return nil, false
+	}
+
+	if record.Offset == 0 {
+		it.done = true
+	} else {
+		it.offset = record.Offset - 1
+	}
+	return record, true
+}
+
+// readAtOrBelow returns the record with the highest offset up to offset, or nil when there is none.
+// The caller holds the read lock.
+func (l *Log) readAtOrBelow(offset uint64) (*api.Record, error) {
+	if l.closed.Load() {
+		return nil, ErrLogClosed
+	}
+
+	for i := len(l.segmentList) - 1; i >= 0; i-- {
+		s := l.segmentList[i]
+		if s.BaseOffset() > offset || s.NextOffset() == s.BaseOffset() {
+			continue
+		}
+
+		off := offset
+		if next := s.NextOffset(); off >= next {
+			off = next - 1
+		}
+		for {
+			record, err := s.Read(off)
+			var notFound *seg.ErrOffsetNotFound
+			if !errors.As(err, &notFound) {
+				return record, err
+			}
+
+			// A gap left by compaction, keep going down
+			if off == s.BaseOffset() {
+				break
+			}
+			off--
+		}
+	}
+	return nil, nil
+}
+
+// Err returns the error that stopped the iteration early, if any
+func (it *LogReverseIterator) Err() error {
+	return it.err
+}
+
+// Close stops the iteration
+func (it *LogReverseIterator) Close() error {
+	it.done = true
+	return nil
+}
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	seg "github.com/BryceDouglasJames/Cute-Logger/internal/core/segment"
+)
+
+// LogStats describes how much a log holds and what it costs on disk
+type LogStats struct {
+	Segments   int
+	Records    uint64
+	StoreBytes uint64
+	IndexBytes uint64
+
+	// Sum of the lengths of every record's value, the payload the store bytes are spent on
+	OriginalValueBytes uint64
+}
+
+// WriteAmplification is the number of store bytes written per byte of record value.
+// Anything above 1 is framing: the record encoding and the length prefix of every entry.
+// It is 0 for a log without any value bytes.
+func (s LogStats) WriteAmplification() float64 {
+	if s.OriginalValueBytes == 0 {
+		return 0
+	}
+	return float64(s.StoreBytes) / float64(s.OriginalValueBytes)
+}
+
+// Stats returns a snapshot of the log's size. Counting the value bytes reads every record,
+// so it is O(n) in the records of the log and meant for capacity planning rather than hot paths.
+func (l *Log) Stats() (LogStats, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed.Load() {
+		return LogStats{}, ErrLogClosed
+	}
+
+	stats := LogStats{Segments: len(l.segmentList)}
+	for _, s := range l.segmentList {
+		stats.StoreBytes += s.StoreSize()
+		stats.IndexBytes += s.IndexSize()
+
+		encoding := s.Config().RecordEncoding
+		err := s.Walk(func(_ uint64, p []byte) error {
+			record, err := seg.UnmarshalRecord(encoding, p)
+			if err != nil {
+				return err
+			}
+			stats.Records++
+			stats.OriginalValueBytes += uint64(len(record.Value))
+			return nil
+		})
+		if err != nil {
+			return LogStats{}, err
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,153 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+)
+
+// broadcast wakes every goroutine waiting on it at once. Its channel is closed and replaced on every notify.
+type broadcast struct {
+	mutex sync.Mutex
+	ch    chan struct{}
+}
+
+// wait returns a channel that is closed the next time notify is called
+func (b *broadcast) wait() <-chan struct{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.ch == nil {
+		b.ch = make(chan struct{})
+	}
+	return b.ch
+}
+
+// notify wakes everyone waiting so far
+func (b *broadcast) notify() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.ch != nil {
+		close(b.ch)
+		b.ch = nil
+	}
+}
+
+// Subscription delivers the log's records in offset order over a channel, for consumers in the same process
+// that have no need for the gRPC stream. It is created by Log.Subscribe.
+type Subscription struct {
+	// C receives every record from the start offset on, and is closed by Unsubscribe, once the log is closed,
+	// or when a record cannot be read, see Err
+	C <-chan *api.Record
+
+	unsubscribe chan struct{}
+	once        sync.Once
+	stopped     chan struct{}
+
+	// Why delivery stopped, only set before C is closed
+	err error
+}
+
+// Err returns the error that ended the subscription once C is closed: nil after Unsubscribe or once the log
+// is closed, and the read error when a record could not be read for any reason but having been removed.
+func (s *Subscription) Err() error {
+	<-s.stopped
+	return s.err
+}
+
+// Unsubscribe stops the subscription and waits for C to be closed. Calling it more than once is harmless.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() { close(s.unsubscribe) })
+	<-s.stopped
+}
+
+// Subscribe returns a subscription receiving every record from startOffset on, including the ones appended later.
+// Up to bufSize records are buffered for a slow receiver, after which the subscription waits for it without
+// holding producers back. Records removed from the log before they were delivered are skipped.
+// Any number of subscriptions can be open on one log at once.
+func (l *Log) Subscribe(startOffset uint64, bufSize int) *Subscription {
+	records := make(chan *api.Record, bufSize)
+	sub := &Subscription{
+		C:           records,
+		unsubscribe: make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+
+	// A closed log has nothing left to deliver
+	if l.ctx.Err() != nil {
+		close(records)
+		close(sub.stopped)
+		return sub
+	}
+
+	l.goBackground(func(ctx context.Context) {
+		defer close(sub.stopped)
+		defer close(records)
+		sub.err = l.deliver(ctx, startOffset, records, sub.unsubscribe)
+	})
+	return sub
+}
+
+// deliver sends records from offset on to out, until ctx is done, unsubscribe is closed or a record cannot be read.
+// Offsets out of range were removed from the log or are not there yet, any other read error is returned.
+func (l *Log) deliver(ctx context.Context, offset uint64, out chan<- *api.Record, unsubscribe <-chan struct{}) error {
+	for {
+		// Take the signal before reading, so an append in between is never missed
+		changed := l.changed.wait()
+
+		record, err := l.Read(offset)
+		if err == nil {
+			select {
+			case out <- record:
+				offset++
+				continue
+			case <-unsubscribe:
+				return nil
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		// A closed log ends the subscription like the log's context does
+		var outOfRange api.ErrOffsetOutOfRange
+		if errors.Is(err, ErrLogClosed) {
+			return nil
+		}
+		if !errors.As(err, &outOfRange) {
+			return err
+		}
+
+		// Skip over records removed by retention, Truncate or compaction, and wait at the end of the log
+		next, wait := l.skip(offset)
+		if !wait {
+			offset = next
+			continue
+		}
+
+		select {
+		case <-changed:
+		case <-unsubscribe:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// skip returns the offset to read next after offset could not be read: the oldest record left when offset was
+// removed by retention or Truncate, the following offset when it is a hole left by compaction, or offset itself
+// with wait set when it is past the end of the log
+func (l *Log) skip(offset uint64) (next uint64, wait bool) {
+	lowest, _ := l.LowestOffset()
+	highest, _ := l.HighestOffset()
+	switch {
+	case offset < lowest:
+		return lowest, false
+	case offset < highest:
+		return offset + 1, false
+	}
+	return offset, true
+}
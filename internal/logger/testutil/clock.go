@@ -0,0 +1,83 @@
+// Package testutil has helpers for testing code built on the log.
+package testutil
+
+import (
+	"sync"
+	"time"
+)
+
+// MockClock is a clock that only moves when Advance is called. It satisfies logger.Clock.
+type MockClock struct {
+	mutex sync.Mutex
+	cond  *sync.Cond
+	now   time.Time
+
+	// Channels handed out by After that have not fired yet
+	waiters []waiter
+}
+
+type waiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewMockClock returns a clock stopped at now
+func NewMockClock(now time.Time) *MockClock {
+	c := &MockClock{now: now}
+	c.cond = sync.NewCond(&c.mutex)
+	return c
+}
+
+// Now returns the clock's current time
+func (c *MockClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// After returns a channel that receives the clock's time once Advance has moved it d past now.
+// A d of zero or less fires right away.
+func (c *MockClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Buffered so firing never waits on a receiver that went away
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, waiter{at: c.now.Add(d), c: ch})
+	c.cond.Broadcast()
+
+	return ch
+}
+
+// Advance moves the clock forward by d and fires every After channel that is due by then
+func (c *MockClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.c <- c.now
+	}
+	c.waiters = pending
+}
+
+// BlockUntil waits until n channels handed out by After are waiting to fire. Tests call it before Advance
+// to be sure a background goroutine is waiting on the clock, and after it to know the goroutine came back round.
+func (c *MockClock) BlockUntil(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
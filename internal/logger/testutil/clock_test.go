@@ -0,0 +1,32 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewMockClock(start)
+	require.Equal(t, start, clock.Now())
+
+	// Nothing fires until the clock has moved far enough
+	soon, later := clock.After(time.Minute), clock.After(time.Hour)
+	clock.BlockUntil(2)
+	clock.Advance(30 * time.Second)
+	require.Empty(t, soon)
+
+	clock.Advance(30 * time.Second)
+	require.Equal(t, start.Add(time.Minute), <-soon)
+	require.Empty(t, later)
+
+	// Moving well past a deadline fires it with the time the clock moved to
+	clock.Advance(2 * time.Hour)
+	require.Equal(t, start.Add(2*time.Hour+time.Minute), <-later)
+	require.Equal(t, start.Add(2*time.Hour+time.Minute), clock.Now())
+
+	// No wait at all fires right away
+	require.Equal(t, clock.Now(), <-clock.After(0))
+}
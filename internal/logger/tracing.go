@@ -0,0 +1,12 @@
+package logger
+
+import "go.opentelemetry.io/otel/trace"
+
+// WithTracingProvider traces every store and index write and read of the log's segments with tracers from tp,
+// registered under store.TracerName and index.TracerName. AppendContext and ReadContext make the spans children
+// of the caller's, Append and Read start new traces. Without it, the default, segments do no tracing work at all.
+func WithTracingProvider(tp trace.TracerProvider) LogOption {
+	return func(l *Log) {
+		l.tracerProvider = tp
+	}
+}
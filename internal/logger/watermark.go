@@ -0,0 +1,76 @@
+package logger
+
+import "context"
+
+// Watermark is the range of offsets a log holds. Low is the oldest record's offset and High the offset the
+// next record gets, so the log holds the records from Low up to but not including High, and none when they are equal.
+type Watermark struct {
+	Low, High uint64
+}
+
+// CurrentWatermark returns the log's watermark under a single read lock
+func (l *Log) CurrentWatermark() Watermark {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return l.watermark()
+}
+
+// watermark returns the log's watermark. The caller holds the lock.
+func (l *Log) watermark() Watermark {
+	return Watermark{
+		Low:  l.lowestOffset(),
+		High: l.activeSegment.NextOffset(),
+	}
+}
+
+// WatchWatermark returns a channel receiving the log's watermark every time an Append, Truncate or removal
+// moves it, e.g. so a follower learns the primary has advanced. A receiver that falls behind only gets the
+// latest watermark, the ones in between are dropped. The channel is closed once ctx is done or the log is closed.
+func (l *Log) WatchWatermark(ctx context.Context) <-chan Watermark {
+	watermarks := make(chan Watermark, 1)
+
+	// A closed log never moves again
+	if l.ctx.Err() != nil {
+		close(watermarks)
+		return watermarks
+	}
+
+	// Taken before returning, so a change right after the call is never missed
+	last := l.CurrentWatermark()
+	l.goBackground(func(logCtx context.Context) {
+		defer close(watermarks)
+
+		for {
+			// Take the signal before checking, so a change in between is never missed
+			changed := l.changed.wait()
+			if current := l.CurrentWatermark(); current != last {
+				last = current
+				sendLatest(watermarks, current)
+			}
+
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			case <-logCtx.Done():
+				return
+			}
+		}
+	})
+	return watermarks
+}
+
+// sendLatest sends w without blocking, replacing a watermark still waiting in the buffer.
+// The caller is the channel's only sender.
+func sendLatest(watermarks chan Watermark, w Watermark) {
+	select {
+	case watermarks <- w:
+	default:
+		select {
+		case <-watermarks:
+		default:
+		}
+		watermarks <- w
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Rejects unary calls with ResourceExhausted while n of them are already being handled, instead of letting
+// them queue up in memory during a load spike. Clients are expected to back off and retry.
+// Only takes effect on gRPC servers created with NewServer.
+func WithMaxQueueDepth(n int) Option {
+	return func(s *grpcServer) error {
+		if n <= 0 {
+			return errors.New("max queue depth must be positive")
+		}
+		sem := make(chan struct{}, n)
+		s.serverOpts = append(s.serverOpts,
+			grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				default:
+					log.Printf("warning: rejecting %s, %d calls are already in progress", info.FullMethod, n)
+					return nil, status.Errorf(codes.ResourceExhausted, "too many requests in progress, limit is %d", n)
+				}
+				return handler(ctx, req)
+			}),
+		)
+		return nil
+	}
+}
+
+// Rejects new streams with ResourceExhausted while n streams are already open, the streaming
+// counterpart of WithMaxQueueDepth. Only takes effect on gRPC servers created with NewServer.
+func WithMaxConcurrentStreams(n int) Option {
+	return func(s *grpcServer) error {
+		if n <= 0 {
+			return errors.New("max concurrent streams must be positive")
+		}
+		sem := make(chan struct{}, n)
+		s.serverOpts = append(s.serverOpts,
+			grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				default:
+					log.Printf("warning: rejecting %s, %d streams are already open", info.FullMethod, n)
+					return status.Errorf(codes.ResourceExhausted, "too many open streams, limit is %d", n)
+				}
+				return handler(srv, ss)
+			}),
+		)
+		return nil
+	}
+}
@@ -0,0 +1,1069 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ../../api/record_grpc.pb.go
+//
+// Generated by this command:
+//
+//	mockgen -source=../../api/record_grpc.pb.go -destination=mock_log_test.go -package=server
+//
+
+// Package server is a generated GoMock package.
+package server
+
+import (
+	context "context"
+	reflect "reflect"
+
+	record "github.com/BryceDouglasJames/Cute-Logger/api"
+	gomock "go.uber.org/mock/gomock"
+	grpc "google.golang.org/grpc"
+	metadata "google.golang.org/grpc/metadata"
+)
+
+// MockLogClient is a mock of LogClient interface.
+type MockLogClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockLogClientMockRecorder
+}
+
+// MockLogClientMockRecorder is the mock recorder for MockLogClient.
+type MockLogClientMockRecorder struct {
+	mock *MockLogClient
+}
+
+// NewMockLogClient creates a new mock instance.
+func NewMockLogClient(ctrl *gomock.Controller) *MockLogClient {
+	mock := &MockLogClient{ctrl: ctrl}
+	mock.recorder = &MockLogClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLogClient) EXPECT() *MockLogClientMockRecorder {
+	return m.recorder
+}
+
+// BulkConsume mocks base method.
+func (m *MockLogClient) BulkConsume(ctx context.Context, in *record.ConsumeRangeRequest, opts ...grpc.CallOption) (record.Log_BulkConsumeClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "BulkConsume", varargs...)
+	ret0, _ := ret[0].(record.Log_BulkConsumeClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BulkConsume indicates an expected call of BulkConsume.
+func (mr *MockLogClientMockRecorder) BulkConsume(ctx, in any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BulkConsume", reflect.TypeOf((*MockLogClient)(nil).BulkConsume), varargs...)
+}
+
+// Consume mocks base method.
+func (m *MockLogClient) Consume(ctx context.Context, in *record.ConsumeRequest, opts ...grpc.CallOption) (*record.ConsumeResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Consume", varargs...)
+	ret0, _ := ret[0].(*record.ConsumeResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockLogClientMockRecorder) Consume(ctx, in any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockLogClient)(nil).Consume), varargs...)
+}
+
+// ConsumeStream mocks base method.
+func (m *MockLogClient) ConsumeStream(ctx context.Context, opts ...grpc.CallOption) (record.Log_ConsumeStreamClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ConsumeStream", varargs...)
+	ret0, _ := ret[0].(record.Log_ConsumeStreamClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeStream indicates an expected call of ConsumeStream.
+func (mr *MockLogClientMockRecorder) ConsumeStream(ctx any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeStream", reflect.TypeOf((*MockLogClient)(nil).ConsumeStream), varargs...)
+}
+
+// Produce mocks base method.
+func (m *MockLogClient) Produce(ctx context.Context, in *record.ProduceRequest, opts ...grpc.CallOption) (*record.ProduceResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Produce", varargs...)
+	ret0, _ := ret[0].(*record.ProduceResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Produce indicates an expected call of Produce.
+func (mr *MockLogClientMockRecorder) Produce(ctx, in any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Produce", reflect.TypeOf((*MockLogClient)(nil).Produce), varargs...)
+}
+
+// ProduceStream mocks base method.
+func (m *MockLogClient) ProduceStream(ctx context.Context, opts ...grpc.CallOption) (record.Log_ProduceStreamClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ProduceStream", varargs...)
+	ret0, _ := ret[0].(record.Log_ProduceStreamClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProduceStream indicates an expected call of ProduceStream.
+func (mr *MockLogClientMockRecorder) ProduceStream(ctx any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceStream", reflect.TypeOf((*MockLogClient)(nil).ProduceStream), varargs...)
+}
+
+// MockLog_ProduceStreamClient is a mock of Log_ProduceStreamClient interface.
+type MockLog_ProduceStreamClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockLog_ProduceStreamClientMockRecorder
+}
+
+// MockLog_ProduceStreamClientMockRecorder is the mock recorder for MockLog_ProduceStreamClient.
+type MockLog_ProduceStreamClientMockRecorder struct {
+	mock *MockLog_ProduceStreamClient
+}
+
+// NewMockLog_ProduceStreamClient creates a new mock instance.
+func NewMockLog_ProduceStreamClient(ctrl *gomock.Controller) *MockLog_ProduceStreamClient {
+	mock := &MockLog_ProduceStreamClient{ctrl: ctrl}
+	mock.recorder = &MockLog_ProduceStreamClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLog_ProduceStreamClient) EXPECT() *MockLog_ProduceStreamClientMockRecorder {
+	return m.recorder
+}
+
+// CloseSend mocks base method.
+func (m *MockLog_ProduceStreamClient) CloseSend() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseSend")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CloseSend indicates an expected call of CloseSend.
+func (mr *MockLog_ProduceStreamClientMockRecorder) CloseSend() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseSend", reflect.TypeOf((*MockLog_ProduceStreamClient)(nil).CloseSend))
+}
+
+// Context mocks base method.
+func (m *MockLog_ProduceStreamClient) Context() context.Context {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Context")
+	ret0, _ := ret[0].(context.Context)
+	return ret0
+}
+
+// Context indicates an expected call of Context.
+func (mr *MockLog_ProduceStreamClientMockRecorder) Context() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockLog_ProduceStreamClient)(nil).Context))
+}
+
+// Header mocks base method.
+func (m *MockLog_ProduceStreamClient) Header() (metadata.MD, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Header")
+	ret0, _ := ret[0].(metadata.MD)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Header indicates an expected call of Header.
+func (mr *MockLog_ProduceStreamClientMockRecorder) Header() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Header", reflect.TypeOf((*MockLog_ProduceStreamClient)(nil).Header))
+}
+
+// Recv mocks base method.
+func (m *MockLog_ProduceStreamClient) Recv() (*record.ProduceResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Recv")
+	ret0, _ := ret[0].(*record.ProduceResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Recv indicates an expected call of Recv.
+func (mr *MockLog_ProduceStreamClientMockRecorder) Recv() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Recv", reflect.TypeOf((*MockLog_ProduceStreamClient)(nil).Recv))
+}
+
+// RecvMsg mocks base method.
+func (m_2 *MockLog_ProduceStreamClient) RecvMsg(m any) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "RecvMsg", m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecvMsg indicates an expected call of RecvMsg.
+func (mr *MockLog_ProduceStreamClientMockRecorder) RecvMsg(m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecvMsg", reflect.TypeOf((*MockLog_ProduceStreamClient)(nil).RecvMsg), m)
+}
+
+// Send mocks base method.
+func (m *MockLog_ProduceStreamClient) Send(arg0 *record.ProduceRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockLog_ProduceStreamClientMockRecorder) Send(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockLog_ProduceStreamClient)(nil).Send), arg0)
+}
+
+// SendMsg mocks base method.
+func (m_2 *MockLog_ProduceStreamClient) SendMsg(m any) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "SendMsg", m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMsg indicates an expected call of SendMsg.
+func (mr *MockLog_ProduceStreamClientMockRecorder) SendMsg(m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMsg", reflect.TypeOf((*MockLog_ProduceStreamClient)(nil).SendMsg), m)
+}
+
+// Trailer mocks base method.
+func (m *MockLog_ProduceStreamClient) Trailer() metadata.MD {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Trailer")
+	ret0, _ := ret[0].(metadata.MD)
+	return ret0
+}
+
+// Trailer indicates an expected call of Trailer.
+func (mr *MockLog_ProduceStreamClientMockRecorder) Trailer() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Trailer", reflect.TypeOf((*MockLog_ProduceStreamClient)(nil).Trailer))
+}
+
+// MockLog_ConsumeStreamClient is a mock of Log_ConsumeStreamClient interface.
+type MockLog_ConsumeStreamClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockLog_ConsumeStreamClientMockRecorder
+}
+
+// MockLog_ConsumeStreamClientMockRecorder is the mock recorder for MockLog_ConsumeStreamClient.
+type MockLog_ConsumeStreamClientMockRecorder struct {
+	mock *MockLog_ConsumeStreamClient
+}
+
+// NewMockLog_ConsumeStreamClient creates a new mock instance.
+func NewMockLog_ConsumeStreamClient(ctrl *gomock.Controller) *MockLog_ConsumeStreamClient {
+	mock := &MockLog_ConsumeStreamClient{ctrl: ctrl}
+	mock.recorder = &MockLog_ConsumeStreamClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLog_ConsumeStreamClient) EXPECT() *MockLog_ConsumeStreamClientMockRecorder {
+	return m.recorder
+}
+
+// CloseSend mocks base method.
+func (m *MockLog_ConsumeStreamClient) CloseSend() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseSend")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CloseSend indicates an expected call of CloseSend.
+func (mr *MockLog_ConsumeStreamClientMockRecorder) CloseSend() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseSend", reflect.TypeOf((*MockLog_ConsumeStreamClient)(nil).CloseSend))
+}
+
+// Context mocks base method.
+func (m *MockLog_ConsumeStreamClient) Context() context.Context {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Context")
+	ret0, _ := ret[0].(context.Context)
+	return ret0
+}
+
+// Context indicates an expected call of Context.
+func (mr *MockLog_ConsumeStreamClientMockRecorder) Context() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockLog_ConsumeStreamClient)(nil).Context))
+}
+
+// Header mocks base method.
+func (m *MockLog_ConsumeStreamClient) Header() (metadata.MD, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Header")
+	ret0, _ := ret[0].(metadata.MD)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Header indicates an expected call of Header.
+func (mr *MockLog_ConsumeStreamClientMockRecorder) Header() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Header", reflect.TypeOf((*MockLog_ConsumeStreamClient)(nil).Header))
+}
+
+// Recv mocks base method.
+func (m *MockLog_ConsumeStreamClient) Recv() (*record.ConsumeResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Recv")
+	ret0, _ := ret[0].(*record.ConsumeResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Recv indicates an expected call of Recv.
+func (mr *MockLog_ConsumeStreamClientMockRecorder) Recv() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Recv", reflect.TypeOf((*MockLog_ConsumeStreamClient)(nil).Recv))
+}
+
+// RecvMsg mocks base method.
+func (m_2 *MockLog_ConsumeStreamClient) RecvMsg(m any) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "RecvMsg", m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecvMsg indicates an expected call of RecvMsg.
+func (mr *MockLog_ConsumeStreamClientMockRecorder) RecvMsg(m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecvMsg", reflect.TypeOf((*MockLog_ConsumeStreamClient)(nil).RecvMsg), m)
+}
+
+// Send mocks base method.
+func (m *MockLog_ConsumeStreamClient) Send(arg0 *record.ConsumeRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockLog_ConsumeStreamClientMockRecorder) Send(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockLog_ConsumeStreamClient)(nil).Send), arg0)
+}
+
+// SendMsg mocks base method.
+func (m_2 *MockLog_ConsumeStreamClient) SendMsg(m any) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "SendMsg", m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMsg indicates an expected call of SendMsg.
+func (mr *MockLog_ConsumeStreamClientMockRecorder) SendMsg(m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMsg", reflect.TypeOf((*MockLog_ConsumeStreamClient)(nil).SendMsg), m)
+}
+
+// Trailer mocks base method.
+func (m *MockLog_ConsumeStreamClient) Trailer() metadata.MD {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Trailer")
+	ret0, _ := ret[0].(metadata.MD)
+	return ret0
+}
+
+// Trailer indicates an expected call of Trailer.
+func (mr *MockLog_ConsumeStreamClientMockRecorder) Trailer() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Trailer", reflect.TypeOf((*MockLog_ConsumeStreamClient)(nil).Trailer))
+}
+
+// MockLog_BulkConsumeClient is a mock of Log_BulkConsumeClient interface.
+type MockLog_BulkConsumeClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockLog_BulkConsumeClientMockRecorder
+}
+
+// MockLog_BulkConsumeClientMockRecorder is the mock recorder for MockLog_BulkConsumeClient.
+type MockLog_BulkConsumeClientMockRecorder struct {
+	mock *MockLog_BulkConsumeClient
+}
+
+// NewMockLog_BulkConsumeClient creates a new mock instance.
+func NewMockLog_BulkConsumeClient(ctrl *gomock.Controller) *MockLog_BulkConsumeClient {
+	mock := &MockLog_BulkConsumeClient{ctrl: ctrl}
+	mock.recorder = &MockLog_BulkConsumeClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLog_BulkConsumeClient) EXPECT() *MockLog_BulkConsumeClientMockRecorder {
+	return m.recorder
+}
+
+// CloseSend mocks base method.
+func (m *MockLog_BulkConsumeClient) CloseSend() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseSend")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CloseSend indicates an expected call of CloseSend.
+func (mr *MockLog_BulkConsumeClientMockRecorder) CloseSend() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseSend", reflect.TypeOf((*MockLog_BulkConsumeClient)(nil).CloseSend))
+}
+
+// Context mocks base method.
+func (m *MockLog_BulkConsumeClient) Context() context.Context {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Context")
+	ret0, _ := ret[0].(context.Context)
+	return ret0
+}
+
+// Context indicates an expected call of Context.
+func (mr *MockLog_BulkConsumeClientMockRecorder) Context() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockLog_BulkConsumeClient)(nil).Context))
+}
+
+// Header mocks base method.
+func (m *MockLog_BulkConsumeClient) Header() (metadata.MD, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Header")
+	ret0, _ := ret[0].(metadata.MD)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Header indicates an expected call of Header.
+func (mr *MockLog_BulkConsumeClientMockRecorder) Header() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Header", reflect.TypeOf((*MockLog_BulkConsumeClient)(nil).Header))
+}
+
+// Recv mocks base method.
+func (m *MockLog_BulkConsumeClient) Recv() (*record.ConsumeResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Recv")
+	ret0, _ := ret[0].(*record.ConsumeResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Recv indicates an expected call of Recv.
+func (mr *MockLog_BulkConsumeClientMockRecorder) Recv() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Recv", reflect.TypeOf((*MockLog_BulkConsumeClient)(nil).Recv))
+}
+
+// RecvMsg mocks base method.
+func (m_2 *MockLog_BulkConsumeClient) RecvMsg(m any) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "RecvMsg", m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecvMsg indicates an expected call of RecvMsg.
+func (mr *MockLog_BulkConsumeClientMockRecorder) RecvMsg(m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecvMsg", reflect.TypeOf((*MockLog_BulkConsumeClient)(nil).RecvMsg), m)
+}
+
+// SendMsg mocks base method.
+func (m_2 *MockLog_BulkConsumeClient) SendMsg(m any) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "SendMsg", m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMsg indicates an expected call of SendMsg.
+func (mr *MockLog_BulkConsumeClientMockRecorder) SendMsg(m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMsg", reflect.TypeOf((*MockLog_BulkConsumeClient)(nil).SendMsg), m)
+}
+
+// Trailer mocks base method.
+func (m *MockLog_BulkConsumeClient) Trailer() metadata.MD {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Trailer")
+	ret0, _ := ret[0].(metadata.MD)
+	return ret0
+}
+
+// Trailer indicates an expected call of Trailer.
+func (mr *MockLog_BulkConsumeClientMockRecorder) Trailer() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Trailer", reflect.TypeOf((*MockLog_BulkConsumeClient)(nil).Trailer))
+}
+
+// MockLogServer is a mock of LogServer interface.
+type MockLogServer struct {
+	ctrl     *gomock.Controller
+	recorder *MockLogServerMockRecorder
+}
+
+// MockLogServerMockRecorder is the mock recorder for MockLogServer.
+type MockLogServerMockRecorder struct {
+	mock *MockLogServer
+}
+
+// NewMockLogServer creates a new mock instance.
+func NewMockLogServer(ctrl *gomock.Controller) *MockLogServer {
+	mock := &MockLogServer{ctrl: ctrl}
+	mock.recorder = &MockLogServerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLogServer) EXPECT() *MockLogServerMockRecorder {
+	return m.recorder
+}
+
+// BulkConsume mocks base method.
+func (m *MockLogServer) BulkConsume(arg0 *record.ConsumeRangeRequest, arg1 record.Log_BulkConsumeServer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BulkConsume", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BulkConsume indicates an expected call of BulkConsume.
+func (mr *MockLogServerMockRecorder) BulkConsume(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BulkConsume", reflect.TypeOf((*MockLogServer)(nil).BulkConsume), arg0, arg1)
+}
+
+// Consume mocks base method.
+func (m *MockLogServer) Consume(arg0 context.Context, arg1 *record.ConsumeRequest) (*record.ConsumeResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", arg0, arg1)
+	ret0, _ := ret[0].(*record.ConsumeResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockLogServerMockRecorder) Consume(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockLogServer)(nil).Consume), arg0, arg1)
+}
+
+// ConsumeStream mocks base method.
+func (m *MockLogServer) ConsumeStream(arg0 record.Log_ConsumeStreamServer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeStream", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConsumeStream indicates an expected call of ConsumeStream.
+func (mr *MockLogServerMockRecorder) ConsumeStream(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeStream", reflect.TypeOf((*MockLogServer)(nil).ConsumeStream), arg0)
+}
+
+// Produce mocks base method.
+func (m *MockLogServer) Produce(arg0 context.Context, arg1 *record.ProduceRequest) (*record.ProduceResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Produce", arg0, arg1)
+	ret0, _ := ret[0].(*record.ProduceResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Produce indicates an expected call of Produce.
+func (mr *MockLogServerMockRecorder) Produce(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Produce", reflect.TypeOf((*MockLogServer)(nil).Produce), arg0, arg1)
+}
+
+// ProduceStream mocks base method.
+func (m *MockLogServer) ProduceStream(arg0 record.Log_ProduceStreamServer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProduceStream", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ProduceStream indicates an expected call of ProduceStream.
+func (mr *MockLogServerMockRecorder) ProduceStream(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceStream", reflect.TypeOf((*MockLogServer)(nil).ProduceStream), arg0)
+}
+
+// mustEmbedUnimplementedLogServer mocks base method.
+func (m *MockLogServer) mustEmbedUnimplementedLogServer() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "mustEmbedUnimplementedLogServer")
+}
+
+// mustEmbedUnimplementedLogServer indicates an expected call of mustEmbedUnimplementedLogServer.
+func (mr *MockLogServerMockRecorder) mustEmbedUnimplementedLogServer() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "mustEmbedUnimplementedLogServer", reflect.TypeOf((*MockLogServer)(nil).mustEmbedUnimplementedLogServer))
+}
+
+// MockUnsafeLogServer is a mock of UnsafeLogServer interface.
+type MockUnsafeLogServer struct {
+	ctrl     *gomock.Controller
+	recorder *MockUnsafeLogServerMockRecorder
+}
+
+// MockUnsafeLogServerMockRecorder is the mock recorder for MockUnsafeLogServer.
+type MockUnsafeLogServerMockRecorder struct {
+	mock *MockUnsafeLogServer
+}
+
+// NewMockUnsafeLogServer creates a new mock instance.
+func NewMockUnsafeLogServer(ctrl *gomock.Controller) *MockUnsafeLogServer {
+	mock := &MockUnsafeLogServer{ctrl: ctrl}
+	mock.recorder = &MockUnsafeLogServerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUnsafeLogServer) EXPECT() *MockUnsafeLogServerMockRecorder {
+	return m.recorder
+}
+
+// mustEmbedUnimplementedLogServer mocks base method.
+func (m *MockUnsafeLogServer) mustEmbedUnimplementedLogServer() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "mustEmbedUnimplementedLogServer")
+}
+
+// mustEmbedUnimplementedLogServer indicates an expected call of mustEmbedUnimplementedLogServer.
+func (mr *MockUnsafeLogServerMockRecorder) mustEmbedUnimplementedLogServer() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "mustEmbedUnimplementedLogServer", reflect.TypeOf((*MockUnsafeLogServer)(nil).mustEmbedUnimplementedLogServer))
+}
+
+// MockLog_ProduceStreamServer is a mock of Log_ProduceStreamServer interface.
+type MockLog_ProduceStreamServer struct {
+	ctrl     *gomock.Controller
+	recorder *MockLog_ProduceStreamServerMockRecorder
+}
+
+// MockLog_ProduceStreamServerMockRecorder is the mock recorder for MockLog_ProduceStreamServer.
+type MockLog_ProduceStreamServerMockRecorder struct {
+	mock *MockLog_ProduceStreamServer
+}
+
+// NewMockLog_ProduceStreamServer creates a new mock instance.
+func NewMockLog_ProduceStreamServer(ctrl *gomock.Controller) *MockLog_ProduceStreamServer {
+	mock := &MockLog_ProduceStreamServer{ctrl: ctrl}
+	mock.recorder = &MockLog_ProduceStreamServerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLog_ProduceStreamServer) EXPECT() *MockLog_ProduceStreamServerMockRecorder {
+	return m.recorder
+}
+
+// Context mocks base method.
+func (m *MockLog_ProduceStreamServer) Context() context.Context {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Context")
+	ret0, _ := ret[0].(context.Context)
+	return ret0
+}
+
+// Context indicates an expected call of Context.
+func (mr *MockLog_ProduceStreamServerMockRecorder) Context() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockLog_ProduceStreamServer)(nil).Context))
+}
+
+// Recv mocks base method.
+func (m *MockLog_ProduceStreamServer) Recv() (*record.ProduceRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Recv")
+	ret0, _ := ret[0].(*record.ProduceRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Recv indicates an expected call of Recv.
+func (mr *MockLog_ProduceStreamServerMockRecorder) Recv() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Recv", reflect.TypeOf((*MockLog_ProduceStreamServer)(nil).Recv))
+}
+
+// RecvMsg mocks base method.
+func (m_2 *MockLog_ProduceStreamServer) RecvMsg(m any) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "RecvMsg", m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecvMsg indicates an expected call of RecvMsg.
+func (mr *MockLog_ProduceStreamServerMockRecorder) RecvMsg(m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecvMsg", reflect.TypeOf((*MockLog_ProduceStreamServer)(nil).RecvMsg), m)
+}
+
+// Send mocks base method.
+func (m *MockLog_ProduceStreamServer) Send(arg0 *record.ProduceResponse) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockLog_ProduceStreamServerMockRecorder) Send(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockLog_ProduceStreamServer)(nil).Send), arg0)
+}
+
+// SendHeader mocks base method.
+func (m *MockLog_ProduceStreamServer) SendHeader(arg0 metadata.MD) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendHeader", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendHeader indicates an expected call of SendHeader.
+func (mr *MockLog_ProduceStreamServerMockRecorder) SendHeader(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendHeader", reflect.TypeOf((*MockLog_ProduceStreamServer)(nil).SendHeader), arg0)
+}
+
+// SendMsg mocks base method.
+func (m_2 *MockLog_ProduceStreamServer) SendMsg(m any) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "SendMsg", m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMsg indicates an expected call of SendMsg.
+func (mr *MockLog_ProduceStreamServerMockRecorder) SendMsg(m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMsg", reflect.TypeOf((*MockLog_ProduceStreamServer)(nil).SendMsg), m)
+}
+
+// SetHeader mocks base method.
+func (m *MockLog_ProduceStreamServer) SetHeader(arg0 metadata.MD) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetHeader", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetHeader indicates an expected call of SetHeader.
+func (mr *MockLog_ProduceStreamServerMockRecorder) SetHeader(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetHeader", reflect.TypeOf((*MockLog_ProduceStreamServer)(nil).SetHeader), arg0)
+}
+
+// SetTrailer mocks base method.
+func (m *MockLog_ProduceStreamServer) SetTrailer(arg0 metadata.MD) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetTrailer", arg0)
+}
+
+// SetTrailer indicates an expected call of SetTrailer.
+func (mr *MockLog_ProduceStreamServerMockRecorder) SetTrailer(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTrailer", reflect.TypeOf((*MockLog_ProduceStreamServer)(nil).SetTrailer), arg0)
+}
+
+// MockLog_ConsumeStreamServer is a mock of Log_ConsumeStreamServer interface.
+type MockLog_ConsumeStreamServer struct {
+	ctrl     *gomock.Controller
+	recorder *MockLog_ConsumeStreamServerMockRecorder
+}
+
+// MockLog_ConsumeStreamServerMockRecorder is the mock recorder for MockLog_ConsumeStreamServer.
+type MockLog_ConsumeStreamServerMockRecorder struct {
+	mock *MockLog_ConsumeStreamServer
+}
+
+// NewMockLog_ConsumeStreamServer creates a new mock instance.
+func NewMockLog_ConsumeStreamServer(ctrl *gomock.Controller) *MockLog_ConsumeStreamServer {
+	mock := &MockLog_ConsumeStreamServer{ctrl: ctrl}
+	mock.recorder = &MockLog_ConsumeStreamServerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLog_ConsumeStreamServer) EXPECT() *MockLog_ConsumeStreamServerMockRecorder {
+	return m.recorder
+}
+
+// Context mocks base method.
+func (m *MockLog_ConsumeStreamServer) Context() context.Context {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Context")
+	ret0, _ := ret[0].(context.Context)
+	return ret0
+}
+
+// Context indicates an expected call of Context.
+func (mr *MockLog_ConsumeStreamServerMockRecorder) Context() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockLog_ConsumeStreamServer)(nil).Context))
+}
+
+// Recv mocks base method.
+func (m *MockLog_ConsumeStreamServer) Recv() (*record.ConsumeRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Recv")
+	ret0, _ := ret[0].(*record.ConsumeRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Recv indicates an expected call of Recv.
+func (mr *MockLog_ConsumeStreamServerMockRecorder) Recv() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Recv", reflect.TypeOf((*MockLog_ConsumeStreamServer)(nil).Recv))
+}
+
+// RecvMsg mocks base method.
+func (m_2 *MockLog_ConsumeStreamServer) RecvMsg(m any) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "RecvMsg", m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecvMsg indicates an expected call of RecvMsg.
+func (mr *MockLog_ConsumeStreamServerMockRecorder) RecvMsg(m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecvMsg", reflect.TypeOf((*MockLog_ConsumeStreamServer)(nil).RecvMsg), m)
+}
+
+// Send mocks base method.
+func (m *MockLog_ConsumeStreamServer) Send(arg0 *record.ConsumeResponse) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockLog_ConsumeStreamServerMockRecorder) Send(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockLog_ConsumeStreamServer)(nil).Send), arg0)
+}
+
+// SendHeader mocks base method.
+func (m *MockLog_ConsumeStreamServer) SendHeader(arg0 metadata.MD) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendHeader", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendHeader indicates an expected call of SendHeader.
+func (mr *MockLog_ConsumeStreamServerMockRecorder) SendHeader(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendHeader", reflect.TypeOf((*MockLog_ConsumeStreamServer)(nil).SendHeader), arg0)
+}
+
+// SendMsg mocks base method.
+func (m_2 *MockLog_ConsumeStreamServer) SendMsg(m any) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "SendMsg", m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMsg indicates an expected call of SendMsg.
+func (mr *MockLog_ConsumeStreamServerMockRecorder) SendMsg(m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMsg", reflect.TypeOf((*MockLog_ConsumeStreamServer)(nil).SendMsg), m)
+}
+
+// SetHeader mocks base method.
+func (m *MockLog_ConsumeStreamServer) SetHeader(arg0 metadata.MD) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetHeader", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetHeader indicates an expected call of SetHeader.
+func (mr *MockLog_ConsumeStreamServerMockRecorder) SetHeader(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetHeader", reflect.TypeOf((*MockLog_ConsumeStreamServer)(nil).SetHeader), arg0)
+}
+
+// SetTrailer mocks base method.
+func (m *MockLog_ConsumeStreamServer) SetTrailer(arg0 metadata.MD) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetTrailer", arg0)
+}
+
+// SetTrailer indicates an expected call of SetTrailer.
+func (mr *MockLog_ConsumeStreamServerMockRecorder) SetTrailer(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTrailer", reflect.TypeOf((*MockLog_ConsumeStreamServer)(nil).SetTrailer), arg0)
+}
+
+// MockLog_BulkConsumeServer is a mock of Log_BulkConsumeServer interface.
+type MockLog_BulkConsumeServer struct {
+	ctrl     *gomock.Controller
+	recorder *MockLog_BulkConsumeServerMockRecorder
+}
+
+// MockLog_BulkConsumeServerMockRecorder is the mock recorder for MockLog_BulkConsumeServer.
+type MockLog_BulkConsumeServerMockRecorder struct {
+	mock *MockLog_BulkConsumeServer
+}
+
+// NewMockLog_BulkConsumeServer creates a new mock instance.
+func NewMockLog_BulkConsumeServer(ctrl *gomock.Controller) *MockLog_BulkConsumeServer {
+	mock := &MockLog_BulkConsumeServer{ctrl: ctrl}
+	mock.recorder = &MockLog_BulkConsumeServerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLog_BulkConsumeServer) EXPECT() *MockLog_BulkConsumeServerMockRecorder {
+	return m.recorder
+}
+
+// Context mocks base method.
+func (m *MockLog_BulkConsumeServer) Context() context.Context {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Context")
+	ret0, _ := ret[0].(context.Context)
+	return ret0
+}
+
+// Context indicates an expected call of Context.
+func (mr *MockLog_BulkConsumeServerMockRecorder) Context() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockLog_BulkConsumeServer)(nil).Context))
+}
+
+// RecvMsg mocks base method.
+func (m_2 *MockLog_BulkConsumeServer) RecvMsg(m any) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "RecvMsg", m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecvMsg indicates an expected call of RecvMsg.
+func (mr *MockLog_BulkConsumeServerMockRecorder) RecvMsg(m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecvMsg", reflect.TypeOf((*MockLog_BulkConsumeServer)(nil).RecvMsg), m)
+}
+
+// Send mocks base method.
+func (m *MockLog_BulkConsumeServer) Send(arg0 *record.ConsumeResponse) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockLog_BulkConsumeServerMockRecorder) Send(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockLog_BulkConsumeServer)(nil).Send), arg0)
+}
+
+// SendHeader mocks base method.
+func (m *MockLog_BulkConsumeServer) SendHeader(arg0 metadata.MD) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendHeader", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendHeader indicates an expected call of SendHeader.
+func (mr *MockLog_BulkConsumeServerMockRecorder) SendHeader(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendHeader", reflect.TypeOf((*MockLog_BulkConsumeServer)(nil).SendHeader), arg0)
+}
+
+// SendMsg mocks base method.
+func (m_2 *MockLog_BulkConsumeServer) SendMsg(m any) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "SendMsg", m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMsg indicates an expected call of SendMsg.
+func (mr *MockLog_BulkConsumeServerMockRecorder) SendMsg(m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMsg", reflect.TypeOf((*MockLog_BulkConsumeServer)(nil).SendMsg), m)
+}
+
+// SetHeader mocks base method.
+func (m *MockLog_BulkConsumeServer) SetHeader(arg0 metadata.MD) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetHeader", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetHeader indicates an expected call of SetHeader.
+func (mr *MockLog_BulkConsumeServerMockRecorder) SetHeader(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetHeader", reflect.TypeOf((*MockLog_BulkConsumeServer)(nil).SetHeader), arg0)
+}
+
+// SetTrailer mocks base method.
+func (m *MockLog_BulkConsumeServer) SetTrailer(arg0 metadata.MD) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetTrailer", arg0)
+}
+
+// SetTrailer indicates an expected call of SetTrailer.
+func (mr *MockLog_BulkConsumeServerMockRecorder) SetTrailer(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTrailer", reflect.TypeOf((*MockLog_BulkConsumeServer)(nil).SetTrailer), arg0)
+}
@@ -0,0 +1,33 @@
+//go:build linux
+
+package server
+
+import (
+	"net"
+	"syscall"
+)
+
+const peerCredSupported = true
+
+// readPeerCred reads the credentials of the process on the other end of conn with SO_PEERCRED
+func readPeerCred(conn *net.UnixConn) (PeerCredInfo, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return PeerCredInfo{}, err
+	}
+
+	var (
+		cred    *syscall.Ucred
+		credErr error
+	)
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return PeerCredInfo{}, err
+	}
+	if credErr != nil {
+		return PeerCredInfo{}, credErr
+	}
+
+	return PeerCredInfo{UID: cred.Uid, GID: cred.Gid, PID: cred.Pid}, nil
+}
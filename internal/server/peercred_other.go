@@ -0,0 +1,15 @@
+//go:build !linux
+
+package server
+
+import (
+	"errors"
+	"net"
+)
+
+const peerCredSupported = false
+
+// readPeerCred reports that peer credentials are unavailable, WithPeerAuthority refuses to be configured here
+func readPeerCred(conn *net.UnixConn) (PeerCredInfo, error) {
+	return PeerCredInfo{}, errors.New("peer credentials are only supported on Linux")
+}
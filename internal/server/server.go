@@ -3,14 +3,27 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
 
 	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/backoff"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/status"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
+//go:generate mockgen -source=server.go -destination=mock_commit_log_test.go -package=server
+//go:generate mockgen -source=../../api/record_grpc.pb.go -destination=mock_log_test.go -package=server
+
 // CommitLog defines the interface for a commit log system.
 // It's designed to abstract the underlying operations of appending to
 // and reading from a log, allowing for different implementations that
@@ -29,18 +42,91 @@ type CommitLog interface {
 	Read(uint64) (*api.Record, error)
 }
 
-// Config represents the configuration for the server
+// OffsetAppender is implemented by commit logs that can append a record only at an expected offset.
+// Produce needs it to honour ProduceRequest.RequiredOffset.
+type OffsetAppender interface {
+	// AppendAt appends the record only if it lands at the given offset,
+	// and otherwise returns an error carrying the Aborted status.
+	AppendAt(*api.Record, uint64) (uint64, error)
+}
+
+// BatchAppender is implemented by commit logs that can append several records at once.
+// ProduceStream appends every record a client has sent so far in one batch when the commit log is one.
+type BatchAppender interface {
+	// AppendBatch appends the records in order and returns their offsets.
+	// On error, the offsets of the records appended before it are returned along with it.
+	AppendBatch([]*api.Record) ([]uint64, error)
+}
+
+// ContextLog is implemented by commit logs that can trace an append or read as part of the caller's trace.
+// Produce and Consume pass their spans on to the commit log when it is one.
+type ContextLog interface {
+	AppendContext(context.Context, *api.Record) (uint64, error)
+	ReadContext(context.Context, uint64) (*api.Record, error)
+}
+
+// OffsetBounds is implemented by commit logs that know their highest offset.
+// BulkConsume needs it to tell records removed from the middle of the log from a range past its end.
+type OffsetBounds interface {
+	HighestOffset() (uint64, error)
+}
+
+// Most records ProduceStream appends in one batch
+const produceStreamBatch = 64
+
+// Config represents the configuration for the server.
+// GetCommitLog and SetCommitLog are safe for concurrent use, so the commit log can be swapped while RPCs
+// are being served. CommitLog itself may only be set directly before the server starts serving.
 type Config struct {
+	mutex     sync.RWMutex
 	CommitLog CommitLog
 }
 
+// GetCommitLog returns the commit log the server reads from and appends to
+func (c *Config) GetCommitLog() CommitLog {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.CommitLog
+}
+
+// SetCommitLog replaces the commit log the server reads from and appends to
+func (c *Config) SetCommitLog(cl CommitLog) error {
+	if cl == nil {
+		return errors.New("CommitLog cannot be nil")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.CommitLog = cl
+	return nil
+}
+
 // Ensure grpcServer implements the LogServer interface
 var _ api.LogServer = (*grpcServer)(nil)
 
 // grpcServer wraps the gRPC server and its configuration
 type grpcServer struct {
 	api.UnimplementedLogServer
-	*Config
+
+	config *Config
+
+	server      *grpc.Server
+	stopTimeout time.Duration
+	tracer      trace.Tracer
+
+	// Options for the gRPC server built by NewServer
+	serverOpts []grpc.ServerOption
+
+	// Largest record value Produce accepts, zero means no limit
+	maxRecordBytes uint64
+
+	// Builds the wait ConsumeStream uses between polls once it has caught up with the log, one per stream
+	newPollBackoff func() backoff.BackOff
+
+	// Unix socket Serve listens on, see WithUnixSocket
+	socketPath  string
+	socketPerms os.FileMode
+	listener    net.Listener
 }
 
 // Option defines a function signature for configuring the grpcServer
@@ -51,10 +137,83 @@ type Option func(*grpcServer) error
 func WithCommitLog(cl CommitLog) Option {
 	// return function handle assigning fields
 	return func(s *grpcServer) error {
-		if cl == nil {
-			return errors.New("CommitLog cannot be nil")
+		return s.config.SetCommitLog(cl)
+	}
+}
+
+// Bounds how long Stop waits for in-flight RPCs to drain before forcing the server closed.
+// A zero duration waits until the context passed to Stop is done.
+func WithGracefulStopTimeout(d time.Duration) Option {
+	return func(s *grpcServer) error {
+		if d < 0 {
+			return errors.New("graceful stop timeout cannot be negative")
+		}
+		s.stopTimeout = d
+		return nil
+	}
+}
+
+// Rejects produced records whose value is larger than n bytes with InvalidArgument,
+// so a single oversized record cannot exhaust the server's memory.
+func WithMaxRecordBytes(n uint64) Option {
+	return func(s *grpcServer) error {
+		if n == 0 {
+			return errors.New("max record bytes must be greater than zero")
+		}
+		s.maxRecordBytes = n
+		return nil
+	}
+}
+
+// Sets how long ConsumeStream waits between polls once it has caught up with the log.
+// newBackOff is called once per stream, so streams back off independently of each other,
+// and the wait is reset every time the stream sends a record. The default is a constant 10ms.
+func WithPollBackoff(newBackOff func() backoff.BackOff) Option {
+	return func(s *grpcServer) error {
+		if newBackOff == nil {
+			return errors.New("poll backoff cannot be nil")
 		}
-		s.Config.CommitLog = cl
+		s.newPollBackoff = newBackOff
+		return nil
+	}
+}
+
+// Listens on a Unix domain socket at path, for lower latency between processes on the same host.
+// A stale socket file left at path by an unclean shutdown is removed before binding, while a socket
+// another server is still listening on makes NewGRPCServer fail.
+// Call Serve to start accepting connections.
+func WithUnixSocket(path string) Option {
+	return func(s *grpcServer) error {
+		if path == "" {
+			return errors.New("unix socket path cannot be empty")
+		}
+		s.socketPath = path
+		return nil
+	}
+}
+
+// Sets the file permissions of the socket created by WithUnixSocket. The default is 0660.
+func WithSocketPermissions(mode os.FileMode) Option {
+	return func(s *grpcServer) error {
+		s.socketPerms = mode
+		return nil
+	}
+}
+
+// Closes connections that have had no RPCs in flight for d by sending them a GOAWAY.
+// The server also pings connections after d without activity and drops them if the ping is not
+// answered within d, which frees the streams of clients that crashed without closing them.
+// Only takes effect on gRPC servers created with NewServer.
+func WithConnectionIdleTimeout(d time.Duration) Option {
+	return func(s *grpcServer) error {
+		if d <= 0 {
+			return errors.New("connection idle timeout must be positive")
+		}
+		s.serverOpts = append(s.serverOpts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle: d,
+			Time:              d,
+			Timeout:           d,
+		}))
 		return nil
 	}
 }
@@ -65,7 +224,12 @@ func NewGRPCServer(opts ...Option) (*grpcServer, error) {
 
 	// Initialize the server with default configuration
 	srv := &grpcServer{
-		Config: &Config{},
+		config:      &Config{},
+		tracer:      defaultTracer(),
+		socketPerms: 0660,
+		newPollBackoff: func() backoff.BackOff {
+			return backoff.ConstantBackOff(10 * time.Millisecond)
+		},
 	}
 
 	// Apply each Option passed to the function
@@ -76,16 +240,124 @@ func NewGRPCServer(opts ...Option) (*grpcServer, error) {
 		}
 	}
 
+	// Bind the Unix socket once every option is known, with a gRPC server ready to Serve on it
+	if srv.socketPath != "" {
+		if err := srv.listenUnix(); err != nil {
+			return nil, err
+		}
+		srv.NewServer()
+	}
+
 	return srv, nil
 }
 
+// Config returns the server's configuration
+func (s *grpcServer) Config() *Config {
+	return s.config
+}
+
+// listenUnix binds the Unix socket configured by WithUnixSocket
+func (s *grpcServer) listenUnix() error {
+	// A socket file left behind by a crashed server would make the bind fail,
+	// but one a running server still accepts connections on is left alone
+	if info, err := os.Lstat(s.socketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s exists and is not a socket", s.socketPath)
+		}
+		if conn, err := net.DialTimeout("unix", s.socketPath, time.Second); err == nil {
+			conn.Close()
+			return fmt.Errorf("%s is in use by another server", s.socketPath)
+		}
+		if err := os.Remove(s.socketPath); err != nil {
+			return err
+		}
+	}
+
+	// The umask is process wide, so rather than changing it for everyone the permissions are set right after binding
+	lis, err := net.Listen("unix", s.socketPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(s.socketPath, s.socketPerms); err != nil {
+		lis.Close()
+		return err
+	}
+
+	s.listener = lis
+	return nil
+}
+
+// Serve accepts connections on the listener created by WithUnixSocket until the server is stopped.
+// Connections are handled by the most recently registered gRPC server, which is one created by
+// NewGRPCServer unless NewServer or Register was called since.
+func (s *grpcServer) Serve() error {
+	if s.listener == nil {
+		return errors.New("no listener configured")
+	}
+	return s.server.Serve(s.listener)
+}
+
+// Register attaches the log service to a gRPC server and remembers it so Stop can shut it down.
+func (s *grpcServer) Register(server *grpc.Server) {
+	api.RegisterLogServer(server, s)
+	s.server = server
+}
+
+// NewServer creates a gRPC server with the options configured on s followed by opts,
+// and registers the log service on it.
+func (s *grpcServer) NewServer(opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(append(append([]grpc.ServerOption{}, s.serverOpts...), opts...)...)
+	s.Register(server)
+	return server
+}
+
+// Stop gracefully drains the registered gRPC server. If the drain outlives the configured
+// stop timeout or ctx, the server is stopped forcefully and the corresponding error is returned.
+func (s *grpcServer) Stop(ctx context.Context) error {
+	if s.server == nil {
+		return errors.New("no gRPC server registered")
+	}
+
+	// GracefulStop blocks until every stream finishes, so run it on the side
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	// A nil channel never fires, which means no timeout was configured
+	var timeout <-chan time.Time
+	if s.stopTimeout > 0 {
+		timer := time.NewTimer(s.stopTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-stopped:
+		return nil
+	case <-timeout:
+		s.server.Stop()
+		return context.DeadlineExceeded
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
+
 // Produce handles the gRPC call for producing (appending) a record to the commit log
-func (s *grpcServer) Produce(ctx context.Context, req *api.ProduceRequest) (*api.ProduceResponse, error) {
+func (s *grpcServer) Produce(ctx context.Context, req *api.ProduceRequest) (res *api.ProduceResponse, err error) {
+	ctx, span := s.tracer.Start(ctx, "log.Produce")
+	defer func() { endSpan(span, res.GetOffset(), err) }()
+
 	// Validate the incoming request
 	if req == nil || req.Record == nil {
 		log.Println("Invalid request: request or request record is nil")
 		return nil, status.Errorf(codes.InvalidArgument, "request and request record must not be nil")
 	}
+	if err := s.checkRecordSize(req.Record); err != nil {
+		return nil, err
+	}
 
 	// Use the context to support cancellation and deadlines
 	select {
@@ -96,8 +368,16 @@ func (s *grpcServer) Produce(ctx context.Context, req *api.ProduceRequest) (*api
 		// Continue if the context is not done
 	}
 
+	// Store the request's headers with the record so they come back out when it is consumed
+	mergeHeaders(req)
+
+	// A required offset turns the append into a compare-and-swap
+	if req.RequiredOffset != nil {
+		return s.produceAt(req.Record, *req.RequiredOffset)
+	}
+
 	// Append the record contained in the request to the commit log
-	offset, err := s.CommitLog.Append(req.Record) // Assuming Append supports context
+	offset, err := s.append(ctx, req.Record)
 	if err != nil {
 		log.Printf("Error appending to commit log: %v", err)
 		return nil, status.Errorf(codes.Internal, "error appending to commit log: %v", err)
@@ -109,80 +389,399 @@ func (s *grpcServer) Produce(ctx context.Context, req *api.ProduceRequest) (*api
 	return response, nil
 }
 
+// mergeHeaders copies the request's headers onto its record, overriding the record's own
+func mergeHeaders(req *api.ProduceRequest) {
+	if len(req.Headers) == 0 {
+		return
+	}
+	if req.Record.Headers == nil {
+		req.Record.Headers = make(map[string][]byte, len(req.Headers))
+	}
+	for key, value := range req.Headers {
+		req.Record.Headers[key] = value
+	}
+}
+
+// append appends record to the commit log, as part of the span in ctx when the commit log is a ContextLog
+func (s *grpcServer) append(ctx context.Context, record *api.Record) (uint64, error) {
+	if clog, ok := s.config.GetCommitLog().(ContextLog); ok {
+		return clog.AppendContext(ctx, record)
+	}
+	return s.config.GetCommitLog().Append(record)
+}
+
+// produceAt appends record only if it lands at offset
+func (s *grpcServer) produceAt(record *api.Record, offset uint64) (*api.ProduceResponse, error) {
+	appender, ok := s.config.GetCommitLog().(OffsetAppender)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "commit log does not support required offsets")
+	}
+
+	off, err := appender.AppendAt(record, offset)
+	if err != nil {
+		// Conflicts carry their own Aborted status, anything else is a failure of the log
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		log.Printf("Error appending to commit log: %v", err)
+		return nil, status.Errorf(codes.Internal, "error appending to commit log: %v", err)
+	}
+
+	log.Printf("Record appended to commit log at offset %d", off)
+	return &api.ProduceResponse{Offset: off}, nil
+}
+
+// checkRecordSize rejects a record whose value is over the configured maximum
+func (s *grpcServer) checkRecordSize(record *api.Record) error {
+	if s.maxRecordBytes > 0 && uint64(len(record.GetValue())) > s.maxRecordBytes {
+		return status.Errorf(codes.InvalidArgument, "record value exceeds maximum size %d", s.maxRecordBytes)
+	}
+	return nil
+}
+
 func (s *grpcServer) ProduceStream(stream api.Log_ProduceStreamServer) error {
+	// One span covers the whole stream, every produced record gets a child span
+	ctx, span := s.tracer.Start(stream.Context(), "log.ProduceStream")
+	defer span.End()
+
+	// Receive in the background, so the requests that pile up while a batch is appended make up the next one
+	requests := make(chan *api.ProduceRequest, produceStreamBatch)
+	recvErr := make(chan error, 1)
+	go func() {
+		defer close(requests)
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+
+			// Log the received request for debugging purposes
+			log.Printf("Received request: %v\n", req)
+
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
 	for {
-		// Attempt to receive a message from the stream
-		req, err := stream.Recv()
-		if err != nil {
-			if err == io.EOF {
-				log.Println("Stream closed by client")
-				return nil
+		// Stop as soon as the client cancels the stream or its deadline passes
+		var req *api.ProduceRequest
+		var ok bool
+		select {
+		case <-ctx.Done():
+			log.Println("Produce stream cancelled or deadline exceeded")
+			return ctx.Err()
+		case req, ok = <-requests:
+		}
+
+		// Every request received has been handled, find out why receiving stopped
+		if !ok {
+			select {
+			case err := <-recvErr:
+				if err == io.EOF {
+					log.Println("Stream closed by client")
+					return nil
+				}
+				log.Printf("Error receiving from stream: %v\n", err)
+				return status.Errorf(codes.Unknown, "Error receiving from stream: %v", err)
+			default:
+				return ctx.Err()
 			}
-			log.Printf("Error receiving from stream: %v\n", err)
-			return status.Errorf(codes.Unknown, "Error receiving from stream: %v", err)
 		}
 
-		// Log the received request for debugging purposes
-		log.Printf("Received request: %v\n", req)
+		// Take along every request that is already waiting
+		batch := []*api.ProduceRequest{req}
+	waiting:
+		for len(batch) < produceStreamBatch {
+			select {
+			case req, ok := <-requests:
+				if !ok {
+					break waiting
+				}
+				batch = append(batch, req)
+			default:
+				break waiting
+			}
+		}
+
+		// Append the records, every response carries the offset its record landed at.
+		// Records appended before a failure are still answered before the stream ends.
+		responses, err := s.produceBatch(ctx, batch)
+		for _, res := range responses {
+			if err := stream.Send(res); err != nil {
+				log.Printf("Error sending to stream: %v\n", err)
+				return status.Errorf(codes.Unknown, "Error sending to stream: %v", err)
+			}
+
+			// Log the sent response for debugging purposes
+			log.Printf("Sent response: %v\n", res)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// produceBatch appends the records of requests received on a produce stream in order, and returns a response for
+// every record appended. Consecutive records go to the commit log in one batch when it is a BatchAppender, while
+// required offsets, and commit logs that cannot append batches, go through Produce one record at a time.
+// On error, the responses of the records appended before it are returned along with it.
+func (s *grpcServer) produceBatch(ctx context.Context, batch []*api.ProduceRequest) ([]*api.ProduceResponse, error) {
+	appender, batches := s.config.GetCommitLog().(BatchAppender)
+
+	var responses []*api.ProduceResponse
+	var pending []*api.Record
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		spans := make([]trace.Span, len(pending))
+		for i := range pending {
+			_, spans[i] = s.tracer.Start(ctx, "log.Produce")
+		}
+
+		offsets, err := appender.AppendBatch(pending)
+		for i, span := range spans {
+			if i < len(offsets) {
+				endSpan(span, offsets[i], nil)
+				responses = append(responses, &api.ProduceResponse{Offset: offsets[i]})
+			} else {
+				endSpan(span, 0, err)
+			}
+		}
+		pending = nil
 
-		// Call the Produce method to process the received request
-		res, err := s.Produce(stream.Context(), req)
 		if err != nil {
 			log.Printf("Error producing message: %v\n", err)
 			return status.Errorf(codes.Internal, "Error producing message: %v", err)
 		}
+		return nil
+	}
+
+	for _, req := range batch {
+		// Oversized records end the stream with the same error Produce gives
+		if err := s.checkRecordSize(req.GetRecord()); err != nil {
+			if flushErr := flush(); flushErr != nil {
+				return responses, flushErr
+			}
+			return responses, err
+		}
 
-		// Attempt to send the response back to the client
-		if err = stream.Send(res); err != nil {
-			log.Printf("Error sending to stream: %v\n", err)
-			return status.Errorf(codes.Unknown, "Error sending to stream: %v", err)
+		if batches && req.Record != nil && req.RequiredOffset == nil {
+			mergeHeaders(req)
+			pending = append(pending, req.Record)
+			continue
 		}
 
-		// Log the sent response for debugging purposes
-		log.Printf("Sent response: %v\n", res)
+		// Everything else keeps its place in the order
+		if err := flush(); err != nil {
+			return responses, err
+		}
+		res, err := s.Produce(ctx, req)
+		if err != nil {
+			log.Printf("Error producing message: %v\n", err)
+			return responses, status.Errorf(codes.Internal, "Error producing message: %v", err)
+		}
+		responses = append(responses, res)
 	}
+
+	return responses, flush()
+}
+
+// belowHighest reports whether offset is below the commit log's highest offset, so a record missing there was removed
+// rather than not written yet. Commit logs that are not an OffsetBounds never report one.
+func (s *grpcServer) belowHighest(offset uint64) bool {
+	bounds, ok := s.config.GetCommitLog().(OffsetBounds)
+	if !ok {
+		return false
+	}
+	highest, err := bounds.HighestOffset()
+	return err == nil && offset < highest
 }
 
 // Consume handles the gRPC call for consuming (reading) a record from the commit log
-func (s *grpcServer) Consume(ctx context.Context, req *api.ConsumeRequest) (*api.ConsumeResponse, error) {
+func (s *grpcServer) Consume(ctx context.Context, req *api.ConsumeRequest) (res *api.ConsumeResponse, err error) {
+	ctx, span := s.tracer.Start(ctx, "log.Consume")
+	defer func() { endSpan(span, req.GetOffset(), err) }()
+
+	return s.consume(ctx, req)
+}
 
+// consume reads the record at the requested offset without starting a span of its own.
+// A ContextLog traces the read as part of the span in ctx.
+func (s *grpcServer) consume(ctx context.Context, req *api.ConsumeRequest) (*api.ConsumeResponse, error) {
 	// Read the record from the commit log at the specified offset in the request
-	record, err := s.CommitLog.Read(req.Offset)
+	var record *api.Record
+	var err error
+	if clog, ok := s.config.GetCommitLog().(ContextLog); ok {
+		record, err = clog.ReadContext(ctx, req.Offset)
+	} else {
+		record, err = s.config.GetCommitLog().Read(req.Offset)
+	}
 
 	// If there's an error reading the record, return the error immediately
 	if err != nil {
 		return nil, err
 	}
 
-	// If the read is successful, return a ConsumeResponse with the read record
-	return &api.ConsumeResponse{Record: record}, nil
+	// If the read is successful, return a ConsumeResponse with the read record and its headers
+	return &api.ConsumeResponse{Record: record, Headers: record.Headers}, nil
 }
 
-// ConsumeStream streams log entries starting from the requested offset
-func (s *grpcServer) ConsumeStream(req *api.ConsumeRequest, stream api.Log_ConsumeStreamServer) error {
+// ConsumeStream streams log entries starting from the offset in the client's first request.
+// Every request the client sends after that seeks the stream to its offset.
+func (s *grpcServer) ConsumeStream(stream api.Log_ConsumeStreamServer) error {
+	// One span covers the whole stream, every record sent gets a child span
+	ctx, span := s.tracer.Start(stream.Context(), "log.ConsumeStream")
+	defer span.End()
+
+	// The first request says where to start, how many records to send at most and whose records to send
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	maxRecords := req.MaxRecords
+	producerID := req.FilterProducerId
+	var sent uint64
+
+	// Each stream backs off on its own, a busy stream does not reset the wait of an idle one
+	pollBackoff := s.newPollBackoff()
+
+	// Receive seeks in the background, only the most recent one matters
+	seeks := make(chan uint64, 1)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			seek, err := stream.Recv()
+			if err != nil {
+				// The client closing its side only means there will be no more seeks
+				if err != io.EOF {
+					recvErr <- err
+				}
+				return
+			}
+
+			// Replace any seek that has not been picked up yet
+			select {
+			case <-seeks:
+			default:
+			}
+			seeks <- seek.Offset
+		}
+	}()
+
 	for {
 		select {
 		// Check if the stream's context is done/cancelled
-		case <-stream.Context().Done():
+		case <-ctx.Done():
 
-			// Stream is done, so return without error
-			return nil
+			// Stream is done, report why to the client
+			return ctx.Err()
+
+		// The client broke its side of the stream
+		case err := <-recvErr:
+			return err
+
+		// The client asked to continue from somewhere else
+		case offset := <-seeks:
+			req.Offset = offset
 
 		default:
 			// Attempt to consume a log entry at the current offset
-			res, err := s.Consume(stream.Context(), req)
+			// Polling an empty log is not traced, only records that are found
+			res, err := s.consume(ctx, req)
 			switch err.(type) {
 			case nil: // No error, proceed
+			case api.ErrOffsetOutOfRange:
+				// A record removed below the end of the log, e.g. by DeleteRange or Compact, is skipped
+				if s.belowHighest(req.Offset) {
+					req.Offset++
+					continue
+				}
+
+				// Caught up with the log, wait for new records or a seek
+				select {
+				case <-ctx.Done():
+				case offset := <-seeks:
+					req.Offset = offset
+				case <-time.After(pollBackoff.NextBackOff()):
+				}
+				continue
 			default: // Any other error, return it
 				return err
 			}
 
+			// Records from other producers are skipped, they do not count towards the maximum either
+			if producerID != "" && res.Record.GetProducerId() != producerID {
+				req.Offset++
+				continue
+			}
+
 			// Send the consumed log entry back to the client
-			if err = stream.Send(res); err != nil {
+			_, sendSpan := s.tracer.Start(ctx, "log.Consume")
+			err = stream.Send(res)
+			endSpan(sendSpan, req.Offset, err)
+			if err != nil {
 				return err // Error sending to stream, return the error
 			}
+			pollBackoff.Reset()
+
+			// End the stream cleanly once the client has all the records it asked for
+			sent++
+			if maxRecords > 0 && sent >= maxRecords {
+				return nil
+			}
 
 			req.Offset++ // Increment the offset for the next iteration/request
 		}
 	}
 }
+
+// BulkConsume sends every record from the request's from offset to its to offset inclusive,
+// then ends the stream. Unlike ConsumeStream it never waits for records that are not there yet.
+// Offsets the log no longer holds are skipped when the commit log is an OffsetBounds.
+func (s *grpcServer) BulkConsume(req *api.ConsumeRangeRequest, stream api.Log_BulkConsumeServer) error {
+	// One span covers the whole range, every record sent gets a child span
+	ctx, span := s.tracer.Start(stream.Context(), "log.BulkConsume")
+	defer span.End()
+
+	if req.FromOffset > req.ToOffset {
+		return status.Errorf(codes.InvalidArgument, "from offset %d is after to offset %d", req.FromOffset, req.ToOffset)
+	}
+
+	for offset := req.FromOffset; offset <= req.ToOffset; offset++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Records removed by compaction or DeleteRange are skipped, while a range reaching past the end of
+		// the log fails rather than coming up short
+		res, err := s.consume(ctx, &api.ConsumeRequest{Offset: offset})
+		if _, ok := err.(api.ErrOffsetOutOfRange); ok && s.belowHighest(offset) {
+			if offset == req.ToOffset {
+				break
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		_, sendSpan := s.tracer.Start(ctx, "log.Consume")
+		err = stream.Send(res)
+		endSpan(sendSpan, offset, err)
+		if err != nil {
+			return err
+		}
+
+		// Stop before the offset wraps around at the very end of the offset space
+		if offset == req.ToOffset {
+			break
+		}
+	}
+	return nil
+}
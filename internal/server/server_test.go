@@ -2,20 +2,32 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/backoff"
 	log "github.com/BryceDouglasJames/Cute-Logger/internal/logger"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	gomock "go.uber.org/mock/gomock"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 )
 
@@ -28,6 +40,7 @@ func TestServer(t *testing.T) {
 		//"testing gRPC produce stream with a mock server":               testProduceStreamWithMockServer,
 		//"raw gRPC server streaming produce and consume":                testRawGrpcServerStreamProduceAndConsume,
 		"raw gRPC server streaming stress test on produce and consume": testRawGrpcServerStreamProduceAndConsumeStressTest,
+		"consume stream with a mock server ends on cancel":             testConsumeStreamWithMockServer,
 	} {
 		t.Run(scenario, func(t *testing.T) {
 			t.Log("YOOOO")
@@ -40,13 +53,14 @@ func TestServer(t *testing.T) {
 }
 
 // setupTest prepares the environment for testing the gRPC Log service.
-func setupTest(t *testing.T, fn func(*Config)) (client api.LogClient, teardown func()) {
+// Any opts are applied to the server after its defaults.
+func setupTest(t *testing.T, fn func(*Config), opts ...Option) (client api.LogClient, teardown func()) {
 	t.Helper()
 
 	lis := bufconn.Listen(bufSize)
 	ctx := context.Background()
 
-	server, _, err := initializeServer(ctx, lis, fn)
+	server, _, err := initializeServer(ctx, lis, fn, opts...)
 	require.NoError(t, err)
 
 	cc, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(
@@ -61,15 +75,17 @@ func setupTest(t *testing.T, fn func(*Config)) (client api.LogClient, teardown f
 
 	teardown = func() {
 		cc.Close()
+
+		// Stop the server before closing its listener, otherwise Serve fails on the closed listener
+		require.NoError(t, server.Stop(ctx))
 		lis.Close()
-		server.GracefulStop()
 	}
 
 	return client, teardown
 }
 
 // initializeServer sets up and starts the gRPC server.
-func initializeServer(ctx context.Context, lis *bufconn.Listener, fn func(*Config)) (server *grpc.Server, cfg *Config, err error) {
+func initializeServer(ctx context.Context, lis *bufconn.Listener, fn func(*Config), opts ...Option) (server *grpcServer, cfg *Config, err error) {
 	// Create a temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "log_test")
 	if err != nil {
@@ -83,22 +99,22 @@ func initializeServer(ctx context.Context, lis *bufconn.Listener, fn func(*Confi
 		return nil, nil, err
 	}
 
-	server = grpc.NewServer()
-	grpcServer, err := NewGRPCServer(WithCommitLog(clog))
+	opts = append([]Option{WithCommitLog(clog), WithGracefulStopTimeout(5 * time.Second)}, opts...)
+	server, err = NewGRPCServer(opts...)
 	if err != nil {
 		os.RemoveAll(tempDir)
 		return nil, nil, err
 	}
 
-	api.RegisterLogServer(server, grpcServer)
+	gsrv := server.NewServer()
 
 	go func() {
-		if err := server.Serve(lis); err != nil {
+		if err := gsrv.Serve(lis); err != nil {
 			panic("Failed to serve: " + err.Error())
 		}
 	}()
 
-	return server, cfg, nil
+	return server, server.Config(), nil
 }
 
 func testRawGrpcServerProduceAndConsume(t *testing.T, _ api.LogClient, ctx context.Context) {
@@ -185,8 +201,8 @@ func testProduceStreamWithMockServer(t *testing.T, _ api.LogClient, _ context.Co
 	// Set up the expected sequence of interactions between the test and the mock stream.
 	// This includes receiving a request, getting the context, sending a response, and simulating the end of the stream.
 	gomock.InOrder(
-		mockStream.EXPECT().Recv().Return(req, nil),
 		mockStream.EXPECT().Context().Return(context.Background()),
+		mockStream.EXPECT().Recv().Return(req, nil),
 		mockStream.EXPECT().Send(res).Return(nil),
 		mockStream.EXPECT().Recv().Return(nil, io.EOF),
 	)
@@ -198,6 +214,55 @@ func testProduceStreamWithMockServer(t *testing.T, _ api.LogClient, _ context.Co
 	}
 }
 
+func testConsumeStreamWithMockServer(t *testing.T, _ api.LogClient, _ context.Context) {
+	tempDir, err := os.MkdirTemp("", "log_test_consume_stream")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	clog, err := log.NewLog(tempDir)
+	require.NoError(t, err)
+	defer clog.Close()
+	_, err = clog.Append(&api.Record{Value: []byte("first")})
+	require.NoError(t, err)
+
+	server, err := NewGRPCServer(WithCommitLog(clog))
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStream := NewMockLog_ConsumeStreamServer(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first request is received once. Later Recv calls come from the goroutine waiting for seeks,
+	// which has none to give and blocks until the stream is done.
+	mockStream.EXPECT().Context().Return(ctx)
+	first := mockStream.EXPECT().Recv().Return(&api.ConsumeRequest{Offset: 0}, nil)
+	mockStream.EXPECT().Recv().After(first).DoAndReturn(func() (*api.ConsumeRequest, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}).AnyTimes()
+
+	// The first record is sent, then the client goes away
+	mockStream.EXPECT().Send(gomock.Any()).DoAndReturn(func(res *api.ConsumeResponse) error {
+		require.Equal(t, uint64(0), res.Record.Offset)
+		require.Equal(t, []byte("first"), res.Record.Value)
+		cancel()
+		return nil
+	})
+
+	// The loop notices the cancellation and returns instead of waiting for more records
+	done := make(chan error, 1)
+	go func() { done <- server.ConsumeStream(mockStream) }()
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ConsumeStream did not return after its context was cancelled")
+	}
+}
+
 func testRawGrpcServerStreamProduceAndConsume(t *testing.T, client api.LogClient, ctx context.Context) {
 	// Define a slice of records to send through the ProduceStream
 	records := []*api.Record{
@@ -221,8 +286,9 @@ func testRawGrpcServerStreamProduceAndConsume(t *testing.T, client api.LogClient
 	require.NoError(t, err)
 
 	// Open a stream to consume records starting from the first offset
-	consumeStream, err := client.ConsumeStream(ctx, &api.ConsumeRequest{Offset: records[0].Offset})
+	consumeStream, err := client.ConsumeStream(ctx)
 	require.NoError(t, err)
+	require.NoError(t, consumeStream.Send(&api.ConsumeRequest{Offset: records[0].Offset}))
 
 	// Receive and verify records from the consume stream
 	for i, want := range records {
@@ -250,18 +316,29 @@ func testRawGrpcServerStreamProduceAndConsumeStressTest(t *testing.T, client api
 	// Start time for consumer setup
 	startTime := time.Now()
 
-	// Set up consumer
-	consumeStream, err := client.ConsumeStream(ctx, &api.ConsumeRequest{})
+	// Set up consumer, cancelled once every record has been consumed
+	consumeCtx, stopConsuming := context.WithCancel(ctx)
+	defer stopConsuming()
+	consumeStream, err := client.ConsumeStream(consumeCtx)
 	require.NoError(t, err)
+	require.NoError(t, consumeStream.Send(&api.ConsumeRequest{}))
 
-	consumeCount := 0
+	// Count consumed records from the consumer goroutine, read concurrently by the assertion below
+	var consumeCount int64
+	var consumerWg sync.WaitGroup
+	consumerWg.Add(1)
 	go func() {
+		defer consumerWg.Done()
 		for {
 			_, err := consumeStream.Recv()
-			if err == io.EOF {
-				break
+			if err != nil {
+				// Cancelling the stream is how the test stops the consumer
+				if err != io.EOF && status.Code(err) != codes.Canceled {
+					t.Errorf("Unexpected error consuming records: %v", err)
+				}
+				return
 			}
-			require.NoError(t, err)
+			atomic.AddInt64(&consumeCount, 1)
 		}
 	}()
 
@@ -295,9 +372,773 @@ func testRawGrpcServerStreamProduceAndConsumeStressTest(t *testing.T, client api
 	fmt.Printf("Produced %d records with %d workers in %v\n", recordCount, workers, produceDuration)
 
 	// Ensure all records were consumed
-	require.Equal(t, recordCount, consumeCount, "Expected to consume the same number of records as produced")
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&consumeCount) == int64(recordCount)
+	}, 10*time.Second, 10*time.Millisecond, "Expected to consume the same number of records as produced")
+
+	// Stop the consumer and make sure it is gone before the server is torn down
+	stopConsuming()
+	consumerWg.Wait()
+	require.Equal(t, int64(recordCount), atomic.LoadInt64(&consumeCount), "Consumed more records than were produced")
 
 	// Measure total time taken for the test
 	totalDuration := time.Since(startTime)
 	fmt.Printf("Stress test completed: produced and consumed %d records in %v\n", recordCount, totalDuration)
 }
+
+func TestGrpcServerStopTimeout(t *testing.T) {
+	lis := bufconn.Listen(bufSize)
+	ctx := context.Background()
+
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_stop")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	clog, err := log.NewLog(tempDir)
+	require.NoError(t, err)
+
+	// Stopping before a gRPC server is registered is an error
+	server, err := NewGRPCServer(WithCommitLog(clog), WithGracefulStopTimeout(100*time.Millisecond))
+	require.NoError(t, err)
+	require.Error(t, server.Stop(ctx))
+
+	gsrv := grpc.NewServer()
+	server.Register(gsrv)
+	go gsrv.Serve(lis)
+
+	cc, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(
+		func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer cc.Close()
+
+	// Open a stream and never close it so the graceful drain cannot finish
+	client := api.NewLogClient(cc)
+	stream, err := client.ProduceStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&api.ProduceRequest{Record: &api.Record{Value: []byte("hold open")}}))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	// Stop should give up on draining once the timeout fires
+	start := time.Now()
+	err = server.Stop(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second, "Stop should not wait on the stream past its timeout")
+}
+
+func TestConnectionIdleTimeout(t *testing.T) {
+	lis := bufconn.Listen(bufSize)
+	ctx := context.Background()
+
+	idle := 100 * time.Millisecond
+	server, _, err := initializeServer(ctx, lis, nil, WithConnectionIdleTimeout(idle))
+	require.NoError(t, err)
+	defer lis.Close()
+	defer server.Stop(ctx)
+
+	// Non-positive timeouts are rejected
+	_, err = NewGRPCServer(WithConnectionIdleTimeout(0))
+	require.Error(t, err)
+
+	cc, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(
+		func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer cc.Close()
+
+	client := api.NewLogClient(cc)
+	_, err = client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("before idle")}})
+	require.NoError(t, err)
+	require.Equal(t, connectivity.Ready, cc.GetState())
+
+	// With no RPCs in flight the server closes the connection once the timeout passes
+	time.Sleep(idle + 100*time.Millisecond)
+	require.Equal(t, connectivity.Idle, cc.GetState(), "server should have sent a GOAWAY to the idle connection")
+
+	// A client that comes back simply reconnects
+	_, err = client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("after idle")}})
+	require.NoError(t, err)
+}
+
+func TestServerConfig(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_config")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	clog, err := log.NewLog(tempDir)
+	require.NoError(t, err)
+	defer clog.Close()
+
+	// A nil commit log is rejected up front
+	_, err = NewGRPCServer(WithCommitLog(nil))
+	require.Error(t, err)
+
+	server, err := NewGRPCServer(WithCommitLog(clog))
+	require.NoError(t, err)
+	require.Same(t, clog, server.Config().GetCommitLog())
+
+	// Setting a nil commit log leaves the current one in place
+	require.Error(t, server.Config().SetCommitLog(nil))
+	require.Same(t, clog, server.Config().GetCommitLog())
+
+	// A config built by hand serves the commit log it was given
+	require.Same(t, clog, (&Config{CommitLog: clog}).GetCommitLog())
+
+	// The commit log can be swapped while records are being produced
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				_, err := server.Produce(context.Background(), &api.ProduceRequest{Record: &api.Record{Value: []byte("swap")}})
+				require.NoError(t, err)
+			}
+		}()
+	}
+	for i := 0; i < 25; i++ {
+		require.NoError(t, server.Config().SetCommitLog(clog))
+	}
+	wg.Wait()
+}
+
+func TestUnixSocket(t *testing.T) {
+	ctx := context.Background()
+
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_unix")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	clog, err := log.NewLog(tempDir)
+	require.NoError(t, err)
+	defer clog.Close()
+
+	// A stale socket from an earlier run is replaced
+	socketPath := filepath.Join(tempDir, "log.sock")
+	stale, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	server, err := NewGRPCServer(WithCommitLog(clog), WithUnixSocket(socketPath), WithSocketPermissions(0600))
+	require.NoError(t, err)
+	go server.Serve()
+	defer server.Stop(ctx)
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// A socket a running server listens on is not taken over
+	_, err = NewGRPCServer(WithCommitLog(clog), WithUnixSocket(socketPath))
+	require.Error(t, err)
+
+	cc, err := grpc.DialContext(ctx, "unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+	client := api.NewLogClient(cc)
+
+	for i := 0; i < 3; i++ {
+		value := []byte(fmt.Sprintf("over a socket %d", i))
+		produced, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: value}})
+		require.NoError(t, err)
+		consumed, err := client.Consume(ctx, &api.ConsumeRequest{Offset: produced.Offset})
+		require.NoError(t, err)
+		require.Equal(t, value, consumed.Record.Value)
+	}
+}
+
+func TestPeerAuthority(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only supported on Linux")
+	}
+	ctx := context.Background()
+
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "log_test_peercred")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	clog, err := log.NewLog(tempDir)
+	require.NoError(t, err)
+	defer clog.Close()
+
+	// Only this process's user is let in, and its credentials are remembered
+	var seenPID atomic.Int32
+	authorizer := PeerAuthorizerFunc(func(uid, gid uint32, pid int32) error {
+		seenPID.Store(pid)
+		if uid != uint32(os.Getuid()) {
+			return fmt.Errorf("uid %d is not allowed", uid)
+		}
+		return nil
+	})
+
+	socketPath := filepath.Join(tempDir, "log.sock")
+	server, err := NewGRPCServer(WithCommitLog(clog), WithUnixSocket(socketPath), WithPeerAuthority(authorizer))
+	require.NoError(t, err)
+	go server.Serve()
+	defer server.Stop(ctx)
+
+	cc, err := grpc.DialContext(ctx, "unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+	client := api.NewLogClient(cc)
+
+	_, err = client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("local")}})
+	require.NoError(t, err)
+	require.Equal(t, int32(os.Getpid()), seenPID.Load())
+
+	// A server that refuses everyone denies unary calls and streams alike
+	deniedPath := filepath.Join(tempDir, "denied.sock")
+	denied, err := NewGRPCServer(WithCommitLog(clog), WithUnixSocket(deniedPath), WithPeerAuthority(PeerAuthorizerFunc(func(uint32, uint32, int32) error {
+		return errors.New("nobody is allowed")
+	})))
+	require.NoError(t, err)
+	go denied.Serve()
+	defer denied.Stop(ctx)
+
+	deniedConn, err := grpc.DialContext(ctx, "unix://"+deniedPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer deniedConn.Close()
+	deniedClient := api.NewLogClient(deniedConn)
+
+	_, err = deniedClient.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("local")}})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	stream, err := deniedClient.ConsumeStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&api.ConsumeRequest{Offset: 0}))
+	_, err = stream.Recv()
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = NewGRPCServer(WithPeerAuthority(nil))
+	require.Error(t, err)
+}
+
+func TestMaxRecordBytes(t *testing.T) {
+	client, teardown := setupTest(t, nil, WithMaxRecordBytes(16))
+	defer teardown()
+	ctx := context.Background()
+
+	// A value right at the limit is accepted, one byte more is not
+	_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: make([]byte, 16)}})
+	require.NoError(t, err)
+	_, err = client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: make([]byte, 17)}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Streams enforce the same limit
+	stream, err := client.ProduceStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&api.ProduceRequest{Record: &api.Record{Value: make([]byte, 16)}}))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&api.ProduceRequest{Record: &api.Record{Value: make([]byte, 17)}}))
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// A zero limit is not valid
+	_, err = NewGRPCServer(WithMaxRecordBytes(0))
+	require.Error(t, err)
+}
+
+// countingBackOff waits a constant millisecond and counts how it is used
+type countingBackOff struct {
+	polls  int32
+	resets int32
+}
+
+func (b *countingBackOff) NextBackOff() time.Duration {
+	atomic.AddInt32(&b.polls, 1)
+	return time.Millisecond
+}
+
+func (b *countingBackOff) Reset() {
+	atomic.AddInt32(&b.resets, 1)
+}
+
+func TestConsumeStreamPollBackoff(t *testing.T) {
+	created := make(chan *countingBackOff, 2)
+	client, teardown := setupTest(t, nil, WithPollBackoff(func() backoff.BackOff {
+		b := &countingBackOff{}
+		created <- b
+		return b
+	}))
+	defer teardown()
+	ctx := context.Background()
+
+	stream, err := client.ConsumeStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&api.ConsumeRequest{Offset: 0}))
+	b := <-created
+
+	// A stream that never gets a record has a backoff of its own, which is never reset
+	idle, err := client.ConsumeStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, idle.Send(&api.ConsumeRequest{Offset: 1000}))
+	other := <-created
+
+	// The log is empty, so the stream backs off between polls
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&b.polls) > 1 }, time.Second, time.Millisecond)
+	require.Zero(t, atomic.LoadInt32(&b.resets))
+
+	// Sending a record starts the backoff over
+	_, err = client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("wake up")}})
+	require.NoError(t, err)
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, []byte("wake up"), res.Record.Value)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&b.resets) == 1 }, time.Second, time.Millisecond)
+	require.Zero(t, atomic.LoadInt32(&other.resets))
+
+	_, err = NewGRPCServer(WithPollBackoff(nil))
+	require.Error(t, err)
+}
+
+func TestConsumeStreamMaxRecords(t *testing.T) {
+	client, teardown := setupTest(t, nil)
+	defer teardown()
+	ctx := context.Background()
+
+	for i := 0; i < 200; i++ {
+		_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte(fmt.Sprintf("record %d", i))}})
+		require.NoError(t, err)
+	}
+
+	stream, err := client.ConsumeStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&api.ConsumeRequest{Offset: 0, MaxRecords: 50}))
+
+	// Exactly the requested number of records arrives, then the stream ends
+	for i := uint64(0); i < 50; i++ {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, i, res.Record.Offset)
+	}
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestBulkConsume(t *testing.T) {
+	client, teardown := setupTest(t, nil)
+	defer teardown()
+	ctx := context.Background()
+
+	for i := 0; i < 200; i++ {
+		_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte(fmt.Sprintf("record %d", i))}})
+		require.NoError(t, err)
+	}
+
+	// Exactly the records in the range arrive, in order, then the stream ends
+	stream, err := client.BulkConsume(ctx, &api.ConsumeRangeRequest{FromOffset: 100, ToOffset: 150})
+	require.NoError(t, err)
+	for i := uint64(100); i <= 150; i++ {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, i, res.Record.Offset)
+		require.Equal(t, []byte(fmt.Sprintf("record %d", i)), res.Record.Value)
+	}
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+
+	// A range of one record
+	stream, err = client.BulkConsume(ctx, &api.ConsumeRangeRequest{FromOffset: 7, ToOffset: 7})
+	require.NoError(t, err)
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), res.Record.Offset)
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+
+	// A backwards range is rejected
+	stream, err = client.BulkConsume(ctx, &api.ConsumeRangeRequest{FromOffset: 10, ToOffset: 5})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// A range past the end of the log fails once it runs out of records
+	stream, err = client.BulkConsume(ctx, &api.ConsumeRangeRequest{FromOffset: 198, ToOffset: 205})
+	require.NoError(t, err)
+	for i := uint64(198); i < 200; i++ {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, i, res.Record.Offset)
+	}
+	_, err = stream.Recv()
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+}
+
+func TestBulkConsumeSkipsRemovedRecords(t *testing.T) {
+	// Room for ten records per segment
+	clog, err := log.NewLog(t.TempDir(), log.WithMaxIndexBytes(200))
+	require.NoError(t, err)
+	defer clog.Close()
+	client, teardown := setupTest(t, nil, WithCommitLog(clog))
+	defer teardown()
+	ctx := context.Background()
+
+	for i := 0; i < 30; i++ {
+		_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte(fmt.Sprintf("record %d", i))}})
+		require.NoError(t, err)
+	}
+	require.NoError(t, clog.DeleteRange(5, 14))
+
+	// The records around the removed ones arrive, and the range still ends at the end of the log
+	stream, err := client.BulkConsume(ctx, &api.ConsumeRangeRequest{FromOffset: 0, ToOffset: 29})
+	require.NoError(t, err)
+	var got []uint64
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, res.Record.Offset)
+	}
+	want := []uint64{0, 1, 2, 3, 4}
+	for i := uint64(15); i < 30; i++ {
+		want = append(want, i)
+	}
+	require.Equal(t, want, got)
+}
+
+func TestConsumeStreamSkipsRemovedRecords(t *testing.T) {
+	// Room for ten records per segment
+	clog, err := log.NewLog(t.TempDir(), log.WithMaxIndexBytes(200))
+	require.NoError(t, err)
+	defer clog.Close()
+	client, teardown := setupTest(t, nil, WithCommitLog(clog))
+	defer teardown()
+	ctx := context.Background()
+
+	for i := 0; i < 30; i++ {
+		_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte(fmt.Sprintf("record %d", i))}})
+		require.NoError(t, err)
+	}
+	require.NoError(t, clog.DeleteRange(5, 14))
+
+	// The stream carries on past the removed records instead of waiting for them
+	stream, err := client.ConsumeStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&api.ConsumeRequest{Offset: 0, MaxRecords: 20}))
+	var got []uint64
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, res.Record.Offset)
+	}
+	want := []uint64{0, 1, 2, 3, 4}
+	for i := uint64(15); i < 30; i++ {
+		want = append(want, i)
+	}
+	require.Equal(t, want, got)
+}
+
+func TestConsumeStreamFilterProducerID(t *testing.T) {
+	client, teardown := setupTest(t, nil)
+	defer teardown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Two producers take turns appending
+	for i := 0; i < 6; i++ {
+		producer := []string{"node-a", "node-b"}[i%2]
+		_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte(producer), ProducerId: producer}})
+		require.NoError(t, err)
+	}
+
+	stream, err := client.ConsumeStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&api.ConsumeRequest{Offset: 0, MaxRecords: 3, FilterProducerId: "node-b"}))
+
+	// Only node-b's records arrive, and only they count towards the maximum
+	for _, want := range []uint64{1, 3, 5} {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, want, res.Record.Offset)
+		require.Equal(t, "node-b", res.Record.ProducerId)
+	}
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestConsumeStreamDeadline(t *testing.T) {
+	client, teardown := setupTest(t, nil)
+	defer teardown()
+
+	// The log is empty, so the stream would wait for records forever without a deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	stream, err := client.ConsumeStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&api.ConsumeRequest{Offset: 0}))
+
+	// The stream should end once the deadline passes
+	_, err = stream.Recv()
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	require.Less(t, time.Since(start), 200*time.Millisecond, "stream should exit promptly after its deadline")
+}
+
+func TestConsumeStreamSeek(t *testing.T) {
+	client, teardown := setupTest(t, nil)
+	defer teardown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Fill the log with a few records
+	for i := 0; i < 10; i++ {
+		_, err := client.Produce(ctx, &api.ProduceRequest{
+			Record: &api.Record{Value: []byte(fmt.Sprintf("record %d", i))},
+		})
+		require.NoError(t, err)
+	}
+
+	stream, err := client.ConsumeStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&api.ConsumeRequest{Offset: 0}))
+
+	// Consume the first half of the log
+	for want := uint64(0); want < 5; want++ {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, want, res.Record.Offset)
+	}
+
+	// Seek back mid-stream. Records the server sent before it saw the seek may still arrive,
+	// so skip ahead until the offsets jump back.
+	require.NoError(t, stream.Send(&api.ConsumeRequest{Offset: 2}))
+	last := uint64(4)
+	for {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		if res.Record.Offset <= last {
+			require.Equal(t, uint64(2), res.Record.Offset)
+			break
+		}
+		last = res.Record.Offset
+	}
+
+	// The stream carries on from the new position
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), res.Record.Offset)
+	require.Equal(t, []byte("record 3"), res.Record.Value)
+}
+
+func TestServerTracing(t *testing.T) {
+	// Record every span in memory
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	client, teardown := setupTest(t, nil, WithTracingProvider(tp))
+	defer teardown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Unary calls get one span each, with the offset on success
+	produced, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("traced")}})
+	require.NoError(t, err)
+	_, err = client.Consume(ctx, &api.ConsumeRequest{Offset: produced.Offset})
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	require.Equal(t, "log.Produce", spans[0].Name)
+	require.Equal(t, "log.Consume", spans[1].Name)
+	for _, span := range spans {
+		require.Contains(t, span.Attributes, attribute.Int64(offsetAttribute, int64(produced.Offset)))
+	}
+
+	// A failed call records the error instead of an offset
+	exporter.Reset()
+	_, err = client.Consume(ctx, &api.ConsumeRequest{Offset: 100})
+	require.Error(t, err)
+	spans = exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, otelcodes.Error, spans[0].Status.Code)
+
+	// A stream gets a root span with a child span per message
+	exporter.Reset()
+	stream, err := client.ProduceStream(ctx)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, stream.Send(&api.ProduceRequest{Record: &api.Record{Value: []byte("streamed")}}))
+		_, err := stream.Recv()
+		require.NoError(t, err)
+	}
+	require.NoError(t, stream.CloseSend())
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+
+	spans = exporter.GetSpans()
+	require.Len(t, spans, 4)
+	root := spans[len(spans)-1]
+	require.Equal(t, "log.ProduceStream", root.Name)
+	for _, child := range spans[:3] {
+		require.Equal(t, "log.Produce", child.Name)
+		require.Equal(t, root.SpanContext.SpanID(), child.Parent.SpanID())
+	}
+}
+
+func TestProduceConsumeHeaders(t *testing.T) {
+	client, teardown := setupTest(t, nil)
+	defer teardown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Headers can come with the request as well as with the record itself
+	produced, err := client.Produce(ctx, &api.ProduceRequest{
+		Record: &api.Record{
+			Value:   []byte("with headers"),
+			Headers: map[string][]byte{"content-type": []byte("text/plain")},
+		},
+		Headers: map[string][]byte{"trace-id": []byte("abc123")},
+	})
+	require.NoError(t, err)
+
+	consumed, err := client.Consume(ctx, &api.ConsumeRequest{Offset: produced.Offset})
+	require.NoError(t, err)
+
+	want := map[string][]byte{
+		"content-type": []byte("text/plain"),
+		"trace-id":     []byte("abc123"),
+	}
+	require.Equal(t, want, consumed.Headers)
+	require.Equal(t, want, consumed.Record.Headers)
+	require.Equal(t, []byte("with headers"), consumed.Record.Value)
+}
+
+func TestProduceWithFailingCommitLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The commit log fails every append
+	clog := NewMockCommitLog(ctrl)
+	clog.EXPECT().Append(gomock.Any()).Return(uint64(0), errors.New("disk full"))
+
+	server, err := NewGRPCServer(WithCommitLog(clog))
+	require.NoError(t, err)
+
+	// The failure surfaces to the client as an internal error
+	_, err = server.Produce(context.Background(), &api.ProduceRequest{Record: &api.Record{Value: []byte("lost")}})
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.Contains(t, status.Convert(err).Message(), "disk full")
+}
+
+func TestProduceRequiredOffset(t *testing.T) {
+	client, teardown := setupTest(t, nil)
+	defer teardown()
+	ctx := context.Background()
+
+	required := func(offset uint64) *uint64 { return &offset }
+
+	// The first record lands at offset 0 as required
+	res, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("first")}, RequiredOffset: required(0)})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res.Offset)
+
+	// A second producer expecting the same offset loses the race
+	_, err = client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("stale")}, RequiredOffset: required(0)})
+	require.Equal(t, codes.Aborted, status.Code(err))
+	require.Equal(t, "offset conflict: expected 0, got 1", status.Convert(err).Message())
+
+	// Retrying against the current offset succeeds, and requests without a required offset are unaffected
+	res, err = client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("retried")}, RequiredOffset: required(1)})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res.Offset)
+	res, err = client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("any")}})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res.Offset)
+
+	// Commit logs that cannot append at an offset reject the request
+	ctrl := gomock.NewController(t)
+	server, err := NewGRPCServer(WithCommitLog(NewMockCommitLog(ctrl)))
+	require.NoError(t, err)
+	_, err = server.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("cas")}, RequiredOffset: required(0)})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestServerMaxQueueDepth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Appends block until released, keeping the calls making them in progress
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	clog := NewMockCommitLog(ctrl)
+	clog.EXPECT().Append(gomock.Any()).DoAndReturn(func(*api.Record) (uint64, error) {
+		entered <- struct{}{}
+		<-release
+		return 0, nil
+	}).Times(2)
+
+	client, teardown := setupTest(t, nil, WithCommitLog(clog), WithMaxQueueDepth(1))
+	defer teardown()
+	ctx := context.Background()
+
+	produced := make(chan error, 1)
+	go func() {
+		_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("slow")}})
+		produced <- err
+	}()
+	<-entered
+
+	// A second call is turned away at once rather than queued
+	_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("rejected")}})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Once the first call is done, the slot is free again
+	release <- struct{}{}
+	require.NoError(t, <-produced)
+	go func() { <-entered; release <- struct{}{} }()
+	_, err = client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("accepted")}})
+	require.NoError(t, err)
+}
+
+func TestServerMaxConcurrentStreams(t *testing.T) {
+	client, teardown := setupTest(t, nil, WithMaxConcurrentStreams(1))
+	defer teardown()
+	ctx := context.Background()
+
+	// The first stream is open once it has answered a message
+	first, err := client.ProduceStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, first.Send(&api.ProduceRequest{Record: &api.Record{Value: []byte("first")}}))
+	_, err = first.Recv()
+	require.NoError(t, err)
+
+	// A second stream is rejected while it is
+	second, err := client.ProduceStream(ctx)
+	require.NoError(t, err)
+	_, err = second.Recv()
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Closing the first stream makes room for another
+	require.NoError(t, first.CloseSend())
+	_, err = first.Recv()
+	require.Equal(t, io.EOF, err)
+	third, err := client.ProduceStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, third.Send(&api.ProduceRequest{Record: &api.Record{Value: []byte("third")}}))
+	_, err = third.Recv()
+	require.NoError(t, err)
+	require.NoError(t, third.CloseSend())
+}
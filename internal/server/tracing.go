@@ -0,0 +1,45 @@
+package server
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name the server's tracer is registered under
+const tracerName = "github.com/BryceDouglasJames/Cute-Logger/internal/server"
+
+// Attribute holding the offset a span produced or consumed
+const offsetAttribute = "log.offset"
+
+// Traces every RPC with spans from tp instead of the global otel.GetTracerProvider().
+// Unary calls get a span each, streams get a root span with a child span per message.
+func WithTracingProvider(tp trace.TracerProvider) Option {
+	return func(s *grpcServer) error {
+		if tp == nil {
+			return errors.New("TracerProvider cannot be nil")
+		}
+		s.tracer = tp.Tracer(tracerName)
+		return nil
+	}
+}
+
+// defaultTracer returns the tracer used when no provider was configured
+func defaultTracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
+
+// endSpan records the outcome of an operation on span and ends it.
+// The offset is only recorded when the operation succeeded.
+func endSpan(span trace.Span, offset uint64, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	} else {
+		span.SetAttributes(attribute.Int64(offsetAttribute, int64(offset)))
+	}
+	span.End()
+}
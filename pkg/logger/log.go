@@ -0,0 +1,92 @@
+// Package logger is the public entry point for using Cute Logger as a library.
+// Log wraps the log the server is built on, exposing the operations an application needs.
+package logger
+
+import (
+	"io"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"github.com/BryceDouglasJames/Cute-Logger/internal/logger"
+)
+
+// Log is a segmented commit log stored in a directory on disk
+type Log struct {
+	log *logger.Log
+}
+
+// Option configures a Log when it is opened
+type Option = logger.LogOption
+
+// WithMaxStoreBytes sets how large a segment's store grows before the log rolls over to a new segment
+func WithMaxStoreBytes(maxBytes uint64) Option {
+	return logger.WithMaxStoreBytes(maxBytes)
+}
+
+// WithMaxIndexBytes sets how large a segment's index grows before the log rolls over to a new segment
+func WithMaxIndexBytes(maxBytes uint64) Option {
+	return logger.WithMaxIndexBytes(maxBytes)
+}
+
+// NewLog opens the log in dir, picking up any segments already there
+func NewLog(dir string, opts ...Option) (*Log, error) {
+	l, err := logger.NewLog(dir, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{log: l}, nil
+}
+
+// Append adds record to the end of the log and returns its offset
+func (l *Log) Append(record *api.Record) (uint64, error) {
+	return l.log.Append(record)
+}
+
+// Read returns the record at offset
+func (l *Log) Read(offset uint64) (*api.Record, error) {
+	return l.log.Read(offset)
+}
+
+// ForEach calls fn with every record in offset order, stopping at the first error fn returns.
+// fn must not write to the log.
+func (l *Log) ForEach(fn func(offset uint64, record *api.Record) error) error {
+	return l.log.ForEach(fn)
+}
+
+// Reader returns a reader over the raw contents of every segment's store, oldest first
+func (l *Log) Reader() io.Reader {
+	return l.log.Reader()
+}
+
+// LowestOffset returns the offset of the oldest record in the log
+func (l *Log) LowestOffset() (uint64, error) {
+	return l.log.LowestOffset()
+}
+
+// HighestOffset returns the offset of the newest record in the log, or 0 when it is empty
+func (l *Log) HighestOffset() (uint64, error) {
+	return l.log.HighestOffset()
+}
+
+// Truncate removes every segment whose records all have offsets up to and including lowest
+func (l *Log) Truncate(lowest uint64) error {
+	return l.log.Truncate(lowest)
+}
+
+// A Log is an io.Closer, so it can be closed alongside whatever embeds it
+var _ io.Closer = (*Log)(nil)
+
+// Close closes every segment. Later calls on the log return an error.
+// Closing again is harmless, so Close can be registered with http.Server.RegisterOnShutdown and still be deferred.
+func (l *Log) Close() error {
+	return l.log.Close()
+}
+
+// Delete closes the log and removes its directory
+func (l *Log) Delete() error {
+	return l.log.Delete()
+}
+
+// Reset deletes everything in the log and starts it over empty
+func (l *Log) Reset() error {
+	return l.log.Reset()
+}
@@ -0,0 +1,160 @@
+package logger_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	api "github.com/BryceDouglasJames/Cute-Logger/api"
+	"github.com/BryceDouglasJames/Cute-Logger/pkg/logger"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestLog(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pkg_log_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	log, err := logger.NewLog(dir, logger.WithMaxStoreBytes(64))
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		off, err := log.Append(&api.Record{Value: []byte("hello world")})
+		require.NoError(t, err)
+		require.Equal(t, uint64(i), off)
+	}
+
+	record, err := log.Read(2)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello world"), record.Value)
+
+	var offsets []uint64
+	require.NoError(t, log.ForEach(func(offset uint64, record *api.Record) error {
+		offsets = append(offsets, offset)
+		return nil
+	}))
+	require.Equal(t, []uint64{0, 1, 2, 3, 4}, offsets)
+
+	raw, err := io.ReadAll(log.Reader())
+	require.NoError(t, err)
+	require.NotEmpty(t, raw)
+
+	// Truncating drops the oldest segments
+	require.NoError(t, log.Truncate(2))
+	lowest, err := log.LowestOffset()
+	require.NoError(t, err)
+	require.Greater(t, lowest, uint64(0))
+	highest, err := log.HighestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), highest)
+
+	// Reset starts over empty
+	require.NoError(t, log.Reset())
+	off, err := log.Append(&api.Record{Value: []byte("again")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), off)
+
+	// The log is reopened from disk after Close
+	require.NoError(t, log.Close())
+	log, err = logger.NewLog(dir)
+	require.NoError(t, err)
+	record, err = log.Read(0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("again"), record.Value)
+
+	require.NoError(t, log.Delete())
+	_, err = os.Stat(dir)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestLogClosesOnHTTPShutdown(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	dir, err := os.MkdirTemp("", "pkg_log_http_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	log, err := logger.NewLog(dir)
+	require.NoError(t, err)
+	// Deferred as well as registered, the second Close is a no-op
+	defer log.Close()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		off, err := log.Append(&api.Record{Value: []byte(r.URL.Path)})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, off)
+	}))
+	closed := make(chan error, 1)
+	srv.Config.RegisterOnShutdown(func() { closed <- log.Close() })
+	srv.Start()
+
+	client := srv.Client()
+	res, err := client.Get(srv.URL + "/hello")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "0", string(body))
+
+	// httptest.Server.Close only tears down the listener and connections, the shutdown
+	// callbacks run when the http.Server it wraps is shut down
+	require.NoError(t, srv.Config.Shutdown(context.Background()))
+	srv.Close()
+	client.CloseIdleConnections()
+
+	// Shutdown starts the callbacks without waiting for them
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("log was not closed on shutdown")
+	}
+
+	// The log refuses appends once closed, and closing it again is harmless
+	_, err = log.Append(&api.Record{Value: []byte("too late")})
+	require.Error(t, err)
+	require.NoError(t, log.Close())
+}
+
+// Close the log along with the HTTP server that writes to it
+func ExampleLog_Close() {
+	dir, err := os.MkdirTemp("", "pkg_log_example")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	log, err := logger.NewLog(dir)
+	if err != nil {
+		panic(err)
+	}
+	defer log.Close()
+
+	srv := &http.Server{Addr: "127.0.0.1:0"}
+	closed := make(chan struct{})
+	srv.RegisterOnShutdown(func() {
+		if err := log.Close(); err != nil {
+			fmt.Println("closing log:", err)
+		}
+		close(closed)
+	})
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		panic(err)
+	}
+	<-closed
+
+	_, err = log.Append(&api.Record{Value: []byte("after shutdown")})
+	fmt.Println(err)
+	// Output: log is closed
+}